@@ -36,3 +36,38 @@ func NewMapping(path, expr string) (*mapping.Executor, error) {
 	}
 	return e, nil
 }
+
+// LintMapping attempts to parse a Bloblang mapping from a string and returns
+// any lints found within it, such as variables that are declared and never
+// used. If the mapping was read from a file the path should be provided in
+// order to resolve relative imports.
+//
+// When a parsing error occurs the returned error may be a *parser.Error type,
+// which allows you to gain positional and structured error messages.
+func LintMapping(path, expr string) ([]parser.Lint, error) {
+	lints, err := parser.LintMapping(path, expr, parser.Context{
+		Functions: query.AllFunctions,
+		Methods:   query.AllMethods,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return lints, nil
+}
+
+// FormatMapping attempts to parse a Bloblang mapping from a string and returns
+// it formatted with consistent indentation and spacing. If the mapping was read
+// from a file the path should be provided in order to resolve relative imports.
+//
+// When a parsing error occurs the returned error may be a *parser.Error type,
+// which allows you to gain positional and structured error messages.
+func FormatMapping(path, expr string) (string, error) {
+	formatted, err := parser.FormatMapping(path, expr, parser.Context{
+		Functions: query.AllFunctions,
+		Methods:   query.AllMethods,
+	})
+	if err != nil {
+		return "", err
+	}
+	return formatted, nil
+}
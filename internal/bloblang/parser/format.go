@@ -0,0 +1,369 @@
+package parser
+
+import (
+	"errors"
+	"strings"
+	"unicode"
+)
+
+type fmtTokenKind int
+
+const (
+	fmtCode fmtTokenKind = iota
+	fmtString
+	fmtComment
+	fmtNewline
+)
+
+type fmtToken struct {
+	kind        fmtTokenKind
+	value       string
+	spaceBefore bool
+
+	// Whether the token is a binary operator, and is therefore surrounded by a
+	// single space.
+	binaryOp bool
+}
+
+// Operators that are always binary.
+var fmtSpacedOps = map[string]bool{
+	"=": true, "==": true, "!=": true, "<=": true, ">=": true,
+	"=>": true, "&&": true, "||": true, "->": true,
+	"+": true, "*": true, "/": true, "%": true,
+	"<": true, ">": true, "|": true,
+}
+
+// Keywords that are followed by an expression rather than acting as an operand.
+var fmtKeywords = map[string]bool{
+	"if": true, "else": true, "match": true,
+}
+
+// markBinaryOps marks the operators within a list of tokens that are binary,
+// where a minus is only binary when it follows an operand.
+func markBinaryOps(tokens []fmtToken) {
+	var prev *fmtToken
+	for i := range tokens {
+		t := &tokens[i]
+		if t.kind == fmtNewline || t.kind == fmtComment {
+			continue
+		}
+		if t.kind == fmtCode {
+			if fmtSpacedOps[t.value] {
+				t.binaryOp = true
+			} else if t.value == "-" && prev != nil {
+				switch {
+				case prev.kind == fmtString:
+					t.binaryOp = true
+				case prev.kind == fmtCode && strings.Contains(")]}", prev.value):
+					t.binaryOp = true
+				case prev.kind == fmtCode && isFmtWordChar([]rune(prev.value)[0]) && !fmtKeywords[prev.value]:
+					t.binaryOp = true
+				}
+			}
+		}
+		prev = t
+	}
+}
+
+// Operators that, when found at the end of a line, indicate that the next line
+// is a continuation of the same expression.
+var fmtContinuationOps = map[string]bool{
+	"=": true, "==": true, "!=": true, "<=": true, ">=": true,
+	"=>": true, "&&": true, "||": true, "->": true,
+	"+": true, "-": true, "*": true, "/": true, "%": true,
+	"<": true, ">": true, "|": true, ".": true,
+}
+
+func isFmtWordChar(r rune) bool {
+	return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// tokenizeMapping breaks a mapping down into a flat list of tokens, where
+// whitespace is recorded against the token that follows it.
+func tokenizeMapping(expr []rune) []fmtToken {
+	var tokens []fmtToken
+	space := false
+
+	push := func(kind fmtTokenKind, value string) {
+		tokens = append(tokens, fmtToken{kind: kind, value: value, spaceBefore: space})
+		space = false
+	}
+
+	for i := 0; i < len(expr); {
+		r := expr[i]
+		switch {
+		case r == ' ' || r == '\t' || r == '\r':
+			space = true
+			i++
+		case r == '\n':
+			push(fmtNewline, "\n")
+			i++
+		case r == '#':
+			j := i
+			for j < len(expr) && expr[j] != '\n' {
+				j++
+			}
+			push(fmtComment, string(expr[i:j]))
+			i = j
+		case r == '"' && i+2 < len(expr) && expr[i+1] == '"' && expr[i+2] == '"':
+			j := i + 3
+			for j < len(expr) && !(j+2 < len(expr) && expr[j] == '"' && expr[j+1] == '"' && expr[j+2] == '"') {
+				j++
+			}
+			if j += 3; j > len(expr) {
+				j = len(expr)
+			}
+			push(fmtString, string(expr[i:j]))
+			i = j
+		case r == '"':
+			j := i + 1
+			for j < len(expr) && expr[j] != '"' && expr[j] != '\n' {
+				if expr[j] == '\\' {
+					j++
+				}
+				j++
+			}
+			if j++; j > len(expr) {
+				j = len(expr)
+			}
+			push(fmtString, string(expr[i:j]))
+			i = j
+		case isFmtWordChar(r):
+			j := i
+			for j < len(expr) && isFmtWordChar(expr[j]) {
+				j++
+			}
+			push(fmtCode, string(expr[i:j]))
+			i = j
+		default:
+			if i+1 < len(expr) && fmtSpacedOps[string(expr[i:i+2])] {
+				push(fmtCode, string(expr[i:i+2]))
+				i += 2
+			} else {
+				push(fmtCode, string(r))
+				i++
+			}
+		}
+	}
+	markBinaryOps(tokens)
+	return tokens
+}
+
+func fmtSpacing(prev, current fmtToken) string {
+	if current.kind == fmtComment {
+		return " "
+	}
+	if prev.kind == fmtCode && (prev.value == "(" || prev.value == "[") {
+		return ""
+	}
+	if current.kind == fmtCode && (current.value == ")" || current.value == "]" || current.value == "," || current.value == ":") {
+		return ""
+	}
+	if prev.kind == fmtCode && (prev.value == "," || prev.value == ":") {
+		return " "
+	}
+	if prev.binaryOp || current.binaryOp {
+		return " "
+	}
+	if current.spaceBefore {
+		return " "
+	}
+	return ""
+}
+
+func formatComment(comment string) string {
+	text := strings.TrimRightFunc(strings.TrimPrefix(comment, "#"), unicode.IsSpace)
+	if text == "" {
+		return "#"
+	}
+	if !strings.HasPrefix(text, " ") {
+		text = " " + text
+	}
+	return "#" + text
+}
+
+// fmtFrame is a level of nesting within brackets.
+type fmtFrame struct {
+	// The indentation of lines within the brackets.
+	indent int
+
+	// The indentation of the line that opened the brackets, which is also used
+	// for a line that begins by closing them.
+	openerIndent int
+
+	// Whether the line that opened the brackets ended with an operator, in
+	// which case the continuation is already indented by the brackets.
+	absorbed bool
+}
+
+// formatTokens renders a list of tokens with consistent indentation and
+// spacing, where each line that opens brackets indents their contents by two
+// spaces.
+func formatTokens(tokens []fmtToken) string {
+	var lines [][]fmtToken
+	var current []fmtToken
+	for _, t := range tokens {
+		if t.kind == fmtNewline {
+			lines = append(lines, current)
+			current = nil
+			continue
+		}
+		current = append(current, t)
+	}
+	lines = append(lines, current)
+
+	var b strings.Builder
+	frames := []fmtFrame{{}}
+	continuation := false
+	pendingBlank, started := false, false
+
+	closeFrame := func() {
+		if len(frames) > 1 {
+			frames = frames[:len(frames)-1]
+		}
+	}
+
+	for _, line := range lines {
+		if len(line) == 0 {
+			pendingBlank = started
+			continue
+		}
+		if pendingBlank {
+			b.WriteByte('\n')
+			pendingBlank = false
+		}
+		started = true
+
+		leadingClosers := 0
+		for _, t := range line {
+			if t.kind != fmtCode || !strings.Contains(")]}", t.value) {
+				break
+			}
+			leadingClosers++
+		}
+
+		var indent int
+		if leadingClosers > 0 {
+			indent = frames[len(frames)-1].openerIndent
+			for i := 0; i < leadingClosers; i++ {
+				closeFrame()
+			}
+		} else {
+			top := frames[len(frames)-1]
+			indent = top.indent
+			if continuation && !top.absorbed {
+				indent++
+			}
+		}
+		b.WriteString(strings.Repeat("  ", indent))
+
+		lowestFrames := len(frames)
+		var lastCode *fmtToken
+		for i, t := range line {
+			if i > 0 {
+				b.WriteString(fmtSpacing(line[i-1], t))
+			}
+			switch t.kind {
+			case fmtComment:
+				b.WriteString(formatComment(t.value))
+				continue
+			case fmtCode:
+				if i >= leadingClosers {
+					switch t.value {
+					case "(", "[", "{":
+						frames = append(frames, fmtFrame{indent: indent + 1, openerIndent: indent})
+					case ")", "]", "}":
+						closeFrame()
+						if len(frames) < lowestFrames {
+							lowestFrames = len(frames)
+						}
+					}
+				}
+			}
+			b.WriteString(t.value)
+			lastCode = &line[i]
+		}
+		b.WriteByte('\n')
+
+		// Lines containing only comments do not affect continuations.
+		if lastCode == nil {
+			continue
+		}
+		endsWithOp := lastCode.kind == fmtCode && fmtContinuationOps[lastCode.value]
+		top := &frames[len(frames)-1]
+		if len(frames) > lowestFrames {
+			// The line opened brackets that are still open.
+			top.absorbed = endsWithOp
+			continuation = false
+		} else {
+			continuation = endsWithOp
+			if !endsWithOp {
+				top.absorbed = false
+			}
+		}
+	}
+	return b.String()
+}
+
+// significantTokens returns the values of the tokens that determine the meaning
+// of a mapping, which are all tokens other than comments, where each sequence
+// of line breaks is reduced to a single one.
+func significantTokens(tokens []fmtToken) []string {
+	var values []string
+	for _, t := range tokens {
+		switch t.kind {
+		case fmtComment:
+			continue
+		case fmtNewline:
+			if len(values) == 0 || values[len(values)-1] == "\n" {
+				continue
+			}
+		}
+		values = append(values, t.value)
+	}
+	if len(values) > 0 && values[len(values)-1] == "\n" {
+		values = values[:len(values)-1]
+	}
+	return values
+}
+
+func equalTokens(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i, v := range a {
+		if b[i] != v {
+			return false
+		}
+	}
+	return true
+}
+
+// FormatMapping parses a mapping and returns it formatted with consistent
+// indentation and spacing, or an error if the mapping fails to parse.
+//
+// Formatting is limited to the layout of a mapping: the whitespace between
+// tokens, the indentation of lines, blank lines and the spacing of comments.
+// Statements are not re-ordered or rewritten, line breaks between statements
+// are kept, and the contents of strings are preserved.
+//
+// The filepath is optional and used for relative file imports.
+func FormatMapping(filepath, expr string, pCtx Context) (string, *Error) {
+	if _, err := ParseMapping(filepath, expr, pCtx); err != nil {
+		return "", err
+	}
+
+	tokens := tokenizeMapping([]rune(expr))
+	formatted := formatTokens(tokens)
+
+	// As a safety net we ensure that the result is still a valid mapping and
+	// that only its layout has changed, meaning it has the same meaning as the
+	// original.
+	if _, err := ParseMapping(filepath, formatted, pCtx); err != nil {
+		return "", NewFatalError([]rune(expr), errors.New("failed to format mapping: result is not a valid mapping"))
+	}
+	if !equalTokens(significantTokens(tokens), significantTokens(tokenizeMapping([]rune(formatted)))) {
+		return "", NewFatalError([]rune(expr), errors.New("failed to format mapping: result differs from the original mapping by more than layout"))
+	}
+	return formatted, nil
+}
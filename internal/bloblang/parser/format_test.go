@@ -0,0 +1,204 @@
+package parser
+
+import (
+	"testing"
+
+	"github.com/Jeffail/benthos/v3/internal/bloblang/query"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFormatMapping(t *testing.T) {
+	tests := map[string]struct {
+		mapping string
+		output  string
+	}{
+		"already formatted": {
+			mapping: `root.foo = this.foo
+`,
+			output: `root.foo = this.foo
+`,
+		},
+		"whitespace and blank lines": {
+			mapping: `
+
+root.foo   =   this.foo	# a comment
+#another comment
+
+
+root.bar = this.bar   `,
+			output: `root.foo = this.foo # a comment
+# another comment
+
+root.bar = this.bar
+`,
+		},
+		"arithmetic and unary operators": {
+			mapping: `root.a = this.b+1
+root.b = this.d +2
+root.c = this.a - -1
+root.d = if this.x>0 { -1 } else { 10%3/2 }
+root.e = !this.ok&&this.b<=3
+root.f = this.(a|b)
+`,
+			output: `root.a = this.b + 1
+root.b = this.d + 2
+root.c = this.a - -1
+root.d = if this.x > 0 { -1 } else { 10 % 3 / 2 }
+root.e = !this.ok && this.b <= 3
+root.f = this.(a | b)
+`,
+		},
+		"object literals": {
+			mapping: `root = {"a":1,"b" : [1,-2],"c":{"d":this.d}}
+`,
+			output: `root = {"a": 1, "b": [1, -2], "c": {"d": this.d}}
+`,
+		},
+		"operators and arguments": {
+			mapping: `root.foo = this.foo==5  &&this.bar!="baz"
+root.bar = this.bar.(b -> b.replace( "a" ,"b" ))
+root.baz = [ 1,2 , 3 ]
+`,
+			output: `root.foo = this.foo == 5 && this.bar != "baz"
+root.bar = this.bar.(b -> b.replace("a", "b"))
+root.baz = [1, 2, 3]
+`,
+		},
+		"indentation": {
+			mapping: `map things {
+root.foo = this.foo
+      root.bar = match this.bar {
+"a" => "b"
+    _ => {
+"c": "d"
+}
+  }
+}
+root = this.apply("things")
+`,
+			output: `map things {
+  root.foo = this.foo
+  root.bar = match this.bar {
+    "a" => "b"
+    _ => {
+      "c": "d"
+    }
+  }
+}
+root = this.apply("things")
+`,
+		},
+		"continuations": {
+			mapping: `root.foo = this.foo +
+this.bar
+root.bar = this.bar.
+      uppercase()
+`,
+			output: `root.foo = this.foo +
+  this.bar
+root.bar = this.bar.
+  uppercase()
+`,
+		},
+		"multiple brackets on one line": {
+			mapping: `root = this.things.map_each(thing -> thing.values.map_each(value -> {
+        "value": value,
+    }).filter(v -> v.value != null)).flatten()
+root.bar = this.map_each(ele -> ele.values().
+ sort().
+      join(",")
+).join("\n")
+`,
+			output: `root = this.things.map_each(thing -> thing.values.map_each(value -> {
+  "value": value,
+}).filter(v -> v.value != null)).flatten()
+root.bar = this.map_each(ele -> ele.values().
+  sort().
+  join(",")
+).join("\n")
+`,
+		},
+		"strings are preserved": {
+			mapping: `root.foo = "a  ,b==c # d"
+root.bar = """
+    multiple   lines
+ # not a comment
+"""
+`,
+			output: `root.foo = "a  ,b==c # d"
+root.bar = """
+    multiple   lines
+ # not a comment
+"""
+`,
+		},
+	}
+
+	pCtx := Context{
+		Functions: query.AllFunctions,
+		Methods:   query.AllMethods,
+	}
+
+	for name, test := range tests {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			res, err := FormatMapping("", test.mapping, pCtx)
+			require.Nil(t, err)
+			assert.Equal(t, test.output, res)
+
+			// Formatting should be idempotent
+			res, err = FormatMapping("", res, pCtx)
+			require.Nil(t, err)
+			assert.Equal(t, test.output, res)
+		})
+	}
+}
+
+func TestFormatMappingError(t *testing.T) {
+	mapping := `root = this.foo(`
+	_, err := FormatMapping("", mapping, Context{
+		Functions: query.AllFunctions,
+		Methods:   query.AllMethods,
+	})
+	require.NotNil(t, err)
+	assert.Equal(t, "line 1 char 17: required: expected function argument", err.ErrorAtPosition([]rune(mapping)))
+}
+
+func TestFormatSignificantTokens(t *testing.T) {
+	original := significantTokens(tokenizeMapping([]rune(`# header
+
+root.foo = this.foo.uppercase( )
+
+
+root.bar = [ "a","b" ] # trailing
+`)))
+
+	tests := map[string]struct {
+		mapping string
+		equal   bool
+	}{
+		"layout only": {
+			mapping: `root.foo = this.foo.uppercase()
+root.bar = ["a", "b"]
+`,
+			equal: true,
+		},
+		"changed string": {
+			mapping: `root.foo = this.foo.uppercase()
+root.bar = ["a", "c"]
+`,
+		},
+		"joined lines": {
+			mapping: `root.foo = this.foo.uppercase() root.bar = ["a", "b"]
+`,
+		},
+	}
+
+	for name, test := range tests {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, test.equal, equalTokens(original, significantTokens(tokenizeMapping([]rune(test.mapping)))))
+		})
+	}
+}
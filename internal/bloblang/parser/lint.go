@@ -0,0 +1,347 @@
+package parser
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/Jeffail/benthos/v3/internal/bloblang/query"
+	"github.com/Jeffail/benthos/v3/lib/message"
+)
+
+// Lint describes a problem found within a mapping that does not prevent it from
+// being parsed, such as a variable that is declared and never used.
+type Lint struct {
+	Line   int
+	Column int
+	What   string
+}
+
+// String returns a human readable representation of the lint.
+func (l Lint) String() string {
+	return fmt.Sprintf("line %v char %v: %v", l.Line, l.Column, l.What)
+}
+
+//------------------------------------------------------------------------------
+
+// Positions are recorded as the length of the remaining input at the point of
+// a parse, since all parsed input is a suffix of the mapping. This allows the
+// same point to be identified when it's parsed more than once by competing
+// parsers.
+
+type lintVar struct {
+	name string
+	pos  int
+}
+
+type lintAssignment struct {
+	path    []string
+	deleted bool
+	pos     int
+}
+
+type lintScope struct {
+	start, end int
+}
+
+// lintCollector gathers information about a mapping as it is parsed, which is
+// then analysed once parsing is complete.
+type lintCollector struct {
+	deprecatedFunctions map[string]struct{}
+	deprecatedMethods   map[string]struct{}
+
+	varDecls    map[int]lintVar
+	varRefs     map[int]lintVar
+	mapDecls    map[int]lintVar
+	mapRefs     map[int]string
+	dynamicMaps bool
+	assignments map[int]lintAssignment
+	scopes      map[int]lintScope
+	lints       map[int]string
+}
+
+func newLintCollector(pCtx Context) *lintCollector {
+	l := &lintCollector{
+		deprecatedFunctions: map[string]struct{}{},
+		deprecatedMethods:   map[string]struct{}{},
+		varDecls:            map[int]lintVar{},
+		varRefs:             map[int]lintVar{},
+		mapDecls:            map[int]lintVar{},
+		mapRefs:             map[int]string{},
+		assignments:         map[int]lintAssignment{},
+		scopes:              map[int]lintScope{},
+		lints:               map[int]string{},
+	}
+	if docs, ok := pCtx.Functions.(interface{ Docs() []query.FunctionSpec }); ok {
+		for _, spec := range docs.Docs() {
+			if spec.Status == query.StatusDeprecated {
+				l.deprecatedFunctions[spec.Name] = struct{}{}
+			}
+		}
+	}
+	if docs, ok := pCtx.Methods.(interface{ Docs() []query.MethodSpec }); ok {
+		for _, spec := range docs.Docs() {
+			if spec.Status == query.StatusDeprecated {
+				l.deprecatedMethods[spec.Name] = struct{}{}
+			}
+		}
+	}
+	return l
+}
+
+func (l *lintCollector) declareVar(input []rune, name string) {
+	if l != nil {
+		l.varDecls[len(input)] = lintVar{name: name, pos: len(input)}
+	}
+}
+
+func (l *lintCollector) referenceVar(input []rune, name string) {
+	if l != nil {
+		l.varRefs[len(input)] = lintVar{name: name, pos: len(input)}
+	}
+}
+
+func (l *lintCollector) declareMap(input []rune, name string) {
+	if l != nil {
+		l.mapDecls[len(input)] = lintVar{name: name, pos: len(input)}
+	}
+}
+
+func (l *lintCollector) assign(input []rune, path []string, fn query.Function) {
+	if l == nil {
+		return
+	}
+	deleted := false
+	if lit, ok := fn.(*query.Literal); ok {
+		_, deleted = lit.Value.(query.Delete)
+	}
+	l.assignments[len(input)] = lintAssignment{path: path, deleted: deleted, pos: len(input)}
+}
+
+// scope records a block, such as the body of a map, that has its own isolated
+// variables and assignments.
+func (l *lintCollector) scope(input, remaining []rune) {
+	if l != nil {
+		l.scopes[len(input)] = lintScope{start: len(input), end: len(remaining)}
+	}
+}
+
+func (l *lintCollector) function(input []rune, name string) {
+	if l == nil {
+		return
+	}
+	if _, deprecated := l.deprecatedFunctions[name]; deprecated {
+		l.lints[len(input)] = fmt.Sprintf("function %v is deprecated", name)
+	}
+}
+
+// lintProbeTargets are values of each type that methods with literal arguments
+// are executed against in order to determine whether the arguments could ever
+// be valid.
+var lintProbeTargets = []interface{}{
+	map[string]interface{}{}, []interface{}{}, "", int64(0), false, nil,
+}
+
+func (l *lintCollector) method(input []rune, name string, target, method query.Function, args []interface{}, initMethod func(target query.Function) (query.Function, error)) {
+	if l == nil {
+		return
+	}
+	if _, deprecated := l.deprecatedMethods[name]; deprecated {
+		l.lints[len(input)] = fmt.Sprintf("method %v is deprecated", name)
+	}
+
+	if name == "apply" && len(args) == 1 {
+		if mapName, ok := args[0].(string); ok {
+			l.mapRefs[len(input)] = mapName
+		} else {
+			l.dynamicMaps = true
+		}
+	}
+
+	for _, arg := range args {
+		if _, isFn := arg.(query.Function); isFn {
+			return
+		}
+	}
+
+	// When both the target and arguments of a method are literal values the
+	// method can be executed in order to detect type mismatches.
+	if _, isLit := target.(*query.Literal); isLit {
+		if tErr := lintExecTypeError(method); tErr != nil {
+			l.lints[len(input)] = fmt.Sprintf("method %v: %v", name, tErr)
+		}
+		return
+	}
+
+	// Otherwise, when the arguments are literal values the method is executed
+	// against a target of each type, and if every one of them results in a
+	// type mismatch then the arguments can never be valid.
+	if initMethod == nil {
+		return
+	}
+	var firstErr *query.TypeError
+	for _, probe := range lintProbeTargets {
+		probeMethod, err := initMethod(query.NewLiteralFunction("", probe))
+		if err != nil {
+			return
+		}
+		tErr := lintExecTypeError(probeMethod)
+		if tErr == nil {
+			return
+		}
+		if firstErr == nil {
+			firstErr = tErr
+		}
+	}
+	l.lints[len(input)] = fmt.Sprintf("method %v: arguments are not valid for a target of any type: %v", name, firstErr)
+}
+
+// lintExecTypeError executes a function without a message and returns the type
+// error it results in, or nil if it succeeds or fails for any other reason.
+func lintExecTypeError(fn query.Function) *query.TypeError {
+	_, err := fn.Exec(query.FunctionContext{
+		Maps:     map[string]query.Function{},
+		Vars:     map[string]interface{}{},
+		MsgBatch: message.New(nil),
+	})
+	var tErr *query.TypeError
+	if errors.As(err, &tErr) {
+		return tErr
+	}
+	return nil
+}
+
+// scopeOf returns the position of the innermost scope that contains a
+// position, or -1 for the root of the mapping.
+func (l *lintCollector) scopeOf(pos int) int {
+	scope, scopeStart := -1, -1
+	for start, s := range l.scopes {
+		if pos <= s.start && pos > s.end && (scopeStart == -1 || start < scopeStart) {
+			scope, scopeStart = start, start
+		}
+	}
+	return scope
+}
+
+func pathString(path []string) string {
+	if len(path) == 0 {
+		return "root"
+	}
+	return "root." + strings.Join(path, ".")
+}
+
+func isPathPrefix(prefix, path []string) bool {
+	if len(prefix) > len(path) {
+		return false
+	}
+	for i, p := range prefix {
+		if path[i] != p {
+			return false
+		}
+	}
+	return true
+}
+
+func (l *lintCollector) analyse(input []rune, maps map[string]query.Function) []Lint {
+	lints := map[int]string{}
+	for pos, lint := range l.lints {
+		lints[pos] = lint
+	}
+
+	// Variables are isolated within each scope.
+	for pos, decl := range l.varDecls {
+		used := false
+		for _, ref := range l.varRefs {
+			if ref.name == decl.name && l.scopeOf(ref.pos) == l.scopeOf(pos) {
+				used = true
+				break
+			}
+		}
+		if !used {
+			lints[pos] = fmt.Sprintf("variable %v is declared but never used", decl.name)
+		}
+	}
+
+	for pos, ref := range l.varRefs {
+		declared := false
+		for declPos, decl := range l.varDecls {
+			if decl.name == ref.name && l.scopeOf(declPos) == l.scopeOf(pos) {
+				declared = true
+				break
+			}
+		}
+		if !declared {
+			lints[pos] = fmt.Sprintf("variable %v is not declared within this scope", ref.name)
+		}
+	}
+
+	// A map that is applied dynamically could be any map.
+	if !l.dynamicMaps {
+		for pos, decl := range l.mapDecls {
+			used := false
+			for _, ref := range l.mapRefs {
+				if ref == decl.name {
+					used = true
+					break
+				}
+			}
+			if !used {
+				lints[pos] = fmt.Sprintf("map %v is declared but never applied", decl.name)
+			}
+		}
+	}
+	for pos, name := range l.mapRefs {
+		if _, exists := maps[name]; !exists {
+			lints[pos] = fmt.Sprintf("map %v is not declared", name)
+		}
+	}
+
+	// Assignments that are deleted later within the same scope have no effect.
+	for pos, a := range l.assignments {
+		if a.deleted {
+			continue
+		}
+		for delPos, d := range l.assignments {
+			if !d.deleted || delPos >= pos || l.scopeOf(delPos) != l.scopeOf(pos) {
+				continue
+			}
+			if isPathPrefix(d.path, a.path) {
+				delLine, _ := LineAndColOf(input, input[len(input)-delPos:])
+				lints[pos] = fmt.Sprintf("assignment to %v is deleted by a later assignment on line %v", pathString(a.path), delLine)
+				break
+			}
+		}
+	}
+
+	positions := make([]int, 0, len(lints))
+	for pos := range lints {
+		positions = append(positions, pos)
+	}
+	sort.Sort(sort.Reverse(sort.IntSlice(positions)))
+
+	result := make([]Lint, 0, len(positions))
+	for _, pos := range positions {
+		line, col := LineAndColOf(input, input[len(input)-pos:])
+		result = append(result, Lint{Line: line, Column: col, What: lints[pos]})
+	}
+	return result
+}
+
+//------------------------------------------------------------------------------
+
+// LintMapping parses a mapping and returns any lints found within it, or an
+// error if the mapping fails to parse. Imported mappings are not linted, and
+// there is no lint for shadowed maps since map name collisions, including
+// those of imported maps, already fail to parse.
+//
+// The filepath is optional and used for relative file imports.
+func LintMapping(filepath, expr string, pCtx Context) ([]Lint, *Error) {
+	pCtx.lints = newLintCollector(pCtx)
+
+	exec, err := ParseMapping(filepath, expr, pCtx)
+	if err != nil {
+		return nil, err
+	}
+	return pCtx.lints.analyse([]rune(expr), exec.Maps()), nil
+}
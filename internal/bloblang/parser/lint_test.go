@@ -0,0 +1,159 @@
+package parser
+
+import (
+	"testing"
+
+	"github.com/Jeffail/benthos/v3/internal/bloblang/query"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLintMapping(t *testing.T) {
+	tests := map[string]struct {
+		mapping string
+		lints   []string
+	}{
+		"no lints": {
+			mapping: `let foo = this.foo
+map things {
+  root.bar = this.bar
+}
+root = this.apply("things")
+root.foo = $foo
+`,
+		},
+		"unused variable": {
+			mapping: `let foo = this.foo
+let bar = this.bar
+root.bar = $bar
+`,
+			lints: []string{"line 1 char 1: variable foo is declared but never used"},
+		},
+		"variable not declared within scope": {
+			mapping: `let foo = this.foo
+map things {
+  root.foo = $foo
+}
+root = this.apply("things")
+root.foo = $foo
+`,
+			lints: []string{"line 3 char 14: variable foo is not declared within this scope"},
+		},
+		"variables within functions": {
+			mapping: `func double(n) {
+  let doubled = n * 2
+  root = $doubled
+}
+root = double(this.n)
+`,
+		},
+		"unused map": {
+			mapping: `map things {
+  root.foo = this.foo
+}
+root = this
+`,
+			lints: []string{"line 1 char 1: map things is declared but never applied"},
+		},
+		"dynamic map application": {
+			mapping: `map things {
+  root.foo = this.foo
+}
+root = this.apply(this.map_name)
+`,
+		},
+		"unknown map": {
+			mapping: `root = this.apply("things")
+`,
+			lints: []string{"line 1 char 13: map things is not declared"},
+		},
+		"deprecated function": {
+			mapping: `root.now = timestamp("2006")
+`,
+			lints: []string{"line 1 char 12: function timestamp is deprecated"},
+		},
+		"deprecated method": {
+			mapping: `root.ts = this.ts.parse_timestamp_unix()
+`,
+			lints: []string{"line 1 char 19: method parse_timestamp_unix is deprecated"},
+		},
+		"literal type mismatch": {
+			mapping: `root.foo = "foo".sum()
+`,
+			lints: []string{"line 1 char 18: method sum: expected array value, got string from string literal (\"foo\")"},
+		},
+		"literal argument type mismatch": {
+			mapping: `root.doc = this.doc.patch(["foo"])
+`,
+			lints: []string{"line 1 char 21: method patch: arguments are not valid for a target of any type: expected object value, got string (\"foo\")"},
+		},
+		"literal arguments": {
+			mapping: `root.doc = this.doc.patch([{"op": "remove", "path": "/foo"}])
+root.tags = this.tags.join(",")
+`,
+		},
+		"deleted assignment": {
+			mapping: `root.foo.bar = "bar"
+root.baz = "baz"
+root.foo = deleted()
+`,
+			lints: []string{"line 1 char 1: assignment to root.foo.bar is deleted by a later assignment on line 3"},
+		},
+		"deleted within a map": {
+			mapping: `map things {
+  root.foo = "foo"
+  root = deleted()
+}
+root = this.apply("things")
+root.foo = "foo"
+`,
+			lints: []string{"line 2 char 3: assignment to root.foo is deleted by a later assignment on line 3"},
+		},
+	}
+
+	for name, test := range tests {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			lints, err := LintMapping("", test.mapping, Context{
+				Functions: query.AllFunctions,
+				Methods:   query.AllMethods,
+			})
+			require.Nil(t, err)
+
+			var lintStrs []string
+			for _, l := range lints {
+				lintStrs = append(lintStrs, l.String())
+			}
+			assert.Equal(t, test.lints, lintStrs)
+		})
+	}
+}
+
+func TestLintMappingShadowedMap(t *testing.T) {
+	// Maps cannot be shadowed as any collision of map names, including those
+	// of imported maps, fails to parse.
+	mapping := `map things {
+  root = this.foo
+}
+map things {
+  root = this.bar
+}
+root = this.apply("things")
+`
+	_, err := LintMapping("", mapping, Context{
+		Functions: query.AllFunctions,
+		Methods:   query.AllMethods,
+	})
+	require.NotNil(t, err)
+	assert.Equal(t, "line 4 char 1: map name collision: things", err.ErrorAtPosition([]rune(mapping)))
+}
+
+func TestLintMappingError(t *testing.T) {
+	mapping := `root = this.foo(`
+	_, err := LintMapping("", mapping, Context{
+		Functions: query.AllFunctions,
+		Methods:   query.AllMethods,
+	})
+	require.NotNil(t, err)
+	assert.Equal(t, "line 1 char 17: required: expected function argument", err.ErrorAtPosition([]rune(mapping)))
+}
@@ -57,9 +57,13 @@ func parseExecutor(baseDir string, pCtx Context) Func {
 		maps := map[string]query.Function{}
 		statements := []mapping.Statement{}
 
+		// Imported mappings share the definitions of the importing mapping.
+		pCtx := pCtx.withUserDefinitions()
+
 		statement := OneOf(
 			importParser(baseDir, maps, pCtx),
 			mapParser(maps, pCtx),
+			userDefinitionParser(input, maps, pCtx),
 			letStatementParser(pCtx),
 			metaStatementParser(false, pCtx),
 			plainMappingStatementParser(pCtx),
@@ -131,7 +135,7 @@ func singleRootImport(baseDir string, pCtx Context) Func {
 		}
 
 		importContent := []rune(string(contents))
-		execRes := parseExecutor(path.Dir(fpath), pCtx)(importContent)
+		execRes := parseExecutor(path.Dir(fpath), pCtx.withoutLints().withImportPath(fpath))(importContent)
 		if execRes.Err != nil {
 			return Fail(NewFatalError(input, NewImportError(fpath, importContent, execRes.Err)), input)
 		}
@@ -217,14 +221,15 @@ func importParser(baseDir string, maps map[string]query.Function, pCtx Context)
 		}
 
 		importContent := []rune(string(contents))
-		execRes := parseExecutor(path.Dir(fpath), pCtx)(importContent)
+		defsBefore := pCtx.userDefs.count()
+		execRes := parseExecutor(path.Dir(fpath), pCtx.withoutLints().withImportPath(fpath))(importContent)
 		if execRes.Err != nil {
 			return Fail(NewFatalError(input, NewImportError(fpath, importContent, execRes.Err)), input)
 		}
 
 		exec := execRes.Payload.(*mapping.Executor)
-		if len(exec.Maps()) == 0 {
-			err := fmt.Errorf("no maps to import from '%v'", fpath)
+		if len(exec.Maps()) == 0 && pCtx.userDefs.count() == defsBefore {
+			err := fmt.Errorf("no maps, functions or methods to import from '%v'", fpath)
 			return Fail(NewFatalError(input, err), input)
 		}
 
@@ -307,11 +312,129 @@ func mapParser(maps map[string]query.Function, pCtx Context) Func {
 		}
 
 		maps[ident] = mapping.NewExecutor("map "+ident, input, maps, statements...)
+		pCtx.lints.declareMap(input, ident)
+		pCtx.lints.scope(input, res.Remaining)
 
 		return Success(ident, res.Remaining)
 	}
 }
 
+func userDefinitionParser(mappingInput []rune, maps map[string]query.Function, pCtx Context) Func {
+	newline := NewlineAllowComment()
+	whitespace := SpacesAndTabs()
+	allWhitespace := DiscardAll(OneOf(whitespace, newline))
+
+	header := Sequence(
+		Expect(OneOf(Term("func"), Term("method")), "assignment"),
+		whitespace,
+		SnakeCase(),
+	)
+
+	params := MustBe(DelimitedPattern(
+		Expect(Sequence(Char('('), Discard(whitespace)), "parameters"),
+		MustBe(Expect(varNameParser(), "parameter name")),
+		MustBe(Expect(Sequence(Discard(whitespace), Char(','), Discard(whitespace)), "comma")),
+		MustBe(Expect(Sequence(Discard(whitespace), Char(')')), "closing bracket")),
+		false,
+	))
+
+	body := func(bodyCtx Context) Func {
+		return MustBe(DelimitedPattern(
+			Expect(Sequence(
+				Char('{'),
+				allWhitespace,
+			), "body"),
+			OneOf(
+				letStatementParser(bodyCtx),
+				metaStatementParser(true, bodyCtx),
+				plainMappingStatementParser(bodyCtx),
+			),
+			Sequence(
+				Discard(whitespace),
+				newline,
+				allWhitespace,
+			),
+			Sequence(
+				allWhitespace,
+				Char('}'),
+			),
+			true,
+		))
+	}
+
+	return func(input []rune) Result {
+		res := header(input)
+		if res.Err != nil {
+			return res
+		}
+
+		seqSlice := res.Payload.([]interface{})
+		kind, name := seqSlice[0].(string), seqSlice[2].(string)
+
+		if res = params(res.Remaining); res.Err != nil {
+			return Fail(res.Err, input)
+		}
+
+		bodyCtx := pCtx
+		paramNames := []string{}
+		for _, p := range res.Payload.([]interface{}) {
+			param := p.(string)
+			if param == "this" || param == "root" {
+				return Fail(NewFatalError(input, fmt.Errorf("parameter name %v is reserved", param)), input)
+			}
+			if bodyCtx.HasNamedContext(param) {
+				return Fail(NewFatalError(input, fmt.Errorf("duplicate parameter name: %v", param)), input)
+			}
+			bodyCtx = bodyCtx.WithNamedContext(param)
+			paramNames = append(paramNames, param)
+		}
+
+		label, defs, builtins := "function", pCtx.userDefs.functions, pCtx.Functions.List()
+		if kind == "method" {
+			label, defs, builtins = "method", pCtx.userDefs.methods, pCtx.Methods.List()
+		}
+		// A definition declared again by the same imported file is the result
+		// of that file being imported more than once, in which case the body is
+		// parsed in order to consume it but the original definition is kept.
+		existing, exists := defs[name]
+		if exists && (pCtx.importPath == "" || pCtx.userDefs.sources[existing] != pCtx.importPath) {
+			return Fail(NewFatalError(input, fmt.Errorf("%v name collision: %v", label, name)), input)
+		}
+		for _, b := range builtins {
+			if b == name {
+				return Fail(NewFatalError(input, fmt.Errorf("%v name collision with a built-in %v: %v", label, label, name)), input)
+			}
+		}
+
+		// Register the definition before parsing the body so that it can be
+		// called recursively.
+		def := existing
+		if !exists {
+			def = &query.UserDefinition{Name: name, Params: paramNames}
+			defs[name] = def
+			pCtx.userDefs.sources[def] = pCtx.importPath
+		}
+		pCtx.userDefs.parsed++
+
+		res = Optional(whitespace)(res.Remaining)
+		if res = body(bodyCtx)(res.Remaining); res.Err != nil {
+			return Fail(res.Err, input)
+		}
+
+		stmtSlice := res.Payload.([]interface{})
+		statements := make([]mapping.Statement, len(stmtSlice))
+		for i, v := range stmtSlice {
+			statements[i] = v.(mapping.Statement)
+		}
+
+		if !exists {
+			def.Body = mapping.NewExecutor(kind+" "+name, mappingInput, maps, statements...)
+		}
+		pCtx.lints.scope(input, res.Remaining)
+		return Success(def, res.Remaining)
+	}
+}
+
 func letStatementParser(pCtx Context) Func {
 	p := Sequence(
 		Expect(Term("let"), "assignment"),
@@ -338,6 +461,7 @@ func letStatementParser(pCtx Context) Func {
 			return res
 		}
 		resSlice := res.Payload.([]interface{})
+		pCtx.lints.declareVar(input, resSlice[2].(string))
 		return Success(
 			mapping.NewStatement(
 				input,
@@ -498,6 +622,7 @@ func plainMappingStatementParser(pCtx Context) Func {
 		if len(path) > 0 && path[0] == "root" {
 			path = path[1:]
 		}
+		pCtx.lints.assign(input, path, resSlice[4].(query.Function))
 
 		return Success(
 			mapping.NewStatement(
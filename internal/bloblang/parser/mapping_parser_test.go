@@ -28,6 +28,12 @@ func TestMappingErrors(t *testing.T) {
 	require.NoError(t, ioutil.WriteFile(noMapsFile, []byte(`foo = "this is valid but has no maps"`), 0777))
 	require.NoError(t, ioutil.WriteFile(goodMapFile, []byte(`map foo { foo = "this is valid" }`), 0777))
 
+	doubleFile := filepath.Join(dir, "double.blobl")
+	otherDoubleFile := filepath.Join(dir, "other_double.blobl")
+
+	require.NoError(t, ioutil.WriteFile(doubleFile, []byte(`func double(n) { root = n * 2 }`), 0777))
+	require.NoError(t, ioutil.WriteFile(otherDoubleFile, []byte(`func double(n) { root = n + n }`), 0777))
+
 	tests := map[string]struct {
 		mapping string
 		err     string
@@ -144,7 +150,7 @@ foo = bar.apply("from_import")`, badMapFile),
 			mapping: fmt.Sprintf(`import "%v"
 
 foo = bar.apply("from_import")`, noMapsFile),
-			err: fmt.Sprintf(`line 1 char 1: no maps to import from '%v'`, noMapsFile),
+			err: fmt.Sprintf(`line 1 char 1: no maps, functions or methods to import from '%v'`, noMapsFile),
 		},
 		"colliding maps file import": {
 			mapping: fmt.Sprintf(`map "foo" { this = that }			
@@ -154,6 +160,83 @@ import "%v"
 foo = bar.apply("foo")`, goodMapFile),
 			err: fmt.Sprintf(`line 3 char 1: map name collisions from import '%v': [foo]`, goodMapFile),
 		},
+		"function called with too few arguments": {
+			mapping: `func foo(a, b) {
+  root = a + b
+}
+root = foo(1)`,
+			err: `line 4 char 8: expected 2 arguments, received: 1`,
+		},
+		"method called with too many arguments": {
+			mapping: `method foo(a) {
+  root = this + a
+}
+root = this.bar.foo(1, 2)`,
+			err: `line 4 char 17: expected 1 arguments, received: 2`,
+		},
+		"function called before declaration": {
+			mapping: `root = foo(1)
+func foo(a) {
+  root = a
+}`,
+			err: `line 1 char 8: unrecognised function 'foo'`,
+		},
+		"function name collision": {
+			mapping: `func foo(a) {
+  root = a
+}
+func foo(b) {
+  root = b
+}`,
+			err: `line 4 char 1: function name collision: foo`,
+		},
+		"function name collision from imports": {
+			mapping: fmt.Sprintf(`import "%v"
+import "%v"`, doubleFile, otherDoubleFile),
+			err: fmt.Sprintf(`line 2 char 1: failed to parse import '%v': line 1 char 1: function name collision: double`, otherDoubleFile),
+		},
+		"function name collision with import": {
+			mapping: fmt.Sprintf(`func double(n) {
+  root = n * 2
+}
+import "%v"`, doubleFile),
+			err: fmt.Sprintf(`line 4 char 1: failed to parse import '%v': line 1 char 1: function name collision: double`, doubleFile),
+		},
+		"function name collision with built-in": {
+			mapping: `func uuid_v4() {
+  root = "nope"
+}`,
+			err: `line 1 char 1: function name collision with a built-in function: uuid_v4`,
+		},
+		"method name collision with built-in": {
+			mapping: `method uppercase() {
+  root = "nope"
+}`,
+			err: `line 1 char 1: method name collision with a built-in method: uppercase`,
+		},
+		"duplicate parameter names": {
+			mapping: `func foo(a, a) {
+  root = a
+}`,
+			err: `line 1 char 1: duplicate parameter name: a`,
+		},
+		"reserved parameter name": {
+			mapping: `func foo(this) {
+  root = this
+}`,
+			err: `line 1 char 1: parameter name this is reserved`,
+		},
+		"no body function definition": {
+			mapping: `func foo(a)
+root = foo(1)`,
+			err: `line 1 char 12: required: expected body`,
+		},
+		"meta assignment in function": {
+			mapping: `func foo(a) {
+  meta foo = a
+}`,
+			err: `line 2 char 3: setting meta fields from within a map is not allowed`,
+		},
 		"quotes at root": {
 			mapping: `
 "root.something" = 5 + 2`,
@@ -188,9 +271,34 @@ func TestMappings(t *testing.T) {
   nested = this
 }`), 0777))
 
+	funcsFile := filepath.Join(dir, "funcs.blobl")
+	require.NoError(t, ioutil.WriteFile(funcsFile, []byte(`func greet(name) {
+  root = "hello " + name
+}
+
+method shout() {
+  root = this.uppercase() + "!"
+}`), 0777))
+
 	directMapFile := filepath.Join(dir, "direct_map.blobl")
 	require.NoError(t, ioutil.WriteFile(directMapFile, []byte(`root.nested = this`), 0777))
 
+	// A shared library imported by two other libraries, which are both
+	// imported by a mapping.
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "lib.blobl"), []byte(`func double(n) {
+  root = n * 2
+}`), 0777))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "a_lib.blobl"), []byte(`import "./lib.blobl"
+
+func quadruple(n) {
+  root = double(double(n))
+}`), 0777))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "b_lib.blobl"), []byte(`import "lib.blobl"
+
+func triple(n) {
+  root = double(n) + n
+}`), 0777))
+
 	type part struct {
 		Content string
 		Meta    map[string]string
@@ -469,6 +577,113 @@ root = this.apply("foo")`, goodMapFile),
 				Content: `{"foo":"this is valid","nested":{"outter":{"inner":"hello world"}}}`,
 			},
 		},
+		"test user defined function": {
+			mapping: `func join_names(first, last) {
+  let sep = " "
+  root = first + $sep + last
+}
+root.name = join_names(this.first, this.last)
+root.this = join_names("is", this.last)`,
+			input: []part{
+				{Content: `{"first":"foo","last":"bar"}`},
+			},
+			output: part{
+				Content: `{"name":"foo bar","this":"is bar"}`,
+			},
+		},
+		"test user defined function uses caller context": {
+			mapping: `func get_field(name) {
+  root = this.get(name)
+}
+root.a = get_field("foo")
+root.b = this.nested.(get_field("bar"))`,
+			input: []part{
+				{Content: `{"foo":"first","bar":"ignored","nested":{"bar":"second"}}`},
+			},
+			output: part{
+				Content: `{"a":"first","b":"second"}`,
+			},
+		},
+		"test user defined method": {
+			mapping: `method wrap(left, right) {
+  root.value = left + this + right
+}
+root = this.values.map_each(v -> v.wrap("[", "]"))`,
+			input: []part{
+				{Content: `{"values":["foo","bar"]}`},
+			},
+			output: part{
+				Content: `[{"value":"[foo]"},{"value":"[bar]"}]`,
+			},
+		},
+		"test recursive user defined function": {
+			mapping: `func fib(n) {
+  root = if n < 2 { n } else { fib(n - 1) + fib(n - 2) }
+}
+root = fib(this.n)`,
+			input: []part{
+				{Content: `{"n":10}`},
+			},
+			output: part{
+				Content: `55`,
+			},
+		},
+		"test user defined function variables isolated": {
+			mapping: `func foo(a) {
+  let v = "inner " + a
+  root = $v
+}
+let v = "outer"
+root.a = foo("value")
+root.b = $v`,
+			input: []part{
+				{Content: `{}`},
+			},
+			output: part{
+				Content: `{"a":"inner value","b":"outer"}`,
+			},
+		},
+		"test user defined function within map": {
+			mapping: `func greet(name) {
+  root = "hello " + name
+}
+map foo {
+  root.greeting = greet(this.name)
+}
+root = this.apply("foo")`,
+			input: []part{
+				{Content: `{"name":"bob"}`},
+			},
+			output: part{
+				Content: `{"greeting":"hello bob"}`,
+			},
+		},
+		"test imported functions": {
+			mapping: fmt.Sprintf(`import "%v"
+
+root.a = greet(this.name)
+root.b = this.name.shout()`, funcsFile),
+			input: []part{
+				{Content: `{"name":"bob"}`},
+			},
+			output: part{
+				Content: `{"a":"hello bob","b":"BOB!"}`,
+			},
+		},
+		"test diamond imported functions": {
+			mapping: fmt.Sprintf(`import "%v"
+import "%v"
+
+root.a = double(this.n)
+root.b = triple(this.n)
+root.c = quadruple(this.n)`, filepath.Join(dir, "a_lib.blobl"), filepath.Join(dir, "b_lib.blobl")),
+			input: []part{
+				{Content: `{"n":3}`},
+			},
+			output: part{
+				Content: `{"a":6,"b":9,"c":12}`,
+			},
+		},
 		"test directly imported map": {
 			mapping: fmt.Sprintf(`from "%v"`, directMapFile),
 			input: []part{
@@ -517,3 +732,51 @@ root = this.apply("foo")`, goodMapFile),
 		})
 	}
 }
+
+func TestMappingUserDefinitionErrors(t *testing.T) {
+	tests := map[string]struct {
+		mapping string
+		input   string
+		err     string
+	}{
+		"unbounded recursion": {
+			mapping: `func forever(n) {
+  root = forever(n + 1)
+}
+root = forever(0)`,
+			input: `{}`,
+			err:   "failed assignment (line 4): failed assignment (line 2): entering func forever exceeded maximum allowed stacks of 10000, this could be due to unbounded recursion",
+		},
+		"error within function body": {
+			mapping: `func add(a, b) {
+  root = a + b
+}
+root = add(this.a, this.b)`,
+			input: `{"a":"foo","b":5}`,
+			err:   "failed assignment (line 4): failed assignment (line 2): cannot add types string (from field `a`) and number (from field `b`)",
+		},
+		"error within method argument": {
+			mapping: `method add(b) {
+  root = this + b
+}
+root = this.a.add(this.b.number())`,
+			input: `{"a":5,"b":"nope"}`,
+			err:   "failed assignment (line 4): argument b: field `this.b`: strconv.ParseFloat: parsing \"nope\": invalid syntax",
+		},
+	}
+
+	for name, test := range tests {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			exec, perr := ParseMapping("", test.mapping, Context{
+				Functions: query.AllFunctions,
+				Methods:   query.AllMethods,
+			})
+			require.Nil(t, perr)
+
+			_, err := exec.MapPart(0, message.New([][]byte{[]byte(test.input)}))
+			require.Error(t, err)
+			assert.Equal(t, test.err, err.Error())
+		})
+	}
+}
@@ -198,7 +198,7 @@ func fieldLiteralMapParser(ctxFn query.Function) Func {
 	}
 }
 
-func variableLiteralParser() Func {
+func variableLiteralParser(pCtx Context) Func {
 	varPathParser := Expect(
 		Sequence(
 			Char('$'),
@@ -224,6 +224,7 @@ func variableLiteralParser() Func {
 
 		path := res.Payload.([]interface{})[1].(string)
 		fn := query.NewVarFunction(path)
+		pCtx.lints.referenceVar(input, path)
 
 		return Success(fn, res.Remaining)
 	}
@@ -295,6 +296,11 @@ func methodParser(fn query.Function, pCtx Context) Func {
 		if err != nil {
 			return Fail(NewFatalError(input, err), input)
 		}
+		// Only built-in methods are probed by the linter, user defined methods
+		// fail to initialise here and are therefore skipped.
+		pCtx.lints.method(input, targetMethod, fn, method, args, func(target query.Function) (query.Function, error) {
+			return pCtx.Methods.Init(targetMethod, target, args...)
+		})
 		return Success(method, res.Remaining)
 	}
 }
@@ -323,6 +329,7 @@ func functionParser(pCtx Context) Func {
 		if err != nil {
 			return Fail(NewFatalError(input, err), input)
 		}
+		pCtx.lints.function(input, targetFunc)
 		return Success(fn, res.Remaining)
 	}
 }
@@ -1,17 +1,21 @@
 package parser
 
 import (
+	"path/filepath"
+
 	"github.com/Jeffail/benthos/v3/internal/bloblang/query"
 )
 
 // FunctionSet provides constructors to the functions available in this query.
 type FunctionSet interface {
 	Init(string, ...interface{}) (query.Function, error)
+	List() []string
 }
 
 // MethodSet provides constructors to the methods available in this query.
 type MethodSet interface {
 	Init(string, query.Function, ...interface{}) (query.Function, error)
+	List() []string
 }
 
 // Context contains context used throughout a Bloblang parser for
@@ -20,6 +24,53 @@ type Context struct {
 	Functions    FunctionSet
 	Methods      MethodSet
 	namedContext *namedContext
+	userDefs     *userDefinitions
+	importPath   string
+	lints        *lintCollector
+}
+
+// userDefinitions contains the functions and methods declared within a mapping,
+// which are shared with any mappings that it imports.
+type userDefinitions struct {
+	functions map[string]*query.UserDefinition
+	methods   map[string]*query.UserDefinition
+
+	// The imported file that each definition was declared within, which allows
+	// the same file to be imported more than once, such as when two imported
+	// files both import a shared library.
+	sources map[*query.UserDefinition]string
+
+	// The number of definitions parsed, including those that were already
+	// declared by an earlier import of the same file.
+	parsed int
+}
+
+func (u *userDefinitions) count() int {
+	if u == nil {
+		return 0
+	}
+	return u.parsed
+}
+
+func (pCtx Context) withUserDefinitions() Context {
+	if pCtx.userDefs == nil {
+		pCtx.userDefs = &userDefinitions{
+			functions: map[string]*query.UserDefinition{},
+			methods:   map[string]*query.UserDefinition{},
+			sources:   map[*query.UserDefinition]string{},
+		}
+	}
+	return pCtx
+}
+
+// withImportPath returns a Context for parsing the contents of an imported
+// file.
+func (pCtx Context) withImportPath(p string) Context {
+	if abs, err := filepath.Abs(p); err == nil {
+		p = abs
+	}
+	pCtx.importPath = p
+	return pCtx
 }
 
 type namedContext struct {
@@ -46,18 +97,45 @@ func (pCtx Context) HasNamedContext(name string) bool {
 	return false
 }
 
+func (pCtx Context) withoutLints() Context {
+	pCtx.lints = nil
+	return pCtx
+}
+
 // InitFunction attempts to initialise a function from the available
 // constructors of the parser context.
 func (pCtx Context) InitFunction(name string, args ...interface{}) (query.Function, error) {
+	if pCtx.userDefs != nil {
+		if def, exists := pCtx.userDefs.functions[name]; exists {
+			return query.NewUserFunction(def, argsToFunctions(args)...)
+		}
+	}
 	return pCtx.Functions.Init(name, args...)
 }
 
 // InitMethod attempts to initialise a method from the available constructors of
 // the parser context.
 func (pCtx Context) InitMethod(name string, target query.Function, args ...interface{}) (query.Function, error) {
+	if pCtx.userDefs != nil {
+		if def, exists := pCtx.userDefs.methods[name]; exists {
+			return query.NewUserMethod(def, target, argsToFunctions(args)...)
+		}
+	}
 	return pCtx.Methods.Init(name, target, args...)
 }
 
+func argsToFunctions(args []interface{}) []query.Function {
+	fns := make([]query.Function, len(args))
+	for i, arg := range args {
+		if fn, ok := arg.(query.Function); ok {
+			fns[i] = fn
+		} else {
+			fns[i] = query.NewLiteralFunction("", arg)
+		}
+	}
+	return fns
+}
+
 func queryParser(pCtx Context) func(input []rune) Result {
 	rootParser := parseWithTails(Expect(
 		OneOf(
@@ -67,7 +145,7 @@ func queryParser(pCtx Context) func(input []rune) Result {
 			bracketsExpressionParser(pCtx),
 			literalValueParser(pCtx),
 			functionParser(pCtx),
-			variableLiteralParser(),
+			variableLiteralParser(pCtx),
 			fieldLiteralRootParser(pCtx),
 		),
 		"query",
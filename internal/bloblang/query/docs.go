@@ -118,6 +118,7 @@ var (
 	MethodCategoryEncoding       MethodCategory = "Encoding and Encryption"
 	MethodCategoryCoercion       MethodCategory = "Type Coercion"
 	MethodCategoryParsing        MethodCategory = "Parsing"
+	MethodCategoryNetwork        MethodCategory = "Network & URL Manipulation"
 	MethodCategoryObjectAndArray MethodCategory = "Object & Array Manipulation"
 	MethodCategoryDeprecated     MethodCategory = "Deprecated"
 	MethodCategoryPlugin         MethodCategory = "Plugin"
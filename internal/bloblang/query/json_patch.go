@@ -0,0 +1,443 @@
+package query
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Implementations of JSON Patch (RFC6902), JSON Pointer (RFC6901) and JSON
+// Merge Patch (RFC7386) that operate on boxed values.
+
+func escapeJSONPointerToken(token string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(token, "~", "~0"), "/", "~1")
+}
+
+func parseJSONPointer(pointer string) ([]string, error) {
+	if pointer == "" {
+		return nil, nil
+	}
+	if pointer[0] != '/' {
+		return nil, fmt.Errorf("invalid path %v: a non-empty path must begin with a forward slash", pointer)
+	}
+	tokens := strings.Split(pointer[1:], "/")
+	for i, t := range tokens {
+		for j := 0; j < len(t); j++ {
+			if t[j] == '~' && (j+1 == len(t) || (t[j+1] != '0' && t[j+1] != '1')) {
+				return nil, fmt.Errorf("invalid path %v: tilde must be followed by 0 or 1", pointer)
+			}
+		}
+		tokens[i] = strings.ReplaceAll(strings.ReplaceAll(t, "~1", "/"), "~0", "~")
+	}
+	return tokens, nil
+}
+
+func patchValueString(v interface{}) string {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprintf("%v", v)
+	}
+	return string(b)
+}
+
+// patchValuesEqual compares two values structurally, where numbers are equal
+// when their values are equal regardless of their underlying types.
+func patchValuesEqual(a, b interface{}) bool {
+	switch at := a.(type) {
+	case map[string]interface{}:
+		bt, ok := b.(map[string]interface{})
+		if !ok || len(at) != len(bt) {
+			return false
+		}
+		for k, av := range at {
+			bv, exists := bt[k]
+			if !exists || !patchValuesEqual(av, bv) {
+				return false
+			}
+		}
+		return true
+	case []interface{}:
+		bt, ok := b.([]interface{})
+		if !ok || len(at) != len(bt) {
+			return false
+		}
+		for i, av := range at {
+			if !patchValuesEqual(av, bt[i]) {
+				return false
+			}
+		}
+		return true
+	}
+	if ITypeOf(a) == ValueNumber && ITypeOf(b) == ValueNumber {
+		af, aErr := IGetNumber(a)
+		bf, bErr := IGetNumber(b)
+		return aErr == nil && bErr == nil && af == bf
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+//------------------------------------------------------------------------------
+
+// jsonPatchDiff returns a list of JSON Patch operations that transforms a
+// value into another. Arrays are compared index by index, and therefore an
+// insertion in the middle of an array results in replacements of all
+// subsequent elements.
+func jsonPatchDiff(path string, from, to interface{}) []interface{} {
+	if patchValuesEqual(from, to) {
+		return nil
+	}
+
+	newOp := func(op, path string, value ...interface{}) map[string]interface{} {
+		obj := map[string]interface{}{"op": op, "path": path}
+		if len(value) > 0 {
+			obj["value"] = IClone(value[0])
+		}
+		return obj
+	}
+
+	var ops []interface{}
+	switch fromT := from.(type) {
+	case map[string]interface{}:
+		toT, ok := to.(map[string]interface{})
+		if !ok {
+			break
+		}
+		keys := make([]string, 0, len(fromT)+len(toT))
+		for k := range fromT {
+			keys = append(keys, k)
+		}
+		for k := range toT {
+			if _, exists := fromT[k]; !exists {
+				keys = append(keys, k)
+			}
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			kPath := path + "/" + escapeJSONPointerToken(k)
+			fromV, inFrom := fromT[k]
+			toV, inTo := toT[k]
+			switch {
+			case !inTo:
+				ops = append(ops, newOp("remove", kPath))
+			case !inFrom:
+				ops = append(ops, newOp("add", kPath, toV))
+			default:
+				ops = append(ops, jsonPatchDiff(kPath, fromV, toV)...)
+			}
+		}
+		return ops
+	case []interface{}:
+		toT, ok := to.([]interface{})
+		if !ok {
+			break
+		}
+		i := 0
+		for ; i < len(fromT) && i < len(toT); i++ {
+			ops = append(ops, jsonPatchDiff(path+"/"+strconv.Itoa(i), fromT[i], toT[i])...)
+		}
+		for j := len(fromT) - 1; j >= i; j-- {
+			ops = append(ops, newOp("remove", path+"/"+strconv.Itoa(j)))
+		}
+		for ; i < len(toT); i++ {
+			ops = append(ops, newOp("add", path+"/"+strconv.Itoa(i), toT[i]))
+		}
+		return ops
+	}
+	return []interface{}{newOp("replace", path, to)}
+}
+
+//------------------------------------------------------------------------------
+
+func patchArrayIndex(token string, length int, allowEnd bool) (int, error) {
+	if allowEnd && token == "-" {
+		return length, nil
+	}
+	if token == "" || (len(token) > 1 && token[0] == '0') || strings.TrimLeft(token, "0123456789") != "" {
+		return 0, fmt.Errorf("invalid array index: %v", token)
+	}
+	i, err := strconv.Atoi(token)
+	if err != nil {
+		return 0, fmt.Errorf("invalid array index: %v", token)
+	}
+	max := length - 1
+	if allowEnd {
+		max = length
+	}
+	if i > max {
+		return 0, fmt.Errorf("array index %v is out of bounds", token)
+	}
+	return i, nil
+}
+
+func jsonPatchGet(root interface{}, pointer string) (interface{}, error) {
+	tokens, err := parseJSONPointer(pointer)
+	if err != nil {
+		return nil, err
+	}
+	current := root
+	for _, t := range tokens {
+		switch c := current.(type) {
+		case map[string]interface{}:
+			v, exists := c[t]
+			if !exists {
+				return nil, fmt.Errorf("path %v does not exist", pointer)
+			}
+			current = v
+		case []interface{}:
+			i, err := patchArrayIndex(t, len(c), false)
+			if err != nil {
+				return nil, fmt.Errorf("path %v: %w", pointer, err)
+			}
+			current = c[i]
+		default:
+			return nil, fmt.Errorf("path %v does not exist", pointer)
+		}
+	}
+	return current, nil
+}
+
+// jsonPatchModify walks to the parent of a path and calls a func with the
+// container and the final key, the result of which replaces the container.
+func jsonPatchModify(root interface{}, pointer string, fn func(container interface{}, key string) (interface{}, error)) (interface{}, error) {
+	tokens, err := parseJSONPointer(pointer)
+	if err != nil {
+		return nil, err
+	}
+
+	var modify func(current interface{}, tokens []string) (interface{}, error)
+	modify = func(current interface{}, tokens []string) (interface{}, error) {
+		if len(tokens) == 1 {
+			res, err := fn(current, tokens[0])
+			if err != nil {
+				return nil, fmt.Errorf("path %v: %w", pointer, err)
+			}
+			return res, nil
+		}
+		switch c := current.(type) {
+		case map[string]interface{}:
+			child, exists := c[tokens[0]]
+			if !exists {
+				return nil, fmt.Errorf("path %v does not exist", pointer)
+			}
+			newChild, err := modify(child, tokens[1:])
+			if err != nil {
+				return nil, err
+			}
+			c[tokens[0]] = newChild
+			return c, nil
+		case []interface{}:
+			i, err := patchArrayIndex(tokens[0], len(c), false)
+			if err != nil {
+				return nil, fmt.Errorf("path %v: %w", pointer, err)
+			}
+			newChild, err := modify(c[i], tokens[1:])
+			if err != nil {
+				return nil, err
+			}
+			c[i] = newChild
+			return c, nil
+		}
+		return nil, fmt.Errorf("path %v does not exist", pointer)
+	}
+	return modify(root, tokens)
+}
+
+func jsonPatchAdd(root interface{}, pointer string, value interface{}) (interface{}, error) {
+	if pointer == "" {
+		return value, nil
+	}
+	return jsonPatchModify(root, pointer, func(container interface{}, key string) (interface{}, error) {
+		switch c := container.(type) {
+		case map[string]interface{}:
+			c[key] = value
+			return c, nil
+		case []interface{}:
+			i, err := patchArrayIndex(key, len(c), true)
+			if err != nil {
+				return nil, err
+			}
+			c = append(c, nil)
+			copy(c[i+1:], c[i:])
+			c[i] = value
+			return c, nil
+		}
+		return nil, errors.New("parent is not an object or array")
+	})
+}
+
+func jsonPatchRemove(root interface{}, pointer string) (interface{}, error) {
+	if pointer == "" {
+		return nil, errors.New("cannot remove the root of the document")
+	}
+	return jsonPatchModify(root, pointer, func(container interface{}, key string) (interface{}, error) {
+		switch c := container.(type) {
+		case map[string]interface{}:
+			if _, exists := c[key]; !exists {
+				return nil, errors.New("value does not exist")
+			}
+			delete(c, key)
+			return c, nil
+		case []interface{}:
+			i, err := patchArrayIndex(key, len(c), false)
+			if err != nil {
+				return nil, err
+			}
+			return append(c[:i], c[i+1:]...), nil
+		}
+		return nil, errors.New("parent is not an object or array")
+	})
+}
+
+func jsonPatchReplace(root interface{}, pointer string, value interface{}) (interface{}, error) {
+	if pointer == "" {
+		return value, nil
+	}
+	return jsonPatchModify(root, pointer, func(container interface{}, key string) (interface{}, error) {
+		switch c := container.(type) {
+		case map[string]interface{}:
+			if _, exists := c[key]; !exists {
+				return nil, errors.New("value does not exist")
+			}
+			c[key] = value
+			return c, nil
+		case []interface{}:
+			i, err := patchArrayIndex(key, len(c), false)
+			if err != nil {
+				return nil, err
+			}
+			c[i] = value
+			return c, nil
+		}
+		return nil, errors.New("parent is not an object or array")
+	})
+}
+
+func jsonPatchApplyOp(root interface{}, opObj map[string]interface{}) (interface{}, error) {
+	getStr := func(field string) (string, error) {
+		v, exists := opObj[field]
+		if !exists {
+			return "", fmt.Errorf("missing field: %v", field)
+		}
+		s, ok := v.(string)
+		if !ok {
+			return "", fmt.Errorf("field %v: %w", field, NewTypeError(v, ValueString))
+		}
+		return s, nil
+	}
+	getValue := func() (interface{}, error) {
+		v, exists := opObj["value"]
+		if !exists {
+			return nil, errors.New("missing field: value")
+		}
+		return IClone(v), nil
+	}
+
+	op, err := getStr("op")
+	if err != nil {
+		return nil, err
+	}
+	path, err := getStr("path")
+	if err != nil {
+		return nil, err
+	}
+
+	switch op {
+	case "add":
+		value, err := getValue()
+		if err != nil {
+			return nil, err
+		}
+		return jsonPatchAdd(root, path, value)
+	case "remove":
+		return jsonPatchRemove(root, path)
+	case "replace":
+		value, err := getValue()
+		if err != nil {
+			return nil, err
+		}
+		return jsonPatchReplace(root, path, value)
+	case "move", "copy":
+		from, err := getStr("from")
+		if err != nil {
+			return nil, err
+		}
+		value, err := jsonPatchGet(root, from)
+		if err != nil {
+			return nil, err
+		}
+		if op == "copy" {
+			return jsonPatchAdd(root, path, IClone(value))
+		}
+		if from == path {
+			return root, nil
+		}
+		if strings.HasPrefix(path, from+"/") {
+			return nil, fmt.Errorf("cannot move path %v into its own child %v", from, path)
+		}
+		if root, err = jsonPatchRemove(root, from); err != nil {
+			return nil, err
+		}
+		return jsonPatchAdd(root, path, value)
+	case "test":
+		expected, exists := opObj["value"]
+		if !exists {
+			return nil, errors.New("missing field: value")
+		}
+		actual, err := jsonPatchGet(root, path)
+		if err != nil {
+			return nil, fmt.Errorf("test failed: %w", err)
+		}
+		if !patchValuesEqual(expected, actual) {
+			return nil, fmt.Errorf("test failed at path %v: expected %v, got %v", path, patchValueString(expected), patchValueString(actual))
+		}
+		return root, nil
+	}
+	return nil, fmt.Errorf("unrecognised operation: %v", op)
+}
+
+// jsonPatchApply applies a list of JSON Patch operations to a value, returning
+// the patched result. The value provided is not modified.
+func jsonPatchApply(root interface{}, ops []interface{}) (interface{}, error) {
+	root = IClone(root)
+	for i, op := range ops {
+		opObj, ok := op.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("operation %v: %w", i, NewTypeError(op, ValueObject))
+		}
+		var err error
+		if root, err = jsonPatchApplyOp(root, opObj); err != nil {
+			return nil, fmt.Errorf("operation %v: %w", i, err)
+		}
+	}
+	return root, nil
+}
+
+//------------------------------------------------------------------------------
+
+// jsonMergePatch applies a JSON Merge Patch document to a value, returning the
+// patched result. The value provided is not modified.
+func jsonMergePatch(target, patch interface{}) interface{} {
+	patchObj, ok := patch.(map[string]interface{})
+	if !ok {
+		return IClone(patch)
+	}
+
+	result := map[string]interface{}{}
+	if targetObj, ok := target.(map[string]interface{}); ok {
+		for k, v := range targetObj {
+			result[k] = v
+		}
+	}
+	for k, v := range patchObj {
+		if v == nil {
+			delete(result, k)
+			continue
+		}
+		result[k] = jsonMergePatch(result[k], v)
+	}
+	return result
+}
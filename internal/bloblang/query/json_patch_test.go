@@ -0,0 +1,258 @@
+package query
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func parseTestJSON(t *testing.T, s string) interface{} {
+	t.Helper()
+	var v interface{}
+	require.NoError(t, json.Unmarshal([]byte(s), &v))
+	return v
+}
+
+func TestJSONPatchApply(t *testing.T) {
+	tests := map[string]struct {
+		doc    string
+		ops    string
+		output string
+		err    string
+	}{
+		"add object member": {
+			doc:    `{"foo":"bar"}`,
+			ops:    `[{"op":"add","path":"/baz","value":"qux"}]`,
+			output: `{"baz":"qux","foo":"bar"}`,
+		},
+		"add array element": {
+			doc:    `{"foo":["bar","baz"]}`,
+			ops:    `[{"op":"add","path":"/foo/1","value":"qux"}]`,
+			output: `{"foo":["bar","qux","baz"]}`,
+		},
+		"append array element": {
+			doc:    `{"foo":["bar"]}`,
+			ops:    `[{"op":"add","path":"/foo/-","value":["abc","def"]}]`,
+			output: `{"foo":["bar",["abc","def"]]}`,
+		},
+		"remove object member": {
+			doc:    `{"baz":"qux","foo":"bar"}`,
+			ops:    `[{"op":"remove","path":"/baz"}]`,
+			output: `{"foo":"bar"}`,
+		},
+		"remove array element": {
+			doc:    `{"foo":["bar","qux","baz"]}`,
+			ops:    `[{"op":"remove","path":"/foo/1"}]`,
+			output: `{"foo":["bar","baz"]}`,
+		},
+		"replace value": {
+			doc:    `{"baz":"qux","foo":"bar"}`,
+			ops:    `[{"op":"replace","path":"/baz","value":"boo"}]`,
+			output: `{"baz":"boo","foo":"bar"}`,
+		},
+		"replace root": {
+			doc:    `{"foo":"bar"}`,
+			ops:    `[{"op":"replace","path":"","value":[1,2]}]`,
+			output: `[1,2]`,
+		},
+		"move value": {
+			doc:    `{"foo":{"bar":"baz","waldo":"fred"},"qux":{"corge":"grault"}}`,
+			ops:    `[{"op":"move","from":"/foo/waldo","path":"/qux/thud"}]`,
+			output: `{"foo":{"bar":"baz"},"qux":{"corge":"grault","thud":"fred"}}`,
+		},
+		"move array element": {
+			doc:    `{"foo":["all","grass","cows","eat"]}`,
+			ops:    `[{"op":"move","from":"/foo/1","path":"/foo/3"}]`,
+			output: `{"foo":["all","cows","eat","grass"]}`,
+		},
+		"copy value": {
+			doc:    `{"foo":{"bar":"baz"}}`,
+			ops:    `[{"op":"copy","from":"/foo","path":"/qux"},{"op":"add","path":"/qux/bar","value":"buz"}]`,
+			output: `{"foo":{"bar":"baz"},"qux":{"bar":"buz"}}`,
+		},
+		"test success": {
+			doc:    `{"baz":"qux","foo":["a",2,"c"]}`,
+			ops:    `[{"op":"test","path":"/baz","value":"qux"},{"op":"test","path":"/foo/1","value":2}]`,
+			output: `{"baz":"qux","foo":["a",2,"c"]}`,
+		},
+		"escaped paths": {
+			doc:    `{"/":9,"~1":10}`,
+			ops:    `[{"op":"test","path":"/~01","value":10},{"op":"remove","path":"/~1"}]`,
+			output: `{"~1":10}`,
+		},
+		"test failure": {
+			doc: `{"baz":"qux"}`,
+			ops: `[{"op":"test","path":"/baz","value":"bar"}]`,
+			err: `operation 0: test failed at path /baz: expected "bar", got "qux"`,
+		},
+		"test missing path": {
+			doc: `{"baz":"qux"}`,
+			ops: `[{"op":"add","path":"/foo","value":1},{"op":"test","path":"/nope","value":"bar"}]`,
+			err: `operation 1: test failed: path /nope does not exist`,
+		},
+		"add to missing parent": {
+			doc: `{"foo":"bar"}`,
+			ops: `[{"op":"add","path":"/baz/bat","value":"qux"}]`,
+			err: `operation 0: path /baz/bat does not exist`,
+		},
+		"add out of bounds": {
+			doc: `{"foo":["bar"]}`,
+			ops: `[{"op":"add","path":"/foo/2","value":"qux"}]`,
+			err: `operation 0: path /foo/2: array index 2 is out of bounds`,
+		},
+		"remove end of array": {
+			doc: `{"foo":["bar"]}`,
+			ops: `[{"op":"remove","path":"/foo/-"}]`,
+			err: `operation 0: path /foo/-: invalid array index: -`,
+		},
+		"leading zero index": {
+			doc: `{"foo":["bar","baz"]}`,
+			ops: `[{"op":"replace","path":"/foo/01","value":"qux"}]`,
+			err: `operation 0: path /foo/01: invalid array index: 01`,
+		},
+		"remove missing value": {
+			doc: `{"foo":"bar"}`,
+			ops: `[{"op":"remove","path":"/baz"}]`,
+			err: `operation 0: path /baz: value does not exist`,
+		},
+		"move into child": {
+			doc: `{"foo":{"bar":"baz"}}`,
+			ops: `[{"op":"move","from":"/foo","path":"/foo/bar/baz"}]`,
+			err: `operation 0: cannot move path /foo into its own child /foo/bar/baz`,
+		},
+		"missing value": {
+			doc: `{}`,
+			ops: `[{"op":"add","path":"/foo"}]`,
+			err: `operation 0: missing field: value`,
+		},
+		"unknown operation": {
+			doc: `{}`,
+			ops: `[{"op":"nope","path":"/foo"}]`,
+			err: `operation 0: unrecognised operation: nope`,
+		},
+		"invalid path": {
+			doc: `{}`,
+			ops: `[{"op":"add","path":"foo","value":1}]`,
+			err: `operation 0: invalid path foo: a non-empty path must begin with a forward slash`,
+		},
+		"operation not an object": {
+			doc: `{}`,
+			ops: `["nope"]`,
+			err: `operation 0: expected object value, got string ("nope")`,
+		},
+	}
+
+	for name, test := range tests {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			doc := parseTestJSON(t, test.doc)
+			docClone := IClone(doc)
+
+			res, err := jsonPatchApply(doc, parseTestJSON(t, test.ops).([]interface{}))
+			assert.Equal(t, docClone, doc)
+			if test.err != "" {
+				require.Error(t, err)
+				assert.Equal(t, test.err, err.Error())
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, parseTestJSON(t, test.output), res)
+		})
+	}
+}
+
+func TestJSONPatchDiff(t *testing.T) {
+	tests := map[string]struct {
+		from string
+		to   string
+		ops  string
+	}{
+		"equal": {
+			from: `{"a":[1,{"b":"c"}]}`,
+			to:   `{"a":[1,{"b":"c"}]}`,
+			ops:  `null`,
+		},
+		"object changes": {
+			from: `{"a":"b","c":{"d":"e"},"f":"g"}`,
+			to:   `{"a":"b","c":{"d":"h"},"i":"j"}`,
+			ops:  `[{"op":"replace","path":"/c/d","value":"h"},{"op":"remove","path":"/f"},{"op":"add","path":"/i","value":"j"}]`,
+		},
+		"array growth": {
+			from: `[1,2]`,
+			to:   `[1,3,4,5]`,
+			ops:  `[{"op":"replace","path":"/1","value":3},{"op":"add","path":"/2","value":4},{"op":"add","path":"/3","value":5}]`,
+		},
+		"array shrink": {
+			from: `[1,2,3,4]`,
+			to:   `[0]`,
+			ops:  `[{"op":"replace","path":"/0","value":0},{"op":"remove","path":"/3"},{"op":"remove","path":"/2"},{"op":"remove","path":"/1"}]`,
+		},
+		"type change": {
+			from: `{"a":[1]}`,
+			to:   `{"a":{"b":1}}`,
+			ops:  `[{"op":"replace","path":"/a","value":{"b":1}}]`,
+		},
+		"escaped keys": {
+			from: `{"a/b":1,"c~d":2}`,
+			to:   `{"a/b":2}`,
+			ops:  `[{"op":"replace","path":"/a~1b","value":2},{"op":"remove","path":"/c~0d"}]`,
+		},
+	}
+
+	for name, test := range tests {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			from, to := parseTestJSON(t, test.from), parseTestJSON(t, test.to)
+
+			ops := jsonPatchDiff("", from, to)
+			if exp := parseTestJSON(t, test.ops); exp == nil {
+				assert.Empty(t, ops)
+			} else {
+				assert.Equal(t, exp, ops)
+			}
+
+			res, err := jsonPatchApply(from, ops)
+			require.NoError(t, err)
+			assert.Equal(t, to, res)
+		})
+	}
+
+	// Numbers of different types are compared by value.
+	assert.Empty(t, jsonPatchDiff("", map[string]interface{}{"a": int64(5)}, map[string]interface{}{"a": 5.0}))
+}
+
+func TestJSONMergePatch(t *testing.T) {
+	// Test cases from RFC7386 Appendix A
+	tests := []struct {
+		target string
+		patch  string
+		output string
+	}{
+		{`{"a":"b"}`, `{"a":"c"}`, `{"a":"c"}`},
+		{`{"a":"b"}`, `{"b":"c"}`, `{"a":"b","b":"c"}`},
+		{`{"a":"b"}`, `{"a":null}`, `{}`},
+		{`{"a":"b","b":"c"}`, `{"a":null}`, `{"b":"c"}`},
+		{`{"a":["b"]}`, `{"a":"c"}`, `{"a":"c"}`},
+		{`{"a":"c"}`, `{"a":["b"]}`, `{"a":["b"]}`},
+		{`{"a":{"b":"c"}}`, `{"a":{"b":"d","c":null}}`, `{"a":{"b":"d"}}`},
+		{`{"a":[{"b":"c"}]}`, `{"a":[1]}`, `{"a":[1]}`},
+		{`["a","b"]`, `["c","d"]`, `["c","d"]`},
+		{`{"a":"b"}`, `["c"]`, `["c"]`},
+		{`{"a":"foo"}`, `null`, `null`},
+		{`{"a":"foo"}`, `"bar"`, `"bar"`},
+		{`{"e":null}`, `{"a":1}`, `{"e":null,"a":1}`},
+		{`[1,2]`, `{"a":"b","c":null}`, `{"a":"b"}`},
+		{`{}`, `{"a":{"bb":{"ccc":null}}}`, `{"a":{"bb":{}}}`},
+	}
+
+	for _, test := range tests {
+		target := parseTestJSON(t, test.target)
+		targetClone := IClone(target)
+
+		res := jsonMergePatch(target, parseTestJSON(t, test.patch))
+		assert.Equal(t, parseTestJSON(t, test.output), res, "%v merged with %v", test.target, test.patch)
+		assert.Equal(t, targetClone, target)
+	}
+}
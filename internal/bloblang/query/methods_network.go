@@ -0,0 +1,399 @@
+package query
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+)
+
+func parseIP(s string) (net.IP, error) {
+	ip := net.ParseIP(s)
+	if ip == nil {
+		return nil, fmt.Errorf("failed to parse IP address: %v", s)
+	}
+	return ip, nil
+}
+
+func ipMethod(fn func(ip net.IP) (interface{}, error)) simpleMethod {
+	return stringMethod(func(s string) (interface{}, error) {
+		ip, err := parseIP(s)
+		if err != nil {
+			return nil, err
+		}
+		return fn(ip)
+	})
+}
+
+func parseCIDR(s string) (*net.IPNet, error) {
+	_, ipNet, err := net.ParseCIDR(s)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse CIDR: %v", s)
+	}
+	return ipNet, nil
+}
+
+var privateIPNets = func() []*net.IPNet {
+	var nets []*net.IPNet
+	for _, cidr := range []string{
+		"10.0.0.0/8",
+		"172.16.0.0/12",
+		"192.168.0.0/16",
+		"fc00::/7",
+	} {
+		_, ipNet, err := net.ParseCIDR(cidr)
+		if err != nil {
+			panic(err)
+		}
+		nets = append(nets, ipNet)
+	}
+	return nets
+}()
+
+//------------------------------------------------------------------------------
+
+var _ = registerSimpleMethod(
+	NewMethodSpec(
+		"parse_ip", "",
+	).InCategory(
+		MethodCategoryNetwork,
+		"Attempts to parse a string as an IPv4 or IPv6 address and returns it in its canonical form, otherwise an error is returned. This is useful for validating addresses and for normalising IPv6 addresses before they are compared.",
+		NewExampleSpec("",
+			`root.ip = this.ip.parse_ip()`,
+			`{"ip":"192.168.0.1"}`,
+			`{"ip":"192.168.0.1"}`,
+
+			`{"ip":"2001:0DB8:0000:0000:0000:0000:0000:0001"}`,
+			`{"ip":"2001:db8::1"}`,
+
+			`{"ip":"192.168.0.256"}`,
+			`Error("failed assignment (line 1): field `+"`this.ip`"+`: failed to parse IP address: 192.168.0.256")`,
+		),
+	),
+	func(...interface{}) (simpleMethod, error) {
+		return ipMethod(func(ip net.IP) (interface{}, error) {
+			return ip.String(), nil
+		}), nil
+	},
+	false,
+	ExpectNArgs(0),
+)
+
+//------------------------------------------------------------------------------
+
+var _ = registerSimpleMethod(
+	NewMethodSpec(
+		"ip_version", "",
+	).InCategory(
+		MethodCategoryNetwork,
+		"Returns the version of an IP address string as the number `4` or `6`. IPv4 addresses that are mapped to IPv6, such as `::ffff:192.168.0.1`, are version `4`.",
+		NewExampleSpec("",
+			`root.version = this.ip.ip_version()`,
+			`{"ip":"192.168.0.1"}`,
+			`{"version":4}`,
+
+			`{"ip":"2001:db8::1"}`,
+			`{"version":6}`,
+		),
+	),
+	func(...interface{}) (simpleMethod, error) {
+		return ipMethod(func(ip net.IP) (interface{}, error) {
+			if ip.To4() != nil {
+				return int64(4), nil
+			}
+			return int64(6), nil
+		}), nil
+	},
+	false,
+	ExpectNArgs(0),
+)
+
+//------------------------------------------------------------------------------
+
+var _ = registerSimpleMethod(
+	NewMethodSpec(
+		"ip_is_private", "",
+	).InCategory(
+		MethodCategoryNetwork,
+		"Checks whether an IP address string is within a private address range, which are the IPv4 ranges defined in RFC1918 (`10.0.0.0/8`, `172.16.0.0/12` and `192.168.0.0/16`) and the IPv6 unique local address range defined in RFC4193 (`fc00::/7`).",
+		NewExampleSpec("",
+			`root.private = this.ip.ip_is_private()`,
+			`{"ip":"172.16.10.5"}`,
+			`{"private":true}`,
+
+			`{"ip":"8.8.8.8"}`,
+			`{"private":false}`,
+
+			`{"ip":"fd12:3456::1"}`,
+			`{"private":true}`,
+		),
+	),
+	func(...interface{}) (simpleMethod, error) {
+		return ipMethod(func(ip net.IP) (interface{}, error) {
+			for _, ipNet := range privateIPNets {
+				if ipNet.Contains(ip) {
+					return true, nil
+				}
+			}
+			return false, nil
+		}), nil
+	},
+	false,
+	ExpectNArgs(0),
+)
+
+//------------------------------------------------------------------------------
+
+var _ = registerSimpleMethod(
+	NewMethodSpec(
+		"ip_in_cidr", "",
+	).InCategory(
+		MethodCategoryNetwork,
+		"Checks whether an IP address string is within a network range described by a CIDR string argument.",
+		NewExampleSpec("",
+			`root.internal = this.ip.ip_in_cidr("10.0.0.0/16")`,
+			`{"ip":"10.0.12.34"}`,
+			`{"internal":true}`,
+
+			`{"ip":"10.1.12.34"}`,
+			`{"internal":false}`,
+		),
+		NewExampleSpec("",
+			`root.matches = this.ip.ip_in_cidr(this.cidr)`,
+			`{"ip":"2001:db8::1","cidr":"2001:db8::/32"}`,
+			`{"matches":true}`,
+		),
+	),
+	func(args ...interface{}) (simpleMethod, error) {
+		ipNet, err := parseCIDR(args[0].(string))
+		if err != nil {
+			return nil, err
+		}
+		return ipMethod(func(ip net.IP) (interface{}, error) {
+			return ipNet.Contains(ip), nil
+		}), nil
+	},
+	true,
+	ExpectNArgs(1),
+	ExpectStringArg(0),
+)
+
+//------------------------------------------------------------------------------
+
+var _ = registerSimpleMethod(
+	NewMethodSpec(
+		"cidr_contains", "",
+	).InCategory(
+		MethodCategoryNetwork,
+		"Checks whether a network range described by a CIDR string contains an argument, which can either be an IP address string or another CIDR string, in which case the entire range of the argument must be contained.",
+		NewExampleSpec("",
+			`root.contains_ip = this.cidr.cidr_contains("192.168.1.20")
+root.contains_cidr = this.cidr.cidr_contains("192.168.1.0/28")`,
+			`{"cidr":"192.168.0.0/16"}`,
+			`{"contains_cidr":true,"contains_ip":true}`,
+
+			`{"cidr":"192.168.1.16/28"}`,
+			`{"contains_cidr":false,"contains_ip":true}`,
+		),
+	),
+	func(args ...interface{}) (simpleMethod, error) {
+		arg := args[0].(string)
+
+		var contains func(ipNet *net.IPNet) bool
+		if argIP := net.ParseIP(arg); argIP != nil {
+			contains = func(ipNet *net.IPNet) bool {
+				return ipNet.Contains(argIP)
+			}
+		} else {
+			argNet, err := parseCIDR(arg)
+			if err != nil {
+				return nil, errors.New("expected an IP address or CIDR argument")
+			}
+			argOnes, argBits := argNet.Mask.Size()
+			contains = func(ipNet *net.IPNet) bool {
+				ones, bits := ipNet.Mask.Size()
+				return bits == argBits && ones <= argOnes && ipNet.Contains(argNet.IP)
+			}
+		}
+
+		return stringMethod(func(s string) (interface{}, error) {
+			ipNet, err := parseCIDR(s)
+			if err != nil {
+				return nil, err
+			}
+			return contains(ipNet), nil
+		}), nil
+	},
+	true,
+	ExpectNArgs(1),
+	ExpectStringArg(0),
+)
+
+//------------------------------------------------------------------------------
+
+var _ = registerSimpleMethod(
+	NewMethodSpec(
+		"parse_url", "",
+	).InCategory(
+		MethodCategoryNetwork,
+		"Attempts to parse a URL string into an object containing the fields `scheme`, `host`, `port`, `path`, `query` and `fragment`. The `port` is a number, or `null` when the URL does not specify one, and the `query` is an object where each key has a string value, or an array of strings when the key is repeated. When the URL contains user information the fields `user` and, if set, `password` are also added.",
+		NewExampleSpec("",
+			`root = this.url.parse_url()`,
+			`{"url":"https://example.com:8080/foo/bar?a=b&c=d&c=e#baz"}`,
+			`{"fragment":"baz","host":"example.com","path":"/foo/bar","port":8080,"query":{"a":"b","c":["d","e"]},"scheme":"https"}`,
+		),
+		NewExampleSpec("",
+			`root.host = this.url.parse_url().host`,
+			`{"url":"http://[2001:db8::1]/foo"}`,
+			`{"host":"2001:db8::1"}`,
+		),
+	),
+	func(...interface{}) (simpleMethod, error) {
+		return stringMethod(func(s string) (interface{}, error) {
+			u, err := url.Parse(s)
+			if err != nil {
+				return nil, err
+			}
+
+			var port interface{}
+			if portStr := u.Port(); portStr != "" {
+				if port, err = strconv.ParseInt(portStr, 10, 64); err != nil {
+					return nil, fmt.Errorf("failed to parse port: %w", err)
+				}
+			}
+
+			query := map[string]interface{}{}
+			for k, v := range u.Query() {
+				if len(v) == 1 {
+					query[k] = v[0]
+				} else {
+					values := make([]interface{}, len(v))
+					for i, s := range v {
+						values[i] = s
+					}
+					query[k] = values
+				}
+			}
+
+			obj := map[string]interface{}{
+				"scheme":   u.Scheme,
+				"host":     u.Hostname(),
+				"port":     port,
+				"path":     u.Path,
+				"query":    query,
+				"fragment": u.Fragment,
+			}
+			if u.User != nil {
+				obj["user"] = u.User.Username()
+				if password, set := u.User.Password(); set {
+					obj["password"] = password
+				}
+			}
+			return obj, nil
+		}), nil
+	},
+	false,
+	ExpectNArgs(0),
+)
+
+//------------------------------------------------------------------------------
+
+var _ = registerSimpleMethod(
+	NewMethodSpec(
+		"format_url", "",
+	).InCategory(
+		MethodCategoryNetwork,
+		"Formats an object into a URL string, where the object has the same structure as the result of the [`parse_url` method](#parse_url). All fields are optional, and query values can either be a single value or an array of values. Query keys are sorted in the resulting URL.",
+		NewExampleSpec("",
+			`root.url = this.url.parse_url().without("path").merge({"path":"/v2/foo"}).format_url()`,
+			`{"url":"https://example.com:8080/foo?a=b"}`,
+			`{"url":"https://example.com:8080/v2/foo?a=b"}`,
+		),
+		NewExampleSpec("",
+			`root.url = {"scheme":"http","host":"2001:db8::1","port":80,"path":"/foo bar","query":{"tags":["a","b"]}}.format_url()`,
+			`{}`,
+			`{"url":"http://[2001:db8::1]:80/foo%20bar?tags=a&tags=b"}`,
+		),
+	),
+	func(...interface{}) (simpleMethod, error) {
+		return func(v interface{}, ctx FunctionContext) (interface{}, error) {
+			obj, ok := v.(map[string]interface{})
+			if !ok {
+				return nil, NewTypeError(v, ValueObject)
+			}
+
+			getStr := func(key string) (string, error) {
+				v, exists := obj[key]
+				if !exists || v == nil {
+					return "", nil
+				}
+				s, ok := v.(string)
+				if !ok {
+					return "", fmt.Errorf("field %v: %w", key, NewTypeError(v, ValueString))
+				}
+				return s, nil
+			}
+
+			var u url.URL
+			var err error
+			if u.Scheme, err = getStr("scheme"); err != nil {
+				return nil, err
+			}
+			if u.Path, err = getStr("path"); err != nil {
+				return nil, err
+			}
+			if u.Fragment, err = getStr("fragment"); err != nil {
+				return nil, err
+			}
+
+			if u.Host, err = getStr("host"); err != nil {
+				return nil, err
+			}
+			if portV, exists := obj["port"]; exists && portV != nil {
+				port, err := IGetInt(portV)
+				if err != nil {
+					return nil, fmt.Errorf("field port: %w", err)
+				}
+				u.Host = net.JoinHostPort(u.Host, strconv.FormatInt(port, 10))
+			} else if ip := net.ParseIP(u.Host); ip != nil && ip.To4() == nil {
+				u.Host = "[" + u.Host + "]"
+			}
+
+			user, err := getStr("user")
+			if err != nil {
+				return nil, err
+			}
+			if _, exists := obj["password"]; exists {
+				password, err := getStr("password")
+				if err != nil {
+					return nil, err
+				}
+				u.User = url.UserPassword(user, password)
+			} else if user != "" {
+				u.User = url.User(user)
+			}
+
+			if queryV, exists := obj["query"]; exists && queryV != nil {
+				queryObj, ok := queryV.(map[string]interface{})
+				if !ok {
+					return nil, fmt.Errorf("field query: %w", NewTypeError(queryV, ValueObject))
+				}
+				query := url.Values{}
+				for k, v := range queryObj {
+					if values, isArray := v.([]interface{}); isArray {
+						for _, value := range values {
+							query.Add(k, IToString(value))
+						}
+					} else {
+						query.Add(k, IToString(v))
+					}
+				}
+				u.RawQuery = query.Encode()
+			}
+			return u.String(), nil
+		}, nil
+	},
+	false,
+	ExpectNArgs(0),
+)
@@ -950,6 +950,95 @@ func mergeMethod(target Function, args ...interface{}) (Function, error) {
 
 //------------------------------------------------------------------------------
 
+var _ = registerSimpleMethod(
+	NewMethodSpec(
+		"diff", "",
+	).InCategory(
+		MethodCategoryObjectAndArray,
+		`Compares a value with an argument value and returns an array of [JSON Patch (RFC6902)](https://tools.ietf.org/html/rfc6902) operations that transforms the value into the argument. The operations can be applied to the value with the `+"[`patch` method](#patch)"+`.
+
+Object keys are compared in alphabetical order and arrays are compared index by index, elements that are added to or removed from the end of an array result in `+"`add`"+` and `+"`remove`"+` operations respectively.`,
+		NewExampleSpec("",
+			`root = this.before.diff(this.after)`,
+			`{"before":{"name":"foo","tags":["a","b"],"old":true},"after":{"name":"bar","tags":["a","b","c"]}}`,
+			`[{"op":"replace","path":"/name","value":"bar"},{"op":"remove","path":"/old"},{"op":"add","path":"/tags/2","value":"c"}]`,
+		),
+	).Beta(),
+	func(args ...interface{}) (simpleMethod, error) {
+		other := args[0]
+		return func(v interface{}, ctx FunctionContext) (interface{}, error) {
+			ops := jsonPatchDiff("", v, other)
+			if ops == nil {
+				ops = []interface{}{}
+			}
+			return ops, nil
+		}, nil
+	},
+	true,
+	ExpectNArgs(1),
+)
+
+var _ = registerSimpleMethod(
+	NewMethodSpec(
+		"patch", "",
+	).InCategory(
+		MethodCategoryObjectAndArray,
+		`Applies an array of [JSON Patch (RFC6902)](https://tools.ietf.org/html/rfc6902) operations to a value and returns the result. The operations `+"`add`, `remove`, `replace`, `move`, `copy` and `test`"+` are supported, where paths are [JSON Pointers (RFC6901)](https://tools.ietf.org/html/rfc6901) and the array index `+"`-`"+` can be used in order to append to an array.
+
+If an operation fails, including a `+"`test`"+` operation where the value at a path does not match, then an error is returned describing the index of the operation that failed.`,
+		NewExampleSpec("",
+			`root = this.doc.patch(this.ops)`,
+			`{"doc":{"name":"foo","tags":["a"]},"ops":[{"op":"replace","path":"/name","value":"bar"},{"op":"add","path":"/tags/-","value":"b"}]}`,
+			`{"name":"bar","tags":["a","b"]}`,
+		),
+		NewExampleSpec("A `test` operation can be used in order to only apply a patch when a value matches.",
+			`root = this.doc.patch([
+  {"op": "test", "path": "/version", "value": 1},
+  {"op": "replace", "path": "/version", "value": 2}
+])`,
+			`{"doc":{"version":1}}`,
+			`{"version":2}`,
+			`{"doc":{"version":3}}`,
+			`Error("failed assignment (line 1): field `+"`this.doc`"+`: operation 0: test failed at path /version: expected 1, got 3")`,
+		),
+	).Beta(),
+	func(args ...interface{}) (simpleMethod, error) {
+		ops, ok := args[0].([]interface{})
+		if !ok {
+			return nil, NewTypeError(args[0], ValueArray)
+		}
+		return func(v interface{}, ctx FunctionContext) (interface{}, error) {
+			return jsonPatchApply(v, ops)
+		}, nil
+	},
+	true,
+	ExpectNArgs(1),
+)
+
+var _ = registerSimpleMethod(
+	NewMethodSpec(
+		"merge_patch", "",
+	).InCategory(
+		MethodCategoryObjectAndArray,
+		`Applies a [JSON Merge Patch (RFC7386)](https://tools.ietf.org/html/rfc7386) document to a value and returns the result. Object fields of the patch are merged recursively, fields with a `+"`null`"+` value are removed, and any other values, including arrays, replace the existing value entirely.`,
+		NewExampleSpec("",
+			`root = this.doc.merge_patch(this.patch)`,
+			`{"doc":{"name":"foo","meta":{"a":"b","c":"d"},"tags":["a","b"]},"patch":{"meta":{"c":null,"e":"f"},"tags":["c"]}}`,
+			`{"meta":{"a":"b","e":"f"},"name":"foo","tags":["c"]}`,
+		),
+	).Beta(),
+	func(args ...interface{}) (simpleMethod, error) {
+		patch := args[0]
+		return func(v interface{}, ctx FunctionContext) (interface{}, error) {
+			return jsonMergePatch(v, patch), nil
+		}, nil
+	},
+	true,
+	ExpectNArgs(1),
+)
+
+//------------------------------------------------------------------------------
+
 var _ = registerSimpleMethod(
 	NewMethodSpec(
 		"not_empty", "",
@@ -0,0 +1,92 @@
+package query
+
+import (
+	"errors"
+	"fmt"
+)
+
+// UserDefinition describes a function or method declared within a mapping. The
+// body of a definition is executed with each of its parameters available as a
+// named context.
+type UserDefinition struct {
+	Name   string
+	Params []string
+
+	// Body is set once the definition has been parsed, which allows a
+	// definition to reference itself.
+	Body Function
+}
+
+func (d *UserDefinition) checkArgs(args []Function) error {
+	if len(args) != len(d.Params) {
+		return fmt.Errorf("expected %v arguments, received: %v", len(d.Params), len(args))
+	}
+	return nil
+}
+
+func (d *UserDefinition) resolveArgs(ctx FunctionContext, args []Function) ([]interface{}, error) {
+	values := make([]interface{}, len(args))
+	for i, arg := range args {
+		v, err := arg.Exec(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("argument %v: %w", d.Params[i], err)
+		}
+		values[i] = v
+	}
+	return values, nil
+}
+
+func (d *UserDefinition) exec(ctx FunctionContext, values []interface{}) (interface{}, error) {
+	if d.Body == nil {
+		return nil, errors.New("definition has no body")
+	}
+
+	// Variables declared by the caller with `let` are not visible within the
+	// body of the definition, which only has access to its own parameters.
+	ctx.Vars = map[string]interface{}{}
+	for i, p := range d.Params {
+		ctx = ctx.WithNamedValue(p, values[i])
+	}
+	return d.Body.Exec(ctx)
+}
+
+// NewUserFunction creates a query function that executes a user defined
+// function with a list of arguments, which are resolved within the context of
+// the caller. The body of the function is also executed with the context of the
+// caller.
+func NewUserFunction(def *UserDefinition, args ...Function) (Function, error) {
+	if err := def.checkArgs(args); err != nil {
+		return nil, err
+	}
+
+	// Targets of the body are not included as a definition may be recursive.
+	return ClosureFunction("func "+def.Name, func(ctx FunctionContext) (interface{}, error) {
+		values, err := def.resolveArgs(ctx, args)
+		if err != nil {
+			return nil, err
+		}
+		return def.exec(ctx, values)
+	}, aggregateTargetPaths(args...)), nil
+}
+
+// NewUserMethod creates a query function that executes a user defined method
+// with a list of arguments, which are resolved within the context of the
+// caller. The body of the method is executed with the result of the target
+// function as its context.
+func NewUserMethod(def *UserDefinition, target Function, args ...Function) (Function, error) {
+	if err := def.checkArgs(args); err != nil {
+		return nil, err
+	}
+
+	return ClosureFunction("method "+def.Name, func(ctx FunctionContext) (interface{}, error) {
+		res, err := target.Exec(ctx)
+		if err != nil {
+			return nil, err
+		}
+		values, err := def.resolveArgs(ctx, args)
+		if err != nil {
+			return nil, err
+		}
+		return def.exec(ctx.WithValue(res), values)
+	}, aggregateTargetPaths(append([]Function{target}, args...)...)), nil
+}
@@ -0,0 +1,301 @@
+// Package circuitbreaker implements a circuit breaker that protects downstream
+// services by failing fast once they appear to be unhealthy.
+package circuitbreaker
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/Jeffail/benthos/v3/internal/docs"
+	"github.com/Jeffail/benthos/v3/lib/metrics"
+)
+
+// ErrOpen is returned when a request is rejected due to the circuit breaker
+// being open.
+var ErrOpen = errors.New("circuit breaker is open")
+
+// State represents the current state of a circuit breaker.
+type State int
+
+// The possible states of a circuit breaker, the numeric values are those
+// exposed by the state gauge metric.
+const (
+	StateClosed   State = 0
+	StateHalfOpen State = 1
+	StateOpen     State = 2
+)
+
+// String returns a human readable name of the state.
+func (s State) String() string {
+	switch s {
+	case StateClosed:
+		return "closed"
+	case StateHalfOpen:
+		return "half_open"
+	case StateOpen:
+		return "open"
+	}
+	return "unknown"
+}
+
+//------------------------------------------------------------------------------
+
+// Config contains configuration fields for a circuit breaker.
+type Config struct {
+	ConsecutiveFailures int     `json:"consecutive_failures" yaml:"consecutive_failures"`
+	ErrorRate           float64 `json:"error_rate" yaml:"error_rate"`
+	Window              string  `json:"window" yaml:"window"`
+	MinRequests         int     `json:"min_requests" yaml:"min_requests"`
+	OpenTimeout         string  `json:"open_timeout" yaml:"open_timeout"`
+	HalfOpenRequests    int     `json:"half_open_requests" yaml:"half_open_requests"`
+}
+
+// NewConfig returns a Config with default values.
+func NewConfig() Config {
+	return Config{
+		ConsecutiveFailures: 5,
+		ErrorRate:           0,
+		Window:              "1m",
+		MinRequests:         10,
+		OpenTimeout:         "30s",
+		HalfOpenRequests:    1,
+	}
+}
+
+// FieldSpecs returns documentation specs for circuit breaker fields.
+func FieldSpecs() docs.FieldSpecs {
+	return docs.FieldSpecs{
+		docs.FieldCommon("consecutive_failures", "The number of consecutive failed requests after which the circuit breaker trips open. Set to zero in order to disable this condition.").HasType(docs.FieldNumber),
+		docs.FieldAdvanced("error_rate", "A ratio of failed requests between 0 and 1 within the `window` after which the circuit breaker trips open. Set to zero in order to disable this condition.", 0.5).HasType(docs.FieldNumber),
+		docs.FieldAdvanced("window", "The rolling period of time over which the `error_rate` is calculated.").HasType(docs.FieldString),
+		docs.FieldAdvanced("min_requests", "The minimum number of requests that must be made within the `window` before the `error_rate` condition is evaluated.").HasType(docs.FieldNumber),
+		docs.FieldCommon("open_timeout", "The period of time to remain open, where requests fail immediately, before attempting half-open probe requests.").HasType(docs.FieldString),
+		docs.FieldAdvanced("half_open_requests", "The number of probe requests permitted whilst half-open. If all probes succeed the circuit breaker closes, and if any fail it opens again.").HasType(docs.FieldNumber),
+	}
+}
+
+//------------------------------------------------------------------------------
+
+const windowBuckets = 10
+
+type windowBucket struct {
+	start     time.Time
+	successes int
+	failures  int
+}
+
+// Breaker is a circuit breaker that trips open after either a number of
+// consecutive failures or a rate of failures within a rolling window. Whilst
+// open all requests are rejected until a timeout elapses, at which point a
+// limited number of probe requests are permitted (half-open) in order to
+// determine whether to close the breaker again. Breaker is safe for concurrent
+// use.
+type Breaker struct {
+	consecutiveLimit int
+	errorRate        float64
+	minRequests      int
+	bucketPeriod     time.Duration
+	openTimeout      time.Duration
+	halfOpenRequests int
+
+	mut         sync.Mutex
+	state       State
+	openedAt    time.Time
+	consecutive int
+	buckets     [windowBuckets]windowBucket
+	probes      int
+	probesOK    int
+
+	now func() time.Time
+
+	mState    metrics.StatGauge
+	mTripped  metrics.StatCounter
+	mRejected metrics.StatCounter
+	mHalfOpen metrics.StatCounter
+	mClosed   metrics.StatCounter
+}
+
+// New creates a circuit breaker from a config, state changes are exposed as
+// metrics under the path `circuit_breaker`.
+func New(conf Config, stats metrics.Type) (*Breaker, error) {
+	if conf.ConsecutiveFailures < 0 {
+		return nil, errors.New("consecutive_failures must not be negative")
+	}
+	if conf.ErrorRate < 0 || conf.ErrorRate > 1 {
+		return nil, errors.New("error_rate must be between 0 and 1")
+	}
+	if conf.ConsecutiveFailures == 0 && conf.ErrorRate == 0 {
+		return nil, errors.New("either consecutive_failures or error_rate must be set")
+	}
+	if conf.HalfOpenRequests <= 0 {
+		return nil, errors.New("half_open_requests must be larger than zero")
+	}
+
+	window, err := time.ParseDuration(conf.Window)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse window: %v", err)
+	}
+	if window <= 0 {
+		return nil, errors.New("window must be larger than zero")
+	}
+
+	openTimeout, err := time.ParseDuration(conf.OpenTimeout)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse open_timeout: %v", err)
+	}
+
+	b := &Breaker{
+		consecutiveLimit: conf.ConsecutiveFailures,
+		errorRate:        conf.ErrorRate,
+		minRequests:      conf.MinRequests,
+		bucketPeriod:     window / windowBuckets,
+		openTimeout:      openTimeout,
+		halfOpenRequests: conf.HalfOpenRequests,
+
+		now: time.Now,
+
+		mState:    stats.GetGauge("circuit_breaker.state"),
+		mTripped:  stats.GetCounter("circuit_breaker.tripped"),
+		mRejected: stats.GetCounter("circuit_breaker.rejected"),
+		mHalfOpen: stats.GetCounter("circuit_breaker.half_open"),
+		mClosed:   stats.GetCounter("circuit_breaker.closed"),
+	}
+	if b.bucketPeriod <= 0 {
+		b.bucketPeriod = 1
+	}
+	b.mState.Set(int64(StateClosed))
+	return b, nil
+}
+
+//------------------------------------------------------------------------------
+
+// State returns the current state of the circuit breaker.
+func (b *Breaker) State() State {
+	b.mut.Lock()
+	defer b.mut.Unlock()
+
+	b.checkOpenTimeout(b.now())
+	return b.state
+}
+
+// Allow checks whether a request may be attempted. Returns ErrOpen if the
+// circuit breaker is open, or if it is half-open and the maximum number of
+// probe requests are already in flight. Each call that returns nil must be
+// followed by a call to either Success or Failure.
+func (b *Breaker) Allow() error {
+	b.mut.Lock()
+	defer b.mut.Unlock()
+
+	b.checkOpenTimeout(b.now())
+
+	switch b.state {
+	case StateOpen:
+		b.mRejected.Incr(1)
+		return ErrOpen
+	case StateHalfOpen:
+		if b.probes >= b.halfOpenRequests {
+			b.mRejected.Incr(1)
+			return ErrOpen
+		}
+		b.probes++
+	}
+	return nil
+}
+
+// Success records a successful request.
+func (b *Breaker) Success() {
+	b.mut.Lock()
+	defer b.mut.Unlock()
+
+	now := b.now()
+	switch b.state {
+	case StateHalfOpen:
+		b.probesOK++
+		if b.probesOK >= b.halfOpenRequests {
+			b.setState(StateClosed, now)
+		}
+	case StateClosed:
+		b.consecutive = 0
+		b.bucket(now).successes++
+	}
+}
+
+// Failure records a failed request.
+func (b *Breaker) Failure() {
+	b.mut.Lock()
+	defer b.mut.Unlock()
+
+	now := b.now()
+	switch b.state {
+	case StateHalfOpen:
+		b.setState(StateOpen, now)
+	case StateClosed:
+		b.consecutive++
+		b.bucket(now).failures++
+		if b.shouldTrip(now) {
+			b.setState(StateOpen, now)
+		}
+	}
+}
+
+//------------------------------------------------------------------------------
+
+func (b *Breaker) checkOpenTimeout(now time.Time) {
+	if b.state == StateOpen && now.Sub(b.openedAt) >= b.openTimeout {
+		b.setState(StateHalfOpen, now)
+	}
+}
+
+func (b *Breaker) setState(s State, now time.Time) {
+	b.state = s
+	b.probes, b.probesOK = 0, 0
+	switch s {
+	case StateOpen:
+		b.openedAt = now
+		b.mTripped.Incr(1)
+	case StateHalfOpen:
+		b.mHalfOpen.Incr(1)
+	case StateClosed:
+		b.consecutive = 0
+		b.buckets = [windowBuckets]windowBucket{}
+		b.mClosed.Incr(1)
+	}
+	b.mState.Set(int64(s))
+}
+
+// bucket returns the window bucket that the current time belongs to, resetting
+// it if the bucket was last used in a previous window.
+func (b *Breaker) bucket(now time.Time) *windowBucket {
+	start := now.Truncate(b.bucketPeriod)
+	bucket := &b.buckets[(start.UnixNano()/int64(b.bucketPeriod))%windowBuckets]
+	if !bucket.start.Equal(start) {
+		*bucket = windowBucket{start: start}
+	}
+	return bucket
+}
+
+func (b *Breaker) shouldTrip(now time.Time) bool {
+	if b.consecutiveLimit > 0 && b.consecutive >= b.consecutiveLimit {
+		return true
+	}
+	if b.errorRate <= 0 {
+		return false
+	}
+
+	windowStart := now.Truncate(b.bucketPeriod).Add(-b.bucketPeriod * (windowBuckets - 1))
+	var successes, failures int
+	for _, bucket := range b.buckets {
+		if bucket.start.Before(windowStart) {
+			continue
+		}
+		successes += bucket.successes
+		failures += bucket.failures
+	}
+	total := successes + failures
+	if total == 0 || total < b.minRequests {
+		return false
+	}
+	return float64(failures)/float64(total) >= b.errorRate
+}
@@ -0,0 +1,151 @@
+package circuitbreaker
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Jeffail/benthos/v3/lib/metrics"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeClock struct {
+	t time.Time
+}
+
+func (f *fakeClock) now() time.Time {
+	return f.t
+}
+
+func newTestBreaker(t *testing.T, conf Config) (*Breaker, *fakeClock, metrics.Type) {
+	t.Helper()
+
+	stats := metrics.NewLocal()
+	b, err := New(conf, stats)
+	require.NoError(t, err)
+
+	clock := &fakeClock{t: time.Unix(1000, 0)}
+	b.now = clock.now
+	return b, clock, stats
+}
+
+func TestBreakerConfigErrors(t *testing.T) {
+	for _, fn := range []func(c *Config){
+		func(c *Config) { c.ConsecutiveFailures = -1 },
+		func(c *Config) { c.ErrorRate = 1.5 },
+		func(c *Config) { c.ConsecutiveFailures = 0 },
+		func(c *Config) { c.HalfOpenRequests = 0 },
+		func(c *Config) { c.Window = "nope" },
+		func(c *Config) { c.Window = "0s" },
+		func(c *Config) { c.OpenTimeout = "nope" },
+	} {
+		conf := NewConfig()
+		fn(&conf)
+		_, err := New(conf, metrics.Noop())
+		assert.Error(t, err)
+	}
+}
+
+func TestBreakerConsecutiveFailures(t *testing.T) {
+	conf := NewConfig()
+	conf.ConsecutiveFailures = 3
+	conf.OpenTimeout = "10s"
+
+	b, clock, stats := newTestBreaker(t, conf)
+
+	for i := 0; i < 2; i++ {
+		require.NoError(t, b.Allow())
+		b.Failure()
+	}
+	require.NoError(t, b.Allow())
+	b.Success()
+
+	for i := 0; i < 2; i++ {
+		require.NoError(t, b.Allow())
+		b.Failure()
+	}
+	assert.Equal(t, StateClosed, b.State())
+
+	require.NoError(t, b.Allow())
+	b.Failure()
+	assert.Equal(t, StateOpen, b.State())
+	assert.Equal(t, ErrOpen, b.Allow())
+
+	clock.t = clock.t.Add(time.Second * 9)
+	assert.Equal(t, ErrOpen, b.Allow())
+
+	clock.t = clock.t.Add(time.Second)
+	assert.Equal(t, StateHalfOpen, b.State())
+
+	local := stats.(*metrics.Local)
+	assert.Equal(t, int64(1), local.GetCounters()["circuit_breaker.tripped"])
+	assert.Equal(t, int64(2), local.GetCounters()["circuit_breaker.rejected"])
+	assert.Equal(t, int64(StateHalfOpen), local.GetCounters()["circuit_breaker.state"])
+}
+
+func TestBreakerHalfOpen(t *testing.T) {
+	conf := NewConfig()
+	conf.ConsecutiveFailures = 1
+	conf.OpenTimeout = "1s"
+	conf.HalfOpenRequests = 2
+
+	b, clock, _ := newTestBreaker(t, conf)
+
+	require.NoError(t, b.Allow())
+	b.Failure()
+	assert.Equal(t, StateOpen, b.State())
+
+	// A failed probe opens the breaker again.
+	clock.t = clock.t.Add(time.Second)
+	require.NoError(t, b.Allow())
+	b.Failure()
+	assert.Equal(t, StateOpen, b.State())
+
+	clock.t = clock.t.Add(time.Second)
+	require.NoError(t, b.Allow())
+	require.NoError(t, b.Allow())
+	assert.Equal(t, ErrOpen, b.Allow())
+
+	b.Success()
+	assert.Equal(t, StateHalfOpen, b.State())
+	b.Success()
+	assert.Equal(t, StateClosed, b.State())
+
+	require.NoError(t, b.Allow())
+}
+
+func TestBreakerErrorRate(t *testing.T) {
+	conf := NewConfig()
+	conf.ConsecutiveFailures = 0
+	conf.ErrorRate = 0.5
+	conf.MinRequests = 4
+	conf.Window = "10s"
+
+	b, clock, _ := newTestBreaker(t, conf)
+
+	// Failures beneath min_requests do not trip.
+	for i := 0; i < 3; i++ {
+		require.NoError(t, b.Allow())
+		b.Failure()
+	}
+	assert.Equal(t, StateClosed, b.State())
+
+	// Failures that leave the window are forgotten.
+	clock.t = clock.t.Add(time.Second * 11)
+	for i := 0; i < 3; i++ {
+		require.NoError(t, b.Allow())
+		b.Success()
+	}
+	require.NoError(t, b.Allow())
+	b.Failure()
+	assert.Equal(t, StateClosed, b.State())
+
+	clock.t = clock.t.Add(time.Second * 2)
+	require.NoError(t, b.Allow())
+	b.Failure()
+	assert.Equal(t, StateClosed, b.State())
+
+	require.NoError(t, b.Allow())
+	b.Failure()
+	assert.Equal(t, StateOpen, b.State())
+}
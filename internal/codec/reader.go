@@ -12,9 +12,11 @@ import (
 	"io"
 	"io/ioutil"
 	"path/filepath"
+	"regexp"
 	"strconv"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/Jeffail/benthos/v3/internal/docs"
 	"github.com/Jeffail/benthos/v3/lib/message"
@@ -32,6 +34,7 @@ var ReaderDocs = docs.FieldCommon(
 	"delim:x", "Consume the file in segments divided by a custom delimiter.",
 	"gzip", "Decompress a gzip file, this codec should precede another codec, e.g. `gzip/all-bytes`, `gzip/tar`, `gzip/csv`, etc.",
 	"lines", "Consume the file in segments divided by linebreaks.",
+	"multiline:x", "EXPERIMENTAL: Consume lines and join consecutive lines into a single message, which is useful for records that span multiple lines such as stack traces. The codec takes the form `multiline:start:<regexp>`, where a line matching the regular expression begins a new message, or `multiline:continue:<regexp>`, where a line matching the regular expression is appended to the current message. Options for the maximum number of lines per message (default 1000, 0 for unlimited) and the period to wait for further lines before a message is flushed (default 1s) can be specified before the mode, e.g. `multiline:max_lines=200:timeout=500ms:start:^\\S`. The regular expression consumes the remainder of the codec and so this must be the final codec of a chain, where preceding codecs such as `gzip` are supported, and when not preceded by a structured codec lines are consumed.",
 	"multipart", "Consumes the output of another codec and batches messages together. A batch ends when an empty message is consumed. For example, the codec `lines/multipart` could be used to consume multipart messages where an empty line indicates the end of each batch.",
 	"tar", "Parse the file as a tar archive, and consume each file of the archive as a message.",
 )
@@ -136,7 +139,28 @@ func chainedReader(codec string, conf ReaderConfig) (ReaderConstructor, error) {
 	var ioCtor ioReaderConstructor
 	var partCtor ReaderConstructor
 
-	for i, codec := range codecs {
+	for i := 0; i < len(codecs); i++ {
+		codec := codecs[i]
+		if strings.HasPrefix(codec, "multiline:") {
+			// The regular expression of a multiline codec may itself contain
+			// slashes, and therefore it consumes the remaining codecs.
+			mConf, err := parseMultilineCodec(strings.Join(codecs[i:], "/"))
+			if err != nil {
+				return nil, err
+			}
+			if partCtor == nil {
+				linesCtor, _, _ := partReader("lines", conf)
+				if ioCtor != nil {
+					linesCtor = chainIOIntoPartCtor(ioCtor, linesCtor)
+					ioCtor = nil
+				}
+				partCtor = linesCtor
+			}
+			partCtor = chainPartIntoReaderCtor(partCtor, func(_ string, r Reader) (Reader, error) {
+				return newMultilineReader(mConf, r)
+			})
+			break
+		}
 		if tmpIOCtor, ok := ioReader(codec, conf); ok {
 			if partCtor != nil {
 				return nil, fmt.Errorf("unable to follow codec '%v' with '%v'", codecs[i-1], codec)
@@ -784,3 +808,272 @@ func (m *multipartReader) Next(ctx context.Context) ([]types.Part, ReaderAckFn,
 func (m *multipartReader) Close(ctx context.Context) error {
 	return m.child.Close(ctx)
 }
+
+//------------------------------------------------------------------------------
+
+type multilineConfig struct {
+	pattern    *regexp.Regexp
+	isStart    bool
+	maxLines   int
+	flushAfter time.Duration
+}
+
+// parseMultilineCodec parses the options of a multiline codec of the form
+// multiline:[max_lines=N:][timeout=D:](start|continue):<regexp>, where the
+// regular expression is the remainder of the string.
+func parseMultilineCodec(codec string) (multilineConfig, error) {
+	conf := multilineConfig{
+		maxLines:   1000,
+		flushAfter: time.Second,
+	}
+
+	remaining := strings.TrimPrefix(codec, "multiline:")
+	for {
+		i := strings.Index(remaining, ":")
+		if i < 0 {
+			return conf, errors.New("multiline codec requires a mode of either start or continue followed by a regular expression")
+		}
+		opt := remaining[:i]
+		remaining = remaining[i+1:]
+
+		switch {
+		case opt == "start" || opt == "continue":
+			if remaining == "" {
+				return conf, errors.New("multiline codec requires a non-empty regular expression")
+			}
+			var err error
+			if conf.pattern, err = regexp.Compile(remaining); err != nil {
+				return conf, fmt.Errorf("invalid regular expression for multiline codec: %w", err)
+			}
+			conf.isStart = opt == "start"
+			return conf, nil
+		case strings.HasPrefix(opt, "max_lines="):
+			maxLines, err := strconv.Atoi(strings.TrimPrefix(opt, "max_lines="))
+			if err != nil || maxLines < 0 {
+				return conf, fmt.Errorf("invalid max_lines for multiline codec: %v", opt)
+			}
+			conf.maxLines = maxLines
+		case strings.HasPrefix(opt, "timeout="):
+			timeout, err := time.ParseDuration(strings.TrimPrefix(opt, "timeout="))
+			if err != nil {
+				return conf, fmt.Errorf("invalid timeout for multiline codec: %w", err)
+			}
+			conf.flushAfter = timeout
+		default:
+			return conf, fmt.Errorf("multiline codec option not recognised: %v", opt)
+		}
+	}
+}
+
+// appends returns whether a line belongs to the record preceding it rather than
+// beginning a new record.
+func (c multilineConfig) appends(line []byte) bool {
+	if c.isStart {
+		return !c.pattern.Match(line)
+	}
+	return c.pattern.Match(line)
+}
+
+// MultilineCodec describes how consecutive lines are joined into records by a
+// multiline codec, for consumers that split lines themselves.
+type MultilineCodec struct {
+	conf multilineConfig
+}
+
+// ParseMultilineCodec parses a codec of the form
+// multiline:[max_lines=N:][timeout=D:](start|continue):<regexp>.
+func ParseMultilineCodec(codec string) (*MultilineCodec, error) {
+	conf, err := parseMultilineCodec(codec)
+	if err != nil {
+		return nil, err
+	}
+	return &MultilineCodec{conf: conf}, nil
+}
+
+// Appends returns whether a line belongs to the record preceding it rather than
+// beginning a new record.
+func (m *MultilineCodec) Appends(line []byte) bool {
+	return m.conf.appends(line)
+}
+
+// MaxLines returns the maximum number of lines of a record, or zero when
+// unlimited.
+func (m *MultilineCodec) MaxLines() int {
+	return m.conf.maxLines
+}
+
+// FlushAfter returns the period to wait for further lines before a record is
+// flushed, or zero when records are only flushed by the following record.
+func (m *MultilineCodec) FlushAfter() time.Duration {
+	return m.conf.flushAfter
+}
+
+type multilineRead struct {
+	parts []types.Part
+	ack   ReaderAckFn
+	err   error
+}
+
+// The period that Close waits for an in flight read of the child to finish
+// before closing the child regardless.
+var multilineCloseGrace = time.Second
+
+type multilineReader struct {
+	child Reader
+	conf  multilineConfig
+
+	// A read of the child that was abandoned due to a flush timeout and is
+	// consumed by the following call.
+	inFlight       chan multilineRead
+	cancelInFlight context.CancelFunc
+
+	lines    [][]byte
+	acks     []ReaderAckFn
+	lastRead time.Time
+	nextErr  error
+}
+
+func newMultilineReader(conf multilineConfig, r Reader) (Reader, error) {
+	return &multilineReader{
+		child: r,
+		conf:  conf,
+	}, nil
+}
+
+func (m *multilineReader) flush() ([]types.Part, ReaderAckFn, error) {
+	acks := m.acks
+	part := message.NewPart(bytes.Join(m.lines, []byte("\n")))
+	m.lines, m.acks = nil, nil
+	return []types.Part{part}, func(ctx context.Context, err error) error {
+		for _, fn := range acks {
+			_ = fn(ctx, err)
+		}
+		return nil
+	}, nil
+}
+
+// readChild reads the next line from the child reader. When a record is
+// pending and a flush timeout is set the read is performed in the background,
+// and if the timeout elapses first timedOut is returned as true.
+func (m *multilineReader) readChild(ctx context.Context) (read multilineRead, timedOut bool, err error) {
+	if m.inFlight == nil && (len(m.lines) == 0 || m.conf.flushAfter <= 0) {
+		read.parts, read.ack, read.err = m.child.Next(ctx)
+		return
+	}
+
+	if m.inFlight == nil {
+		readCtx, cancel := context.WithCancel(context.Background())
+		resChan := make(chan multilineRead, 1)
+		go func() {
+			var res multilineRead
+			res.parts, res.ack, res.err = m.child.Next(readCtx)
+			resChan <- res
+		}()
+		m.inFlight, m.cancelInFlight = resChan, cancel
+	}
+
+	var timeoutChan <-chan time.Time
+	if len(m.lines) > 0 && m.conf.flushAfter > 0 {
+		timer := time.NewTimer(time.Until(m.lastRead.Add(m.conf.flushAfter)))
+		defer timer.Stop()
+		timeoutChan = timer.C
+	}
+
+	select {
+	case read = <-m.inFlight:
+		m.cancelInFlight()
+		m.inFlight, m.cancelInFlight = nil, nil
+	case <-timeoutChan:
+		timedOut = true
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+	return
+}
+
+func (m *multilineReader) Next(ctx context.Context) ([]types.Part, ReaderAckFn, error) {
+	for {
+		if m.nextErr != nil {
+			if len(m.lines) > 0 {
+				return m.flush()
+			}
+			return nil, nil, m.nextErr
+		}
+
+		read, timedOut, err := m.readChild(ctx)
+		if err != nil {
+			return nil, nil, err
+		}
+		if timedOut {
+			return m.flush()
+		}
+		if read.err != nil {
+			m.nextErr = read.err
+			continue
+		}
+		m.lastRead = time.Now()
+
+		var line []byte
+		for i, p := range read.parts {
+			if i > 0 {
+				line = append(line, '\n')
+			}
+			line = append(line, p.Get()...)
+		}
+
+		if !m.conf.appends(line) && len(m.lines) > 0 {
+			parts, ackFn, _ := m.flush()
+			m.lines = [][]byte{line}
+			m.acks = []ReaderAckFn{read.ack}
+			return parts, ackFn, nil
+		}
+
+		m.lines = append(m.lines, line)
+		m.acks = append(m.acks, read.ack)
+		if m.conf.maxLines > 0 && len(m.lines) >= m.conf.maxLines {
+			return m.flush()
+		}
+	}
+}
+
+func (m *multilineReader) Close(ctx context.Context) error {
+	errShutdown := errors.New("service shutting down")
+	for _, fn := range m.acks {
+		_ = fn(ctx, errShutdown)
+	}
+	m.lines, m.acks = nil, nil
+
+	if m.inFlight == nil {
+		return m.child.Close(ctx)
+	}
+
+	// A read of the child is still in flight and must finish before the child
+	// is closed, with its result rejected. Children that ignore the
+	// cancellation of the read are closed after a grace period in order to
+	// unblock it, and the result is then rejected once it arrives.
+	inFlight := m.inFlight
+	m.cancelInFlight()
+	m.inFlight, m.cancelInFlight = nil, nil
+
+	nack := func(read multilineRead) {
+		if read.err == nil && read.ack != nil {
+			_ = read.ack(context.Background(), errShutdown)
+		}
+	}
+
+	grace := time.NewTimer(multilineCloseGrace)
+	defer grace.Stop()
+	select {
+	case read := <-inFlight:
+		nack(read)
+		return m.child.Close(ctx)
+	case <-grace.C:
+	case <-ctx.Done():
+	}
+
+	err := m.child.Close(ctx)
+	go func() {
+		nack(<-inFlight)
+	}()
+	return err
+}
@@ -10,7 +10,9 @@ import (
 	"io"
 	"sync"
 	"testing"
+	"time"
 
+	"github.com/Jeffail/benthos/v3/lib/message"
 	"github.com/Jeffail/benthos/v3/lib/types"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -658,3 +660,221 @@ func TestMultipartLinesReader(t *testing.T) {
 	data = []byte("")
 	testReaderSuite(t, "lines/multipart", "", data)
 }
+
+func TestMultilineReader(t *testing.T) {
+	data := []byte(`2021-01-01 ERROR oh no
+java.lang.Exception: boom
+	at com.example.Foo.bar(Foo.java:10)
+	at com.example.Foo.main(Foo.java:5)
+2021-01-01 INFO all good
+2021-01-01 WARN hmm
+  continued
+`)
+
+	testReaderSuite(t, `multiline:start:^\d{4}-`, "", data,
+		"2021-01-01 ERROR oh no\njava.lang.Exception: boom\n\tat com.example.Foo.bar(Foo.java:10)\n\tat com.example.Foo.main(Foo.java:5)",
+		"2021-01-01 INFO all good",
+		"2021-01-01 WARN hmm\n  continued",
+	)
+
+	testReaderSuite(t, `multiline:continue:^(\s|java\.)`, "", data,
+		"2021-01-01 ERROR oh no\njava.lang.Exception: boom\n\tat com.example.Foo.bar(Foo.java:10)\n\tat com.example.Foo.main(Foo.java:5)",
+		"2021-01-01 INFO all good",
+		"2021-01-01 WARN hmm\n  continued",
+	)
+
+	testReaderSuite(t, `delim:|/multiline:max_lines=2:start:^a/b`, "", []byte("a/b|c|d|a/b|e"),
+		"a/b\nc",
+		"d",
+		"a/b\ne",
+	)
+
+	var gzipBuf bytes.Buffer
+	zw := gzip.NewWriter(&gzipBuf)
+	_, err := zw.Write([]byte("foo\n bar\nbaz\n"))
+	require.NoError(t, err)
+	require.NoError(t, zw.Close())
+
+	testReaderSuite(t, `gzip/multiline:continue:^\s`, "", gzipBuf.Bytes(), "foo\n bar", "baz")
+
+	testReaderSuite(t, `multiline:start:^foo`, "", []byte(""))
+}
+
+func TestMultilineReaderConfigErrors(t *testing.T) {
+	for _, codec := range []string{
+		"multiline:",
+		"multiline:start",
+		"multiline:start:",
+		"multiline:start:(",
+		"multiline:nope:foo",
+		"multiline:max_lines=a:start:foo",
+		"multiline:timeout=a:start:foo",
+	} {
+		_, err := GetReader(codec, NewReaderConfig())
+		assert.Error(t, err, codec)
+	}
+}
+
+type blockingReader struct {
+	lines chan string
+}
+
+func (b *blockingReader) Read(p []byte) (int, error) {
+	line, open := <-b.lines
+	if !open {
+		return 0, io.EOF
+	}
+	return copy(p, line), nil
+}
+
+func (b *blockingReader) Close() error {
+	return nil
+}
+
+func TestMultilineReaderTimeout(t *testing.T) {
+	ctor, err := GetReader(`multiline:timeout=50ms:start:^\S`, NewReaderConfig())
+	require.NoError(t, err)
+
+	src := &blockingReader{lines: make(chan string)}
+	r, err := ctor("", src, func(ctx context.Context, err error) error {
+		return nil
+	})
+	require.NoError(t, err)
+
+	go func() {
+		src.lines <- "foo\n"
+		src.lines <- " bar\n"
+	}()
+
+	p, ackFn, err := r.Next(context.Background())
+	require.NoError(t, err)
+	require.NoError(t, ackFn(context.Background(), nil))
+	assert.Equal(t, []string{"foo\n bar"}, strsFromParts(p))
+
+	go func() {
+		src.lines <- "baz\n"
+		close(src.lines)
+	}()
+
+	p, _, err = r.Next(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, []string{"baz"}, strsFromParts(p))
+
+	_, _, err = r.Next(context.Background())
+	assert.Equal(t, io.EOF, err)
+
+	assert.NoError(t, r.Close(context.Background()))
+}
+
+// cancellableReader is a child reader that emits a final line when a read is
+// cancelled, or when it is closed if ignoreCancel is set.
+type cancellableReader struct {
+	lines        chan string
+	ignoreCancel bool
+
+	mut             sync.Mutex
+	reading         bool
+	closedMidRead   bool
+	closed          chan struct{}
+	rejected        []error
+	rejectedSignals chan struct{}
+}
+
+func newCancellableReader(ignoreCancel bool) *cancellableReader {
+	return &cancellableReader{
+		lines:           make(chan string),
+		ignoreCancel:    ignoreCancel,
+		closed:          make(chan struct{}),
+		rejectedSignals: make(chan struct{}, 10),
+	}
+}
+
+func (c *cancellableReader) part(line string) ([]types.Part, ReaderAckFn, error) {
+	return []types.Part{message.NewPart([]byte(line))}, func(ctx context.Context, err error) error {
+		if err != nil {
+			c.mut.Lock()
+			c.rejected = append(c.rejected, err)
+			c.mut.Unlock()
+			c.rejectedSignals <- struct{}{}
+		}
+		return nil
+	}, nil
+}
+
+func (c *cancellableReader) Next(ctx context.Context) ([]types.Part, ReaderAckFn, error) {
+	c.mut.Lock()
+	c.reading = true
+	c.mut.Unlock()
+	defer func() {
+		c.mut.Lock()
+		c.reading = false
+		c.mut.Unlock()
+	}()
+
+	cancelChan := ctx.Done()
+	if c.ignoreCancel {
+		cancelChan = nil
+	}
+	select {
+	case line := <-c.lines:
+		return c.part(line)
+	case <-cancelChan:
+	case <-c.closed:
+	}
+	return c.part("late")
+}
+
+func (c *cancellableReader) Close(ctx context.Context) error {
+	c.mut.Lock()
+	c.closedMidRead = c.reading
+	c.mut.Unlock()
+	close(c.closed)
+	return nil
+}
+
+func TestMultilineReaderCloseInFlight(t *testing.T) {
+	for _, ignoreCancel := range []bool{false, true} {
+		ignoreCancel := ignoreCancel
+		t.Run(fmt.Sprintf("ignore cancel %v", ignoreCancel), func(t *testing.T) {
+			if ignoreCancel {
+				prevGrace := multilineCloseGrace
+				multilineCloseGrace = time.Millisecond * 10
+				defer func() {
+					multilineCloseGrace = prevGrace
+				}()
+			}
+
+			conf, err := parseMultilineCodec(`multiline:timeout=20ms:start:^\S`)
+			require.NoError(t, err)
+
+			child := newCancellableReader(ignoreCancel)
+			r, err := newMultilineReader(conf, child)
+			require.NoError(t, err)
+
+			go func() {
+				child.lines <- "foo"
+			}()
+
+			// The record is flushed by the timeout, leaving a read of the child
+			// in flight.
+			p, ackFn, err := r.Next(context.Background())
+			require.NoError(t, err)
+			require.NoError(t, ackFn(context.Background(), nil))
+			assert.Equal(t, []string{"foo"}, strsFromParts(p))
+
+			require.NoError(t, r.Close(context.Background()))
+
+			select {
+			case <-child.rejectedSignals:
+			case <-time.After(time.Second):
+				t.Fatal("timed out waiting for the in flight read to be rejected")
+			}
+
+			child.mut.Lock()
+			defer child.mut.Unlock()
+			assert.Equal(t, ignoreCancel, child.closedMidRead)
+			require.Len(t, child.rejected, 1)
+			assert.EqualError(t, child.rejected[0], "service shutting down")
+		})
+	}
+}
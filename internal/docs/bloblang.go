@@ -21,17 +21,47 @@ func LintBloblangMapping(ctx LintContext, line, col int, v interface{}) []Lint {
 	if str == "" {
 		return nil
 	}
+	if ctx.LintBloblang {
+		return lintBloblangMappingStatic(line, col, str)
+	}
 	_, err := bloblang.NewMapping("", str)
 	if err == nil {
 		return nil
 	}
+	return []Lint{bloblangMappingErrLint(line, col, str, err)}
+}
+
+func bloblangMappingErrLint(line, col int, str string, err error) Lint {
 	if mErr, ok := err.(*parser.Error); ok {
 		bline, bcol := parser.LineAndColOf([]rune(str), mErr.Input)
 		lint := NewLintError(line+bline, mErr.ErrorAtPositionStructured("", []rune(str)))
 		lint.Column = col + bcol
-		return []Lint{lint}
+		return lint
 	}
-	return []Lint{NewLintError(line, err.Error())}
+	return NewLintError(line, err.Error())
+}
+
+func lintBloblangMappingStatic(line, col int, str string) []Lint {
+	bLints, err := bloblang.LintMapping("", str)
+	if err != nil {
+		return []Lint{bloblangMappingErrLint(line, col, str, err)}
+	}
+
+	var lints []Lint
+	for _, bLint := range bLints {
+		lint := NewLintError(line+bLint.Line, bLint.What)
+		lint.Column = col + bLint.Column
+		lints = append(lints, lint)
+	}
+
+	formatted, err := bloblang.FormatMapping("", str)
+	if err != nil {
+		return append(lints, bloblangMappingErrLint(line, col, str, err))
+	}
+	if strings.TrimRight(formatted, "\n") != strings.TrimRight(str, "\n") {
+		lints = append(lints, NewLintWarning(line, "mapping is not formatted"))
+	}
+	return lints
 }
 
 // LintBloblangField is function for linting a config field expected to be an
@@ -289,6 +319,7 @@ func BloblangMethodsMarkdown() ([]byte, error) {
 		query.MethodCategoryCoercion,
 		query.MethodCategoryObjectAndArray,
 		query.MethodCategoryParsing,
+		query.MethodCategoryNetwork,
 		query.MethodCategoryEncoding,
 		query.MethodCategoryDeprecated,
 	} {
@@ -12,6 +12,11 @@ import (
 type LintContext struct {
 	// A map of label names to the line they were defined at.
 	Labels map[string]int
+
+	// LintBloblang enables the static analysis of Bloblang mappings, which
+	// reports problems such as unused variables as errors and unformatted
+	// mappings as warnings.
+	LintBloblang bool
 }
 
 // NewLintContext creates a new linting context.
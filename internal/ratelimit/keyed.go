@@ -0,0 +1,144 @@
+package ratelimit
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/Jeffail/benthos/v3/internal/docs"
+)
+
+// KeyedConfig contains configuration fields for a rate limit that is
+// partitioned by key.
+type KeyedConfig struct {
+	Key         string `json:"key" yaml:"key"`
+	Count       int    `json:"count" yaml:"count"`
+	Interval    string `json:"interval" yaml:"interval"`
+	Burst       int    `json:"burst" yaml:"burst"`
+	IdleTimeout string `json:"idle_timeout" yaml:"idle_timeout"`
+}
+
+// NewKeyedConfig returns a KeyedConfig with default values.
+func NewKeyedConfig() KeyedConfig {
+	return KeyedConfig{
+		Key:         "",
+		Count:       1000,
+		Interval:    "1s",
+		Burst:       0,
+		IdleTimeout: "1m",
+	}
+}
+
+// KeyedFieldSpec returns a field spec for a KeyedConfig.
+func KeyedFieldSpec(name string) docs.FieldSpec {
+	return docs.FieldAdvanced(name,
+		"Allows you to rate limit by an interpolated key, where each distinct key is given its own token bucket. This allows you to throttle requests of a particular key, such as a tenant, without affecting other keys.",
+	).HasType(docs.FieldObject).WithChildren(
+		docs.FieldCommon("key", "An interpolated key to partition rate limits by. When empty rate limiting by key is disabled.", `${! meta("tenant") }`).HasType(docs.FieldString).IsInterpolated(),
+		docs.FieldCommon("count", "The number of requests allowed for each key within an interval.").HasType(docs.FieldNumber),
+		docs.FieldCommon("interval", "The interval over which the count of requests are spread.").HasType(docs.FieldString),
+		docs.FieldAdvanced("burst", "The maximum number of requests that can be made for a key in a burst after a period of inactivity. When set to zero the burst matches the `count`.").HasType(docs.FieldNumber),
+		docs.FieldAdvanced("idle_timeout", "The minimum period of inactivity after which the bucket of a key is discarded. Buckets are only discarded once they have been fully refilled, and therefore discarding them does not change the limits applied.").HasType(docs.FieldString),
+	).AtVersion("3.47.0")
+}
+
+//------------------------------------------------------------------------------
+
+// Keyed is a rate limit where each distinct key has its own token bucket.
+// Buckets that are idle are evicted periodically in order to bound the memory
+// used by short lived keys. Keyed is safe for concurrent use.
+type Keyed struct {
+	perToken    time.Duration
+	burst       int
+	idleTimeout time.Duration
+
+	mut       sync.Mutex
+	buckets   map[string]*keyedBucket
+	lastSweep time.Time
+}
+
+type keyedBucket struct {
+	bucket     *TokenBucket
+	lastAccess time.Time
+}
+
+// NewKeyed creates a keyed rate limit from a config.
+func NewKeyed(conf KeyedConfig) (*Keyed, error) {
+	if conf.Count <= 0 {
+		return nil, errors.New("count must be larger than zero")
+	}
+	if conf.Burst < 0 {
+		return nil, errors.New("burst must not be negative")
+	}
+
+	interval, err := time.ParseDuration(conf.Interval)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse interval: %v", err)
+	}
+	if interval <= 0 {
+		return nil, errors.New("interval must be larger than zero")
+	}
+
+	idleTimeout, err := time.ParseDuration(conf.IdleTimeout)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse idle timeout: %v", err)
+	}
+
+	burst := conf.Burst
+	if burst == 0 {
+		burst = conf.Count
+	}
+
+	perToken := interval / time.Duration(conf.Count)
+	if perToken <= 0 {
+		perToken = 1
+	}
+
+	return &Keyed{
+		perToken:    perToken,
+		burst:       burst,
+		idleTimeout: idleTimeout,
+		buckets:     map[string]*keyedBucket{},
+		lastSweep:   time.Now(),
+	}, nil
+}
+
+// Access the rate limit of a key. Returns zero if the key can be accessed,
+// otherwise the duration to wait before attempting to access the key again.
+func (k *Keyed) Access(key string) time.Duration {
+	now := time.Now()
+
+	k.mut.Lock()
+	defer k.mut.Unlock()
+
+	if now.Sub(k.lastSweep) >= k.idleTimeout {
+		k.sweep(now)
+	}
+
+	b, exists := k.buckets[key]
+	if !exists {
+		b = &keyedBucket{
+			bucket: NewTokenBucket(k.perToken, k.burst, now),
+		}
+		k.buckets[key] = b
+	}
+	b.lastAccess = now
+	return b.bucket.Take(now)
+}
+
+// Len returns the number of keys currently being tracked.
+func (k *Keyed) Len() int {
+	k.mut.Lock()
+	defer k.mut.Unlock()
+	return len(k.buckets)
+}
+
+func (k *Keyed) sweep(now time.Time) {
+	for key, b := range k.buckets {
+		if now.Sub(b.lastAccess) >= k.idleTimeout && b.bucket.Full(now) {
+			delete(k.buckets, key)
+		}
+	}
+	k.lastSweep = now
+}
@@ -0,0 +1,119 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTokenBucket(t *testing.T) {
+	now := time.Unix(0, 0)
+	b := NewTokenBucket(time.Millisecond*100, 3, now)
+
+	for i := 0; i < 3; i++ {
+		assert.Equal(t, time.Duration(0), b.Take(now), i)
+	}
+	assert.Equal(t, time.Millisecond*100, b.Take(now))
+	assert.False(t, b.Full(now))
+
+	now = now.Add(time.Millisecond * 50)
+	assert.Equal(t, time.Millisecond*50, b.Take(now))
+
+	now = now.Add(time.Millisecond * 50)
+	assert.Equal(t, time.Duration(0), b.Take(now))
+	assert.Equal(t, time.Millisecond*100, b.Take(now))
+
+	now = now.Add(time.Second)
+	assert.True(t, b.Full(now))
+	for i := 0; i < 3; i++ {
+		assert.Equal(t, time.Duration(0), b.Take(now), i)
+	}
+	assert.True(t, b.Take(now) > 0)
+}
+
+func TestKeyedConfigErrors(t *testing.T) {
+	for _, fn := range []func(c *KeyedConfig){
+		func(c *KeyedConfig) { c.Count = 0 },
+		func(c *KeyedConfig) { c.Burst = -1 },
+		func(c *KeyedConfig) { c.Interval = "nope" },
+		func(c *KeyedConfig) { c.Interval = "0s" },
+		func(c *KeyedConfig) { c.IdleTimeout = "nope" },
+	} {
+		conf := NewKeyedConfig()
+		fn(&conf)
+		_, err := NewKeyed(conf)
+		assert.Error(t, err)
+	}
+}
+
+func TestKeyedIsolation(t *testing.T) {
+	conf := NewKeyedConfig()
+	conf.Count = 2
+	conf.Interval = "1h"
+
+	k, err := NewKeyed(conf)
+	require.NoError(t, err)
+
+	assert.Equal(t, time.Duration(0), k.Access("foo"))
+	assert.Equal(t, time.Duration(0), k.Access("foo"))
+	assert.True(t, k.Access("foo") > 0)
+
+	assert.Equal(t, time.Duration(0), k.Access("bar"))
+	assert.Equal(t, time.Duration(0), k.Access("bar"))
+	assert.True(t, k.Access("bar") > 0)
+
+	assert.Equal(t, 2, k.Len())
+}
+
+func TestKeyedBurst(t *testing.T) {
+	conf := NewKeyedConfig()
+	conf.Count = 10
+	conf.Interval = "1h"
+	conf.Burst = 1
+
+	k, err := NewKeyed(conf)
+	require.NoError(t, err)
+
+	assert.Equal(t, time.Duration(0), k.Access("foo"))
+
+	wait := k.Access("foo")
+	assert.True(t, wait > time.Minute*5 && wait <= time.Minute*6, wait)
+}
+
+func TestKeyedEviction(t *testing.T) {
+	conf := NewKeyedConfig()
+	conf.Count = 1
+	conf.Interval = "10ms"
+	conf.IdleTimeout = "20ms"
+
+	k, err := NewKeyed(conf)
+	require.NoError(t, err)
+
+	k.Access("foo")
+	k.Access("bar")
+	assert.Equal(t, 2, k.Len())
+
+	<-time.After(time.Millisecond * 30)
+
+	k.Access("baz")
+	assert.Equal(t, 1, k.Len())
+}
+
+func TestKeyedNoEvictionWhenDepleted(t *testing.T) {
+	conf := NewKeyedConfig()
+	conf.Count = 1
+	conf.Interval = "1h"
+	conf.IdleTimeout = "10ms"
+
+	k, err := NewKeyed(conf)
+	require.NoError(t, err)
+
+	k.Access("foo")
+	<-time.After(time.Millisecond * 20)
+
+	k.Access("bar")
+	assert.Equal(t, 2, k.Len())
+	assert.True(t, k.Access("foo") > 0)
+}
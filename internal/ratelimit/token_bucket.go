@@ -0,0 +1,59 @@
+package ratelimit
+
+import (
+	"time"
+)
+
+// TokenBucket is a rate limiter that refills tokens at a constant rate up to a
+// maximum burst capacity, where each access consumes a single token. A
+// TokenBucket is not safe for concurrent use.
+type TokenBucket struct {
+	perToken time.Duration
+	burst    float64
+
+	tokens float64
+	last   time.Time
+}
+
+// NewTokenBucket creates a token bucket that refills a token every perToken
+// duration up to a capacity of burst tokens. The bucket starts full.
+func NewTokenBucket(perToken time.Duration, burst int, now time.Time) *TokenBucket {
+	return &TokenBucket{
+		perToken: perToken,
+		burst:    float64(burst),
+		tokens:   float64(burst),
+		last:     now,
+	}
+}
+
+func (t *TokenBucket) refill(now time.Time) {
+	if elapsed := now.Sub(t.last); elapsed > 0 {
+		t.tokens += float64(elapsed) / float64(t.perToken)
+		if t.tokens > t.burst {
+			t.tokens = t.burst
+		}
+		t.last = now
+	}
+}
+
+// Take attempts to consume a token from the bucket. Returns zero if a token
+// was consumed, otherwise the duration to wait until a token is available.
+func (t *TokenBucket) Take(now time.Time) time.Duration {
+	t.refill(now)
+	if t.tokens >= 1 {
+		t.tokens--
+		return 0
+	}
+	wait := time.Duration((1 - t.tokens) * float64(t.perToken))
+	if wait <= 0 {
+		wait = 1
+	}
+	return wait
+}
+
+// Full returns true if the bucket has been refilled to its burst capacity,
+// meaning discarding it would not change the behaviour of the limit.
+func (t *TokenBucket) Full(now time.Time) bool {
+	t.refill(now)
+	return t.tokens >= t.burst
+}
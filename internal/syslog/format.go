@@ -0,0 +1,194 @@
+package syslog
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var facilityNames = []string{
+	"kern", "user", "mail", "daemon", "auth", "syslog", "lpr", "news",
+	"uucp", "cron", "authpriv", "ftp", "ntp", "security", "console", "solaris-cron",
+	"local0", "local1", "local2", "local3", "local4", "local5", "local6", "local7",
+}
+
+var severityNames = []string{
+	"emerg", "alert", "crit", "err", "warning", "notice", "info", "debug",
+}
+
+func parseCode(s string, names []string) (int, error) {
+	s = strings.TrimSpace(s)
+	if code, err := strconv.Atoi(s); err == nil {
+		if code < 0 || code >= len(names) {
+			return 0, fmt.Errorf("value %v is out of range [0, %v]", code, len(names)-1)
+		}
+		return code, nil
+	}
+	lower := strings.ToLower(s)
+	for i, name := range names {
+		if name == lower {
+			return i, nil
+		}
+	}
+	return 0, fmt.Errorf("value not recognised: %v", s)
+}
+
+// ParseFacility parses a syslog facility from either its numerical code or
+// its keyword, e.g. "local0".
+func ParseFacility(s string) (int, error) {
+	code, err := parseCode(s, facilityNames)
+	if err != nil {
+		return 0, fmt.Errorf("invalid facility: %w", err)
+	}
+	return code, nil
+}
+
+// ParseSeverity parses a syslog severity from either its numerical code or its
+// keyword, e.g. "warning".
+func ParseSeverity(s string) (int, error) {
+	code, err := parseCode(s, severityNames)
+	if err != nil {
+		return 0, fmt.Errorf("invalid severity: %w", err)
+	}
+	return code, nil
+}
+
+//------------------------------------------------------------------------------
+
+// Fields contains the contents of a syslog message to be formatted.
+type Fields struct {
+	Facility       int
+	Severity       int
+	Timestamp      time.Time
+	Hostname       string
+	AppName        string
+	ProcID         string
+	MsgID          string
+	StructuredData map[string]map[string]string
+	Message        []byte
+}
+
+// Marshal formats a syslog message, where a format of auto is treated as
+// RFC5424. Structured data is only supported by RFC5424 and is otherwise
+// ignored.
+func Marshal(format Format, f Fields) []byte {
+	if format == FormatRFC3164 {
+		return marshalRFC3164(f)
+	}
+	return marshalRFC5424(f)
+}
+
+// headerValue sanitises a header field so that it contains only printable
+// US-ASCII characters without spaces, and truncates it to a maximum length.
+func headerValue(s string, maxLen int) string {
+	if s == "" {
+		return "-"
+	}
+	b := make([]byte, 0, len(s))
+	for i := 0; i < len(s) && len(b) < maxLen; i++ {
+		c := s[i]
+		if c < 33 || c > 126 {
+			c = '_'
+		}
+		b = append(b, c)
+	}
+	return string(b)
+}
+
+var sdEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, `]`, `\]`)
+
+// sdName sanitises an SD-ID or PARAM-NAME.
+func sdName(s string) string {
+	b := make([]byte, 0, len(s))
+	for i := 0; i < len(s) && len(b) < 32; i++ {
+		c := s[i]
+		if c < 33 || c > 126 || c == '=' || c == ']' || c == '"' || c == ' ' {
+			c = '_'
+		}
+		b = append(b, c)
+	}
+	return string(b)
+}
+
+func appendStructuredData(buf []byte, sd map[string]map[string]string) []byte {
+	if len(sd) == 0 {
+		return append(buf, '-')
+	}
+
+	ids := make([]string, 0, len(sd))
+	for id := range sd {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	for _, id := range ids {
+		buf = append(buf, '[')
+		buf = append(buf, sdName(id)...)
+
+		params := sd[id]
+		keys := make([]string, 0, len(params))
+		for k := range params {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		for _, k := range keys {
+			buf = append(buf, ' ')
+			buf = append(buf, sdName(k)...)
+			buf = append(buf, '=', '"')
+			buf = append(buf, sdEscaper.Replace(params[k])...)
+			buf = append(buf, '"')
+		}
+		buf = append(buf, ']')
+	}
+	return buf
+}
+
+func appendPriority(buf []byte, f Fields) []byte {
+	buf = append(buf, '<')
+	buf = strconv.AppendInt(buf, int64(f.Facility*8+f.Severity), 10)
+	return append(buf, '>')
+}
+
+// RFC5424 timestamps are limited to microsecond precision, and therefore any
+// further digits are truncated.
+const rfc5424TimeFormat = "2006-01-02T15:04:05.999999Z07:00"
+
+func marshalRFC5424(f Fields) []byte {
+	buf := appendPriority(make([]byte, 0, len(f.Message)+128), f)
+	buf = append(buf, "1 "...)
+	buf = f.Timestamp.AppendFormat(buf, rfc5424TimeFormat)
+	buf = append(buf, ' ')
+	buf = append(buf, headerValue(f.Hostname, 255)...)
+	buf = append(buf, ' ')
+	buf = append(buf, headerValue(f.AppName, 48)...)
+	buf = append(buf, ' ')
+	buf = append(buf, headerValue(f.ProcID, 128)...)
+	buf = append(buf, ' ')
+	buf = append(buf, headerValue(f.MsgID, 32)...)
+	buf = append(buf, ' ')
+	buf = appendStructuredData(buf, f.StructuredData)
+	if len(f.Message) > 0 {
+		buf = append(buf, ' ')
+		buf = append(buf, f.Message...)
+	}
+	return buf
+}
+
+func marshalRFC3164(f Fields) []byte {
+	buf := appendPriority(make([]byte, 0, len(f.Message)+64), f)
+	buf = f.Timestamp.AppendFormat(buf, time.Stamp)
+	buf = append(buf, ' ')
+	buf = append(buf, headerValue(f.Hostname, 255)...)
+	buf = append(buf, ' ')
+	buf = append(buf, headerValue(f.AppName, 32)...)
+	if f.ProcID != "" {
+		buf = append(buf, '[')
+		buf = append(buf, headerValue(f.ProcID, 128)...)
+		buf = append(buf, ']')
+	}
+	buf = append(buf, ": "...)
+	return append(buf, f.Message...)
+}
@@ -0,0 +1,118 @@
+package syslog
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseFacilitySeverity(t *testing.T) {
+	for in, exp := range map[string]int{
+		"0":      0,
+		"local0": 16,
+		"LOCAL7": 23,
+		" 23 ":   23,
+		"user":   1,
+	} {
+		code, err := ParseFacility(in)
+		require.NoError(t, err, in)
+		assert.Equal(t, exp, code, in)
+	}
+	for _, in := range []string{"24", "-1", "nope", ""} {
+		_, err := ParseFacility(in)
+		assert.Error(t, err, in)
+	}
+
+	for in, exp := range map[string]int{
+		"7":       7,
+		"emerg":   0,
+		"warning": 4,
+		"info":    6,
+	} {
+		code, err := ParseSeverity(in)
+		require.NoError(t, err, in)
+		assert.Equal(t, exp, code, in)
+	}
+	for _, in := range []string{"8", "warn", ""} {
+		_, err := ParseSeverity(in)
+		assert.Error(t, err, in)
+	}
+}
+
+func TestMarshalRFC5424(t *testing.T) {
+	fields := Fields{
+		Facility:  20,
+		Severity:  5,
+		Timestamp: time.Date(2003, 10, 11, 22, 14, 15, 3000000, time.UTC),
+		Hostname:  "mymachine.example.com",
+		AppName:   "evnts log",
+		MsgID:     "ID47",
+		StructuredData: map[string]map[string]string{
+			"exampleSDID@32473": {
+				"iut":         "3",
+				"eventSource": `App "1" [x]`,
+			},
+			"a": {},
+		},
+		Message: []byte("An application event log entry..."),
+	}
+
+	assert.Equal(t,
+		`<165>1 2003-10-11T22:14:15.003Z mymachine.example.com evnts_log - ID47 [a][exampleSDID@32473 eventSource="App \"1\" [x\]" iut="3"] An application event log entry...`,
+		string(Marshal(FormatRFC5424, fields)),
+	)
+
+	m, err := NewParser(FormatAuto, false).Parse(Marshal(FormatAuto, fields))
+	require.NoError(t, err)
+	assert.Equal(t, "An application event log entry...", m.Structured["message"])
+	assert.Equal(t, map[string]interface{}{
+		"a": map[string]interface{}{},
+		"exampleSDID@32473": map[string]interface{}{
+			"iut":         "3",
+			"eventSource": `App "1" [x]`,
+		},
+	}, m.Structured["structureddata"])
+
+	fields.StructuredData = nil
+	fields.Message = nil
+	assert.Equal(t,
+		`<165>1 2003-10-11T22:14:15.003Z mymachine.example.com evnts_log - ID47 -`,
+		string(Marshal(FormatRFC5424, fields)),
+	)
+
+	fields.Timestamp = time.Date(2003, 10, 11, 22, 14, 15, 123456789, time.FixedZone("", -7*60*60))
+	assert.Equal(t,
+		`<165>1 2003-10-11T22:14:15.123456-07:00 mymachine.example.com evnts_log - ID47 -`,
+		string(Marshal(FormatRFC5424, fields)),
+	)
+
+	m, err = NewParser(FormatRFC5424, false).Parse(Marshal(FormatRFC5424, fields))
+	require.NoError(t, err)
+	assert.Equal(t, "2003-10-11T22:14:15.123456-07:00", m.Structured["timestamp"])
+}
+
+func TestMarshalRFC3164(t *testing.T) {
+	fields := Fields{
+		Facility:  4,
+		Severity:  2,
+		Timestamp: time.Date(2003, 10, 1, 22, 14, 15, 0, time.UTC),
+		Hostname:  "mymachine",
+		AppName:   "su",
+		ProcID:    "123",
+		MsgID:     "ignored",
+		Message:   []byte("'su root' failed for lonvick on /dev/pts/8"),
+	}
+
+	assert.Equal(t,
+		`<34>Oct  1 22:14:15 mymachine su[123]: 'su root' failed for lonvick on /dev/pts/8`,
+		string(Marshal(FormatRFC3164, fields)),
+	)
+
+	fields.ProcID = ""
+	m, err := NewParser(FormatAuto, false).Parse(Marshal(FormatRFC3164, fields))
+	require.NoError(t, err)
+	assert.Equal(t, "su", m.Structured["appname"])
+	assert.Equal(t, "'su root' failed for lonvick on /dev/pts/8", m.Structured["message"])
+}
@@ -0,0 +1,163 @@
+// Package syslog contains utilities for framing, parsing and formatting syslog
+// messages.
+package syslog
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// Framing describes how syslog messages are delimited within a stream, as
+// described in RFC6587.
+type Framing string
+
+// The supported framing methods of a stream.
+const (
+	FramingAuto           Framing = "auto"
+	FramingOctetCounting  Framing = "octet_counting"
+	FramingNonTransparent Framing = "non_transparent"
+)
+
+// ParseFraming attempts to parse a framing method from a string.
+func ParseFraming(s string) (Framing, error) {
+	switch f := Framing(s); f {
+	case FramingAuto, FramingOctetCounting, FramingNonTransparent:
+		return f, nil
+	}
+	return "", fmt.Errorf("framing not recognised: %v", s)
+}
+
+// ErrFrameTooLarge is returned when a frame exceeds the maximum size allowed.
+var ErrFrameTooLarge = errors.New("syslog frame exceeds maximum size")
+
+//------------------------------------------------------------------------------
+
+// FrameReader reads syslog messages from a stream.
+type FrameReader struct {
+	r       *bufio.Reader
+	framing Framing
+	maxSize int
+}
+
+// NewFrameReader creates a reader of syslog frames from a stream. When the
+// framing is auto the method is detected for each frame, where frames that
+// begin with a digit are octet counted.
+func NewFrameReader(r io.Reader, framing Framing, maxSize int) *FrameReader {
+	return &FrameReader{
+		r:       bufio.NewReader(r),
+		framing: framing,
+		maxSize: maxSize,
+	}
+}
+
+// Next returns the next frame of the stream, or io.EOF when the stream ends.
+func (f *FrameReader) Next() ([]byte, error) {
+	for {
+		first, err := f.r.Peek(1)
+		if err != nil {
+			return nil, err
+		}
+
+		// Skip stray line endings between frames.
+		if first[0] == '\n' || first[0] == '\r' {
+			_, _ = f.r.ReadByte()
+			continue
+		}
+
+		framing := f.framing
+		if framing == FramingAuto {
+			if first[0] >= '0' && first[0] <= '9' {
+				framing = FramingOctetCounting
+			} else {
+				framing = FramingNonTransparent
+			}
+		}
+
+		if framing == FramingOctetCounting {
+			return f.nextOctetCounted()
+		}
+		return f.nextNonTransparent()
+	}
+}
+
+// maxOctetCountDigits is the maximum number of digits accepted for the octet
+// count of a frame, which prevents an unterminated prefix from being read
+// indefinitely.
+const maxOctetCountDigits = 10
+
+func (f *FrameReader) nextOctetCounted() ([]byte, error) {
+	var lenStr []byte
+	for {
+		b, err := f.r.ReadByte()
+		if err != nil {
+			if err == io.EOF && len(lenStr) > 0 {
+				err = io.ErrUnexpectedEOF
+			}
+			return nil, err
+		}
+		if b == ' ' {
+			break
+		}
+		lenStr = append(lenStr, b)
+		if b < '0' || b > '9' || len(lenStr) > maxOctetCountDigits {
+			return nil, fmt.Errorf("invalid octet count: %q", lenStr)
+		}
+	}
+
+	length, err := strconv.Atoi(string(lenStr))
+	if err != nil || length < 0 {
+		return nil, fmt.Errorf("invalid octet count: %q", lenStr)
+	}
+	if f.maxSize > 0 && length > f.maxSize {
+		return nil, ErrFrameTooLarge
+	}
+
+	frame := make([]byte, length)
+	if _, err := io.ReadFull(f.r, frame); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	return frame, nil
+}
+
+func (f *FrameReader) nextNonTransparent() ([]byte, error) {
+	var frame []byte
+	for {
+		chunk, err := f.r.ReadSlice('\n')
+		frame = append(frame, chunk...)
+		if f.maxSize > 0 && len(frame) > f.maxSize+1 {
+			return nil, ErrFrameTooLarge
+		}
+		if err == bufio.ErrBufferFull {
+			continue
+		}
+		if err != nil {
+			if err == io.EOF && len(frame) > 0 {
+				break
+			}
+			return nil, err
+		}
+		break
+	}
+	return bytes.TrimRight(frame, "\r\n"), nil
+}
+
+//------------------------------------------------------------------------------
+
+// AppendFrame appends a syslog message to a buffer using a framing method,
+// where auto is treated as octet counting.
+func AppendFrame(buf []byte, msg []byte, framing Framing) []byte {
+	if framing == FramingNonTransparent {
+		buf = append(buf, msg...)
+		return append(buf, '\n')
+	}
+	buf = strconv.AppendInt(buf, int64(len(msg)), 10)
+	buf = append(buf, ' ')
+	return append(buf, msg...)
+}
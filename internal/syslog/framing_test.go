@@ -0,0 +1,118 @@
+package syslog
+
+import (
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func readFrames(t *testing.T, r *FrameReader) ([]string, error) {
+	t.Helper()
+
+	var frames []string
+	for {
+		frame, err := r.Next()
+		if err != nil {
+			if err == io.EOF {
+				err = nil
+			}
+			return frames, err
+		}
+		frames = append(frames, string(frame))
+	}
+}
+
+func TestFrameReader(t *testing.T) {
+	tests := []struct {
+		name        string
+		framing     Framing
+		input       string
+		output      []string
+		errContains string
+	}{
+		{
+			name:    "octet counting",
+			framing: FramingOctetCounting,
+			input:   "5 hello11 hello\nworld3 foo",
+			output:  []string{"hello", "hello\nworld", "foo"},
+		},
+		{
+			name:    "non transparent",
+			framing: FramingNonTransparent,
+			input:   "hello\nworld\r\n\nfoo",
+			output:  []string{"hello", "world", "foo"},
+		},
+		{
+			name:    "auto mixed",
+			framing: FramingAuto,
+			input:   "<1>hello\n11 <1>hi\nthere<2>foo\n",
+			output:  []string{"<1>hello", "<1>hi\nthere", "<2>foo"},
+		},
+		{
+			name:        "octet count too large",
+			framing:     FramingOctetCounting,
+			input:       "50 hello",
+			errContains: "maximum size",
+		},
+		{
+			name:        "non transparent too large",
+			framing:     FramingNonTransparent,
+			input:       "hello\n" + strings.Repeat("a", 30) + "\n",
+			output:      []string{"hello"},
+			errContains: "maximum size",
+		},
+		{
+			name:        "bad octet count",
+			framing:     FramingOctetCounting,
+			input:       "5a hello",
+			errContains: "invalid octet count",
+		},
+		{
+			name:        "octet count too many digits",
+			framing:     FramingOctetCounting,
+			input:       strings.Repeat("1", 11) + " hello",
+			errContains: "invalid octet count",
+		},
+		{
+			name:        "unterminated octet count",
+			framing:     FramingOctetCounting,
+			input:       strings.Repeat("1", 1000),
+			errContains: "invalid octet count",
+		},
+		{
+			name:        "truncated",
+			framing:     FramingOctetCounting,
+			input:       "10 hello",
+			errContains: "unexpected EOF",
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			frames, err := readFrames(t, NewFrameReader(strings.NewReader(test.input), test.framing, 20))
+			if test.errContains != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), test.errContains)
+			} else {
+				require.NoError(t, err)
+			}
+			assert.Equal(t, test.output, frames)
+		})
+	}
+}
+
+func TestAppendFrame(t *testing.T) {
+	var buf []byte
+	buf = AppendFrame(buf, []byte("hello"), FramingOctetCounting)
+	buf = AppendFrame(buf, []byte("world"), FramingNonTransparent)
+	buf = AppendFrame(buf, []byte("foo"), FramingAuto)
+	assert.Equal(t, "5 helloworld\n3 foo", string(buf))
+
+	frames, err := readFrames(t, NewFrameReader(strings.NewReader(string(buf)), FramingAuto, 0))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"hello", "world", "foo"}, frames)
+}
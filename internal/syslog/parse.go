@@ -0,0 +1,173 @@
+package syslog
+
+import (
+	"bytes"
+	"fmt"
+	"time"
+
+	gosyslog "github.com/influxdata/go-syslog/v3"
+	"github.com/influxdata/go-syslog/v3/rfc3164"
+	"github.com/influxdata/go-syslog/v3/rfc5424"
+)
+
+// Format describes a syslog message format.
+type Format string
+
+// The supported syslog formats.
+const (
+	FormatAuto    Format = "auto"
+	FormatRFC5424 Format = "rfc5424"
+	FormatRFC3164 Format = "rfc3164"
+)
+
+// ParseFormat attempts to parse a syslog format from a string.
+func ParseFormat(s string) (Format, error) {
+	switch f := Format(s); f {
+	case FormatAuto, FormatRFC5424, FormatRFC3164:
+		return f, nil
+	}
+	return "", fmt.Errorf("format not recognised: %v", s)
+}
+
+//------------------------------------------------------------------------------
+
+// Message is a parsed syslog message.
+type Message struct {
+	Facility *uint8
+	Severity *uint8
+	Hostname *string
+	Appname  *string
+
+	// Structured contains all fields of the message in a form that can be
+	// marshalled as a JSON object.
+	Structured map[string]interface{}
+}
+
+// Parser parses syslog messages of a given format.
+type Parser struct {
+	format  Format
+	rfc5424 gosyslog.Machine
+	rfc3164 gosyslog.Machine
+}
+
+// NewParser creates a syslog parser for a format, where auto detects whether
+// each message is RFC5424 or RFC3164 by the presence of a version number
+// following the priority.
+func NewParser(format Format, bestEffort bool) *Parser {
+	var opts5424, opts3164 []gosyslog.MachineOption
+	if bestEffort {
+		opts5424 = append(opts5424, rfc5424.WithBestEffort())
+		opts3164 = append(opts3164, rfc3164.WithBestEffort())
+	}
+	opts3164 = append(opts3164,
+		rfc3164.WithRFC3339(),
+		rfc3164.WithYear(rfc3164.CurrentYear{}),
+		rfc3164.WithTimezone(time.UTC),
+	)
+	return &Parser{
+		format:  format,
+		rfc5424: rfc5424.NewParser(opts5424...),
+		rfc3164: rfc3164.NewParser(opts3164...),
+	}
+}
+
+// isRFC5424 returns true if the message appears to be RFC5424, where the
+// priority is immediately followed by a non-zero version number and a space.
+func isRFC5424(msg []byte) bool {
+	i := bytes.IndexByte(msg, '>')
+	if i < 0 || i+2 >= len(msg) {
+		return false
+	}
+	rest := msg[i+1:]
+	j := 0
+	for j < len(rest) && j < 3 && rest[j] >= '0' && rest[j] <= '9' {
+		j++
+	}
+	return j > 0 && rest[0] != '0' && j < len(rest) && rest[j] == ' '
+}
+
+// Parse a syslog message.
+func (p *Parser) Parse(msg []byte) (*Message, error) {
+	format := p.format
+	if format == FormatAuto {
+		format = FormatRFC3164
+		if isRFC5424(msg) {
+			format = FormatRFC5424
+		}
+	}
+
+	if format == FormatRFC5424 {
+		res, err := p.rfc5424.Parse(msg)
+		if err != nil {
+			return nil, err
+		}
+		return fromRFC5424(res.(*rfc5424.SyslogMessage)), nil
+	}
+
+	res, err := p.rfc3164.Parse(msg)
+	if err != nil {
+		return nil, err
+	}
+	return fromRFC3164(res.(*rfc3164.SyslogMessage)), nil
+}
+
+func fromBase(b gosyslog.Base) *Message {
+	m := &Message{
+		Facility:   b.Facility,
+		Severity:   b.Severity,
+		Hostname:   b.Hostname,
+		Appname:    b.Appname,
+		Structured: map[string]interface{}{},
+	}
+	if b.Message != nil {
+		m.Structured["message"] = *b.Message
+	}
+	if b.Timestamp != nil {
+		m.Structured["timestamp"] = b.Timestamp.Format(time.RFC3339Nano)
+	}
+	if b.Facility != nil {
+		m.Structured["facility"] = *b.Facility
+	}
+	if b.Severity != nil {
+		m.Structured["severity"] = *b.Severity
+	}
+	if b.Priority != nil {
+		m.Structured["priority"] = *b.Priority
+	}
+	if b.Hostname != nil {
+		m.Structured["hostname"] = *b.Hostname
+	}
+	if b.ProcID != nil {
+		m.Structured["procid"] = *b.ProcID
+	}
+	if b.Appname != nil {
+		m.Structured["appname"] = *b.Appname
+	}
+	if b.MsgID != nil {
+		m.Structured["msgid"] = *b.MsgID
+	}
+	return m
+}
+
+func fromRFC5424(res *rfc5424.SyslogMessage) *Message {
+	m := fromBase(res.Base)
+	if res.Version != 0 {
+		m.Structured["version"] = res.Version
+	}
+	if res.StructuredData != nil {
+		sd := make(map[string]interface{}, len(*res.StructuredData))
+		for id, params := range *res.StructuredData {
+			p := make(map[string]interface{}, len(params))
+			for k, v := range params {
+				p[k] = v
+			}
+			sd[id] = p
+		}
+		m.Structured["structureddata"] = sd
+	}
+	return m
+}
+
+func fromRFC3164(res *rfc3164.SyslogMessage) *Message {
+	return fromBase(res.Base)
+}
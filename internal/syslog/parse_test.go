@@ -0,0 +1,71 @@
+package syslog
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseRFC5424(t *testing.T) {
+	p := NewParser(FormatAuto, true)
+
+	m, err := p.Parse([]byte(`<165>1 2003-10-11T22:14:15.003Z mymachine.example.com evntslog 1234 ID47 [exampleSDID@32473 iut="3" eventSource="Application"] An application event log entry...`))
+	require.NoError(t, err)
+
+	assert.Equal(t, map[string]interface{}{
+		"message":   "An application event log entry...",
+		"timestamp": "2003-10-11T22:14:15.003Z",
+		"facility":  uint8(20),
+		"severity":  uint8(5),
+		"priority":  uint8(165),
+		"version":   uint16(1),
+		"hostname":  "mymachine.example.com",
+		"procid":    "1234",
+		"appname":   "evntslog",
+		"msgid":     "ID47",
+		"structureddata": map[string]interface{}{
+			"exampleSDID@32473": map[string]interface{}{
+				"iut":         "3",
+				"eventSource": "Application",
+			},
+		},
+	}, m.Structured)
+	assert.Equal(t, uint8(20), *m.Facility)
+	assert.Equal(t, uint8(5), *m.Severity)
+	assert.Equal(t, "mymachine.example.com", *m.Hostname)
+	assert.Equal(t, "evntslog", *m.Appname)
+}
+
+func TestParseRFC3164(t *testing.T) {
+	p := NewParser(FormatAuto, true)
+
+	m, err := p.Parse([]byte(`<34>Oct 11 22:14:15 mymachine su: 'su root' failed for lonvick on /dev/pts/8`))
+	require.NoError(t, err)
+
+	assert.Equal(t, map[string]interface{}{
+		"message":   "'su root' failed for lonvick on /dev/pts/8",
+		"timestamp": fmt.Sprintf("%v-10-11T22:14:15Z", time.Now().Year()),
+		"facility":  uint8(4),
+		"severity":  uint8(2),
+		"priority":  uint8(34),
+		"hostname":  "mymachine",
+		"appname":   "su",
+	}, m.Structured)
+}
+
+func TestParseErrors(t *testing.T) {
+	_, err := NewParser(FormatRFC5424, false).Parse([]byte(`<34>Oct 11 22:14:15 mymachine su: hello`))
+	assert.Error(t, err)
+
+	_, err = NewParser(FormatAuto, false).Parse([]byte(`not a syslog message`))
+	assert.Error(t, err)
+
+	_, err = ParseFormat("nope")
+	assert.Error(t, err)
+
+	_, err = ParseFraming("nope")
+	assert.Error(t, err)
+}
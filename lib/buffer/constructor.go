@@ -60,8 +60,10 @@ var Constructors = map[string]TypeSpec{}
 
 // String constants representing each buffer type.
 const (
+	TypeDisk   = "disk"
 	TypeMemory = "memory"
 	TypeNone   = "none"
+	TypeWindow = "window"
 )
 
 //------------------------------------------------------------------------------
@@ -69,16 +71,20 @@ const (
 // Config is the all encompassing configuration struct for all buffer types.
 type Config struct {
 	Type   string       `json:"type" yaml:"type"`
+	Disk   DiskConfig   `json:"disk" yaml:"disk"`
 	Memory MemoryConfig `json:"memory" yaml:"memory"`
 	None   struct{}     `json:"none" yaml:"none"`
+	Window WindowConfig `json:"window" yaml:"window"`
 }
 
 // NewConfig returns a configuration struct fully populated with default values.
 func NewConfig() Config {
 	return Config{
 		Type:   "none",
+		Disk:   NewDiskConfig(),
 		Memory: NewMemoryConfig(),
 		None:   struct{}{},
+		Window: NewWindowConfig(),
 	}
 }
 
@@ -161,12 +167,14 @@ different options and their qualities:
 | Type      | Throughput | Consumers | Capacity |
 | --------- | ---------- | --------- | -------- |
 | Memory    | Highest    | Parallel  | RAM      |
+| Disk      | High       | Single    | Disk     |
 
 #### Delivery Guarantees
 
 | Event     | Shutdown  | Crash     | Disk Corruption |
 | --------- | --------- | --------- | --------------- |
 | Memory    | Flushed\* | Lost      | Lost            |
+| Disk      | Persisted | Persisted | Lost            |
 
 \* Makes a best attempt at flushing the remaining messages before closing
   gracefully.`
@@ -0,0 +1,96 @@
+package buffer
+
+import (
+	"fmt"
+
+	"github.com/Jeffail/benthos/v3/internal/docs"
+	"github.com/Jeffail/benthos/v3/lib/buffer/single"
+	"github.com/Jeffail/benthos/v3/lib/log"
+	"github.com/Jeffail/benthos/v3/lib/metrics"
+	"github.com/Jeffail/benthos/v3/lib/types"
+)
+
+//------------------------------------------------------------------------------
+
+func init() {
+	Constructors[TypeDisk] = TypeSpec{
+		constructor: NewDisk,
+		Summary: `
+Stores consumed messages in a write-ahead-log of segment files on disk and
+acknowledges them at the input level. Messages that have not been delivered
+when Benthos shuts down or crashes are replayed when it next starts.`,
+		Description: `
+Messages are appended to segment files within the configured directory, and
+once a segment reaches ` + "`segment_size`" + ` a new one is created. The
+position of the reader is tracked in a cursor file within the same directory,
+and segments are deleted once all of their messages have been delivered.
+
+When Benthos restarts any messages that were not yet delivered are read from the
+directory before new messages, and any record that was only partially written
+at the tail of the log (due to a crash) is truncated.
+
+This buffer supports only a single consumer and therefore messages are delivered
+in the order in which they were written.
+
+### Durability
+
+The ` + "`sync_policy`" + ` field determines how frequently writes are flushed
+to stable storage with fsync:
+
+- ` + "`always`" + `: Flush after every write and every acknowledgement. This
+  is the safest option but has the lowest throughput.
+- ` + "`interval`" + `: Flush periodically at the rate of ` + "`sync_interval`" + `.
+  A crash of the Benthos process will not lose data, but an operating system
+  crash or power loss may lose the writes of the last interval.
+- ` + "`none`" + `: Never explicitly flush and leave it to the operating system.
+
+### Capacity
+
+When the total size of undelivered messages reaches ` + "`max_size`" + `
+consumption is stopped with back pressure upstream until the backlog has been
+reduced. Set ` + "`max_size`" + ` to zero in order to remove this limit.
+
+### Retention
+
+When ` + "`max_age`" + ` is set segments that have not been written to
+within that duration are deleted, even if their messages have not yet been
+delivered, which prevents stale messages from being delivered after a long
+outage of the output. Deleted messages are logged and counted by the metric
+` + "`segments.expired`" + `.`,
+		FieldSpecs: docs.FieldSpecs{
+			docs.FieldCommon("directory", "The path of a directory in which to store segment files, which will be created if it does not exist."),
+			docs.FieldAdvanced("segment_size", "The maximum size (in bytes) of each segment file. Messages larger than this size are rejected."),
+			docs.FieldCommon("max_size", "The maximum total size (in bytes) of undelivered messages to allow before applying back pressure upstream. Set to zero to disable the limit."),
+			docs.FieldAdvanced("max_age", "An optional duration after which segments that have not been written to are deleted, including any messages within them that have not been delivered. Leave empty to keep messages until they are delivered.", "24h", "168h"),
+			docs.FieldAdvanced("sync_policy", "Determines when writes are flushed to stable storage.").HasOptions(
+				single.DiskSyncAlways, single.DiskSyncInterval, single.DiskSyncNone,
+			),
+			docs.FieldAdvanced("sync_interval", "The period at which writes are flushed to stable storage when the `sync_policy` is `interval`."),
+		},
+		Status:  docs.StatusExperimental,
+		Version: "3.47.0",
+	}
+}
+
+//------------------------------------------------------------------------------
+
+// DiskConfig is config values for a disk based write-ahead-log buffer type.
+type DiskConfig single.DiskConfig
+
+// NewDiskConfig creates a new DiskConfig with default values.
+func NewDiskConfig() DiskConfig {
+	return DiskConfig(single.NewDiskConfig())
+}
+
+//------------------------------------------------------------------------------
+
+// NewDisk creates a buffer persisted to a write-ahead-log on disk.
+func NewDisk(config Config, mgr types.Manager, log log.Modular, stats metrics.Type) (Type, error) {
+	d, err := single.NewDisk(single.DiskConfig(config.Disk), log, stats)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open disk buffer: %v", err)
+	}
+	return NewSingleWrapper(config, d, log, stats), nil
+}
+
+//------------------------------------------------------------------------------
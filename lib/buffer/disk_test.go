@@ -0,0 +1,95 @@
+package buffer
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/Jeffail/benthos/v3/lib/log"
+	"github.com/Jeffail/benthos/v3/lib/message"
+	"github.com/Jeffail/benthos/v3/lib/metrics"
+	"github.com/Jeffail/benthos/v3/lib/response"
+	"github.com/Jeffail/benthos/v3/lib/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiskBufferReplay(t *testing.T) {
+	dir, err := ioutil.TempDir("", "benthos_disk_test_")
+	require.NoError(t, err)
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	conf := NewConfig()
+	conf.Type = "disk"
+	conf.Disk.Directory = dir
+
+	buf, err := New(conf, nil, log.Noop(), metrics.Noop())
+	require.NoError(t, err)
+
+	tChan, resChan := make(chan types.Transaction), make(chan types.Response)
+	require.NoError(t, buf.Consume(tChan))
+
+	for _, content := range []string{"one", "two"} {
+		select {
+		case tChan <- types.NewTransaction(message.New([][]byte{[]byte(content)}), resChan):
+		case <-time.After(time.Second):
+			t.Fatal("Timed out")
+		}
+		select {
+		case res := <-resChan:
+			require.NoError(t, res.Error())
+		case <-time.After(time.Second):
+			t.Fatal("Timed out")
+		}
+	}
+
+	var outTr types.Transaction
+	select {
+	case outTr = <-buf.TransactionChan():
+		assert.Equal(t, "one", string(outTr.Payload.Get(0).Get()))
+	case <-time.After(time.Second):
+		t.Fatal("Timed out")
+	}
+	select {
+	case outTr.ResponseChan <- response.NewAck():
+	case <-time.After(time.Second):
+		t.Fatal("Timed out")
+	}
+
+	// Read the second message but shut down before it is delivered.
+	select {
+	case outTr = <-buf.TransactionChan():
+		assert.Equal(t, "two", string(outTr.Payload.Get(0).Get()))
+	case <-time.After(time.Second):
+		t.Fatal("Timed out")
+	}
+
+	buf.CloseAsync()
+	select {
+	case outTr.ResponseChan <- response.NewNoack():
+	case <-time.After(time.Second):
+		t.Fatal("Timed out")
+	}
+	require.NoError(t, buf.WaitForClose(time.Second*5))
+
+	if buf, err = New(conf, nil, log.Noop(), metrics.Noop()); err != nil {
+		t.Fatal(err)
+	}
+	require.NoError(t, buf.Consume(make(chan types.Transaction)))
+
+	select {
+	case outTr = <-buf.TransactionChan():
+		assert.Equal(t, "two", string(outTr.Payload.Get(0).Get()))
+	case <-time.After(time.Second):
+		t.Fatal("Timed out")
+	}
+	select {
+	case outTr.ResponseChan <- response.NewAck():
+	case <-time.After(time.Second):
+		t.Fatal("Timed out")
+	}
+
+	buf.CloseAsync()
+	require.NoError(t, buf.WaitForClose(time.Second*5))
+}
@@ -0,0 +1,625 @@
+package single
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Jeffail/benthos/v3/lib/log"
+	"github.com/Jeffail/benthos/v3/lib/message"
+	"github.com/Jeffail/benthos/v3/lib/metrics"
+	"github.com/Jeffail/benthos/v3/lib/types"
+)
+
+//------------------------------------------------------------------------------
+
+// Sync policies supported by the disk buffer.
+const (
+	DiskSyncAlways   = "always"
+	DiskSyncInterval = "interval"
+	DiskSyncNone     = "none"
+)
+
+const (
+	diskSegmentSuffix  = ".seg"
+	diskCursorFile     = "cursor"
+	diskRecordHeaderSz = 8
+)
+
+// DiskConfig is config options for a disk based write-ahead-log buffer.
+type DiskConfig struct {
+	Directory    string `json:"directory" yaml:"directory"`
+	SegmentSize  int    `json:"segment_size" yaml:"segment_size"`
+	MaxSize      int    `json:"max_size" yaml:"max_size"`
+	MaxAge       string `json:"max_age" yaml:"max_age"`
+	SyncPolicy   string `json:"sync_policy" yaml:"sync_policy"`
+	SyncInterval string `json:"sync_interval" yaml:"sync_interval"`
+}
+
+// NewDiskConfig creates a new DiskConfig with default values.
+func NewDiskConfig() DiskConfig {
+	return DiskConfig{
+		Directory:    "",
+		SegmentSize:  64 * 1024 * 1024,   // 64MiB
+		MaxSize:      1024 * 1024 * 1024, // 1GiB
+		MaxAge:       "",
+		SyncPolicy:   DiskSyncInterval,
+		SyncInterval: "1s",
+	}
+}
+
+//------------------------------------------------------------------------------
+
+// Disk is a buffer that persists messages to an append only log of segment
+// files within a directory. The position of the reader is tracked in a cursor
+// file and therefore unacknowledged messages are replayed when the buffer is
+// reopened.
+//
+// Each record within a segment is written as a four byte big endian length,
+// followed by a four byte CRC32 checksum of the payload, followed by the
+// serialised message payload.
+type Disk struct {
+	config DiskConfig
+	log    log.Modular
+
+	mSegments metrics.StatGauge
+	mExpired  metrics.StatCounter
+
+	syncInterval time.Duration
+	maxAge       time.Duration
+
+	segments []int
+
+	writeIndex  int
+	writeOffset int64
+	writeFile   *os.File
+
+	readIndex   int
+	readOffset  int64
+	readLimit   int64
+	readFile    *os.File
+	pendingSize int64
+
+	cursorFile *os.File
+	backlog    int64
+	dirty      bool
+
+	closed    bool
+	closeChan chan struct{}
+
+	cond *sync.Cond
+}
+
+// NewDisk opens (or creates) a disk based buffer within the configured
+// directory, any messages that were not shifted from a previous run are
+// replayed.
+func NewDisk(config DiskConfig, log log.Modular, stats metrics.Type) (*Disk, error) {
+	if config.Directory == "" {
+		return nil, errors.New("a directory must be specified")
+	}
+	if config.SegmentSize <= diskRecordHeaderSz {
+		return nil, fmt.Errorf("segment size must be greater than %v bytes", diskRecordHeaderSz)
+	}
+
+	d := &Disk{
+		config:    config,
+		log:       log,
+		mSegments: stats.GetGauge("segments"),
+		mExpired:  stats.GetCounter("segments.expired"),
+		closeChan: make(chan struct{}),
+		cond:      sync.NewCond(&sync.Mutex{}),
+	}
+
+	if config.MaxAge != "" {
+		var err error
+		if d.maxAge, err = time.ParseDuration(config.MaxAge); err != nil {
+			return nil, fmt.Errorf("failed to parse max age: %v", err)
+		}
+		if d.maxAge <= 0 {
+			return nil, errors.New("max age must be greater than zero")
+		}
+	}
+
+	switch config.SyncPolicy {
+	case DiskSyncAlways, DiskSyncNone:
+	case DiskSyncInterval:
+		var err error
+		if d.syncInterval, err = time.ParseDuration(config.SyncInterval); err != nil {
+			return nil, fmt.Errorf("failed to parse sync interval: %v", err)
+		}
+		if d.syncInterval <= 0 {
+			return nil, errors.New("sync interval must be greater than zero")
+		}
+	default:
+		return nil, fmt.Errorf("unrecognised sync policy: %v", config.SyncPolicy)
+	}
+
+	if err := os.MkdirAll(config.Directory, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create buffer directory: %v", err)
+	}
+	if err := d.recover(); err != nil {
+		d.closeFiles()
+		return nil, err
+	}
+
+	if d.backlog > 0 {
+		d.log.Infof("Replaying %v bytes of buffered messages from: %v\n", d.backlog, config.Directory)
+	}
+	if d.syncInterval > 0 {
+		go d.syncLoop()
+	}
+	if d.maxAge > 0 {
+		go d.expireLoop()
+	}
+	return d, nil
+}
+
+//------------------------------------------------------------------------------
+
+func (d *Disk) segmentPath(index int) string {
+	return filepath.Join(d.config.Directory, fmt.Sprintf("%020d%v", index, diskSegmentSuffix))
+}
+
+// recover reads the state of the directory and positions the reader and writer
+// accordingly. Partially written records at the tail of the log are truncated.
+func (d *Disk) recover() error {
+	infos, err := ioutil.ReadDir(d.config.Directory)
+	if err != nil {
+		return fmt.Errorf("failed to read buffer directory: %v", err)
+	}
+	for _, info := range infos {
+		name := info.Name()
+		if info.IsDir() || !strings.HasSuffix(name, diskSegmentSuffix) {
+			continue
+		}
+		index, err := strconv.Atoi(strings.TrimSuffix(name, diskSegmentSuffix))
+		if err != nil {
+			continue
+		}
+		d.segments = append(d.segments, index)
+	}
+	sort.Ints(d.segments)
+
+	if d.cursorFile, err = os.OpenFile(
+		filepath.Join(d.config.Directory, diskCursorFile), os.O_RDWR|os.O_CREATE, 0644,
+	); err != nil {
+		return fmt.Errorf("failed to open cursor file: %v", err)
+	}
+
+	cursor := make([]byte, 16)
+	if _, err := io.ReadFull(d.cursorFile, cursor); err == nil {
+		d.readIndex = int(binary.BigEndian.Uint64(cursor[:8]))
+		d.readOffset = int64(binary.BigEndian.Uint64(cursor[8:]))
+	}
+
+	// Drop segments that were fully consumed but not yet removed.
+	for len(d.segments) > 0 && d.segments[0] < d.readIndex {
+		if err := os.Remove(d.segmentPath(d.segments[0])); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove consumed segment: %v", err)
+		}
+		d.segments = d.segments[1:]
+	}
+	if len(d.segments) == 0 || d.segments[0] != d.readIndex {
+		// The segment pointed to by our cursor no longer exists, therefore
+		// begin reading from the start of the oldest segment.
+		d.readOffset = 0
+		if len(d.segments) > 0 {
+			d.readIndex = d.segments[0]
+		}
+	}
+
+	if len(d.segments) == 0 {
+		d.segments = []int{d.readIndex}
+	}
+	d.writeIndex = d.segments[len(d.segments)-1]
+	if d.writeFile, err = os.OpenFile(d.segmentPath(d.writeIndex), os.O_RDWR|os.O_CREATE, 0644); err != nil {
+		return fmt.Errorf("failed to open segment: %v", err)
+	}
+	if d.writeOffset, err = validSegmentLength(d.writeFile); err != nil {
+		return fmt.Errorf("failed to validate segment: %v", err)
+	}
+	if err = d.writeFile.Truncate(d.writeOffset); err != nil {
+		return fmt.Errorf("failed to truncate segment: %v", err)
+	}
+
+	for _, index := range d.segments {
+		if index == d.writeIndex {
+			d.backlog += d.writeOffset
+			continue
+		}
+		info, err := os.Stat(d.segmentPath(index))
+		if err != nil {
+			return fmt.Errorf("failed to stat segment: %v", err)
+		}
+		d.backlog += info.Size()
+	}
+	if d.readIndex == d.writeIndex && d.readOffset > d.writeOffset {
+		d.readOffset = d.writeOffset
+	}
+	d.backlog -= d.readOffset
+	d.mSegments.Set(int64(len(d.segments)))
+	return d.writeCursor()
+}
+
+// validSegmentLength scans the records of a segment and returns the length of
+// the segment up until the first incomplete or corrupted record.
+func validSegmentLength(f *os.File) (int64, error) {
+	info, err := f.Stat()
+	if err != nil {
+		return 0, err
+	}
+	size := info.Size()
+
+	var offset int64
+	header := make([]byte, diskRecordHeaderSz)
+	for offset+diskRecordHeaderSz <= size {
+		if _, err := f.ReadAt(header, offset); err != nil {
+			return 0, err
+		}
+		recLen := int64(binary.BigEndian.Uint32(header[:4]))
+		if recLen == 0 || offset+diskRecordHeaderSz+recLen > size {
+			break
+		}
+		payload := make([]byte, recLen)
+		if _, err := f.ReadAt(payload, offset+diskRecordHeaderSz); err != nil {
+			return 0, err
+		}
+		if crc32.ChecksumIEEE(payload) != binary.BigEndian.Uint32(header[4:]) {
+			break
+		}
+		offset += diskRecordHeaderSz + recLen
+	}
+	return offset, nil
+}
+
+//------------------------------------------------------------------------------
+
+// writeCursor persists the current read position.
+func (d *Disk) writeCursor() error {
+	cursor := make([]byte, 16)
+	binary.BigEndian.PutUint64(cursor[:8], uint64(d.readIndex))
+	binary.BigEndian.PutUint64(cursor[8:], uint64(d.readOffset))
+	_, err := d.cursorFile.WriteAt(cursor, 0)
+	return err
+}
+
+// sync flushes both the active segment and the cursor to stable storage.
+func (d *Disk) sync() error {
+	if !d.dirty {
+		return nil
+	}
+	if err := d.writeFile.Sync(); err != nil {
+		return err
+	}
+	if err := d.cursorFile.Sync(); err != nil {
+		return err
+	}
+	d.dirty = false
+	return nil
+}
+
+// markDirty flags that changes have been made and syncs them if the policy
+// requires it.
+func (d *Disk) markDirty() error {
+	d.dirty = true
+	if d.config.SyncPolicy == DiskSyncAlways {
+		return d.sync()
+	}
+	return nil
+}
+
+func (d *Disk) syncLoop() {
+	ticker := time.NewTicker(d.syncInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+		case <-d.closeChan:
+			return
+		}
+		d.cond.L.Lock()
+		if !d.closed {
+			if err := d.sync(); err != nil {
+				d.log.Errorf("Failed to sync buffer to disk: %v\n", err)
+			}
+		}
+		d.cond.L.Unlock()
+	}
+}
+
+func (d *Disk) expireLoop() {
+	interval := d.maxAge / 4
+	if interval > time.Minute {
+		interval = time.Minute
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+		case <-d.closeChan:
+			return
+		}
+		d.cond.L.Lock()
+		if !d.closed {
+			if err := d.expireSegments(); err != nil {
+				d.log.Errorf("Failed to expire buffer segments: %v\n", err)
+			}
+			d.cond.Broadcast()
+		}
+		d.cond.L.Unlock()
+	}
+}
+
+// expireSegments deletes the oldest segments that have not been written to
+// within the max age, regardless of whether their messages were delivered. The
+// write segment is rotated first when it has expired with undelivered messages.
+func (d *Disk) expireSegments() error {
+	for {
+		info, err := os.Stat(d.segmentPath(d.segments[0]))
+		if err != nil {
+			return err
+		}
+		if time.Since(info.ModTime()) < d.maxAge {
+			return nil
+		}
+		if len(d.segments) == 1 {
+			if d.readOffset >= d.writeOffset {
+				return nil
+			}
+			if err := d.rotate(); err != nil {
+				return err
+			}
+			continue
+		}
+
+		// Any message that has been read but not yet shifted is dropped along
+		// with the segment, and therefore a subsequent shift does nothing.
+		dropped := info.Size() - d.readOffset
+		d.backlog -= dropped
+		d.pendingSize = 0
+		if err := d.advanceReader(); err != nil {
+			return err
+		}
+		d.mExpired.Incr(1)
+		d.log.Warnf("Deleted buffer segment containing %v bytes of undelivered messages as it exceeded the max age\n", dropped)
+	}
+}
+
+// rotate closes the current write segment and opens the next.
+func (d *Disk) rotate() error {
+	if err := d.writeFile.Sync(); err != nil {
+		return err
+	}
+	if d.readIndex == d.writeIndex {
+		d.readLimit = d.writeOffset
+	}
+	nextIndex := d.writeIndex + 1
+	nextFile, err := os.OpenFile(d.segmentPath(nextIndex), os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	d.writeFile.Close()
+	d.writeFile = nextFile
+	d.writeIndex = nextIndex
+	d.writeOffset = 0
+	d.segments = append(d.segments, nextIndex)
+	d.mSegments.Set(int64(len(d.segments)))
+	return nil
+}
+
+// advanceReader moves the reader onto the next segment and deletes the
+// previous one, which is assumed to be fully consumed.
+func (d *Disk) advanceReader() error {
+	if d.readFile != nil {
+		d.readFile.Close()
+		d.readFile = nil
+	}
+	prevIndex := d.readIndex
+	d.segments = d.segments[1:]
+	d.mSegments.Set(int64(len(d.segments)))
+	d.readIndex = d.segments[0]
+	d.readOffset = 0
+	if err := d.writeCursor(); err != nil {
+		return err
+	}
+	if err := d.markDirty(); err != nil {
+		return err
+	}
+	if err := os.Remove(d.segmentPath(prevIndex)); err != nil && !os.IsNotExist(err) {
+		d.log.Errorf("Failed to remove consumed segment: %v\n", err)
+	}
+	return nil
+}
+
+// readEnd returns the offset at which the current read segment ends.
+func (d *Disk) readEnd() int64 {
+	if d.readIndex == d.writeIndex {
+		return d.writeOffset
+	}
+	return d.readLimit
+}
+
+func (d *Disk) closeFiles() {
+	if d.writeFile != nil {
+		d.writeFile.Close()
+	}
+	if d.readFile != nil {
+		d.readFile.Close()
+	}
+	if d.cursorFile != nil {
+		d.cursorFile.Close()
+	}
+}
+
+//------------------------------------------------------------------------------
+
+// CloseOnceEmpty closes the disk buffer once the backlog reaches 0.
+func (d *Disk) CloseOnceEmpty() {
+	defer func() {
+		d.cond.L.Unlock()
+		d.Close()
+	}()
+	d.cond.L.Lock()
+
+	// Until the backlog is cleared.
+	for d.backlog > 0 && !d.closed {
+		// Wait for a broadcast from our reader.
+		d.cond.Wait()
+	}
+}
+
+// Close unblocks any blocked calls, flushes pending writes to disk and closes
+// all open files. Messages that were not shifted remain on disk and will be
+// replayed when the buffer is next opened.
+func (d *Disk) Close() {
+	d.cond.L.Lock()
+	defer d.cond.L.Unlock()
+
+	if d.closed {
+		return
+	}
+	d.closed = true
+	close(d.closeChan)
+	d.cond.Broadcast()
+
+	if err := d.sync(); err != nil {
+		d.log.Errorf("Failed to sync buffer to disk: %v\n", err)
+	}
+	d.closeFiles()
+}
+
+// ShiftMessage removes the last message read. Returns the backlog count.
+func (d *Disk) ShiftMessage() (int, error) {
+	d.cond.L.Lock()
+	defer func() {
+		d.cond.Broadcast()
+		d.cond.L.Unlock()
+	}()
+
+	if d.closed || d.pendingSize == 0 {
+		return int(d.backlog), nil
+	}
+
+	d.readOffset += d.pendingSize
+	d.backlog -= d.pendingSize
+	d.pendingSize = 0
+
+	if err := d.writeCursor(); err != nil {
+		return int(d.backlog), err
+	}
+	return int(d.backlog), d.markDirty()
+}
+
+// NextMessage reads the next message, blocks until there's something to read.
+func (d *Disk) NextMessage() (types.Message, error) {
+	d.cond.L.Lock()
+	defer d.cond.L.Unlock()
+
+	for {
+		for d.readIndex == d.writeIndex && d.readOffset >= d.writeOffset && !d.closed {
+			d.cond.Wait()
+		}
+		if d.closed {
+			return nil, types.ErrTypeClosed
+		}
+		if d.readFile == nil {
+			var err error
+			if d.readFile, err = os.Open(d.segmentPath(d.readIndex)); err != nil {
+				return nil, err
+			}
+			info, err := d.readFile.Stat()
+			if err != nil {
+				return nil, err
+			}
+			d.readLimit = info.Size()
+		}
+		if d.readIndex == d.writeIndex || d.readOffset < d.readLimit {
+			break
+		}
+		if err := d.advanceReader(); err != nil {
+			return nil, err
+		}
+	}
+
+	end := d.readEnd()
+
+	header := make([]byte, diskRecordHeaderSz)
+	if _, err := d.readFile.ReadAt(header, d.readOffset); err != nil {
+		d.pendingSize = end - d.readOffset
+		return nil, types.ErrBlockCorrupted
+	}
+	recLen := int64(binary.BigEndian.Uint32(header[:4]))
+	if recLen == 0 || d.readOffset+diskRecordHeaderSz+recLen > end {
+		// We have no way of knowing where the next record starts, therefore
+		// skip the remainder of the segment.
+		d.pendingSize = end - d.readOffset
+		return nil, types.ErrBlockCorrupted
+	}
+
+	d.pendingSize = diskRecordHeaderSz + recLen
+	payload := make([]byte, recLen)
+	if _, err := d.readFile.ReadAt(payload, d.readOffset+diskRecordHeaderSz); err != nil {
+		return nil, err
+	}
+	if crc32.ChecksumIEEE(payload) != binary.BigEndian.Uint32(header[4:]) {
+		return nil, types.ErrBlockCorrupted
+	}
+	return message.FromBytes(payload)
+}
+
+// PushMessage pushes a new message, returns the backlog count.
+func (d *Disk) PushMessage(msg types.Message) (int, error) {
+	d.cond.L.Lock()
+	defer func() {
+		d.cond.Broadcast()
+		d.cond.L.Unlock()
+	}()
+
+	blob := message.ToBytes(msg)
+	recLen := int64(diskRecordHeaderSz + len(blob))
+	if recLen > int64(d.config.SegmentSize) {
+		return 0, types.ErrMessageTooLarge
+	}
+
+	// Block while the reader is catching up.
+	for d.config.MaxSize > 0 && d.backlog > 0 && d.backlog+recLen > int64(d.config.MaxSize) && !d.closed {
+		d.cond.Wait()
+	}
+	if d.closed {
+		return 0, types.ErrTypeClosed
+	}
+
+	if d.writeOffset+recLen > int64(d.config.SegmentSize) {
+		if err := d.rotate(); err != nil {
+			return int(d.backlog), fmt.Errorf("failed to rotate segment: %v", err)
+		}
+	}
+
+	record := make([]byte, recLen)
+	binary.BigEndian.PutUint32(record[:4], uint32(len(blob)))
+	binary.BigEndian.PutUint32(record[4:8], crc32.ChecksumIEEE(blob))
+	copy(record[diskRecordHeaderSz:], blob)
+
+	if _, err := d.writeFile.WriteAt(record, d.writeOffset); err != nil {
+		// Attempt to remove any partially written data.
+		d.writeFile.Truncate(d.writeOffset)
+		return int(d.backlog), err
+	}
+
+	d.writeOffset += recLen
+	d.backlog += recLen
+	return int(d.backlog), d.markDirty()
+}
+
+//------------------------------------------------------------------------------
@@ -0,0 +1,219 @@
+package single
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Jeffail/benthos/v3/lib/log"
+	"github.com/Jeffail/benthos/v3/lib/message"
+	"github.com/Jeffail/benthos/v3/lib/metrics"
+	"github.com/Jeffail/benthos/v3/lib/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestDisk(t *testing.T, dir string, segmentSize int) *Disk {
+	t.Helper()
+
+	conf := NewDiskConfig()
+	conf.Directory = dir
+	conf.SegmentSize = segmentSize
+	conf.SyncPolicy = DiskSyncAlways
+
+	d, err := NewDisk(conf, log.Noop(), metrics.Noop())
+	require.NoError(t, err)
+	return d
+}
+
+func TestDiskBasic(t *testing.T) {
+	dir, err := ioutil.TempDir("", "benthos_disk_test_")
+	require.NoError(t, err)
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	d := newTestDisk(t, dir, 100)
+	defer d.Close()
+
+	n := 50
+	for i := 0; i < n; i++ {
+		_, err := d.PushMessage(message.New([][]byte{
+			[]byte("hello"),
+			[]byte(fmt.Sprintf("test%v", i)),
+		}))
+		require.NoError(t, err)
+	}
+
+	for i := 0; i < n; i++ {
+		m, err := d.NextMessage()
+		require.NoError(t, err)
+		require.Equal(t, 2, m.Len())
+		assert.Equal(t, fmt.Sprintf("test%v", i), string(m.Get(1).Get()))
+
+		backlog, err := d.ShiftMessage()
+		require.NoError(t, err)
+		if i == n-1 {
+			assert.Equal(t, 0, backlog)
+		}
+	}
+
+	segments, err := filepath.Glob(filepath.Join(dir, "*"+diskSegmentSuffix))
+	require.NoError(t, err)
+	assert.Len(t, segments, 1)
+}
+
+func TestDiskReplay(t *testing.T) {
+	dir, err := ioutil.TempDir("", "benthos_disk_test_")
+	require.NoError(t, err)
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	d := newTestDisk(t, dir, 100)
+	for i := 0; i < 10; i++ {
+		_, err := d.PushMessage(message.New([][]byte{[]byte(fmt.Sprintf("test%v", i))}))
+		require.NoError(t, err)
+	}
+
+	for i := 0; i < 3; i++ {
+		_, err := d.NextMessage()
+		require.NoError(t, err)
+		_, err = d.ShiftMessage()
+		require.NoError(t, err)
+	}
+
+	// Read but do not shift, this message should be replayed.
+	m, err := d.NextMessage()
+	require.NoError(t, err)
+	assert.Equal(t, "test3", string(m.Get(0).Get()))
+	d.Close()
+
+	d = newTestDisk(t, dir, 100)
+	defer d.Close()
+
+	for i := 3; i < 10; i++ {
+		m, err := d.NextMessage()
+		require.NoError(t, err)
+		assert.Equal(t, fmt.Sprintf("test%v", i), string(m.Get(0).Get()))
+		_, err = d.ShiftMessage()
+		require.NoError(t, err)
+	}
+}
+
+func TestDiskTruncatedTail(t *testing.T) {
+	dir, err := ioutil.TempDir("", "benthos_disk_test_")
+	require.NoError(t, err)
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	d := newTestDisk(t, dir, 1000)
+	for i := 0; i < 2; i++ {
+		_, err := d.PushMessage(message.New([][]byte{[]byte(fmt.Sprintf("test%v", i))}))
+		require.NoError(t, err)
+	}
+	d.Close()
+
+	// Simulate a crash part way through writing a record.
+	f, err := os.OpenFile(filepath.Join(dir, fmt.Sprintf("%020d%v", 0, diskSegmentSuffix)), os.O_WRONLY|os.O_APPEND, 0644)
+	require.NoError(t, err)
+	_, err = f.Write([]byte{0, 0, 0, 100, 1, 2})
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	d = newTestDisk(t, dir, 1000)
+	defer d.Close()
+
+	_, err = d.PushMessage(message.New([][]byte{[]byte("test2")}))
+	require.NoError(t, err)
+
+	for i := 0; i < 3; i++ {
+		m, err := d.NextMessage()
+		require.NoError(t, err)
+		assert.Equal(t, fmt.Sprintf("test%v", i), string(m.Get(0).Get()))
+		_, err = d.ShiftMessage()
+		require.NoError(t, err)
+	}
+}
+
+func TestDiskTooLarge(t *testing.T) {
+	dir, err := ioutil.TempDir("", "benthos_disk_test_")
+	require.NoError(t, err)
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	d := newTestDisk(t, dir, 20)
+	defer d.Close()
+
+	_, err = d.PushMessage(message.New([][]byte{[]byte("this message is far too large")}))
+	assert.Equal(t, types.ErrMessageTooLarge, err)
+}
+
+func TestDiskCloseUnblocks(t *testing.T) {
+	dir, err := ioutil.TempDir("", "benthos_disk_test_")
+	require.NoError(t, err)
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	d := newTestDisk(t, dir, 100)
+
+	errChan := make(chan error)
+	go func() {
+		_, err := d.NextMessage()
+		errChan <- err
+	}()
+
+	d.Close()
+	assert.Equal(t, types.ErrTypeClosed, <-errChan)
+}
+
+func TestDiskMaxAge(t *testing.T) {
+	dir, err := ioutil.TempDir("", "benthos_disk_test_")
+	require.NoError(t, err)
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	conf := NewDiskConfig()
+	conf.Directory = dir
+	conf.SegmentSize = 100
+	conf.SyncPolicy = DiskSyncNone
+	conf.MaxAge = "50ms"
+
+	stats := metrics.NewLocal()
+	d, err := NewDisk(conf, log.Noop(), stats)
+	require.NoError(t, err)
+	defer d.Close()
+
+	for i := 0; i < 5; i++ {
+		_, err := d.PushMessage(message.New([][]byte{[]byte(fmt.Sprintf("stale%v", i))}))
+		require.NoError(t, err)
+	}
+
+	m, err := d.NextMessage()
+	require.NoError(t, err)
+	assert.Equal(t, "stale0", string(m.Get(0).Get()))
+
+	assert.Eventually(t, func() bool {
+		d.cond.L.Lock()
+		defer d.cond.L.Unlock()
+		return d.backlog == 0
+	}, time.Second, 10*time.Millisecond)
+
+	// The message in flight was expired along with its segment.
+	backlog, err := d.ShiftMessage()
+	require.NoError(t, err)
+	assert.Equal(t, 0, backlog)
+
+	_, err = d.PushMessage(message.New([][]byte{[]byte("fresh")}))
+	require.NoError(t, err)
+
+	m, err = d.NextMessage()
+	require.NoError(t, err)
+	assert.Equal(t, "fresh", string(m.Get(0).Get()))
+
+	assert.Greater(t, stats.GetCounters()["segments.expired"], int64(0))
+}
+
+func TestDiskBadMaxAge(t *testing.T) {
+	conf := NewDiskConfig()
+	conf.Directory = "./foo"
+	conf.MaxAge = "nope"
+
+	_, err := NewDisk(conf, log.Noop(), metrics.Noop())
+	require.Error(t, err)
+}
@@ -0,0 +1,595 @@
+package buffer
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync/atomic"
+	"time"
+
+	"github.com/Jeffail/benthos/v3/internal/bloblang"
+	"github.com/Jeffail/benthos/v3/internal/bloblang/mapping"
+	"github.com/Jeffail/benthos/v3/internal/bloblang/query"
+	"github.com/Jeffail/benthos/v3/internal/docs"
+	"github.com/Jeffail/benthos/v3/lib/log"
+	"github.com/Jeffail/benthos/v3/lib/message"
+	"github.com/Jeffail/benthos/v3/lib/metrics"
+	"github.com/Jeffail/benthos/v3/lib/processor"
+	"github.com/Jeffail/benthos/v3/lib/response"
+	"github.com/Jeffail/benthos/v3/lib/types"
+	"github.com/Jeffail/benthos/v3/lib/util/throttle"
+)
+
+//------------------------------------------------------------------------------
+
+func init() {
+	Constructors[TypeWindow] = TypeSpec{
+		constructor: NewWindow,
+		Summary: `
+Groups consumed messages into batches by event time windows, emitting one batch
+for each window once it has closed.`,
+		Description: `
+Each message consumed has a timestamp extracted from it using the
+` + "`timestamp_mapping`" + ` [Bloblang mapping](/docs/guides/bloblang/about),
+which determines the window (or windows) that the message belongs to. The
+mapping may result in either a timestamp, a unix timestamp number, or a
+string in RFC 3339 format. When the mapping fails the message is flagged with
+the error, which can be handled with [error handling
+patterns](/docs/configuration/error_handling), and is placed within the window
+of the highest event time observed so far.
+
+Messages are acknowledged at the input level once they have been assigned to
+windows, and therefore windows that have not yet closed are lost if Benthos
+crashes. During a graceful shutdown all open windows are flushed.
+
+### Modes
+
+- ` + "`tumbling`" + `: Fixed size, non-overlapping windows of duration ` + "`size`" + `.
+- ` + "`sliding`" + `: Fixed size windows of duration ` + "`size`" + ` that begin every
+  ` + "`slide`" + `, where a message may belong to multiple overlapping windows.
+- ` + "`session`" + `: Windows that grow for as long as messages keep arriving
+  within ` + "`gap`" + ` of each other.
+
+### Lateness
+
+Windows are closed when the watermark, which is the highest event time observed
+minus ` + "`allowed_lateness`" + `, passes the end of the window. Messages that
+only belong to windows that have already closed are considered late and are
+dropped, therefore the windows emitted depend only on the order and timestamps
+of the consumed messages.
+
+When ` + "`idle_timeout`" + ` is set all open windows are closed once no
+messages have been consumed for that duration, which prevents the final
+windows of a stream from being held indefinitely.
+
+### Metadata
+
+Each message of an emitted batch has the following metadata fields added:
+
+` + "```text" + `
+- window_start
+- window_end
+` + "```" + `
+
+Both of which are timestamps in RFC 3339 format.`,
+		FieldSpecs: docs.FieldSpecs{
+			docs.FieldCommon("mode", "The type of windows to create.").HasOptions(
+				"tumbling", "sliding", "session",
+			),
+			docs.FieldCommon(
+				"timestamp_mapping", "A [Bloblang mapping](/docs/guides/bloblang/about) that extracts the event timestamp of a message.",
+				`root = this.created_at`,
+				`root = meta("kafka_timestamp_unix").number()`,
+			).Linter(docs.LintBloblangMapping),
+			docs.FieldCommon("size", "The duration of windows, used by the `tumbling` and `sliding` modes.", "30s", "1h"),
+			docs.FieldCommon("slide", "The duration between the start of each window, used by the `sliding` mode.", "10s"),
+			docs.FieldCommon("gap", "The maximum duration between messages of the same session, used by the `session` mode.", "5m"),
+			docs.FieldCommon("allowed_lateness", "The duration by which the watermark trails the highest observed event time, giving out of order messages a chance to be included in their windows.", "10s"),
+			docs.FieldAdvanced("idle_timeout", "An optional duration after which all open windows are closed when no messages have been consumed. Leave empty to disable.", "1m"),
+		},
+		Status:  docs.StatusExperimental,
+		Version: "3.47.0",
+	}
+}
+
+//------------------------------------------------------------------------------
+
+// WindowConfig contains configuration fields for the window buffer type.
+type WindowConfig struct {
+	Mode             string `json:"mode" yaml:"mode"`
+	TimestampMapping string `json:"timestamp_mapping" yaml:"timestamp_mapping"`
+	Size             string `json:"size" yaml:"size"`
+	Slide            string `json:"slide" yaml:"slide"`
+	Gap              string `json:"gap" yaml:"gap"`
+	AllowedLateness  string `json:"allowed_lateness" yaml:"allowed_lateness"`
+	IdleTimeout      string `json:"idle_timeout" yaml:"idle_timeout"`
+}
+
+// NewWindowConfig creates a new WindowConfig with default values.
+func NewWindowConfig() WindowConfig {
+	return WindowConfig{
+		Mode:             "tumbling",
+		TimestampMapping: "root = now()",
+		Size:             "",
+		Slide:            "",
+		Gap:              "",
+		AllowedLateness:  "0s",
+		IdleTimeout:      "",
+	}
+}
+
+//------------------------------------------------------------------------------
+
+type windowPart struct {
+	ts   time.Time
+	part types.Part
+}
+
+type windowState struct {
+	start time.Time
+	end   time.Time
+	parts []windowPart
+}
+
+// Window is a buffer that groups messages into event time windows and emits a
+// batch for each window once it closes.
+type Window struct {
+	log log.Modular
+
+	mode      string
+	timestamp *mapping.Executor
+	size      time.Duration
+	slide     time.Duration
+	gap       time.Duration
+	lateness  time.Duration
+	idle      time.Duration
+
+	open      []*windowState
+	watermark time.Time
+
+	errThrottle *throttle.Type
+
+	mLate    metrics.StatCounter
+	mErr     metrics.StatCounter
+	mOpen    metrics.StatGauge
+	mEmitted metrics.StatCounter
+
+	running   int32
+	consuming int32
+
+	messagesIn  <-chan types.Transaction
+	messagesOut chan types.Transaction
+
+	stopConsumingChan chan struct{}
+	closeChan         chan struct{}
+	closedChan        chan struct{}
+}
+
+// NewWindow creates a buffer that groups messages into event time windows.
+func NewWindow(conf Config, mgr types.Manager, log log.Modular, stats metrics.Type) (Type, error) {
+	w := &Window{
+		log:               log,
+		mode:              conf.Window.Mode,
+		mLate:             stats.GetCounter("late"),
+		mErr:              stats.GetCounter("error"),
+		mOpen:             stats.GetGauge("windows.open"),
+		mEmitted:          stats.GetCounter("windows.emitted"),
+		running:           1,
+		consuming:         1,
+		messagesOut:       make(chan types.Transaction),
+		stopConsumingChan: make(chan struct{}),
+		closeChan:         make(chan struct{}),
+		closedChan:        make(chan struct{}),
+	}
+	w.errThrottle = throttle.New(throttle.OptCloseChan(w.closeChan))
+
+	var err error
+	if w.timestamp, err = bloblang.NewMapping("", conf.Window.TimestampMapping); err != nil {
+		return nil, fmt.Errorf("failed to parse timestamp mapping: %w", err)
+	}
+
+	parseDur := func(name, str string, required bool) (time.Duration, error) {
+		if str == "" {
+			if required {
+				return 0, fmt.Errorf("field %v is required for mode %v", name, w.mode)
+			}
+			return 0, nil
+		}
+		d, err := time.ParseDuration(str)
+		if err != nil {
+			return 0, fmt.Errorf("failed to parse %v: %w", name, err)
+		}
+		if d < 0 || (required && d == 0) {
+			return 0, fmt.Errorf("field %v must be greater than zero", name)
+		}
+		return d, nil
+	}
+
+	switch w.mode {
+	case "tumbling":
+		if w.size, err = parseDur("size", conf.Window.Size, true); err != nil {
+			return nil, err
+		}
+	case "sliding":
+		if w.size, err = parseDur("size", conf.Window.Size, true); err != nil {
+			return nil, err
+		}
+		if w.slide, err = parseDur("slide", conf.Window.Slide, true); err != nil {
+			return nil, err
+		}
+		if w.slide > w.size {
+			return nil, errors.New("field slide must not be greater than size")
+		}
+	case "session":
+		if w.gap, err = parseDur("gap", conf.Window.Gap, true); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("window mode not recognised: %v", w.mode)
+	}
+	if w.lateness, err = parseDur("allowed_lateness", conf.Window.AllowedLateness, false); err != nil {
+		return nil, err
+	}
+	if w.idle, err = parseDur("idle_timeout", conf.Window.IdleTimeout, false); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+//------------------------------------------------------------------------------
+
+func (w *Window) getTimestamp(index int, msg types.Message) (time.Time, error) {
+	v, err := w.timestamp.Exec(query.FunctionContext{
+		Maps:     w.timestamp.Maps(),
+		Vars:     map[string]interface{}{},
+		Index:    index,
+		MsgBatch: msg,
+	}.WithValueFunc(func() *interface{} {
+		jObj, err := msg.Get(index).JSON()
+		if err != nil {
+			return nil
+		}
+		return &jObj
+	}))
+	if err != nil {
+		return time.Time{}, err
+	}
+	return query.IGetTimestamp(v)
+}
+
+// windowStarts returns the start times of the tumbling or sliding windows that
+// a timestamp belongs to.
+func (w *Window) windowStarts(ts time.Time) []time.Time {
+	nanos := ts.UnixNano()
+	if w.mode == "tumbling" {
+		start := nanos - mod(nanos, int64(w.size))
+		return []time.Time{time.Unix(0, start).UTC()}
+	}
+
+	var starts []time.Time
+	lastStart := nanos - mod(nanos, int64(w.slide))
+	for s := lastStart; s > nanos-int64(w.size); s -= int64(w.slide) {
+		starts = append(starts, time.Unix(0, s).UTC())
+	}
+	return starts
+}
+
+func mod(a, b int64) int64 {
+	m := a % b
+	if m < 0 {
+		m += b
+	}
+	return m
+}
+
+// assign adds a message part to the windows it belongs to, returns false if the
+// part was late and therefore dropped.
+func (w *Window) assign(ts time.Time, part types.Part) bool {
+	if w.mode == "session" {
+		return w.assignSession(ts, part)
+	}
+
+	assigned := false
+	for _, start := range w.windowStarts(ts) {
+		end := start.Add(w.size)
+		if !end.After(w.watermark) {
+			continue
+		}
+		var target *windowState
+		for _, win := range w.open {
+			if win.start.Equal(start) {
+				target = win
+				break
+			}
+		}
+		if target == nil {
+			target = &windowState{start: start, end: end}
+			w.open = append(w.open, target)
+		}
+		target.parts = append(target.parts, windowPart{ts: ts, part: part.Copy()})
+		assigned = true
+	}
+	return assigned
+}
+
+func (w *Window) assignSession(ts time.Time, part types.Part) bool {
+	target := &windowState{
+		start: ts,
+		end:   ts.Add(w.gap),
+		parts: []windowPart{{ts: ts, part: part.Copy()}},
+	}
+
+	// Merge with any open sessions that the message bridges.
+	remaining := w.open[:0]
+	merged := false
+	for _, win := range w.open {
+		if ts.Before(win.start.Add(-w.gap)) || !ts.Before(win.end) {
+			remaining = append(remaining, win)
+			continue
+		}
+		merged = true
+		if win.start.Before(target.start) {
+			target.start = win.start
+		}
+		if win.end.After(target.end) {
+			target.end = win.end
+		}
+		target.parts = append(win.parts, target.parts...)
+	}
+	w.open = remaining
+
+	if !merged && !target.end.After(w.watermark) {
+		return false
+	}
+	w.open = append(w.open, target)
+	return true
+}
+
+// advance moves the watermark forward and returns any windows that are now
+// closed.
+func (w *Window) advance(watermark time.Time) []*windowState {
+	if watermark.After(w.watermark) {
+		w.watermark = watermark
+	}
+
+	var closed []*windowState
+	remaining := w.open[:0]
+	for _, win := range w.open {
+		if win.end.After(w.watermark) {
+			remaining = append(remaining, win)
+		} else {
+			closed = append(closed, win)
+		}
+	}
+	w.open = remaining
+	return closed
+}
+
+// flushAll closes all open windows and moves the watermark to the end of the
+// latest window so that subsequent messages belonging to them are late.
+func (w *Window) flushAll() []*windowState {
+	closed := w.open
+	w.open = nil
+	for _, win := range closed {
+		if win.end.After(w.watermark) {
+			w.watermark = win.end
+		}
+	}
+	return closed
+}
+
+func (w *Window) toBatches(closed []*windowState) []types.Message {
+	sort.SliceStable(closed, func(i, j int) bool {
+		if closed[i].end.Equal(closed[j].end) {
+			return closed[i].start.Before(closed[j].start)
+		}
+		return closed[i].end.Before(closed[j].end)
+	})
+
+	batches := make([]types.Message, 0, len(closed))
+	for _, win := range closed {
+		sort.SliceStable(win.parts, func(i, j int) bool {
+			return win.parts[i].ts.Before(win.parts[j].ts)
+		})
+		startStr, endStr := win.start.Format(time.RFC3339Nano), win.end.Format(time.RFC3339Nano)
+
+		msg := message.New(nil)
+		for _, p := range win.parts {
+			p.part.Metadata().Set("window_start", startStr)
+			p.part.Metadata().Set("window_end", endStr)
+			msg.Append(p.part)
+		}
+		batches = append(batches, msg)
+	}
+	return batches
+}
+
+//------------------------------------------------------------------------------
+
+// ingest assigns each message of a batch to windows and returns the batches of
+// any windows that were closed as a result.
+func (w *Window) ingest(msg types.Message) []types.Message {
+	timestamps := make([]time.Time, msg.Len())
+	failed := make([]error, msg.Len())
+
+	maxTS := w.watermark.Add(w.lateness)
+	msg.Iter(func(i int, p types.Part) error {
+		ts, err := w.getTimestamp(i, msg)
+		if err != nil {
+			failed[i] = fmt.Errorf("failed to extract timestamp: %w", err)
+			return nil
+		}
+		timestamps[i] = ts
+		if ts.After(maxTS) {
+			maxTS = ts
+		}
+		return nil
+	})
+
+	msg.Iter(func(i int, p types.Part) error {
+		if failed[i] != nil {
+			// Messages without a timestamp are flagged and placed within the
+			// window of the highest event time observed so far, or the
+			// current time if none have been observed.
+			w.mErr.Incr(1)
+			w.log.Debugf("Failed to assign message to window: %v\n", failed[i])
+			processor.FlagErr(p, failed[i])
+			if timestamps[i] = maxTS; maxTS.IsZero() {
+				timestamps[i] = time.Now()
+			}
+		}
+		if !w.assign(timestamps[i], p) {
+			w.mLate.Incr(1)
+			w.log.Debugf("Dropping late message with timestamp %v\n", timestamps[i])
+		}
+		return nil
+	})
+	return w.toBatches(w.advance(maxTS.Add(-w.lateness)))
+}
+
+// emit sends a batch downstream, retrying until it is acknowledged. Returns
+// false if the buffer was closed before the batch was delivered.
+func (w *Window) emit(msg types.Message) bool {
+	resChan := make(chan types.Response)
+	for {
+		// Prioritise closing over emitting as both may be ready at once.
+		select {
+		case <-w.closeChan:
+			return false
+		default:
+		}
+		select {
+		case w.messagesOut <- types.NewTransaction(msg, resChan):
+		case <-w.closeChan:
+			return false
+		}
+		var res types.Response
+		select {
+		case res = <-resChan:
+		case <-w.closeChan:
+			return false
+		}
+		if res.Error() == nil {
+			w.mEmitted.Incr(1)
+			w.errThrottle.Reset()
+			return true
+		}
+		w.mErr.Incr(1)
+		if !w.errThrottle.Retry() {
+			return false
+		}
+	}
+}
+
+func (w *Window) loop() {
+	defer func() {
+		close(w.messagesOut)
+		close(w.closedChan)
+	}()
+
+	var idleChan <-chan time.Time
+	var idleTimer *time.Timer
+	if w.idle > 0 {
+		idleTimer = time.NewTimer(w.idle)
+		defer idleTimer.Stop()
+		idleChan = idleTimer.C
+	}
+
+	emitAll := func(batches []types.Message) bool {
+		for _, b := range batches {
+			if !w.emit(b) {
+				return false
+			}
+		}
+		w.mOpen.Set(int64(len(w.open)))
+		return true
+	}
+
+	for atomic.LoadInt32(&w.consuming) == 1 {
+		var tr types.Transaction
+		var open bool
+		select {
+		case tr, open = <-w.messagesIn:
+			if !open {
+				emitAll(w.toBatches(w.flushAll()))
+				return
+			}
+		case <-idleChan:
+			idleTimer.Reset(w.idle)
+			if !emitAll(w.toBatches(w.flushAll())) {
+				return
+			}
+			continue
+		case <-w.stopConsumingChan:
+			emitAll(w.toBatches(w.flushAll()))
+			return
+		}
+
+		if idleTimer != nil {
+			if !idleTimer.Stop() {
+				<-idleTimer.C
+			}
+			idleTimer.Reset(w.idle)
+		}
+
+		batches := w.ingest(tr.Payload)
+		select {
+		case tr.ResponseChan <- response.NewAck():
+		case <-w.closeChan:
+			return
+		}
+		if !emitAll(batches) {
+			return
+		}
+	}
+	emitAll(w.toBatches(w.flushAll()))
+}
+
+//------------------------------------------------------------------------------
+
+// Consume assigns a messages channel for the output to read.
+func (w *Window) Consume(msgs <-chan types.Transaction) error {
+	if w.messagesIn != nil {
+		return types.ErrAlreadyStarted
+	}
+	w.messagesIn = msgs
+	go w.loop()
+	return nil
+}
+
+// TransactionChan returns the channel used for consuming messages from this
+// buffer.
+func (w *Window) TransactionChan() <-chan types.Transaction {
+	return w.messagesOut
+}
+
+// CloseAsync shuts down the buffer and stops processing messages. Unlike
+// StopConsuming, which flushes all open windows before closing, any windows
+// that are still open or awaiting acknowledgement are dropped. Call
+// StopConsuming and wait for the buffer to close in order to flush them.
+func (w *Window) CloseAsync() {
+	if atomic.CompareAndSwapInt32(&w.running, 1, 0) {
+		close(w.closeChan)
+	}
+	w.StopConsuming()
+}
+
+// StopConsuming instructs the buffer to stop consuming messages and to flush
+// all open windows.
+func (w *Window) StopConsuming() {
+	if atomic.CompareAndSwapInt32(&w.consuming, 1, 0) {
+		close(w.stopConsumingChan)
+	}
+}
+
+// WaitForClose blocks until the buffer has closed down.
+func (w *Window) WaitForClose(timeout time.Duration) error {
+	select {
+	case <-w.closedChan:
+	case <-time.After(timeout):
+		return types.ErrTimeout
+	}
+	return nil
+}
+
+//------------------------------------------------------------------------------
@@ -0,0 +1,250 @@
+package buffer
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/Jeffail/benthos/v3/lib/log"
+	"github.com/Jeffail/benthos/v3/lib/message"
+	"github.com/Jeffail/benthos/v3/lib/metrics"
+	"github.com/Jeffail/benthos/v3/lib/processor"
+	"github.com/Jeffail/benthos/v3/lib/response"
+	"github.com/Jeffail/benthos/v3/lib/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type windowResult struct {
+	start, end string
+	contents   []string
+}
+
+func runWindowBuffer(t *testing.T, conf Config, inputs []string, flush bool) []windowResult {
+	t.Helper()
+
+	buf, err := New(conf, nil, log.Noop(), metrics.Noop())
+	require.NoError(t, err)
+
+	tChan := make(chan types.Transaction)
+	require.NoError(t, buf.Consume(tChan))
+
+	var results []windowResult
+	resultsDone := make(chan struct{})
+	go func() {
+		defer close(resultsDone)
+		for tr := range buf.TransactionChan() {
+			var res windowResult
+			res.start = tr.Payload.Get(0).Metadata().Get("window_start")
+			res.end = tr.Payload.Get(0).Metadata().Get("window_end")
+			tr.Payload.Iter(func(i int, p types.Part) error {
+				res.contents = append(res.contents, string(p.Get()))
+				return nil
+			})
+			results = append(results, res)
+
+			// When the buffer is closed rather than flushed it may stop
+			// waiting for acknowledgements of emitted windows.
+			select {
+			case tr.ResponseChan <- response.NewAck():
+			case <-time.After(time.Millisecond * 100):
+			}
+		}
+	}()
+
+	resChan := make(chan types.Response)
+	for _, input := range inputs {
+		select {
+		case tChan <- types.NewTransaction(message.New([][]byte{[]byte(input)}), resChan):
+		case <-time.After(time.Second):
+			t.Fatal("Timed out")
+		}
+		select {
+		case res := <-resChan:
+			require.NoError(t, res.Error())
+		case <-time.After(time.Second):
+			t.Fatal("Timed out")
+		}
+	}
+
+	if flush {
+		buf.StopConsuming()
+	} else {
+		buf.CloseAsync()
+	}
+	require.NoError(t, buf.WaitForClose(time.Second*5))
+	<-resultsDone
+	return results
+}
+
+func windowDoc(ts int) string {
+	return fmt.Sprintf(`{"ts":"2021-01-01T00:00:%02dZ"}`, ts)
+}
+
+func TestWindowTumbling(t *testing.T) {
+	conf := NewConfig()
+	conf.Type = TypeWindow
+	conf.Window.Mode = "tumbling"
+	conf.Window.TimestampMapping = "root = this.ts"
+	conf.Window.Size = "10s"
+
+	results := runWindowBuffer(t, conf, []string{
+		windowDoc(1), windowDoc(5), windowDoc(12), windowDoc(3), windowDoc(25),
+	}, true)
+
+	assert.Equal(t, []windowResult{
+		{
+			start:    "2021-01-01T00:00:00Z",
+			end:      "2021-01-01T00:00:10Z",
+			contents: []string{windowDoc(1), windowDoc(5)},
+		},
+		{
+			start:    "2021-01-01T00:00:10Z",
+			end:      "2021-01-01T00:00:20Z",
+			contents: []string{windowDoc(12)},
+		},
+		{
+			start:    "2021-01-01T00:00:20Z",
+			end:      "2021-01-01T00:00:30Z",
+			contents: []string{windowDoc(25)},
+		},
+	}, results)
+}
+
+func TestWindowTumblingAllowedLateness(t *testing.T) {
+	conf := NewConfig()
+	conf.Type = TypeWindow
+	conf.Window.Mode = "tumbling"
+	conf.Window.TimestampMapping = "root = this.ts"
+	conf.Window.Size = "10s"
+	conf.Window.AllowedLateness = "5s"
+
+	results := runWindowBuffer(t, conf, []string{
+		windowDoc(1), windowDoc(12), windowDoc(3), windowDoc(16), windowDoc(4),
+	}, false)
+
+	assert.Equal(t, []windowResult{
+		{
+			start:    "2021-01-01T00:00:00Z",
+			end:      "2021-01-01T00:00:10Z",
+			contents: []string{windowDoc(1), windowDoc(3)},
+		},
+	}, results)
+}
+
+func TestWindowSliding(t *testing.T) {
+	conf := NewConfig()
+	conf.Type = TypeWindow
+	conf.Window.Mode = "sliding"
+	conf.Window.TimestampMapping = "root = this.ts"
+	conf.Window.Size = "10s"
+	conf.Window.Slide = "5s"
+
+	results := runWindowBuffer(t, conf, []string{
+		windowDoc(1), windowDoc(7), windowDoc(12),
+	}, true)
+
+	assert.Equal(t, []windowResult{
+		{
+			start:    "2020-12-31T23:59:55Z",
+			end:      "2021-01-01T00:00:05Z",
+			contents: []string{windowDoc(1)},
+		},
+		{
+			start:    "2021-01-01T00:00:00Z",
+			end:      "2021-01-01T00:00:10Z",
+			contents: []string{windowDoc(1), windowDoc(7)},
+		},
+		{
+			start:    "2021-01-01T00:00:05Z",
+			end:      "2021-01-01T00:00:15Z",
+			contents: []string{windowDoc(7), windowDoc(12)},
+		},
+		{
+			start:    "2021-01-01T00:00:10Z",
+			end:      "2021-01-01T00:00:20Z",
+			contents: []string{windowDoc(12)},
+		},
+	}, results)
+}
+
+func TestWindowSession(t *testing.T) {
+	conf := NewConfig()
+	conf.Type = TypeWindow
+	conf.Window.Mode = "session"
+	conf.Window.TimestampMapping = "root = this.ts"
+	conf.Window.Gap = "5s"
+	conf.Window.AllowedLateness = "3s"
+
+	results := runWindowBuffer(t, conf, []string{
+		windowDoc(1), windowDoc(4), windowDoc(10), windowDoc(7), windowDoc(20), windowDoc(2), windowDoc(30),
+	}, true)
+
+	assert.Equal(t, []windowResult{
+		{
+			start:    "2021-01-01T00:00:01Z",
+			end:      "2021-01-01T00:00:15Z",
+			contents: []string{windowDoc(1), windowDoc(4), windowDoc(7), windowDoc(10)},
+		},
+		{
+			start:    "2021-01-01T00:00:20Z",
+			end:      "2021-01-01T00:00:25Z",
+			contents: []string{windowDoc(20)},
+		},
+		{
+			start:    "2021-01-01T00:00:30Z",
+			end:      "2021-01-01T00:00:35Z",
+			contents: []string{windowDoc(30)},
+		},
+	}, results)
+}
+
+func TestWindowBadTimestamp(t *testing.T) {
+	conf := NewConfig()
+	conf.Type = TypeWindow
+	conf.Window.TimestampMapping = "root = this.ts"
+	conf.Window.Size = "10s"
+
+	buf, err := New(conf, nil, log.Noop(), metrics.Noop())
+	require.NoError(t, err)
+
+	tChan, resChan := make(chan types.Transaction), make(chan types.Response)
+	require.NoError(t, buf.Consume(tChan))
+
+	// A message without a valid timestamp is acknowledged and flagged rather
+	// than rejected, and is placed within the current window.
+	tChan <- types.NewTransaction(message.New([][]byte{
+		[]byte(windowDoc(1)),
+		[]byte(`{"ts":"nope"}`),
+	}), resChan)
+	require.NoError(t, (<-resChan).Error())
+
+	tChan <- types.NewTransaction(message.New([][]byte{[]byte(windowDoc(12))}), resChan)
+	require.NoError(t, (<-resChan).Error())
+
+	var tr types.Transaction
+	select {
+	case tr = <-buf.TransactionChan():
+	case <-time.After(time.Second * 5):
+		t.Fatal("Timed out")
+	}
+	require.Equal(t, 2, tr.Payload.Len())
+	assert.Equal(t, "2021-01-01T00:00:00Z", tr.Payload.Get(1).Metadata().Get("window_start"))
+	assert.Equal(t, `{"ts":"nope"}`, string(tr.Payload.Get(1).Get()))
+	assert.Contains(t, processor.GetFail(tr.Payload.Get(1)), "failed to extract timestamp")
+	assert.Empty(t, processor.GetFail(tr.Payload.Get(0)))
+	tr.ResponseChan <- response.NewAck()
+
+	buf.CloseAsync()
+	require.NoError(t, buf.WaitForClose(time.Second*5))
+}
+
+func TestWindowBadConfig(t *testing.T) {
+	conf := NewConfig()
+	conf.Type = TypeWindow
+	conf.Window.Mode = "sliding"
+	conf.Window.Size = "10s"
+
+	_, err := New(conf, nil, log.Noop(), metrics.Noop())
+	assert.EqualError(t, err, "field slide is required for mode sliding")
+}
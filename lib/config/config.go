@@ -221,6 +221,12 @@ func AddExamples(conf *Type, examples ...string) {
 // Read will attempt to read a configuration file path into a structure. Returns
 // an array of lint messages or an error.
 func Read(path string, replaceEnvs bool, config *Type) ([]string, error) {
+	return ReadWithLintContext(docs.NewLintContext(), path, replaceEnvs, config)
+}
+
+// ReadWithLintContext behaves the same as Read but lints the config with a
+// custom lint context, which allows optional lint rules to be enabled.
+func ReadWithLintContext(ctx docs.LintContext, path string, replaceEnvs bool, config *Type) ([]string, error) {
 	configBytes, lints, err := ReadWithJSONPointersLinted(path, replaceEnvs)
 	if err != nil {
 		return nil, err
@@ -230,7 +236,7 @@ func Read(path string, replaceEnvs bool, config *Type) ([]string, error) {
 		return nil, err
 	}
 
-	newLints, err := Lint(configBytes, *config)
+	newLints, err := LintWithContext(ctx, configBytes, *config)
 	if err != nil {
 		return nil, err
 	}
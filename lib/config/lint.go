@@ -10,7 +10,14 @@ import (
 
 // Lint attempts to report errors within a user config. Returns a slice of lint
 // results.
-func Lint(rawBytes []byte, _ Type) ([]string, error) {
+func Lint(rawBytes []byte, conf Type) ([]string, error) {
+	return LintWithContext(docs.NewLintContext(), rawBytes, conf)
+}
+
+// LintWithContext attempts to report errors within a user config using a
+// custom lint context, which allows optional lint rules to be enabled. Returns
+// a slice of lint results.
+func LintWithContext(ctx docs.LintContext, rawBytes []byte, _ Type) ([]string, error) {
 	if bytes.HasPrefix(rawBytes, []byte("# BENTHOS LINT DISABLE")) {
 		return nil, nil
 	}
@@ -28,7 +35,7 @@ func Lint(rawBytes []byte, _ Type) ([]string, error) {
 	}
 
 	var lintStrs []string
-	for _, lint := range Spec().LintNode(docs.NewLintContext(), rawNode.Content[0]) {
+	for _, lint := range Spec().LintNode(ctx, rawNode.Content[0]) {
 		if lint.Level == docs.LintError {
 			lintStrs = append(lintStrs, fmt.Sprintf("line %v: %v", lint.Line, lint.What))
 		}
@@ -4,6 +4,7 @@ import (
 	"reflect"
 	"testing"
 
+	"github.com/Jeffail/benthos/v3/internal/docs"
 	"github.com/Jeffail/benthos/v3/lib/config"
 	_ "github.com/Jeffail/benthos/v3/public/components/all"
 )
@@ -167,4 +168,43 @@ tests:
 	}
 }
 
+func TestConfigLintsBloblang(t *testing.T) {
+	conf := `pipeline:
+  processors:
+    - bloblang: |
+        let foo = this.foo
+        root.bar   =  this.bar
+`
+
+	lints, err := config.Lint([]byte(conf), config.New())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(lints) > 0 {
+		t.Errorf("Unexpected lint results: %v", lints)
+	}
+
+	ctx := docs.NewLintContext()
+	ctx.LintBloblang = true
+
+	lints, err = config.LintWithContext(ctx, []byte(conf), config.New())
+	if err != nil {
+		t.Fatal(err)
+	}
+	exp := []string{
+		"line 4: variable foo is declared but never used",
+	}
+	if !reflect.DeepEqual(exp, lints) {
+		t.Errorf("Wrong lint results: %v != %v", lints, exp)
+	}
+
+	var fmtLevels []docs.LintLevel
+	for _, l := range docs.LintBloblangMapping(ctx, 0, 0, "root.bar   =  this.bar") {
+		fmtLevels = append(fmtLevels, l.Level)
+	}
+	if exp := []docs.LintLevel{docs.LintWarning}; !reflect.DeepEqual(exp, fmtLevels) {
+		t.Errorf("Wrong lint levels for unformatted mapping: %v != %v", fmtLevels, exp)
+	}
+}
+
 //------------------------------------------------------------------------------
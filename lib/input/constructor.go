@@ -239,10 +239,12 @@ const (
 	TypeSequence          = "sequence"
 	TypeSFTP              = "sftp"
 	TypeSocket            = "socket"
+	TypeSQLSelect         = "sql_select"
 	TypeSocketServer      = "socket_server"
 	TypeSQS               = "sqs"
 	TypeSTDIN             = "stdin"
 	TypeSubprocess        = "subprocess"
+	TypeSyslog            = "syslog"
 	TypeTCP               = "tcp"
 	TypeTCPServer         = "tcp_server"
 	TypeUDPServer         = "udp_server"
@@ -298,10 +300,12 @@ type Config struct {
 	Sequence          SequenceConfig               `json:"sequence" yaml:"sequence"`
 	SFTP              SFTPConfig                   `json:"sftp" yaml:"sftp"`
 	Socket            SocketConfig                 `json:"socket" yaml:"socket"`
+	SQLSelect         SQLSelectConfig              `json:"sql_select" yaml:"sql_select"`
 	SocketServer      SocketServerConfig           `json:"socket_server" yaml:"socket_server"`
 	SQS               reader.AmazonSQSConfig       `json:"sqs" yaml:"sqs"`
 	STDIN             STDINConfig                  `json:"stdin" yaml:"stdin"`
 	Subprocess        SubprocessConfig             `json:"subprocess" yaml:"subprocess"`
+	Syslog            SyslogConfig                 `json:"syslog" yaml:"syslog"`
 	TCP               TCPConfig                    `json:"tcp" yaml:"tcp"`
 	TCPServer         TCPServerConfig              `json:"tcp_server" yaml:"tcp_server"`
 	UDPServer         UDPServerConfig              `json:"udp_server" yaml:"udp_server"`
@@ -357,10 +361,12 @@ func NewConfig() Config {
 		Sequence:          NewSequenceConfig(),
 		SFTP:              NewSFTPConfig(),
 		Socket:            NewSocketConfig(),
+		SQLSelect:         NewSQLSelectConfig(),
 		SocketServer:      NewSocketServerConfig(),
 		SQS:               reader.NewAmazonSQSConfig(),
 		STDIN:             NewSTDINConfig(),
 		Subprocess:        NewSubprocessConfig(),
+		Syslog:            NewSyslogConfig(),
 		TCP:               NewTCPConfig(),
 		TCPServer:         NewTCPServerConfig(),
 		UDPServer:         NewUDPServerConfig(),
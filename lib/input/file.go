@@ -34,6 +34,12 @@ Consumes data from files on disk, emitting messages according to a chosen codec.
 			docs.FieldDeprecated("delimiter"),
 			docs.FieldDeprecated("multipart"),
 			docs.FieldAdvanced("delete_on_finish", "Whether to delete consumed files from the disk once they are fully consumed."),
+			docs.FieldAdvanced("follow", "Follow files for appended data rather than finishing once they have been consumed, similar to `tail -F`.").WithChildren(
+				docs.FieldCommon("enabled", "Whether to follow files."),
+				docs.FieldAdvanced("poll_interval", "The period at which files are checked for new data, and paths are expanded in order to find new and rotated files."),
+				docs.FieldAdvanced("cache", "An optional [cache resource](/docs/components/caches/about) in which to store the acknowledged offsets of each file, allowing consumption to resume where it left off after a restart."),
+				docs.FieldAdvanced("start_at_end", "Whether files that exist when the input starts, and have no stored offset, should be consumed from their end rather than their beginning."),
+			).AtVersion("3.47.0"),
 		},
 		Description: `
 ### Metadata
@@ -45,7 +51,33 @@ This input adds the following metadata fields to each message:
 ` + "```" + `
 
 You can access these metadata fields using
-[function interpolation](/docs/configuration/interpolation#metadata).`,
+[function interpolation](/docs/configuration/interpolation#metadata).
+
+### Following Files
+
+When ` + "`follow.enabled`" + ` is set to ` + "`true`" + ` files are not
+finished once they have been consumed, and instead are watched for appended
+data. The configured paths are expanded periodically and therefore new files
+that match a glob pattern are also consumed.
+
+Files are tracked by their inode rather than their path, and so when a file is
+rotated (renamed and replaced) the remaining data of the old file is consumed
+before it is closed and the new file is followed from its beginning. When a file
+is truncated, or its beginning is rewritten, it is consumed again from its
+beginning.
+
+The offsets of each file that have been acknowledged are stored within the
+cache resource specified by ` + "`follow.cache`" + `, keyed by inode, and so if
+the cache is persisted then consumption resumes exactly where it left off after
+a restart. A checksum of the beginning of each file is stored along with its
+offset, and when it no longer matches, such as when a new file has reused the
+inode of a deleted one, the stored offset is ignored.
+
+Only the ` + "`lines`, `delim` and `multiline`" + ` codecs are supported when
+following files, where a ` + "`multiline`" + ` record that is still being
+written is flushed once no further lines have been appended within its timeout.
+The fields ` + "`delete_on_finish` and `multipart`" + ` cannot be used when
+following files.`,
 		Categories: []Category{
 			CategoryLocal,
 		},
@@ -58,6 +90,24 @@ input:
   file:
     paths: [ ./data/*.csv ]
     codec: csv
+`,
+			},
+			{
+				Title:   "Follow Log Files",
+				Summary: "In this example we follow a directory of log files, including those that are rotated, and persist our progress to a file based cache:",
+				Config: `
+input:
+  file:
+    paths: [ ./logs/**/*.log ]
+    codec: lines
+    follow:
+      enabled: true
+      cache: offsets
+
+cache_resources:
+  - label: offsets
+    file:
+      directory: ./offsets
 `,
 			},
 		},
@@ -68,13 +118,32 @@ input:
 
 // FileConfig contains configuration values for the File input type.
 type FileConfig struct {
-	Path           string   `json:"path" yaml:"path"`
-	Paths          []string `json:"paths" yaml:"paths"`
-	Codec          string   `json:"codec" yaml:"codec"`
-	Multipart      bool     `json:"multipart" yaml:"multipart"`
-	MaxBuffer      int      `json:"max_buffer" yaml:"max_buffer"`
-	Delim          string   `json:"delimiter" yaml:"delimiter"`
-	DeleteOnFinish bool     `json:"delete_on_finish" yaml:"delete_on_finish"`
+	Path           string           `json:"path" yaml:"path"`
+	Paths          []string         `json:"paths" yaml:"paths"`
+	Codec          string           `json:"codec" yaml:"codec"`
+	Multipart      bool             `json:"multipart" yaml:"multipart"`
+	MaxBuffer      int              `json:"max_buffer" yaml:"max_buffer"`
+	Delim          string           `json:"delimiter" yaml:"delimiter"`
+	DeleteOnFinish bool             `json:"delete_on_finish" yaml:"delete_on_finish"`
+	Follow         FileFollowConfig `json:"follow" yaml:"follow"`
+}
+
+// FileFollowConfig contains configuration values for following files.
+type FileFollowConfig struct {
+	Enabled      bool   `json:"enabled" yaml:"enabled"`
+	PollInterval string `json:"poll_interval" yaml:"poll_interval"`
+	Cache        string `json:"cache" yaml:"cache"`
+	StartAtEnd   bool   `json:"start_at_end" yaml:"start_at_end"`
+}
+
+// NewFileFollowConfig creates a new FileFollowConfig with default values.
+func NewFileFollowConfig() FileFollowConfig {
+	return FileFollowConfig{
+		Enabled:      false,
+		PollInterval: "1s",
+		Cache:        "",
+		StartAtEnd:   false,
+	}
 }
 
 // NewFileConfig creates a new FileConfig with default values.
@@ -88,6 +157,7 @@ func NewFileConfig() FileConfig {
 		MaxBuffer:      1000000,
 		Delim:          "",
 		DeleteOnFinish: false,
+		Follow:         NewFileFollowConfig(),
 	}
 }
 
@@ -104,6 +174,13 @@ func NewFile(conf Config, mgr types.Manager, log log.Modular, stats metrics.Type
 	if conf.File.Multipart && !strings.HasSuffix(conf.File.Codec, "/multipart") {
 		conf.File.Codec += "/multipart"
 	}
+	if conf.File.Follow.Enabled {
+		rdr, err := newFileFollowConsumer(conf.File, mgr, log)
+		if err != nil {
+			return nil, err
+		}
+		return NewAsyncReader(TypeFile, true, reader.NewAsyncPreserver(rdr), log, stats)
+	}
 	rdr, err := newFileConsumer(conf.File, log)
 	if err != nil {
 		return nil, err
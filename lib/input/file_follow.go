@@ -0,0 +1,611 @@
+package input
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Jeffail/benthos/v3/internal/codec"
+	"github.com/Jeffail/benthos/v3/internal/filepath"
+	"github.com/Jeffail/benthos/v3/lib/input/reader"
+	"github.com/Jeffail/benthos/v3/lib/log"
+	"github.com/Jeffail/benthos/v3/lib/message"
+	"github.com/Jeffail/benthos/v3/lib/types"
+)
+
+//------------------------------------------------------------------------------
+
+const (
+	fileFollowCacheKeyPrefix = "file_follow_"
+
+	// The number of bytes at the beginning of a file that are used in order to
+	// detect when it has been truncated and rewritten, or when a stored offset
+	// belongs to a different file that has since reused its identity.
+	fileFollowFingerprintSize = 1024
+)
+
+// followPosition is the acknowledged offset of a file along with a checksum of
+// the first fpLen bytes of the file.
+type followPosition struct {
+	offset int64
+	fpLen  int
+	fp     uint32
+}
+
+func (p followPosition) String() string {
+	return fmt.Sprintf("%v:%v:%x", p.offset, p.fpLen, p.fp)
+}
+
+// matches returns whether the beginning of a file is the same as when the
+// position was stored.
+func (p followPosition) matches(head []byte) bool {
+	return p.fpLen <= len(head) && crc32.ChecksumIEEE(head[:p.fpLen]) == p.fp
+}
+
+func parseFollowPosition(s string) (followPosition, error) {
+	var p followPosition
+	parts := strings.Split(s, ":")
+	if len(parts) != 3 {
+		return p, fmt.Errorf("expected three segments, got %v", len(parts))
+	}
+	var err error
+	if p.offset, err = strconv.ParseInt(parts[0], 10, 64); err != nil {
+		return p, err
+	}
+	if p.fpLen, err = strconv.Atoi(parts[1]); err != nil {
+		return p, err
+	}
+	fp, err := strconv.ParseUint(parts[2], 16, 32)
+	if err != nil {
+		return p, err
+	}
+	p.fp = uint32(fp)
+	return p, nil
+}
+
+// readHead reads the bytes of a file that are used for its fingerprint.
+func readHead(file *os.File, size int64) ([]byte, error) {
+	if size > fileFollowFingerprintSize {
+		size = fileFollowFingerprintSize
+	}
+	head := make([]byte, size)
+	n, err := file.ReadAt(head, 0)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	return head[:n], nil
+}
+
+type followedRecord struct {
+	end   int64
+	acked bool
+}
+
+// followedFile tracks the read and acknowledged positions of a file that is
+// being followed.
+type followedFile struct {
+	id   string
+	path string
+
+	file    *os.File
+	buf     []byte
+	offset  int64 // The offset of the start of buf within the file.
+	readPos int64 // The offset at which the next read of the file begins.
+
+	orphaned bool
+
+	// Lines that are yet to be joined into a record by a multiline codec.
+	lines    [][]byte
+	linesEnd int64
+	lastLine time.Time
+
+	ackMut    sync.Mutex
+	gen       int
+	pending   []*followedRecord
+	committed int64
+	fpLen     int
+	fp        uint32
+}
+
+// reset moves the reader back to the beginning of the file, used when the file
+// has been truncated.
+func (f *followedFile) reset() error {
+	if _, err := f.file.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	f.buf = nil
+	f.offset = 0
+	f.readPos = 0
+	f.lines = nil
+
+	f.ackMut.Lock()
+	f.gen++
+	f.pending = nil
+	f.committed = 0
+	f.fpLen, f.fp = 0, 0
+	f.ackMut.Unlock()
+	return nil
+}
+
+// position returns the acknowledged position of the file.
+func (f *followedFile) position() followPosition {
+	f.ackMut.Lock()
+	defer f.ackMut.Unlock()
+	return followPosition{offset: f.committed, fpLen: f.fpLen, fp: f.fp}
+}
+
+// updateFingerprint checks that the beginning of the file matches the
+// fingerprint taken previously, returning false if it does not, and otherwise
+// extends the fingerprint to cover any bytes written since.
+func (f *followedFile) updateFingerprint(size int64) (bool, error) {
+	head, err := readHead(f.file, size)
+	if err != nil {
+		return false, err
+	}
+
+	f.ackMut.Lock()
+	defer f.ackMut.Unlock()
+	if !(followPosition{fpLen: f.fpLen, fp: f.fp}).matches(head) {
+		return false, nil
+	}
+	f.fpLen, f.fp = len(head), crc32.ChecksumIEEE(head)
+	return true, nil
+}
+
+// next attempts to extract the next delimited record from the file, returning
+// the record and the offset at which it ends.
+func (f *followedFile) next(delim []byte, maxBuffer int) ([]byte, int64, bool, error) {
+	readBuf := make([]byte, 32*1024)
+	for {
+		if i := bytes.Index(f.buf, delim); i >= 0 {
+			record := f.buf[:i]
+			consumed := int64(i + len(delim))
+			f.buf = f.buf[consumed:]
+			f.offset += consumed
+			return record, f.offset, true, nil
+		}
+
+		n, err := f.file.Read(readBuf)
+		if n > 0 {
+			f.buf = append(f.buf, readBuf[:n]...)
+			f.readPos += int64(n)
+			continue
+		}
+		if err != nil && err != io.EOF {
+			return nil, 0, false, err
+		}
+
+		// We've caught up with the end of the file. If the file will no longer
+		// be written to, or the record is too large, then flush what remains.
+		if len(f.buf) > 0 && (f.orphaned || len(f.buf) >= maxBuffer) {
+			record := f.buf
+			f.offset += int64(len(f.buf))
+			f.buf = nil
+			return record, f.offset, true, nil
+		}
+		return nil, 0, false, nil
+	}
+}
+
+// flushLines joins the pending lines of a multiline codec into a record.
+func (f *followedFile) flushLines() ([]byte, int64, bool, error) {
+	record := bytes.Join(f.lines, []byte("\n"))
+	f.lines = nil
+	return record, f.linesEnd, true, nil
+}
+
+// nextMultiline attempts to extract the next record of a multiline codec from
+// the file, returning the record and the offset at which its last line ends.
+func (f *followedFile) nextMultiline(ml *codec.MultilineCodec, maxBuffer int) ([]byte, int64, bool, error) {
+	for {
+		line, end, ok, err := f.next([]byte("\n"), maxBuffer)
+		if err != nil {
+			return nil, 0, false, err
+		}
+		if !ok {
+			// No more lines are expected for a while, or at all, and so the
+			// pending record is flushed.
+			if len(f.lines) > 0 && (f.orphaned || time.Since(f.lastLine) >= ml.FlushAfter()) {
+				return f.flushLines()
+			}
+			return nil, 0, false, nil
+		}
+		if len(line) == 0 {
+			if len(f.lines) == 0 {
+				return line, end, true, nil
+			}
+			continue
+		}
+		f.lastLine = time.Now()
+		line = append([]byte(nil), line...)
+
+		if !ml.Appends(line) && len(f.lines) > 0 {
+			record, recordEnd, _, _ := f.flushLines()
+			f.lines, f.linesEnd = [][]byte{line}, end
+			return record, recordEnd, true, nil
+		}
+
+		f.lines, f.linesEnd = append(f.lines, line), end
+		if ml.MaxLines() > 0 && len(f.lines) >= ml.MaxLines() {
+			return f.flushLines()
+		}
+	}
+}
+
+// track registers a record as pending acknowledgement and returns a function
+// that acknowledges it.
+func (f *followedFile) track(end int64, commit func(id string, pos followPosition)) func() {
+	f.ackMut.Lock()
+	gen := f.gen
+	rec := &followedRecord{end: end}
+	f.pending = append(f.pending, rec)
+	f.ackMut.Unlock()
+
+	return func() {
+		f.ackMut.Lock()
+		defer f.ackMut.Unlock()
+
+		if gen != f.gen {
+			return
+		}
+		rec.acked = true
+
+		// Offsets can only be committed once all prior records are also
+		// acknowledged.
+		advanced := false
+		for len(f.pending) > 0 && f.pending[0].acked {
+			f.committed = f.pending[0].end
+			f.pending = f.pending[1:]
+			advanced = true
+		}
+		if advanced {
+			commit(f.id, followPosition{offset: f.committed, fpLen: f.fpLen, fp: f.fp})
+		}
+	}
+}
+
+//------------------------------------------------------------------------------
+
+// fileFollowConsumer consumes delimited records from files as they are appended
+// to, tracking rotations and truncations of the files.
+type fileFollowConsumer struct {
+	log log.Modular
+
+	patterns     []string
+	delim        []byte
+	multiline    *codec.MultilineCodec
+	maxBuffer    int
+	pollInterval time.Duration
+	startAtEnd   bool
+	cache        types.Cache
+
+	mut       sync.Mutex
+	files     map[string]*followedFile
+	order     []string
+	nextIndex int
+	scanned   bool
+	closed    bool
+}
+
+func newFileFollowConsumer(conf FileConfig, mgr types.Manager, log log.Modular) (*fileFollowConsumer, error) {
+	if conf.DeleteOnFinish {
+		return nil, errors.New("delete_on_finish cannot be used when following files, as followed files are never finished")
+	}
+	if conf.Multipart {
+		return nil, errors.New("multipart cannot be used when following files")
+	}
+
+	f := &fileFollowConsumer{
+		log:        log,
+		patterns:   conf.Paths,
+		maxBuffer:  conf.MaxBuffer,
+		startAtEnd: conf.Follow.StartAtEnd,
+		files:      map[string]*followedFile{},
+	}
+
+	switch {
+	case conf.Codec == "lines":
+		f.delim = []byte("\n")
+	case strings.HasPrefix(conf.Codec, "delim:"):
+		if f.delim = []byte(strings.TrimPrefix(conf.Codec, "delim:")); len(f.delim) == 0 {
+			return nil, errors.New("delim codec requires a non-empty delimiter")
+		}
+	case strings.HasPrefix(conf.Codec, "multiline:"):
+		var err error
+		if f.multiline, err = codec.ParseMultilineCodec(conf.Codec); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("codec '%v' is not supported when following files, only lines, delim and multiline codecs can be used", conf.Codec)
+	}
+
+	var err error
+	if f.pollInterval, err = time.ParseDuration(conf.Follow.PollInterval); err != nil {
+		return nil, fmt.Errorf("failed to parse poll interval: %v", err)
+	}
+	if conf.Follow.Cache != "" {
+		if f.cache, err = mgr.GetCache(conf.Follow.Cache); err != nil {
+			return nil, err
+		}
+	}
+	return f, nil
+}
+
+//------------------------------------------------------------------------------
+
+func (f *fileFollowConsumer) storedPosition(id string) (followPosition, bool) {
+	if f.cache == nil {
+		return followPosition{}, false
+	}
+	posBytes, err := f.cache.Get(fileFollowCacheKeyPrefix + id)
+	if err != nil {
+		if err != types.ErrKeyNotFound {
+			f.log.Errorf("Failed to obtain stored offset of file: %v\n", err)
+		}
+		return followPosition{}, false
+	}
+	pos, err := parseFollowPosition(string(posBytes))
+	if err != nil {
+		f.log.Errorf("Failed to parse stored offset of file: %v\n", err)
+		return followPosition{}, false
+	}
+	return pos, true
+}
+
+func (f *fileFollowConsumer) commitPosition(id string, pos followPosition) {
+	if f.cache == nil {
+		return
+	}
+	if err := f.cache.Set(fileFollowCacheKeyPrefix+id, []byte(pos.String())); err != nil {
+		f.log.Errorf("Failed to store offset of file: %v\n", err)
+	}
+}
+
+func (f *fileFollowConsumer) openFile(path string) (*followedFile, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	head, err := readHead(file, info.Size())
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	ff := &followedFile{
+		id:    fileIdentity(path, info),
+		path:  path,
+		file:  file,
+		fpLen: len(head),
+		fp:    crc32.ChecksumIEEE(head),
+	}
+
+	// A stored position is only used when the beginning of the file is
+	// unchanged, otherwise it belongs to a file that has since been replaced
+	// or rewritten.
+	var start int64
+	if pos, exists := f.storedPosition(ff.id); exists && pos.offset <= info.Size() && pos.matches(head) {
+		start = pos.offset
+	} else if f.startAtEnd && !f.scanned {
+		start = info.Size()
+	}
+	if start > 0 {
+		if _, err = file.Seek(start, io.SeekStart); err != nil {
+			file.Close()
+			return nil, err
+		}
+	}
+	ff.offset, ff.readPos, ff.committed = start, start, start
+	return ff, nil
+}
+
+// scan expands the configured paths and begins following any new files, files
+// that are no longer found are marked as orphaned and will be closed once they
+// are fully consumed.
+func (f *fileFollowConsumer) scan() error {
+	paths, err := filepath.Globs(f.patterns)
+	if err != nil {
+		return err
+	}
+
+	seen := map[string]struct{}{}
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil || !info.Mode().IsRegular() {
+			continue
+		}
+
+		id := fileIdentity(path, info)
+		seen[id] = struct{}{}
+
+		if ff, exists := f.files[id]; exists {
+			ff.path = path
+			unchanged, err := ff.updateFingerprint(info.Size())
+			if err != nil {
+				f.log.Errorf("Failed to read file '%v': %v\n", path, err)
+				continue
+			}
+			if !unchanged || info.Size() < ff.readPos {
+				f.log.Infof("Detected truncation of file '%v', consuming from the beginning\n", path)
+				if err := ff.reset(); err != nil {
+					return err
+				}
+				if _, err := ff.updateFingerprint(info.Size()); err != nil {
+					return err
+				}
+				f.commitPosition(ff.id, ff.position())
+			}
+			continue
+		}
+
+		ff, err := f.openFile(path)
+		if err != nil {
+			f.log.Errorf("Failed to open file '%v': %v\n", path, err)
+			continue
+		}
+		if _, exists := f.files[ff.id]; exists {
+			// The file was replaced between our stat and open calls.
+			ff.file.Close()
+			continue
+		}
+		seen[ff.id] = struct{}{}
+		f.files[ff.id] = ff
+		f.order = append(f.order, ff.id)
+		f.log.Infof("Following file '%v'\n", path)
+	}
+	f.scanned = true
+
+	for id, ff := range f.files {
+		if _, exists := seen[id]; !exists {
+			ff.orphaned = true
+		}
+	}
+	return nil
+}
+
+func (f *fileFollowConsumer) removeFile(id string) {
+	if ff, exists := f.files[id]; exists {
+		ff.file.Close()
+		delete(f.files, id)
+	}
+	for i, oid := range f.order {
+		if oid == id {
+			f.order = append(f.order[:i], f.order[i+1:]...)
+			break
+		}
+	}
+}
+
+//------------------------------------------------------------------------------
+
+// ConnectWithContext performs the initial scan of the configured paths.
+func (f *fileFollowConsumer) ConnectWithContext(ctx context.Context) error {
+	f.mut.Lock()
+	defer f.mut.Unlock()
+
+	if f.closed {
+		return types.ErrTypeClosed
+	}
+	if f.scanned {
+		return nil
+	}
+	return f.scan()
+}
+
+// readNext attempts to read a record from each followed file in turn.
+func (f *fileFollowConsumer) readNext() (types.Message, reader.AsyncAckFn, error) {
+	for attempts := len(f.order); attempts > 0; attempts-- {
+		if f.nextIndex >= len(f.order) {
+			f.nextIndex = 0
+		}
+		ff := f.files[f.order[f.nextIndex]]
+
+		var record []byte
+		var end int64
+		var ok bool
+		var err error
+		if f.multiline != nil {
+			record, end, ok, err = ff.nextMultiline(f.multiline, f.maxBuffer)
+		} else {
+			record, end, ok, err = ff.next(f.delim, f.maxBuffer)
+		}
+		if err != nil {
+			f.log.Errorf("Failed to read file '%v': %v\n", ff.path, err)
+			f.removeFile(ff.id)
+			continue
+		}
+		if !ok {
+			if ff.orphaned {
+				f.log.Infof("Finished following file '%v'\n", ff.path)
+				f.removeFile(ff.id)
+			} else {
+				f.nextIndex++
+			}
+			continue
+		}
+		f.nextIndex++
+
+		ackFn := ff.track(end, f.commitPosition)
+		if len(record) == 0 {
+			// Empty records are skipped without consuming an attempt.
+			ackFn()
+			attempts++
+			continue
+		}
+
+		part := message.NewPart(append([]byte(nil), record...))
+		part.Metadata().Set("path", ff.path)
+		msg := message.New(nil)
+		msg.Append(part)
+		return msg, func(ctx context.Context, res types.Response) error {
+			if res.Error() == nil {
+				ackFn()
+			}
+			return nil
+		}, nil
+	}
+	return nil, nil, nil
+}
+
+// ReadWithContext attempts to read a new message from the followed files.
+func (f *fileFollowConsumer) ReadWithContext(ctx context.Context) (types.Message, reader.AsyncAckFn, error) {
+	f.mut.Lock()
+	if f.closed {
+		f.mut.Unlock()
+		return nil, nil, types.ErrTypeClosed
+	}
+	msg, ackFn, err := f.readNext()
+	f.mut.Unlock()
+	if msg != nil || err != nil {
+		return msg, ackFn, err
+	}
+
+	select {
+	case <-time.After(f.pollInterval):
+	case <-ctx.Done():
+		return nil, nil, types.ErrTimeout
+	}
+
+	f.mut.Lock()
+	defer f.mut.Unlock()
+	if f.closed {
+		return nil, nil, types.ErrTypeClosed
+	}
+	if err := f.scan(); err != nil {
+		return nil, nil, err
+	}
+	if msg, ackFn, err = f.readNext(); msg != nil || err != nil {
+		return msg, ackFn, err
+	}
+	return nil, nil, types.ErrTimeout
+}
+
+// CloseAsync begins cleaning up resources used by this reader asynchronously.
+func (f *fileFollowConsumer) CloseAsync() {
+	f.mut.Lock()
+	f.closed = true
+	for id := range f.files {
+		f.removeFile(id)
+	}
+	f.mut.Unlock()
+}
+
+// WaitForClose will block until either the reader is closed or a specified
+// timeout occurs.
+func (f *fileFollowConsumer) WaitForClose(time.Duration) error {
+	return nil
+}
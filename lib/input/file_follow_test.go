@@ -0,0 +1,257 @@
+package input
+
+import (
+	"hash/crc32"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Jeffail/benthos/v3/lib/cache"
+	"github.com/Jeffail/benthos/v3/lib/log"
+	"github.com/Jeffail/benthos/v3/lib/metrics"
+	"github.com/Jeffail/benthos/v3/lib/response"
+	"github.com/Jeffail/benthos/v3/lib/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func appendToFile(t *testing.T, path, content string) {
+	t.Helper()
+
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	require.NoError(t, err)
+	_, err = f.WriteString(content)
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+}
+
+func readFollowed(t *testing.T, in Type, expected ...string) {
+	t.Helper()
+
+	for _, exp := range expected {
+		var ts types.Transaction
+		select {
+		case ts = <-in.TransactionChan():
+			assert.Equal(t, exp, string(ts.Payload.Get(0).Get()))
+		case <-time.After(time.Second * 5):
+			t.Fatalf("Timed out waiting for message: %v", exp)
+		}
+		select {
+		case ts.ResponseChan <- response.NewAck():
+		case <-time.After(time.Second):
+			t.Fatal("Timed out waiting for response")
+		}
+	}
+}
+
+type fakeCacheMgr struct {
+	fakeProcMgr
+	caches map[string]types.Cache
+}
+
+func (f *fakeCacheMgr) GetCache(name string) (types.Cache, error) {
+	if c, exists := f.caches[name]; exists {
+		return c, nil
+	}
+	return nil, types.ErrCacheNotFound
+}
+
+func readFollowedUnordered(t *testing.T, in Type, expected ...string) {
+	t.Helper()
+
+	exp := map[string]struct{}{}
+	for _, e := range expected {
+		exp[e] = struct{}{}
+	}
+
+	act := map[string]struct{}{}
+	for range expected {
+		var ts types.Transaction
+		select {
+		case ts = <-in.TransactionChan():
+			act[string(ts.Payload.Get(0).Get())] = struct{}{}
+		case <-time.After(time.Second * 5):
+			t.Fatal("Timed out waiting for message")
+		}
+		select {
+		case ts.ResponseChan <- response.NewAck():
+		case <-time.After(time.Second):
+			t.Fatal("Timed out waiting for response")
+		}
+	}
+	assert.Equal(t, exp, act)
+}
+
+func newFollowMgr(t *testing.T) types.Manager {
+	t.Helper()
+
+	memCache, err := cache.NewMemory(cache.NewConfig(), nil, log.Noop(), metrics.Noop())
+	require.NoError(t, err)
+
+	return &fakeCacheMgr{
+		caches: map[string]types.Cache{
+			"offsets": memCache,
+		},
+	}
+}
+
+func TestFileFollow(t *testing.T) {
+	dir, err := ioutil.TempDir("", "benthos_file_follow_test")
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		os.RemoveAll(dir)
+	})
+
+	logPath := filepath.Join(dir, "foo.log")
+	appendToFile(t, logPath, "first\nsecond\n")
+
+	mgr := newFollowMgr(t)
+
+	conf := NewConfig()
+	conf.File.Paths = []string{filepath.Join(dir, "*.log")}
+	conf.File.Follow.Enabled = true
+	conf.File.Follow.PollInterval = "10ms"
+	conf.File.Follow.Cache = "offsets"
+
+	f, err := NewFile(conf, mgr, log.Noop(), metrics.Noop())
+	require.NoError(t, err)
+
+	readFollowed(t, f, "first", "second")
+
+	// Partial lines should not be consumed until they are terminated.
+	appendToFile(t, logPath, "thi")
+	appendToFile(t, logPath, "rd\n")
+	readFollowed(t, f, "third")
+
+	// New files that match the pattern are consumed.
+	appendToFile(t, filepath.Join(dir, "bar.log"), "from bar\n")
+	readFollowed(t, f, "from bar")
+
+	// Rotated files are consumed to their end and the new file followed.
+	appendToFile(t, logPath, "fourth\n")
+	require.NoError(t, os.Rename(logPath, filepath.Join(dir, "foo.log.1")))
+	appendToFile(t, logPath, "fifth\n")
+	readFollowedUnordered(t, f, "fourth", "fifth")
+
+	f.CloseAsync()
+	require.NoError(t, f.WaitForClose(time.Second*5))
+
+	// After a restart we resume from the stored offsets.
+	appendToFile(t, logPath, "sixth\n")
+	appendToFile(t, filepath.Join(dir, "bar.log"), "more from bar\n")
+
+	f, err = NewFile(conf, mgr, log.Noop(), metrics.Noop())
+	require.NoError(t, err)
+
+	readFollowedUnordered(t, f, "sixth", "more from bar")
+
+	// Truncated files are consumed again from the beginning.
+	require.NoError(t, os.Truncate(logPath, 0))
+	time.Sleep(time.Millisecond * 100)
+	appendToFile(t, logPath, "new\n")
+	readFollowed(t, f, "new")
+
+	f.CloseAsync()
+	require.NoError(t, f.WaitForClose(time.Second*5))
+}
+
+func TestFileFollowRewritten(t *testing.T) {
+	dir, err := ioutil.TempDir("", "benthos_file_follow_test")
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		os.RemoveAll(dir)
+	})
+
+	logPath := filepath.Join(dir, "foo.log")
+	appendToFile(t, logPath, "first\nsecond\n")
+
+	mgr := newFollowMgr(t)
+	c, err := mgr.GetCache("offsets")
+	require.NoError(t, err)
+
+	// A stored position of a different file that shares the same identity
+	// must not be used.
+	info, err := os.Stat(logPath)
+	require.NoError(t, err)
+	stale := followPosition{offset: 6, fpLen: 6, fp: crc32.ChecksumIEEE([]byte("other\n"))}
+	require.NoError(t, c.Set(fileFollowCacheKeyPrefix+fileIdentity(logPath, info), []byte(stale.String())))
+
+	conf := NewConfig()
+	conf.File.Paths = []string{logPath}
+	conf.File.Follow.Enabled = true
+	conf.File.Follow.PollInterval = "10ms"
+	conf.File.Follow.Cache = "offsets"
+
+	f, err := NewFile(conf, mgr, log.Noop(), metrics.Noop())
+	require.NoError(t, err)
+
+	readFollowed(t, f, "first", "second")
+
+	// A file that is truncated and rewritten beyond the previous read position
+	// between polls is consumed again from its beginning.
+	require.NoError(t, ioutil.WriteFile(logPath, []byte("rewritten and much longer\n"), 0644))
+	readFollowed(t, f, "rewritten and much longer")
+
+	f.CloseAsync()
+	require.NoError(t, f.WaitForClose(time.Second*5))
+}
+
+func TestFileFollowMultiline(t *testing.T) {
+	dir, err := ioutil.TempDir("", "benthos_file_follow_test")
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		os.RemoveAll(dir)
+	})
+
+	logPath := filepath.Join(dir, "foo.log")
+	appendToFile(t, logPath, "first\n  at foo\n  at bar\nsecond\n")
+
+	conf := NewConfig()
+	conf.File.Paths = []string{logPath}
+	conf.File.Codec = `multiline:timeout=50ms:start:^\S`
+	conf.File.Follow.Enabled = true
+	conf.File.Follow.PollInterval = "10ms"
+
+	f, err := NewFile(conf, nil, log.Noop(), metrics.Noop())
+	require.NoError(t, err)
+
+	// The final record is flushed once no more lines arrive within the
+	// timeout.
+	readFollowed(t, f, "first\n  at foo\n  at bar", "second")
+
+	appendToFile(t, logPath, "third\n  at baz\n")
+	readFollowed(t, f, "third\n  at baz")
+
+	f.CloseAsync()
+	require.NoError(t, f.WaitForClose(time.Second*5))
+}
+
+func TestFileFollowBadConfig(t *testing.T) {
+	conf := NewConfig()
+	conf.File.Paths = []string{"./foo.log"}
+	conf.File.Follow.Enabled = true
+
+	conf.File.DeleteOnFinish = true
+	_, err := NewFile(conf, nil, log.Noop(), metrics.Noop())
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "delete_on_finish")
+
+	conf.File.DeleteOnFinish = false
+	conf.File.Multipart = true
+	_, err = NewFile(conf, nil, log.Noop(), metrics.Noop())
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "multipart")
+}
+
+func TestFileFollowBadCodec(t *testing.T) {
+	conf := NewConfig()
+	conf.File.Paths = []string{"./foo.csv"}
+	conf.File.Codec = "csv"
+	conf.File.Follow.Enabled = true
+
+	_, err := NewFile(conf, nil, log.Noop(), metrics.Noop())
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "not supported when following files")
+}
@@ -0,0 +1,18 @@
+// +build !windows
+
+package input
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+)
+
+// fileIdentity returns a string that uniquely identifies a file on disk
+// regardless of its path, allowing rotated files to be tracked.
+func fileIdentity(path string, info os.FileInfo) string {
+	if stat, ok := info.Sys().(*syscall.Stat_t); ok {
+		return fmt.Sprintf("%v:%v", stat.Dev, stat.Ino)
+	}
+	return path
+}
@@ -0,0 +1,13 @@
+// +build windows
+
+package input
+
+import (
+	"os"
+)
+
+// fileIdentity returns a string that identifies a file on disk, on Windows this
+// is the path of the file.
+func fileIdentity(path string, info os.FileInfo) string {
+	return path
+}
@@ -0,0 +1,308 @@
+package input
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Jeffail/benthos/v3/lib/cache"
+	"github.com/Jeffail/benthos/v3/lib/log"
+	"github.com/Jeffail/benthos/v3/lib/metrics"
+	"github.com/Jeffail/benthos/v3/lib/processor"
+	"github.com/Jeffail/benthos/v3/lib/response"
+	"github.com/Jeffail/benthos/v3/lib/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// sqlSelectFakeDriver serves rows of a single table with the columns id and
+// name, where rows are filtered by the last argument of a query (if any) being
+// less than the id.
+type sqlSelectFakeDriver struct {
+	mut     sync.Mutex
+	rows    [][]driver.Value
+	limit   int
+	queries []string
+	args    [][]driver.Value
+}
+
+var sqlSelectTestDriver = &sqlSelectFakeDriver{}
+
+func init() {
+	sql.Register("sql_select_fake", sqlSelectTestDriver)
+}
+
+func (d *sqlSelectFakeDriver) reset(limit int, rows ...[]driver.Value) {
+	d.mut.Lock()
+	d.rows = rows
+	d.limit = limit
+	d.queries = nil
+	d.args = nil
+	d.mut.Unlock()
+}
+
+func (d *sqlSelectFakeDriver) Open(name string) (driver.Conn, error) {
+	return &sqlSelectFakeConn{d: d}, nil
+}
+
+type sqlSelectFakeConn struct {
+	d *sqlSelectFakeDriver
+}
+
+func (c *sqlSelectFakeConn) Prepare(query string) (driver.Stmt, error) {
+	return &sqlSelectFakeStmt{d: c.d, query: query}, nil
+}
+
+func (c *sqlSelectFakeConn) Close() error { return nil }
+
+func (c *sqlSelectFakeConn) Begin() (driver.Tx, error) {
+	return nil, errors.New("not supported")
+}
+
+type sqlSelectFakeStmt struct {
+	d     *sqlSelectFakeDriver
+	query string
+}
+
+func (s *sqlSelectFakeStmt) Close() error  { return nil }
+func (s *sqlSelectFakeStmt) NumInput() int { return -1 }
+
+func (s *sqlSelectFakeStmt) Exec(args []driver.Value) (driver.Result, error) {
+	return nil, errors.New("not supported")
+}
+
+func (s *sqlSelectFakeStmt) Query(args []driver.Value) (driver.Rows, error) {
+	s.d.mut.Lock()
+	defer s.d.mut.Unlock()
+
+	s.d.queries = append(s.d.queries, s.query)
+	s.d.args = append(s.d.args, args)
+
+	var cursor int64 = -1
+	if len(args) > 0 {
+		cursor = args[len(args)-1].(int64)
+	}
+	res := &sqlSelectFakeRows{}
+	for _, row := range s.d.rows {
+		if row[0].(int64) > cursor && len(res.rows) < s.d.limit {
+			res.rows = append(res.rows, row)
+		}
+	}
+	return res, nil
+}
+
+type sqlSelectFakeRows struct {
+	rows [][]driver.Value
+}
+
+func (r *sqlSelectFakeRows) Columns() []string { return []string{"id", "name"} }
+func (r *sqlSelectFakeRows) Close() error      { return nil }
+
+func (r *sqlSelectFakeRows) Next(dest []driver.Value) error {
+	if len(r.rows) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.rows[0])
+	r.rows = r.rows[1:]
+	return nil
+}
+
+//------------------------------------------------------------------------------
+
+func TestSQLSelectBuildQuery(t *testing.T) {
+	tests := []struct {
+		name      string
+		conf      func(c *SQLSelectConfig)
+		cursor    interface{}
+		query     string
+		argsCount int
+	}{
+		{
+			name:  "no cursor",
+			conf:  func(c *SQLSelectConfig) {},
+			query: "SELECT * FROM foo ORDER BY id ASC LIMIT 10",
+		},
+		{
+			name: "mysql with cursor",
+			conf: func(c *SQLSelectConfig) {
+				c.Columns = []string{"id", "name"}
+			},
+			cursor:    int64(5),
+			query:     "SELECT id, name FROM foo WHERE id > ? ORDER BY id ASC LIMIT 10",
+			argsCount: 1,
+		},
+		{
+			name: "postgres with where",
+			conf: func(c *SQLSelectConfig) {
+				c.Driver = "postgres"
+				c.Where = "name != $1"
+				c.Args = []string{"bar"}
+			},
+			cursor:    int64(5),
+			query:     "SELECT * FROM foo WHERE (name != $1) AND id > $2 ORDER BY id ASC LIMIT 10",
+			argsCount: 2,
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			conf := NewSQLSelectConfig()
+			conf.Table = "foo"
+			conf.CursorColumn = "id"
+			conf.Limit = 10
+			test.conf(&conf)
+
+			s, err := newSQLSelectReader(conf, nil, log.Noop())
+			require.NoError(t, err)
+
+			query, args := s.buildQuery(test.cursor)
+			assert.Equal(t, test.query, query)
+			assert.Len(t, args, test.argsCount)
+		})
+	}
+}
+
+func TestSQLSelectCursor(t *testing.T) {
+	sqlSelectTestDriver.reset(2,
+		[]driver.Value{int64(1), []byte("foo")},
+		[]driver.Value{int64(2), []byte("bar")},
+		[]driver.Value{int64(3), []byte("baz")},
+	)
+
+	memCache, err := cache.NewMemory(cache.NewConfig(), nil, log.Noop(), metrics.Noop())
+	require.NoError(t, err)
+
+	mgr := &fakeCacheMgr{caches: map[string]types.Cache{"cursors": memCache}}
+
+	conf := NewSQLSelectConfig()
+	conf.Driver = "sql_select_fake"
+	conf.Table = "foo"
+	conf.CursorColumn = "id"
+	conf.CursorCache = "cursors"
+	conf.PollInterval = "10ms"
+
+	s, err := newSQLSelectReader(conf, mgr, log.Noop())
+	require.NoError(t, err)
+
+	ctx, done := context.WithTimeout(context.Background(), time.Second*10)
+	defer done()
+
+	require.NoError(t, s.ConnectWithContext(ctx))
+
+	var ackFns []func()
+	for _, exp := range []string{`{"id":1,"name":"foo"}`, `{"id":2,"name":"bar"}`, `{"id":3,"name":"baz"}`} {
+		msg, ackFn, err := s.ReadWithContext(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, exp, string(msg.Get(0).Get()))
+		ackFns = append(ackFns, func() {
+			require.NoError(t, ackFn(ctx, response.NewAck()))
+		})
+	}
+
+	_, _, err = s.ReadWithContext(ctx)
+	assert.Equal(t, types.ErrTimeout, err)
+
+	// Acknowledge out of order, the cursor should only be committed once all
+	// prior rows are acknowledged.
+	ackFns[1]()
+	_, err = memCache.Get("sql_select_foo_id")
+	assert.Equal(t, types.ErrKeyNotFound, err)
+
+	ackFns[0]()
+	cursorBytes, err := memCache.Get("sql_select_foo_id")
+	require.NoError(t, err)
+	assert.Equal(t, "2", string(cursorBytes))
+
+	ackFns[2]()
+	cursorBytes, err = memCache.Get("sql_select_foo_id")
+	require.NoError(t, err)
+	assert.Equal(t, "3", string(cursorBytes))
+
+	s.CloseAsync()
+	require.NoError(t, s.WaitForClose(time.Second))
+
+	// A new reader should resume from the stored cursor.
+	sqlSelectTestDriver.reset(2,
+		[]driver.Value{int64(1), []byte("foo")},
+		[]driver.Value{int64(2), []byte("bar")},
+		[]driver.Value{int64(3), []byte("baz")},
+		[]driver.Value{int64(4), []byte("buz")},
+	)
+
+	s, err = newSQLSelectReader(conf, mgr, log.Noop())
+	require.NoError(t, err)
+	require.NoError(t, s.ConnectWithContext(ctx))
+
+	msg, _, err := s.ReadWithContext(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, `{"id":4,"name":"buz"}`, string(msg.Get(0).Get()))
+
+	s.CloseAsync()
+	require.NoError(t, s.WaitForClose(time.Second))
+}
+
+func TestSQLSelectBadConfig(t *testing.T) {
+	conf := NewSQLSelectConfig()
+	conf.CursorColumn = "id"
+	_, err := newSQLSelectReader(conf, nil, log.Noop())
+	assert.EqualError(t, err, "a table must be specified")
+
+	conf = NewSQLSelectConfig()
+	conf.Table = "foo"
+	_, err = newSQLSelectReader(conf, nil, log.Noop())
+	assert.EqualError(t, err, "a cursor column must be specified")
+
+	conf = NewSQLSelectConfig()
+	conf.Table = "foo"
+	conf.CursorColumn = "id"
+	conf.Columns = []string{"name"}
+	_, err = newSQLSelectReader(conf, nil, log.Noop())
+	assert.EqualError(t, err, "columns must include the cursor column 'id'")
+}
+
+func TestSQLSelectMissingCursor(t *testing.T) {
+	sqlSelectTestDriver.reset(2,
+		[]driver.Value{int64(1), nil},
+		[]driver.Value{int64(2), []byte("bar")},
+	)
+
+	conf := NewSQLSelectConfig()
+	conf.Driver = "sql_select_fake"
+	conf.Table = "foo"
+	conf.CursorColumn = "name"
+	conf.PollInterval = "10ms"
+
+	s, err := newSQLSelectReader(conf, nil, log.Noop())
+	require.NoError(t, err)
+
+	ctx, done := context.WithTimeout(context.Background(), time.Second*10)
+	defer done()
+
+	require.NoError(t, s.ConnectWithContext(ctx))
+
+	// A row without a cursor is emitted flagged and does not block the rows
+	// that follow it.
+	msg, ackFn, err := s.ReadWithContext(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, `{"id":1,"name":null}`, string(msg.Get(0).Get()))
+	assert.Equal(t, "cursor column 'name' was not found within selected row", processor.GetFail(msg.Get(0)))
+	require.NoError(t, ackFn(ctx, response.NewAck()))
+	assert.Nil(t, s.cursor)
+
+	msg, ackFn, err = s.ReadWithContext(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, `{"id":2,"name":"bar"}`, string(msg.Get(0).Get()))
+	assert.Empty(t, processor.GetFail(msg.Get(0)))
+	require.NoError(t, ackFn(ctx, response.NewAck()))
+	assert.Equal(t, "bar", s.cursor)
+	assert.Empty(t, s.rows)
+
+	s.CloseAsync()
+	require.NoError(t, s.WaitForClose(time.Second))
+}
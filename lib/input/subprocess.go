@@ -6,10 +6,13 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"os/exec"
+	"strings"
 	"sync"
 	"time"
 
+	"github.com/Jeffail/benthos/v3/internal/codec"
 	"github.com/Jeffail/benthos/v3/internal/docs"
 	"github.com/Jeffail/benthos/v3/lib/input/reader"
 	"github.com/Jeffail/benthos/v3/lib/log"
@@ -42,8 +45,8 @@ The execution environment of the subprocess is the same as the Benthos instance,
 			docs.FieldCommon("name", "The command to execute as a subprocess.", "cat", "sed", "awk"),
 			docs.FieldCommon("args", "A list of arguments to provide the command.").Array(),
 			docs.FieldCommon(
-				"codec", "The way in which messages should be consumed from the subprocess.",
-			).HasOptions("lines"),
+				"codec", "The way in which messages should be consumed from the subprocess. The `multiline:x` codec joins consecutive lines into a single message and is configured in the same way as the [`multiline:x` codec of the `file` input](/docs/components/inputs/file#codec).",
+			).HasOptions("lines", "multiline:x"),
 			docs.FieldCommon("restart_on_exit", "Whether the command should be re-executed each time the subprocess ends."),
 			docs.FieldAdvanced("max_buffer", "The maximum expected size of an individual message."),
 		},
@@ -74,11 +77,80 @@ func linesSubprocCodec(conf SubprocessConfig, stdout, stderr io.Reader) (outScan
 
 type subprocCodec func(SubprocessConfig, io.Reader, io.Reader) (subprocScanner, subprocScanner)
 
+// codecSubprocScanner adapts a reader codec into a scanner.
+type codecSubprocScanner struct {
+	r   codec.Reader
+	b   []byte
+	err error
+}
+
+func (c *codecSubprocScanner) Scan() bool {
+	if c.r == nil {
+		return false
+	}
+	parts, ackFn, err := c.r.Next(context.Background())
+	if err != nil {
+		if err != io.EOF {
+			c.err = err
+		}
+		_ = c.r.Close(context.Background())
+		return false
+	}
+	_ = ackFn(context.Background(), nil)
+	c.b = nil
+	for i, p := range parts {
+		if i > 0 {
+			c.b = append(c.b, '\n')
+		}
+		c.b = append(c.b, p.Get()...)
+	}
+	return true
+}
+
+func (c *codecSubprocScanner) Bytes() []byte {
+	return c.b
+}
+
+func (c *codecSubprocScanner) Text() string {
+	return string(c.b)
+}
+
+func (c *codecSubprocScanner) Err() error {
+	return c.err
+}
+
+func multilineSubprocCodec(codecStr string) (subprocCodec, error) {
+	// Validate the codec up front.
+	if _, err := codec.GetReader(codecStr, codec.NewReaderConfig()); err != nil {
+		return nil, err
+	}
+	return func(conf SubprocessConfig, stdout, stderr io.Reader) (outScanner, errScanner subprocScanner) {
+		_, errScanner = linesSubprocCodec(conf, stdout, stderr)
+
+		codecConf := codec.NewReaderConfig()
+		codecConf.MaxScanTokenSize = conf.MaxBuffer
+
+		ctor, err := codec.GetReader(codecStr, codecConf)
+		if err == nil {
+			var r codec.Reader
+			if r, err = ctor("", ioutil.NopCloser(stdout), func(context.Context, error) error {
+				return nil
+			}); err == nil {
+				return &codecSubprocScanner{r: r}, errScanner
+			}
+		}
+		return &codecSubprocScanner{err: err}, errScanner
+	}, nil
+}
+
 func codecFromStr(codec string) (subprocCodec, error) {
 	// TODO: Flesh this out with more options based on s.conf.Codec.
 	if codec == "lines" {
 		return linesSubprocCodec, nil
 	}
+	if strings.HasPrefix(codec, "multiline:") {
+		return multilineSubprocCodec(codec)
+	}
 	return nil, fmt.Errorf("codec not recognised: %v", codec)
 }
 
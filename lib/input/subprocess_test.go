@@ -175,3 +175,43 @@ func main() {
 	i.CloseAsync()
 	require.NoError(t, i.WaitForClose(time.Second))
 }
+
+func TestSubprocessMultiline(t *testing.T) {
+	filePath := testProgram(t, `package main
+
+import (
+	"fmt"
+)
+
+func main() {
+	fmt.Println("foo")
+	fmt.Println("  bar")
+	fmt.Println("  baz")
+	fmt.Println("buz")
+}
+`)
+
+	conf := NewConfig()
+	conf.Type = TypeSubprocess
+	conf.Subprocess.Name = "go"
+	conf.Subprocess.Args = []string{"run", filePath}
+	conf.Subprocess.Codec = `multiline:continue:^\s`
+
+	i, err := New(conf, nil, log.Noop(), metrics.Noop())
+	require.NoError(t, err)
+
+	msg := readMsg(t, i.TransactionChan())
+	assert.Equal(t, 1, msg.Len())
+	assert.Equal(t, "foo\n  bar\n  baz", string(msg.Get(0).Get()))
+
+	msg = readMsg(t, i.TransactionChan())
+	assert.Equal(t, 1, msg.Len())
+	assert.Equal(t, "buz", string(msg.Get(0).Get()))
+
+	i.CloseAsync()
+	assert.NoError(t, i.WaitForClose(time.Second))
+
+	conf.Subprocess.Codec = "multiline:nope"
+	_, err = New(conf, nil, log.Noop(), metrics.Noop())
+	assert.Error(t, err)
+}
@@ -0,0 +1,389 @@
+package input
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/Jeffail/benthos/v3/internal/docs"
+	"github.com/Jeffail/benthos/v3/internal/syslog"
+	"github.com/Jeffail/benthos/v3/lib/input/reader"
+	"github.com/Jeffail/benthos/v3/lib/log"
+	"github.com/Jeffail/benthos/v3/lib/message"
+	"github.com/Jeffail/benthos/v3/lib/metrics"
+	"github.com/Jeffail/benthos/v3/lib/processor"
+	"github.com/Jeffail/benthos/v3/lib/types"
+)
+
+//------------------------------------------------------------------------------
+
+func init() {
+	Constructors[TypeSyslog] = TypeSpec{
+		constructor: fromSimpleConstructor(NewSyslog),
+		Status:      docs.StatusExperimental,
+		Version:     "3.47.0",
+		Summary: `
+Creates a server that receives syslog messages over UDP, TCP or TLS and parses
+them into structured messages.`,
+		Description: `
+Messages are parsed as either [RFC5424](https://tools.ietf.org/html/rfc5424) or
+[RFC3164](https://tools.ietf.org/html/rfc3164), and with a ` + "`format`" + ` of
+` + "`auto`" + ` the format is detected for each message by checking for a
+version number following the priority. Each parsed message is a JSON object of
+the following form:
+
+` + "```json" + `
+{
+  "message": "An application event log entry...",
+  "timestamp": "2006-01-02T15:04:05.999999999Z",
+  "facility": 4,
+  "severity": 2,
+  "priority": 34,
+  "version": 1,
+  "hostname": "mymachine.example.com",
+  "procid": "1234",
+  "appname": "su",
+  "msgid": "ID47",
+  "structureddata": {
+    "exampleSDID@32473": {
+      "iut": "3",
+      "eventSource": "Application"
+    }
+  }
+}
+` + "```" + `
+
+Fields that are absent from a message are omitted. Messages that fail to parse
+are passed on with their raw contents and flagged as having failed, allowing
+them to be handled with [error handling patterns](/docs/configuration/error_handling).
+
+When receiving over TCP or TLS messages are framed as described in
+[RFC6587](https://tools.ietf.org/html/rfc6587), where the ` + "`framing`" + `
+field determines whether messages are octet counted, delimited by a newline
+(non-transparent), or with ` + "`auto`" + ` detected for each message. When
+receiving over UDP each datagram is treated as a single message.
+
+### Metadata
+
+This input adds the following metadata fields to each message:
+
+` + "``` text" + `
+- syslog_facility
+- syslog_severity
+- syslog_hostname
+- syslog_appname
+- syslog_remote_addr
+` + "```" + `
+
+Fields that are absent from a message are omitted. You can access these
+metadata fields using [function interpolation](/docs/configuration/interpolation#metadata).`,
+		FieldSpecs: docs.FieldSpecs{
+			docs.FieldCommon("network", "A network type to accept.").HasOptions("udp", "tcp", "tls"),
+			docs.FieldCommon("address", "The address to listen from.", "0.0.0.0:514", "localhost:6514"),
+			docs.FieldCommon("format", "The syslog format of messages.").HasOptions("auto", "rfc5424", "rfc3164"),
+			docs.FieldAdvanced("framing", "The framing of messages received over TCP or TLS.").HasOptions("auto", "octet_counting", "non_transparent"),
+			docs.FieldAdvanced("best_effort", "Whether messages that are partially malformed should be parsed as far as possible rather than rejected."),
+			docs.FieldAdvanced("cert_file", "A certificate file to use when the network is `tls`."),
+			docs.FieldAdvanced("key_file", "A key file to use when the network is `tls`."),
+			docs.FieldAdvanced("max_buffer", "The maximum size of a single message. Connections that send messages exceeding this value are closed."),
+		},
+		Categories: []Category{
+			CategoryNetwork,
+		},
+	}
+}
+
+//------------------------------------------------------------------------------
+
+// SyslogConfig contains configuration for the Syslog input type.
+type SyslogConfig struct {
+	Network    string `json:"network" yaml:"network"`
+	Address    string `json:"address" yaml:"address"`
+	Format     string `json:"format" yaml:"format"`
+	Framing    string `json:"framing" yaml:"framing"`
+	BestEffort bool   `json:"best_effort" yaml:"best_effort"`
+	CertFile   string `json:"cert_file" yaml:"cert_file"`
+	KeyFile    string `json:"key_file" yaml:"key_file"`
+	MaxBuffer  int    `json:"max_buffer" yaml:"max_buffer"`
+}
+
+// NewSyslogConfig creates a new SyslogConfig with default values.
+func NewSyslogConfig() SyslogConfig {
+	return SyslogConfig{
+		Network:    "udp",
+		Address:    "0.0.0.0:514",
+		Format:     "auto",
+		Framing:    "auto",
+		BestEffort: true,
+		CertFile:   "",
+		KeyFile:    "",
+		MaxBuffer:  1000000,
+	}
+}
+
+//------------------------------------------------------------------------------
+
+// NewSyslog creates a new Syslog input type.
+func NewSyslog(conf Config, mgr types.Manager, log log.Modular, stats metrics.Type) (Type, error) {
+	rdr, err := newSyslogReader(conf.Syslog, log)
+	if err != nil {
+		return nil, err
+	}
+	return NewAsyncReader(TypeSyslog, true, reader.NewAsyncPreserver(rdr), log, stats)
+}
+
+//------------------------------------------------------------------------------
+
+type syslogReader struct {
+	conf    SyslogConfig
+	framing syslog.Framing
+	parser  *syslog.Parser
+	log     log.Modular
+
+	listener net.Listener
+	conn     net.PacketConn
+
+	msgChan chan types.Message
+
+	ctx     context.Context
+	closeFn func()
+	wg      sync.WaitGroup
+}
+
+func newSyslogReader(conf SyslogConfig, log log.Modular) (*syslogReader, error) {
+	format, err := syslog.ParseFormat(conf.Format)
+	if err != nil {
+		return nil, err
+	}
+	framing, err := syslog.ParseFraming(conf.Framing)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &syslogReader{
+		conf:    conf,
+		framing: framing,
+		parser:  syslog.NewParser(format, conf.BestEffort),
+		log:     log,
+		msgChan: make(chan types.Message),
+	}
+
+	switch conf.Network {
+	case "udp":
+		s.conn, err = net.ListenPacket("udp", conf.Address)
+	case "tcp":
+		s.listener, err = net.Listen("tcp", conf.Address)
+	case "tls":
+		if conf.CertFile == "" || conf.KeyFile == "" {
+			return nil, errors.New("both a cert_file and key_file must be specified when the network is tls")
+		}
+		var cert tls.Certificate
+		if cert, err = tls.LoadX509KeyPair(conf.CertFile, conf.KeyFile); err != nil {
+			return nil, err
+		}
+		s.listener, err = tls.Listen("tcp", conf.Address, &tls.Config{
+			Certificates: []tls.Certificate{cert},
+		})
+	default:
+		return nil, fmt.Errorf("syslog network '%v' is not supported by this input", conf.Network)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	s.ctx, s.closeFn = context.WithCancel(context.Background())
+	return s, nil
+}
+
+// Addr returns the address that the syslog server is listening on.
+func (s *syslogReader) Addr() net.Addr {
+	if s.listener != nil {
+		return s.listener.Addr()
+	}
+	return s.conn.LocalAddr()
+}
+
+//------------------------------------------------------------------------------
+
+func (s *syslogReader) parse(raw []byte, remoteAddr net.Addr) types.Message {
+	msg := message.New(nil)
+	part := message.NewPart(raw)
+
+	parsed, err := s.parser.Parse(raw)
+	if err != nil {
+		s.log.Debugf("Failed to parse syslog message: %v\n", err)
+		processor.FlagErr(part, err)
+	} else {
+		if err = part.SetJSON(parsed.Structured); err != nil {
+			processor.FlagErr(part, err)
+		}
+		meta := part.Metadata()
+		if parsed.Facility != nil {
+			meta.Set("syslog_facility", strconv.Itoa(int(*parsed.Facility)))
+		}
+		if parsed.Severity != nil {
+			meta.Set("syslog_severity", strconv.Itoa(int(*parsed.Severity)))
+		}
+		if parsed.Hostname != nil {
+			meta.Set("syslog_hostname", *parsed.Hostname)
+		}
+		if parsed.Appname != nil {
+			meta.Set("syslog_appname", *parsed.Appname)
+		}
+	}
+	if remoteAddr != nil {
+		part.Metadata().Set("syslog_remote_addr", remoteAddr.String())
+	}
+
+	msg.Append(part)
+	return msg
+}
+
+func (s *syslogReader) send(msg types.Message) bool {
+	select {
+	case s.msgChan <- msg:
+		return true
+	case <-s.ctx.Done():
+	}
+	return false
+}
+
+func (s *syslogReader) udpLoop() {
+	defer s.wg.Done()
+
+	buf := make([]byte, 65536)
+	for {
+		n, addr, err := s.conn.ReadFrom(buf)
+		if err != nil {
+			select {
+			case <-s.ctx.Done():
+				return
+			default:
+			}
+			s.log.Errorf("Failed to read syslog datagram: %v\n", err)
+			continue
+		}
+
+		trimmed := bytes.TrimRight(buf[:n], "\r\n")
+		if len(trimmed) == 0 {
+			continue
+		}
+		raw := make([]byte, len(trimmed))
+		copy(raw, trimmed)
+		if !s.send(s.parse(raw, addr)) {
+			return
+		}
+	}
+}
+
+func (s *syslogReader) acceptLoop() {
+	defer s.wg.Done()
+
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			select {
+			case <-s.ctx.Done():
+				return
+			default:
+			}
+			s.log.Errorf("Failed to accept syslog connection: %v\n", err)
+			select {
+			case <-time.After(time.Second):
+				continue
+			case <-s.ctx.Done():
+				return
+			}
+		}
+
+		s.wg.Add(1)
+		go s.connLoop(conn)
+	}
+}
+
+func (s *syslogReader) connLoop(conn net.Conn) {
+	connCtx, connDone := context.WithCancel(s.ctx)
+	defer func() {
+		connDone()
+		conn.Close()
+		s.wg.Done()
+	}()
+	go func() {
+		<-connCtx.Done()
+		conn.Close()
+	}()
+
+	frames := syslog.NewFrameReader(conn, s.framing, s.conf.MaxBuffer)
+	for {
+		raw, err := frames.Next()
+		if err != nil {
+			if err != io.EOF && connCtx.Err() == nil {
+				s.log.Errorf("Syslog connection dropped due to: %v\n", err)
+			}
+			return
+		}
+		if !s.send(s.parse(raw, conn.RemoteAddr())) {
+			return
+		}
+	}
+}
+
+//------------------------------------------------------------------------------
+
+// ConnectWithContext begins accepting syslog messages.
+func (s *syslogReader) ConnectWithContext(ctx context.Context) error {
+	s.wg.Add(1)
+	if s.listener != nil {
+		go s.acceptLoop()
+	} else {
+		go s.udpLoop()
+	}
+	s.log.Infof("Receiving syslog messages over %v from address: %v\n", s.conf.Network, s.Addr())
+	return nil
+}
+
+// ReadWithContext attempts to read a new syslog message.
+func (s *syslogReader) ReadWithContext(ctx context.Context) (types.Message, reader.AsyncAckFn, error) {
+	select {
+	case msg := <-s.msgChan:
+		return msg, func(context.Context, types.Response) error {
+			return nil
+		}, nil
+	case <-s.ctx.Done():
+		return nil, nil, types.ErrTypeClosed
+	case <-ctx.Done():
+	}
+	return nil, nil, types.ErrTimeout
+}
+
+// CloseAsync shuts down the syslog server.
+func (s *syslogReader) CloseAsync() {
+	s.closeFn()
+	if s.listener != nil {
+		s.listener.Close()
+	} else {
+		s.conn.Close()
+	}
+}
+
+// WaitForClose blocks until the syslog server has closed down.
+func (s *syslogReader) WaitForClose(timeout time.Duration) error {
+	done := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(timeout):
+		return types.ErrTimeout
+	}
+	return nil
+}
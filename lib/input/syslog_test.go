@@ -0,0 +1,220 @@
+package input
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Jeffail/benthos/v3/lib/input/reader"
+	"github.com/Jeffail/benthos/v3/lib/log"
+	"github.com/Jeffail/benthos/v3/lib/metrics"
+	"github.com/Jeffail/benthos/v3/lib/processor"
+	"github.com/Jeffail/benthos/v3/lib/response"
+	"github.com/Jeffail/benthos/v3/lib/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestSyslog(t *testing.T, conf SyslogConfig) (Type, net.Addr) {
+	t.Helper()
+
+	conf.Address = "127.0.0.1:0"
+	rdr, err := newSyslogReader(conf, log.Noop())
+	require.NoError(t, err)
+
+	in, err := NewAsyncReader(TypeSyslog, true, reader.NewAsyncPreserver(rdr), log.Noop(), metrics.Noop())
+	require.NoError(t, err)
+
+	t.Cleanup(func() {
+		in.CloseAsync()
+		assert.NoError(t, in.WaitForClose(time.Second*5))
+	})
+	return in, rdr.Addr()
+}
+
+func readSyslogParts(t *testing.T, in Type, n int) []types.Part {
+	t.Helper()
+
+	var parts []types.Part
+	for len(parts) < n {
+		select {
+		case tran, open := <-in.TransactionChan():
+			require.True(t, open)
+			tran.Payload.Iter(func(i int, p types.Part) error {
+				parts = append(parts, p.Copy())
+				return nil
+			})
+			select {
+			case tran.ResponseChan <- response.NewAck():
+			case <-time.After(time.Second):
+				t.Fatal("timed out")
+			}
+		case <-time.After(time.Second * 5):
+			t.Fatal("timed out")
+		}
+	}
+	return parts
+}
+
+const (
+	testRFC5424 = `<165>1 2003-10-11T22:14:15.003Z mymachine.example.com evntslog 1234 ID47 [exampleSDID@32473 iut="3"] An application event log entry...`
+	testRFC3164 = `<34>Oct 11 22:14:15 mymachine su: 'su root' failed for lonvick on /dev/pts/8`
+)
+
+func octetFrame(msg string) string {
+	return fmt.Sprintf("%v %v", len(msg), msg)
+}
+
+func TestSyslogConfigErrors(t *testing.T) {
+	for _, fn := range []func(c *SyslogConfig){
+		func(c *SyslogConfig) { c.Network = "unix" },
+		func(c *SyslogConfig) { c.Format = "nope" },
+		func(c *SyslogConfig) { c.Framing = "nope" },
+		func(c *SyslogConfig) { c.Network = "tls" },
+	} {
+		conf := NewConfig()
+		conf.Syslog.Address = "127.0.0.1:0"
+		fn(&conf.Syslog)
+		_, err := NewSyslog(conf, nil, log.Noop(), metrics.Noop())
+		assert.Error(t, err)
+	}
+}
+
+func TestSyslogUDP(t *testing.T) {
+	in, addr := newTestSyslog(t, NewSyslogConfig())
+
+	conn, err := net.Dial("udp", addr.String())
+	require.NoError(t, err)
+	defer conn.Close()
+
+	_, err = conn.Write([]byte(testRFC5424 + "\n"))
+	require.NoError(t, err)
+	_, err = conn.Write([]byte("not syslog"))
+	require.NoError(t, err)
+
+	parts := readSyslogParts(t, in, 2)
+
+	structured, err := parts[0].JSON()
+	require.NoError(t, err)
+	assert.Equal(t, "An application event log entry...", structured.(map[string]interface{})["message"])
+	assert.Equal(t, map[string]interface{}{
+		"exampleSDID@32473": map[string]interface{}{"iut": "3"},
+	}, structured.(map[string]interface{})["structureddata"])
+	assert.Equal(t, "20", parts[0].Metadata().Get("syslog_facility"))
+	assert.Equal(t, "5", parts[0].Metadata().Get("syslog_severity"))
+	assert.Equal(t, "mymachine.example.com", parts[0].Metadata().Get("syslog_hostname"))
+	assert.Equal(t, "evntslog", parts[0].Metadata().Get("syslog_appname"))
+	assert.Equal(t, conn.LocalAddr().String(), parts[0].Metadata().Get("syslog_remote_addr"))
+	assert.False(t, processor.HasFailed(parts[0]))
+
+	assert.Equal(t, "not syslog", string(parts[1].Get()))
+	assert.True(t, processor.HasFailed(parts[1]))
+}
+
+func TestSyslogTCPFraming(t *testing.T) {
+	tests := []struct {
+		framing string
+		input   string
+	}{
+		{
+			framing: "octet_counting",
+			input:   octetFrame(testRFC5424) + octetFrame(testRFC3164),
+		},
+		{
+			framing: "non_transparent",
+			input:   testRFC5424 + "\n" + testRFC3164 + "\n",
+		},
+		{
+			framing: "auto",
+			input:   octetFrame(testRFC5424) + testRFC3164 + "\n",
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.framing, func(t *testing.T) {
+			conf := NewSyslogConfig()
+			conf.Network = "tcp"
+			conf.Framing = test.framing
+			in, addr := newTestSyslog(t, conf)
+
+			conn, err := net.Dial("tcp", addr.String())
+			require.NoError(t, err)
+			defer conn.Close()
+
+			_, err = conn.Write([]byte(test.input))
+			require.NoError(t, err)
+
+			parts := readSyslogParts(t, in, 2)
+			assert.Equal(t, "mymachine.example.com", parts[0].Metadata().Get("syslog_hostname"))
+			assert.Equal(t, "mymachine", parts[1].Metadata().Get("syslog_hostname"))
+			assert.Equal(t, "4", parts[1].Metadata().Get("syslog_facility"))
+			assert.Equal(t, "2", parts[1].Metadata().Get("syslog_severity"))
+			for _, p := range parts {
+				assert.False(t, processor.HasFailed(p))
+			}
+		})
+	}
+}
+
+func writeTestCert(t *testing.T) (certFile, keyFile string) {
+	t.Helper()
+
+	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	template := x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{Organization: []string{"Benthos"}},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	certBytes, err := x509.CreateCertificate(rand.Reader, &template, &template, &priv.PublicKey, priv)
+	require.NoError(t, err)
+
+	keyBytes, err := x509.MarshalECPrivateKey(priv)
+	require.NoError(t, err)
+
+	tmpDir := t.TempDir()
+	certFile = filepath.Join(tmpDir, "cert.pem")
+	keyFile = filepath.Join(tmpDir, "key.pem")
+	require.NoError(t, ioutil.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certBytes}), 0600))
+	require.NoError(t, ioutil.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyBytes}), 0600))
+	return
+}
+
+func TestSyslogTLS(t *testing.T) {
+	conf := NewSyslogConfig()
+	conf.Network = "tls"
+	conf.CertFile, conf.KeyFile = writeTestCert(t)
+	in, addr := newTestSyslog(t, conf)
+
+	conn, err := tls.Dial("tcp", addr.String(), &tls.Config{
+		InsecureSkipVerify: true,
+	})
+	require.NoError(t, err)
+	defer conn.Close()
+
+	_, err = conn.Write([]byte(octetFrame(testRFC3164)))
+	require.NoError(t, err)
+
+	parts := readSyslogParts(t, in, 1)
+	structured, err := parts[0].JSON()
+	require.NoError(t, err)
+	assert.Equal(t, "'su root' failed for lonvick on /dev/pts/8", structured.(map[string]interface{})["message"])
+	assert.Equal(t, "su", parts[0].Metadata().Get("syslog_appname"))
+}
@@ -0,0 +1,604 @@
+package metrics
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/Jeffail/benthos/v3/internal/docs"
+	"github.com/Jeffail/benthos/v3/lib/log"
+	btls "github.com/Jeffail/benthos/v3/lib/util/tls"
+	collectormetrics "go.opentelemetry.io/proto/otlp/collector/metrics/v1"
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	metricspb "go.opentelemetry.io/proto/otlp/metrics/v1"
+	resourcepb "go.opentelemetry.io/proto/otlp/resource/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/proto"
+)
+
+//------------------------------------------------------------------------------
+
+func init() {
+	Constructors[TypeOpenTelemetry] = TypeSpec{
+		constructor: NewOpenTelemetry,
+		Status:      docs.StatusExperimental,
+		Version:     "3.47.0",
+		Summary: `
+Push metrics to an [OpenTelemetry collector](https://opentelemetry.io/docs/collector/) using the OTLP protocol.`,
+		Description: `
+Metrics are pushed periodically over either gRPC or HTTP according to the field ` + "`protocol`" + `.
+
+Counters are exported as cumulative monotonic sums, gauges are exported as
+gauges, and timings are exported as cumulative histograms in seconds with the
+bucket boundaries specified by the field ` + "`histogram_buckets`" + `. Labels
+created with a ` + "`path_mapping`" + ` are exported as attributes of each data
+point.`,
+		FieldSpecs: docs.FieldSpecs{
+			docs.FieldCommon("endpoint", "The address of a collector to send metrics to, as a host and port.", "localhost:4317", "otel-collector:4318"),
+			docs.FieldCommon("protocol", "The protocol to export metrics with.").HasOptions("grpc", "http"),
+			docs.FieldAdvanced("url_path", "The URL path metrics are sent to when the protocol is `http`."),
+			docs.FieldAdvanced("headers", "A map of headers to add to each export request, which can be used for authentication.").Map(),
+			btls.FieldSpec(),
+			docs.FieldCommon("service_name", "A name to provide for this service, which is added as the resource attribute `service.name`."),
+			docs.FieldAdvanced("resource_attributes", "A map of attributes to add to the resource of all metrics.", map[string]string{
+				"deployment.environment": "production",
+			}).Map(),
+			docs.FieldCommon("push_interval", "The period of time between each push of metrics."),
+			docs.FieldAdvanced("timeout", "The maximum period of time to wait for a push to complete."),
+			docs.FieldAdvanced("histogram_buckets", "The explicit upper bounds, in seconds, of histogram buckets used for timing metrics.").Array(),
+			pathMappingDocs(true, false),
+		},
+	}
+}
+
+//------------------------------------------------------------------------------
+
+// OpenTelemetryConfig contains config fields for the OpenTelemetry metrics
+// type.
+type OpenTelemetryConfig struct {
+	Endpoint           string            `json:"endpoint" yaml:"endpoint"`
+	Protocol           string            `json:"protocol" yaml:"protocol"`
+	URLPath            string            `json:"url_path" yaml:"url_path"`
+	Headers            map[string]string `json:"headers" yaml:"headers"`
+	TLS                btls.Config       `json:"tls" yaml:"tls"`
+	ServiceName        string            `json:"service_name" yaml:"service_name"`
+	ResourceAttributes map[string]string `json:"resource_attributes" yaml:"resource_attributes"`
+	PushInterval       string            `json:"push_interval" yaml:"push_interval"`
+	Timeout            string            `json:"timeout" yaml:"timeout"`
+	HistogramBuckets   []float64         `json:"histogram_buckets" yaml:"histogram_buckets"`
+	PathMapping        string            `json:"path_mapping" yaml:"path_mapping"`
+}
+
+// NewOpenTelemetryConfig creates an OpenTelemetryConfig struct with default
+// values.
+func NewOpenTelemetryConfig() OpenTelemetryConfig {
+	return OpenTelemetryConfig{
+		Endpoint:           "localhost:4317",
+		Protocol:           "grpc",
+		URLPath:            "/v1/metrics",
+		Headers:            map[string]string{},
+		TLS:                btls.NewConfig(),
+		ServiceName:        "benthos",
+		ResourceAttributes: map[string]string{},
+		PushInterval:       "10s",
+		Timeout:            "5s",
+		HistogramBuckets:   []float64{0.0001, 0.0005, 0.001, 0.005, 0.01, 0.05, 0.1, 0.5, 1, 5, 10},
+		PathMapping:        "",
+	}
+}
+
+//------------------------------------------------------------------------------
+
+type otelKind int
+
+const (
+	otelCounter otelKind = iota
+	otelGauge
+	otelTimer
+)
+
+// otelSeries is a single metric series identified by a name and a set of
+// label values, which implements each of the stat interfaces.
+type otelSeries struct {
+	kind  otelKind
+	name  string
+	attrs []*commonpb.KeyValue
+
+	// Counters and gauges
+	value int64
+
+	// Timers
+	histMut sync.Mutex
+	bounds  []float64
+	buckets []uint64
+	count   uint64
+	sum     float64
+}
+
+// Incr increments a metric by an amount.
+func (s *otelSeries) Incr(count int64) error {
+	atomic.AddInt64(&s.value, count)
+	return nil
+}
+
+// Decr decrements a metric by an amount.
+func (s *otelSeries) Decr(count int64) error {
+	atomic.AddInt64(&s.value, -count)
+	return nil
+}
+
+// Set sets a gauge metric.
+func (s *otelSeries) Set(value int64) error {
+	atomic.StoreInt64(&s.value, value)
+	return nil
+}
+
+// Timing sets a timing metric.
+func (s *otelSeries) Timing(delta int64) error {
+	secs := float64(delta) / float64(time.Second)
+
+	s.histMut.Lock()
+	s.buckets[sort.SearchFloat64s(s.bounds, secs)]++
+	s.count++
+	s.sum += secs
+	s.histMut.Unlock()
+	return nil
+}
+
+func (s *otelSeries) dataPoint(start, now uint64) interface{} {
+	if s.kind != otelTimer {
+		return &metricspb.NumberDataPoint{
+			Attributes:        s.attrs,
+			StartTimeUnixNano: start,
+			TimeUnixNano:      now,
+			Value: &metricspb.NumberDataPoint_AsInt{
+				AsInt: atomic.LoadInt64(&s.value),
+			},
+		}
+	}
+
+	s.histMut.Lock()
+	defer s.histMut.Unlock()
+	return &metricspb.HistogramDataPoint{
+		Attributes:        s.attrs,
+		StartTimeUnixNano: start,
+		TimeUnixNano:      now,
+		Count:             s.count,
+		Sum:               s.sum,
+		BucketCounts:      append([]uint64(nil), s.buckets...),
+		ExplicitBounds:    s.bounds,
+	}
+}
+
+//------------------------------------------------------------------------------
+
+// OpenTelemetry is a stats object with the capability to push metrics to an
+// OpenTelemetry collector.
+type OpenTelemetry struct {
+	client otlpMetricsClient
+
+	seriesMut sync.RWMutex
+	series    map[string]*otelSeries
+
+	resource  *resourcepb.Resource
+	bounds    []float64
+	startTime time.Time
+
+	pushInterval time.Duration
+	timeout      time.Duration
+
+	ctx    context.Context
+	cancel func()
+	closed chan struct{}
+
+	pathMapping *pathMapping
+	config      OpenTelemetryConfig
+	log         log.Modular
+}
+
+// NewOpenTelemetry creates and returns a new OpenTelemetry object.
+func NewOpenTelemetry(config Config, opts ...func(Type)) (Type, error) {
+	o := &OpenTelemetry{
+		config:    config.OpenTelemetry,
+		series:    map[string]*otelSeries{},
+		startTime: time.Now(),
+		closed:    make(chan struct{}),
+		log:       log.Noop(),
+	}
+
+	o.ctx, o.cancel = context.WithCancel(context.Background())
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	var err error
+	if o.pathMapping, err = newPathMapping(o.config.PathMapping, o.log); err != nil {
+		return nil, fmt.Errorf("failed to init path mapping: %v", err)
+	}
+	if o.pushInterval, err = time.ParseDuration(o.config.PushInterval); err != nil {
+		return nil, fmt.Errorf("failed to parse push interval: %v", err)
+	}
+	if o.timeout, err = time.ParseDuration(o.config.Timeout); err != nil {
+		return nil, fmt.Errorf("failed to parse timeout: %v", err)
+	}
+
+	o.bounds = append([]float64(nil), o.config.HistogramBuckets...)
+	if !sort.Float64sAreSorted(o.bounds) {
+		return nil, fmt.Errorf("histogram buckets must be in increasing order: %v", o.bounds)
+	}
+
+	o.resource = &resourcepb.Resource{
+		Attributes: []*commonpb.KeyValue{otelStringAttr("service.name", o.config.ServiceName)},
+	}
+	for _, k := range sortedKeys(o.config.ResourceAttributes) {
+		o.resource.Attributes = append(o.resource.Attributes, otelStringAttr(k, o.config.ResourceAttributes[k]))
+	}
+
+	if o.client, err = newOTLPMetricsClient(o.config); err != nil {
+		return nil, err
+	}
+
+	go o.loop()
+	return o, nil
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func otelStringAttr(k, v string) *commonpb.KeyValue {
+	return &commonpb.KeyValue{
+		Key: k,
+		Value: &commonpb.AnyValue{
+			Value: &commonpb.AnyValue_StringValue{StringValue: v},
+		},
+	}
+}
+
+//------------------------------------------------------------------------------
+
+func (o *OpenTelemetry) getSeries(kind otelKind, name string, labelNames, labelValues []string) *otelSeries {
+	key := fmt.Sprintf("%v:%v:%v", kind, name, labelValues)
+
+	o.seriesMut.RLock()
+	s, exists := o.series[key]
+	o.seriesMut.RUnlock()
+	if exists {
+		return s
+	}
+
+	o.seriesMut.Lock()
+	defer o.seriesMut.Unlock()
+
+	if s, exists = o.series[key]; exists {
+		return s
+	}
+
+	s = &otelSeries{kind: kind, name: name}
+	for i, k := range labelNames {
+		if i >= len(labelValues) {
+			break
+		}
+		s.attrs = append(s.attrs, otelStringAttr(k, labelValues[i]))
+	}
+	if kind == otelTimer {
+		s.bounds = o.bounds
+		s.buckets = make([]uint64, len(o.bounds)+1)
+	}
+	o.series[key] = s
+	return s
+}
+
+func (o *OpenTelemetry) getVec(kind otelKind, path string, n []string) func(vs []string) *otelSeries {
+	name, labels, values := o.pathMapping.mapPathWithTags(path)
+	if name == "" {
+		return nil
+	}
+	labels = append(labels, n...)
+	return func(vs []string) *otelSeries {
+		fvs := append([]string{}, values...)
+		fvs = append(fvs, vs...)
+		return o.getSeries(kind, name, labels, fvs)
+	}
+}
+
+// GetCounter returns a stat counter object for a path.
+func (o *OpenTelemetry) GetCounter(path string) StatCounter {
+	f := o.getVec(otelCounter, path, nil)
+	if f == nil {
+		return DudStat{}
+	}
+	return f(nil)
+}
+
+// GetCounterVec returns a stat counter object for a path with the labels
+func (o *OpenTelemetry) GetCounterVec(path string, n []string) StatCounterVec {
+	f := o.getVec(otelCounter, path, n)
+	return fakeCounterVec(func(vs []string) StatCounter {
+		if f == nil {
+			return DudStat{}
+		}
+		return f(vs)
+	})
+}
+
+// GetTimer returns a stat timer object for a path.
+func (o *OpenTelemetry) GetTimer(path string) StatTimer {
+	f := o.getVec(otelTimer, path, nil)
+	if f == nil {
+		return DudStat{}
+	}
+	return f(nil)
+}
+
+// GetTimerVec returns a stat timer object for a path with the labels
+func (o *OpenTelemetry) GetTimerVec(path string, n []string) StatTimerVec {
+	f := o.getVec(otelTimer, path, n)
+	return fakeTimerVec(func(vs []string) StatTimer {
+		if f == nil {
+			return DudStat{}
+		}
+		return f(vs)
+	})
+}
+
+// GetGauge returns a stat gauge object for a path.
+func (o *OpenTelemetry) GetGauge(path string) StatGauge {
+	f := o.getVec(otelGauge, path, nil)
+	if f == nil {
+		return DudStat{}
+	}
+	return f(nil)
+}
+
+// GetGaugeVec returns a stat timer object for a path with the labels
+func (o *OpenTelemetry) GetGaugeVec(path string, n []string) StatGaugeVec {
+	f := o.getVec(otelGauge, path, n)
+	return fakeGaugeVec(func(vs []string) StatGauge {
+		if f == nil {
+			return DudStat{}
+		}
+		return f(vs)
+	})
+}
+
+//------------------------------------------------------------------------------
+
+func (o *OpenTelemetry) buildRequest() *collectormetrics.ExportMetricsServiceRequest {
+	o.seriesMut.RLock()
+	series := make([]*otelSeries, 0, len(o.series))
+	for _, s := range o.series {
+		series = append(series, s)
+	}
+	o.seriesMut.RUnlock()
+
+	start := uint64(o.startTime.UnixNano())
+	now := uint64(time.Now().UnixNano())
+
+	metricsByName := map[string]*metricspb.Metric{}
+	for _, s := range series {
+		key := fmt.Sprintf("%v:%v", s.kind, s.name)
+		m, exists := metricsByName[key]
+		if !exists {
+			m = &metricspb.Metric{Name: s.name}
+			switch s.kind {
+			case otelCounter:
+				m.Data = &metricspb.Metric_Sum{Sum: &metricspb.Sum{
+					AggregationTemporality: metricspb.AggregationTemporality_AGGREGATION_TEMPORALITY_CUMULATIVE,
+					IsMonotonic:            true,
+				}}
+			case otelGauge:
+				m.Data = &metricspb.Metric_Gauge{Gauge: &metricspb.Gauge{}}
+			case otelTimer:
+				m.Unit = "s"
+				m.Data = &metricspb.Metric_Histogram{Histogram: &metricspb.Histogram{
+					AggregationTemporality: metricspb.AggregationTemporality_AGGREGATION_TEMPORALITY_CUMULATIVE,
+				}}
+			}
+			metricsByName[key] = m
+		}
+
+		switch t := s.dataPoint(start, now).(type) {
+		case *metricspb.NumberDataPoint:
+			if s.kind == otelCounter {
+				m.GetSum().DataPoints = append(m.GetSum().DataPoints, t)
+			} else {
+				m.GetGauge().DataPoints = append(m.GetGauge().DataPoints, t)
+			}
+		case *metricspb.HistogramDataPoint:
+			m.GetHistogram().DataPoints = append(m.GetHistogram().DataPoints, t)
+		}
+	}
+
+	keys := make([]string, 0, len(metricsByName))
+	for k := range metricsByName {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	ilm := &metricspb.InstrumentationLibraryMetrics{
+		InstrumentationLibrary: &commonpb.InstrumentationLibrary{Name: "benthos"},
+	}
+	for _, k := range keys {
+		ilm.Metrics = append(ilm.Metrics, metricsByName[k])
+	}
+
+	return &collectormetrics.ExportMetricsServiceRequest{
+		ResourceMetrics: []*metricspb.ResourceMetrics{
+			{
+				Resource:                      o.resource,
+				InstrumentationLibraryMetrics: []*metricspb.InstrumentationLibraryMetrics{ilm},
+			},
+		},
+	}
+}
+
+func (o *OpenTelemetry) push() {
+	ctx, done := context.WithTimeout(context.Background(), o.timeout)
+	defer done()
+
+	if err := o.client.Export(ctx, o.buildRequest()); err != nil {
+		o.log.Errorf("Failed to push metrics: %v\n", err)
+	}
+}
+
+func (o *OpenTelemetry) loop() {
+	defer close(o.closed)
+
+	ticker := time.NewTicker(o.pushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-o.ctx.Done():
+			return
+		case <-ticker.C:
+			o.push()
+		}
+	}
+}
+
+//------------------------------------------------------------------------------
+
+// SetLogger sets the logger used to print connection errors.
+func (o *OpenTelemetry) SetLogger(log log.Modular) {
+	o.log = log
+}
+
+// Close stops the OpenTelemetry object from aggregating metrics and pushes
+// the final state of metrics before cleaning up resources.
+func (o *OpenTelemetry) Close() error {
+	o.cancel()
+	<-o.closed
+	o.push()
+	return o.client.Close()
+}
+
+//------------------------------------------------------------------------------
+
+type otlpMetricsClient interface {
+	Export(ctx context.Context, req *collectormetrics.ExportMetricsServiceRequest) error
+	Close() error
+}
+
+func newOTLPMetricsClient(conf OpenTelemetryConfig) (otlpMetricsClient, error) {
+	switch strings.ToLower(conf.Protocol) {
+	case "grpc":
+		return newOTLPMetricsGRPCClient(conf)
+	case "http":
+		return newOTLPMetricsHTTPClient(conf)
+	}
+	return nil, fmt.Errorf("unrecognised protocol: %v", conf.Protocol)
+}
+
+type otlpMetricsGRPCClient struct {
+	conn    *grpc.ClientConn
+	client  collectormetrics.MetricsServiceClient
+	headers metadata.MD
+}
+
+func newOTLPMetricsGRPCClient(conf OpenTelemetryConfig) (*otlpMetricsGRPCClient, error) {
+	creds := grpc.WithInsecure()
+	if conf.TLS.Enabled {
+		tlsConf, err := conf.TLS.Get()
+		if err != nil {
+			return nil, err
+		}
+		creds = grpc.WithTransportCredentials(credentials.NewTLS(tlsConf))
+	}
+
+	// Dialing is non-blocking, and connection errors are therefore reported
+	// when pushing metrics.
+	conn, err := grpc.Dial(conf.Endpoint, creds)
+	if err != nil {
+		return nil, fmt.Errorf("failed to dial collector: %w", err)
+	}
+	return &otlpMetricsGRPCClient{
+		conn:    conn,
+		client:  collectormetrics.NewMetricsServiceClient(conn),
+		headers: metadata.New(conf.Headers),
+	}, nil
+}
+
+func (c *otlpMetricsGRPCClient) Export(ctx context.Context, req *collectormetrics.ExportMetricsServiceRequest) error {
+	if len(c.headers) > 0 {
+		ctx = metadata.NewOutgoingContext(ctx, c.headers)
+	}
+	_, err := c.client.Export(ctx, req)
+	return err
+}
+
+func (c *otlpMetricsGRPCClient) Close() error {
+	return c.conn.Close()
+}
+
+type otlpMetricsHTTPClient struct {
+	url     string
+	headers map[string]string
+	client  *http.Client
+}
+
+func newOTLPMetricsHTTPClient(conf OpenTelemetryConfig) (*otlpMetricsHTTPClient, error) {
+	c := &otlpMetricsHTTPClient{
+		url:     "http://" + conf.Endpoint + conf.URLPath,
+		headers: conf.Headers,
+		client:  &http.Client{},
+	}
+	if conf.TLS.Enabled {
+		tlsConf, err := conf.TLS.Get()
+		if err != nil {
+			return nil, err
+		}
+		c.url = "https://" + conf.Endpoint + conf.URLPath
+		c.client.Transport = &http.Transport{TLSClientConfig: tlsConf}
+	}
+	return c, nil
+}
+
+func (c *otlpMetricsHTTPClient) Export(ctx context.Context, req *collectormetrics.ExportMetricsServiceRequest) error {
+	body, err := proto.Marshal(req)
+	if err != nil {
+		return err
+	}
+
+	hReq, err := http.NewRequestWithContext(ctx, "POST", c.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	hReq.Header.Set("Content-Type", "application/x-protobuf")
+	for k, v := range c.headers {
+		hReq.Header.Set(k, v)
+	}
+
+	res, err := c.client.Do(hReq)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		resBody, _ := ioutil.ReadAll(res.Body)
+		return fmt.Errorf("unexpected status code %v: %s", res.StatusCode, resBody)
+	}
+	return nil
+}
+
+func (c *otlpMetricsHTTPClient) Close() error {
+	c.client.CloseIdleConnections()
+	return nil
+}
+
+//------------------------------------------------------------------------------
@@ -0,0 +1,270 @@
+package metrics
+
+import (
+	"context"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	collectormetrics "go.opentelemetry.io/proto/otlp/collector/metrics/v1"
+	metricspb "go.opentelemetry.io/proto/otlp/metrics/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/proto"
+)
+
+type otlpMetricsReceiver struct {
+	collectormetrics.UnimplementedMetricsServiceServer
+
+	mut      sync.Mutex
+	requests []*collectormetrics.ExportMetricsServiceRequest
+	headers  []string
+}
+
+func (r *otlpMetricsReceiver) Export(ctx context.Context, req *collectormetrics.ExportMetricsServiceRequest) (*collectormetrics.ExportMetricsServiceResponse, error) {
+	r.mut.Lock()
+	r.requests = append(r.requests, req)
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		r.headers = append(r.headers, md.Get("x-foo")...)
+	}
+	r.mut.Unlock()
+	return &collectormetrics.ExportMetricsServiceResponse{}, nil
+}
+
+func (r *otlpMetricsReceiver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.URL.Path != "/v1/metrics" {
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+
+	body, err := ioutil.ReadAll(req.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var exportReq collectormetrics.ExportMetricsServiceRequest
+	if err := proto.Unmarshal(body, &exportReq); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	r.mut.Lock()
+	r.requests = append(r.requests, &exportReq)
+	r.headers = append(r.headers, req.Header.Get("X-Foo"))
+	r.mut.Unlock()
+}
+
+// lastMetrics returns the metrics of the last received request by name.
+func (r *otlpMetricsReceiver) lastMetrics(t *testing.T) map[string]*metricspb.Metric {
+	t.Helper()
+
+	r.mut.Lock()
+	defer r.mut.Unlock()
+
+	require.NotEmpty(t, r.requests)
+	req := r.requests[len(r.requests)-1]
+
+	require.Len(t, req.ResourceMetrics, 1)
+	rm := req.ResourceMetrics[0]
+
+	attrs := map[string]string{}
+	for _, kv := range rm.Resource.Attributes {
+		attrs[kv.Key] = kv.Value.GetStringValue()
+	}
+	assert.Equal(t, map[string]string{
+		"service.name":           "foo",
+		"deployment.environment": "testing",
+	}, attrs)
+
+	metrics := map[string]*metricspb.Metric{}
+	for _, ilm := range rm.InstrumentationLibraryMetrics {
+		for _, m := range ilm.Metrics {
+			metrics[m.Name] = m
+		}
+	}
+	return metrics
+}
+
+func testOTELMetrics(t *testing.T, fn func(c *OpenTelemetryConfig)) Type {
+	t.Helper()
+
+	conf := NewConfig()
+	conf.Type = TypeOpenTelemetry
+	conf.OpenTelemetry.ServiceName = "foo"
+	conf.OpenTelemetry.ResourceAttributes = map[string]string{
+		"deployment.environment": "testing",
+	}
+	conf.OpenTelemetry.Headers = map[string]string{
+		"x-foo": "bar",
+	}
+	conf.OpenTelemetry.PushInterval = "1h"
+	conf.OpenTelemetry.HistogramBuckets = []float64{0.001, 0.01, 0.1}
+	fn(&conf.OpenTelemetry)
+
+	m, err := New(conf)
+	require.NoError(t, err)
+	return m
+}
+
+func TestOpenTelemetryMetricsTypes(t *testing.T) {
+	receiver := &otlpMetricsReceiver{}
+
+	ts := httptest.NewServer(receiver)
+	defer ts.Close()
+
+	m := testOTELMetrics(t, func(c *OpenTelemetryConfig) {
+		c.Protocol = "http"
+		c.Endpoint = strings.TrimPrefix(ts.URL, "http://")
+	})
+
+	m.GetCounter("foo.counter").Incr(3)
+	m.GetCounter("foo.counter").Incr(2)
+	m.GetGauge("foo.gauge").Set(10)
+	m.GetGauge("foo.gauge").Decr(3)
+
+	timer := m.GetTimer("foo.timer")
+	timer.Timing(int64(time.Microsecond * 500))
+	timer.Timing(int64(time.Millisecond * 5))
+	timer.Timing(int64(time.Millisecond * 5))
+	timer.Timing(int64(time.Second))
+
+	require.NoError(t, m.Close())
+
+	metrics := receiver.lastMetrics(t)
+	require.Len(t, metrics, 3)
+
+	counter := metrics["foo.counter"].GetSum()
+	require.NotNil(t, counter)
+	assert.True(t, counter.IsMonotonic)
+	assert.Equal(t, metricspb.AggregationTemporality_AGGREGATION_TEMPORALITY_CUMULATIVE, counter.AggregationTemporality)
+	require.Len(t, counter.DataPoints, 1)
+	assert.Equal(t, int64(5), counter.DataPoints[0].GetAsInt())
+
+	gauge := metrics["foo.gauge"].GetGauge()
+	require.NotNil(t, gauge)
+	require.Len(t, gauge.DataPoints, 1)
+	assert.Equal(t, int64(7), gauge.DataPoints[0].GetAsInt())
+
+	assert.Equal(t, "s", metrics["foo.timer"].Unit)
+	hist := metrics["foo.timer"].GetHistogram()
+	require.NotNil(t, hist)
+	require.Len(t, hist.DataPoints, 1)
+	assert.Equal(t, uint64(4), hist.DataPoints[0].Count)
+	assert.InDelta(t, 1.0105, hist.DataPoints[0].Sum, 0.00001)
+	assert.Equal(t, []float64{0.001, 0.01, 0.1}, hist.DataPoints[0].ExplicitBounds)
+	assert.Equal(t, []uint64{1, 2, 0, 1}, hist.DataPoints[0].BucketCounts)
+
+	receiver.mut.Lock()
+	assert.Equal(t, []string{"bar"}, receiver.headers)
+	receiver.mut.Unlock()
+}
+
+func TestOpenTelemetryMetricsLabels(t *testing.T) {
+	receiver := &otlpMetricsReceiver{}
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	server := grpc.NewServer()
+	collectormetrics.RegisterMetricsServiceServer(server, receiver)
+	go func() {
+		_ = server.Serve(lis)
+	}()
+	defer server.Stop()
+
+	m := testOTELMetrics(t, func(c *OpenTelemetryConfig) {
+		c.Endpoint = lis.Addr().String()
+		c.PathMapping = `let matches = this.re_find_all_submatch("resource_processor_([a-zA-Z]+)_(.*)")
+meta processor = $matches.0.1 | deleted()
+root = if $matches.length() > 0 { $matches.0.2 } else if this == "drop.me" { deleted() } else { this }`
+	})
+
+	m.GetCounter("resource_processor_foo_count").Incr(1)
+	m.GetCounter("resource_processor_bar_count").Incr(2)
+	m.GetCounter("drop.me").Incr(1)
+	m.GetCounterVec("requests", []string{"code"}).With("200").Incr(3)
+	m.GetCounterVec("requests", []string{"code"}).With("500").Incr(1)
+	m.GetGaugeVec("resource_processor_foo_gauge", []string{"baz"}).With("buz").Set(5)
+
+	require.NoError(t, m.Close())
+
+	metrics := receiver.lastMetrics(t)
+	require.Len(t, metrics, 3)
+
+	countPoints := map[string]int64{}
+	for _, dp := range metrics["count"].GetSum().DataPoints {
+		attrs := map[string]string{}
+		for _, kv := range dp.Attributes {
+			attrs[kv.Key] = kv.Value.GetStringValue()
+		}
+		countPoints[attrs["processor"]] = dp.GetAsInt()
+	}
+	assert.Equal(t, map[string]int64{"foo": 1, "bar": 2}, countPoints)
+
+	requestPoints := map[string]int64{}
+	for _, dp := range metrics["requests"].GetSum().DataPoints {
+		require.Len(t, dp.Attributes, 1)
+		assert.Equal(t, "code", dp.Attributes[0].Key)
+		requestPoints[dp.Attributes[0].Value.GetStringValue()] = dp.GetAsInt()
+	}
+	assert.Equal(t, map[string]int64{"200": 3, "500": 1}, requestPoints)
+
+	gaugePoints := metrics["gauge"].GetGauge().DataPoints
+	require.Len(t, gaugePoints, 1)
+	gaugeAttrs := map[string]string{}
+	for _, kv := range gaugePoints[0].Attributes {
+		gaugeAttrs[kv.Key] = kv.Value.GetStringValue()
+	}
+	assert.Equal(t, map[string]string{"processor": "foo", "baz": "buz"}, gaugeAttrs)
+	assert.Equal(t, int64(5), gaugePoints[0].GetAsInt())
+
+	receiver.mut.Lock()
+	assert.Equal(t, []string{"bar"}, receiver.headers)
+	receiver.mut.Unlock()
+}
+
+func TestOpenTelemetryMetricsPushInterval(t *testing.T) {
+	receiver := &otlpMetricsReceiver{}
+
+	ts := httptest.NewServer(receiver)
+	defer ts.Close()
+
+	m := testOTELMetrics(t, func(c *OpenTelemetryConfig) {
+		c.Protocol = "http"
+		c.Endpoint = strings.TrimPrefix(ts.URL, "http://")
+		c.PushInterval = "10ms"
+	})
+	defer m.Close()
+
+	m.GetCounter("foo").Incr(1)
+
+	assert.Eventually(t, func() bool {
+		receiver.mut.Lock()
+		defer receiver.mut.Unlock()
+		return len(receiver.requests) > 0
+	}, time.Second*5, time.Millisecond*10)
+}
+
+func TestOpenTelemetryMetricsBadConfig(t *testing.T) {
+	for _, fn := range []func(c *OpenTelemetryConfig){
+		func(c *OpenTelemetryConfig) { c.Protocol = "nope" },
+		func(c *OpenTelemetryConfig) { c.PushInterval = "nope" },
+		func(c *OpenTelemetryConfig) { c.HistogramBuckets = []float64{1, 0.5} },
+		func(c *OpenTelemetryConfig) { c.PathMapping = "root = " },
+	} {
+		conf := NewConfig()
+		conf.Type = TypeOpenTelemetry
+		fn(&conf.OpenTelemetry)
+
+		_, err := New(conf)
+		assert.Error(t, err)
+	}
+}
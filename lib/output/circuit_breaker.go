@@ -0,0 +1,303 @@
+package output
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/Jeffail/benthos/v3/internal/circuitbreaker"
+	"github.com/Jeffail/benthos/v3/internal/component/output"
+	"github.com/Jeffail/benthos/v3/internal/docs"
+	"github.com/Jeffail/benthos/v3/internal/interop"
+	"github.com/Jeffail/benthos/v3/lib/log"
+	"github.com/Jeffail/benthos/v3/lib/metrics"
+	"github.com/Jeffail/benthos/v3/lib/response"
+	"github.com/Jeffail/benthos/v3/lib/types"
+)
+
+//------------------------------------------------------------------------------
+
+func init() {
+	Constructors[TypeCircuitBreaker] = TypeSpec{
+		constructor: fromSimpleConstructor(NewCircuitBreaker),
+		Status:      docs.StatusExperimental,
+		Version:     "3.47.0",
+		Summary: `
+Writes messages to a child output, and when the child output appears to be
+unhealthy trips open so that messages fail immediately rather than being
+attempted.`,
+		Description: `
+The circuit breaker trips open after either a number of consecutive failed
+writes or when the rate of failed writes within a rolling window exceeds a
+threshold. Whilst open, messages are rejected immediately with an error without
+being sent to the child output. Once the ` + "`open_timeout`" + ` has elapsed
+the circuit breaker becomes half-open, where a limited number of probe writes
+are attempted. If the probes succeed the circuit breaker closes, otherwise it
+opens once again.
+
+Rejected messages are handled in the same way as any other output error. In
+order to reroute messages whilst the circuit breaker is open place it within a
+` + "[`try`](/docs/components/outputs/try)" + ` output, as shown in the
+examples.
+
+### Metrics
+
+The current state of the circuit breaker is exposed as the gauge
+` + "`circuit_breaker.state`" + `, where 0 is closed, 1 is half-open and 2 is
+open. The counters ` + "`circuit_breaker.tripped`" + `,
+` + "`circuit_breaker.half_open`" + `, ` + "`circuit_breaker.closed`" + ` and
+` + "`circuit_breaker.rejected`" + ` track state transitions and rejected
+writes.`,
+		FieldSpecs: circuitbreaker.FieldSpecs().Add(
+			docs.FieldCommon("output", "A child output.").HasType(docs.FieldOutput),
+		),
+		Categories: []Category{
+			CategoryUtility,
+		},
+		Examples: []docs.AnnotatedExample{
+			{
+				Title:   "Rerouting Whilst Open",
+				Summary: "In this example messages are written to an HTTP endpoint, and after five consecutive failures all messages are written to a file instead for one minute, after which the endpoint is probed again.",
+				Config: `
+output:
+  try:
+    - circuit_breaker:
+        consecutive_failures: 5
+        open_timeout: 1m
+        output:
+          http_client:
+            url: http://example.com/events
+            verb: POST
+    - file:
+        path: /var/log/benthos/failed_events.jsonl
+        codec: lines
+`,
+			},
+		},
+	}
+}
+
+//------------------------------------------------------------------------------
+
+// CircuitBreakerConfig contains configuration values for the CircuitBreaker
+// output type.
+type CircuitBreakerConfig struct {
+	circuitbreaker.Config `json:",inline" yaml:",inline"`
+	Output                *Config `json:"output" yaml:"output"`
+}
+
+// NewCircuitBreakerConfig creates a new CircuitBreakerConfig with default
+// values.
+func NewCircuitBreakerConfig() CircuitBreakerConfig {
+	return CircuitBreakerConfig{
+		Config: circuitbreaker.NewConfig(),
+		Output: nil,
+	}
+}
+
+//------------------------------------------------------------------------------
+
+type dummyCircuitBreakerConfig struct {
+	circuitbreaker.Config `json:",inline" yaml:",inline"`
+	Output                interface{} `json:"output" yaml:"output"`
+}
+
+// MarshalJSON prints an empty object instead of nil.
+func (c CircuitBreakerConfig) MarshalJSON() ([]byte, error) {
+	dummy := dummyCircuitBreakerConfig{
+		Config: c.Config,
+		Output: c.Output,
+	}
+	if c.Output == nil {
+		dummy.Output = struct{}{}
+	}
+	return json.Marshal(dummy)
+}
+
+// MarshalYAML prints an empty object instead of nil.
+func (c CircuitBreakerConfig) MarshalYAML() (interface{}, error) {
+	dummy := dummyCircuitBreakerConfig{
+		Config: c.Config,
+		Output: c.Output,
+	}
+	if c.Output == nil {
+		dummy.Output = struct{}{}
+	}
+	return dummy, nil
+}
+
+//------------------------------------------------------------------------------
+
+// CircuitBreaker is an output type that writes messages to a child output, and
+// rejects messages without attempting them whilst the child output appears to
+// be unhealthy.
+type CircuitBreaker struct {
+	running int32
+
+	wrapped Type
+	breaker *circuitbreaker.Breaker
+
+	stats metrics.Type
+	log   log.Modular
+
+	transactionsIn  <-chan types.Transaction
+	transactionsOut chan types.Transaction
+
+	closeChan  chan struct{}
+	closedChan chan struct{}
+}
+
+// NewCircuitBreaker creates a new CircuitBreaker output type.
+func NewCircuitBreaker(
+	conf Config,
+	mgr types.Manager,
+	log log.Modular,
+	stats metrics.Type,
+) (Type, error) {
+	if conf.CircuitBreaker.Output == nil {
+		return nil, errors.New("cannot create circuit_breaker output without a child")
+	}
+
+	breaker, err := circuitbreaker.New(conf.CircuitBreaker.Config, stats)
+	if err != nil {
+		return nil, err
+	}
+
+	oMgr, oLog, oStats := interop.LabelChild("circuit_breaker.output", mgr, log, stats)
+	wrapped, err := New(*conf.CircuitBreaker.Output, oMgr, oLog, metrics.Combine(stats, oStats))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create output '%v': %v", conf.CircuitBreaker.Output.Type, err)
+	}
+
+	return &CircuitBreaker{
+		running: 1,
+
+		wrapped: wrapped,
+		breaker: breaker,
+
+		log:   log,
+		stats: stats,
+
+		transactionsOut: make(chan types.Transaction),
+
+		closeChan:  make(chan struct{}),
+		closedChan: make(chan struct{}),
+	}, nil
+}
+
+//------------------------------------------------------------------------------
+
+func (c *CircuitBreaker) loop() {
+	wg := sync.WaitGroup{}
+
+	defer func() {
+		wg.Wait()
+		close(c.transactionsOut)
+		c.wrapped.CloseAsync()
+		for err := c.wrapped.WaitForClose(time.Second); err != nil; err = c.wrapped.WaitForClose(time.Second) {
+		}
+		close(c.closedChan)
+	}()
+
+	for atomic.LoadInt32(&c.running) == 1 {
+		var tran types.Transaction
+		var open bool
+		select {
+		case tran, open = <-c.transactionsIn:
+			if !open {
+				return
+			}
+		case <-c.closeChan:
+			return
+		}
+
+		if err := c.breaker.Allow(); err != nil {
+			select {
+			case tran.ResponseChan <- response.NewError(err):
+			case <-c.closeChan:
+				return
+			}
+			continue
+		}
+
+		resChan := make(chan types.Response)
+		select {
+		case c.transactionsOut <- types.NewTransaction(tran.Payload, resChan):
+		case <-c.closeChan:
+			return
+		}
+
+		wg.Add(1)
+		go func(ts types.Transaction, resChan chan types.Response) {
+			defer wg.Done()
+
+			var res types.Response
+			select {
+			case res = <-resChan:
+			case <-c.closeChan:
+				return
+			}
+
+			if err := res.Error(); err != nil {
+				c.breaker.Failure()
+				c.log.Errorf("Failed to send message: %v\n", err)
+			} else {
+				c.breaker.Success()
+			}
+
+			select {
+			case ts.ResponseChan <- res:
+			case <-c.closeChan:
+			}
+		}(tran, resChan)
+	}
+}
+
+// Consume assigns a messages channel for the output to read.
+func (c *CircuitBreaker) Consume(ts <-chan types.Transaction) error {
+	if c.transactionsIn != nil {
+		return types.ErrAlreadyStarted
+	}
+	if err := c.wrapped.Consume(c.transactionsOut); err != nil {
+		return err
+	}
+	c.transactionsIn = ts
+	go c.loop()
+	return nil
+}
+
+// Connected returns a boolean indicating whether this output is currently
+// connected to its target.
+func (c *CircuitBreaker) Connected() bool {
+	return c.wrapped.Connected()
+}
+
+// MaxInFlight returns the maximum number of in flight messages permitted by the
+// output. This value can be used to determine a sensible value for parent
+// outputs, but should not be relied upon as part of dispatcher logic.
+func (c *CircuitBreaker) MaxInFlight() (int, bool) {
+	return output.GetMaxInFlight(c.wrapped)
+}
+
+// CloseAsync shuts down the CircuitBreaker output and stops processing
+// requests.
+func (c *CircuitBreaker) CloseAsync() {
+	if atomic.CompareAndSwapInt32(&c.running, 1, 0) {
+		close(c.closeChan)
+	}
+}
+
+// WaitForClose blocks until the CircuitBreaker output has closed down.
+func (c *CircuitBreaker) WaitForClose(timeout time.Duration) error {
+	select {
+	case <-c.closedChan:
+	case <-time.After(timeout):
+		return types.ErrTimeout
+	}
+	return nil
+}
+
+//------------------------------------------------------------------------------
@@ -0,0 +1,106 @@
+package output
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Jeffail/benthos/v3/internal/circuitbreaker"
+	"github.com/Jeffail/benthos/v3/lib/log"
+	"github.com/Jeffail/benthos/v3/lib/message"
+	"github.com/Jeffail/benthos/v3/lib/metrics"
+	"github.com/Jeffail/benthos/v3/lib/response"
+	"github.com/Jeffail/benthos/v3/lib/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCircuitBreakerConfigErrs(t *testing.T) {
+	conf := NewConfig()
+	conf.Type = TypeCircuitBreaker
+	_, err := New(conf, nil, log.Noop(), metrics.Noop())
+	assert.Error(t, err)
+
+	childConf := NewConfig()
+	childConf.Type = TypeDrop
+
+	conf.CircuitBreaker.Output = &childConf
+	conf.CircuitBreaker.OpenTimeout = "nope"
+	_, err = New(conf, nil, log.Noop(), metrics.Noop())
+	assert.Error(t, err)
+}
+
+func TestCircuitBreakerTrips(t *testing.T) {
+	childConf := NewConfig()
+	childConf.Type = TypeDrop
+
+	conf := NewConfig()
+	conf.CircuitBreaker.Output = &childConf
+	conf.CircuitBreaker.ConsecutiveFailures = 2
+	conf.CircuitBreaker.OpenTimeout = "50ms"
+
+	stats := metrics.NewLocal()
+	o, err := NewCircuitBreaker(conf, nil, log.Noop(), stats)
+	require.NoError(t, err)
+
+	c, ok := o.(*CircuitBreaker)
+	require.True(t, ok)
+
+	mOut := &mockOutput{}
+	c.wrapped = mOut
+
+	tChan := make(chan types.Transaction)
+	require.NoError(t, c.Consume(tChan))
+	t.Cleanup(func() {
+		c.CloseAsync()
+		assert.NoError(t, c.WaitForClose(time.Second))
+	})
+
+	sendMsg := func() <-chan types.Response {
+		resChan := make(chan types.Response, 1)
+		select {
+		case tChan <- types.NewTransaction(message.New([][]byte{[]byte("hello world")}), resChan):
+		case <-time.After(time.Second):
+			t.Fatal("timed out")
+		}
+		return resChan
+	}
+
+	readRes := func(resChan <-chan types.Response) error {
+		select {
+		case res := <-resChan:
+			return res.Error()
+		case <-time.After(time.Second):
+			t.Fatal("timed out")
+		}
+		return nil
+	}
+
+	for i := 0; i < 2; i++ {
+		resChan := sendMsg()
+		tran := readTran(t, mOut.ts)
+		sendRes(t, tran.ResponseChan, response.NewError(errors.New("nope")))
+		assert.EqualError(t, readRes(resChan), "nope")
+	}
+
+	// Open, messages are rejected without reaching the child.
+	assert.Equal(t, circuitbreaker.ErrOpen, readRes(sendMsg()))
+	assert.Equal(t, int64(circuitbreaker.StateOpen), stats.GetCounters()["circuit_breaker.state"])
+
+	<-time.After(time.Millisecond * 60)
+
+	// Half open, a probe is attempted and closes the breaker.
+	resChan := sendMsg()
+	tran := readTran(t, mOut.ts)
+	sendRes(t, tran.ResponseChan, response.NewAck())
+	assert.NoError(t, readRes(resChan))
+	assert.Equal(t, int64(circuitbreaker.StateClosed), stats.GetCounters()["circuit_breaker.state"])
+
+	resChan = sendMsg()
+	tran = readTran(t, mOut.ts)
+	sendRes(t, tran.ResponseChan, response.NewAck())
+	assert.NoError(t, readRes(resChan))
+
+	assert.Equal(t, int64(1), stats.GetCounters()["circuit_breaker.tripped"])
+	assert.Equal(t, int64(1), stats.GetCounters()["circuit_breaker.rejected"])
+}
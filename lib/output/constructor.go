@@ -167,12 +167,14 @@ const (
 	TypeBroker             = "broker"
 	TypeCache              = "cache"
 	TypeCassandra          = "cassandra"
+	TypeCircuitBreaker     = "circuit_breaker"
 	TypeDrop               = "drop"
 	TypeDropOn             = "drop_on"
 	TypeDropOnError        = "drop_on_error"
 	TypeDynamic            = "dynamic"
 	TypeDynamoDB           = "dynamodb"
 	TypeElasticsearch      = "elasticsearch"
+	TypeFallbackDLQ        = "fallback_dlq"
 	TypeFile               = "file"
 	TypeFiles              = "files"
 	TypeGCPCloudStorage    = "gcp_cloud_storage"
@@ -208,6 +210,7 @@ const (
 	TypeSubprocess         = "subprocess"
 	TypeSwitch             = "switch"
 	TypeSyncResponse       = "sync_response"
+	TypeSyslog             = "syslog"
 	TypeTableStorage       = "table_storage"
 	TypeTCP                = "tcp"
 	TypeTry                = "try"
@@ -239,12 +242,14 @@ type Config struct {
 	Broker             BrokerConfig                   `json:"broker" yaml:"broker"`
 	Cache              writer.CacheConfig             `json:"cache" yaml:"cache"`
 	Cassandra          CassandraConfig                `json:"cassandra" yaml:"cassandra"`
+	CircuitBreaker     CircuitBreakerConfig           `json:"circuit_breaker" yaml:"circuit_breaker"`
 	Drop               writer.DropConfig              `json:"drop" yaml:"drop"`
 	DropOn             DropOnConfig                   `json:"drop_on" yaml:"drop_on"`
 	DropOnError        DropOnErrorConfig              `json:"drop_on_error" yaml:"drop_on_error"`
 	Dynamic            DynamicConfig                  `json:"dynamic" yaml:"dynamic"`
 	DynamoDB           writer.DynamoDBConfig          `json:"dynamodb" yaml:"dynamodb"`
 	Elasticsearch      writer.ElasticsearchConfig     `json:"elasticsearch" yaml:"elasticsearch"`
+	FallbackDLQ        FallbackDLQConfig              `json:"fallback_dlq" yaml:"fallback_dlq"`
 	File               FileConfig                     `json:"file" yaml:"file"`
 	Files              writer.FilesConfig             `json:"files" yaml:"files"`
 	GCPCloudStorage    GCPCloudStorageConfig          `json:"gcp_cloud_storage" yaml:"gcp_cloud_storage"`
@@ -281,6 +286,7 @@ type Config struct {
 	Subprocess         SubprocessConfig               `json:"subprocess" yaml:"subprocess"`
 	Switch             SwitchConfig                   `json:"switch" yaml:"switch"`
 	SyncResponse       struct{}                       `json:"sync_response" yaml:"sync_response"`
+	Syslog             writer.SyslogConfig            `json:"syslog" yaml:"syslog"`
 	TableStorage       writer.AzureTableStorageConfig `json:"table_storage" yaml:"table_storage"`
 	TCP                writer.TCPConfig               `json:"tcp" yaml:"tcp"`
 	Try                TryConfig                      `json:"try" yaml:"try"`
@@ -312,12 +318,14 @@ func NewConfig() Config {
 		Broker:             NewBrokerConfig(),
 		Cache:              writer.NewCacheConfig(),
 		Cassandra:          NewCassandraConfig(),
+		CircuitBreaker:     NewCircuitBreakerConfig(),
 		Drop:               writer.NewDropConfig(),
 		DropOn:             NewDropOnConfig(),
 		DropOnError:        NewDropOnErrorConfig(),
 		Dynamic:            NewDynamicConfig(),
 		DynamoDB:           writer.NewDynamoDBConfig(),
 		Elasticsearch:      writer.NewElasticsearchConfig(),
+		FallbackDLQ:        NewFallbackDLQConfig(),
 		File:               NewFileConfig(),
 		Files:              writer.NewFilesConfig(),
 		GCPCloudStorage:    NewGCPCloudStorageConfig(),
@@ -354,6 +362,7 @@ func NewConfig() Config {
 		Subprocess:         NewSubprocessConfig(),
 		Switch:             NewSwitchConfig(),
 		SyncResponse:       struct{}{},
+		Syslog:             writer.NewSyslogConfig(),
 		TableStorage:       writer.NewAzureTableStorageConfig(),
 		TCP:                writer.NewTCPConfig(),
 		Try:                NewTryConfig(),
@@ -26,6 +26,23 @@ Both the ` + "`id` and `index`" + ` fields can be dynamically set using function
 interpolations described [here](/docs/configuration/interpolation#bloblang-queries). When
 sending batched messages these interpolations are performed per message part.
 
+### Actions
+
+The ` + "`action`" + ` field determines the operation performed for each
+message, and can also be dynamically set using function interpolations. The
+following actions are supported:
+
+- ` + "`index`" + ` writes the message as a document, replacing any existing document with the same ID.
+- ` + "`create`" + ` writes the message as a document, failing if a document with the same ID already exists.
+- ` + "`update`" + ` merges the message as a partial document into an existing document. When ` + "`doc_as_upsert`" + ` is ` + "`true`" + ` the message is written as a new document if one does not exist.
+- ` + "`upsert`" + ` runs the script specified by the field ` + "`script`" + ` against an existing document, where the message is provided to the script as ` + "`params`" + `. If the document does not exist the message is written as a new document instead.
+- ` + "`delete`" + ` deletes the document with the ID of the message, the contents of the message are ignored. Deleting a document that does not exist is not considered an error.
+
+When a batch is sent and only some of its messages fail, only those messages are
+retried or, once retries are exhausted, reported as failed. This means
+[error handling patterns](/docs/configuration/error_handling) such as dead
+letter queues only receive the messages that failed.
+
 ### AWS
 
 It's possible to enable AWS connectivity with this output using the ` + "`aws`" + `
@@ -38,6 +55,9 @@ false for connections to succeed.`,
 			docs.FieldCommon("index", "The index to place messages.").IsInterpolated(),
 			docs.FieldAdvanced("pipeline", "An optional pipeline id to preprocess incoming documents.").IsInterpolated(),
 			docs.FieldCommon("id", "The ID for indexed messages. Interpolation should be used in order to create a unique ID for each message.").IsInterpolated(),
+			docs.FieldCommon("action", "The [action](#actions) to perform for each message.", "index", "create", "update", "upsert", "delete", `${! meta("action") }`).IsInterpolated().AtVersion("3.47.0"),
+			docs.FieldAdvanced("doc_as_upsert", "Whether the `update` action should write a message as a new document when an existing document is not found.").AtVersion("3.47.0"),
+			docs.FieldAdvanced("script", "An inline script to execute for the `upsert` action, where the contents of a message are provided as `params`.", "ctx._source.counter += params.count").AtVersion("3.47.0"),
 			docs.FieldCommon("type", "The document type."),
 			docs.FieldAdvanced("sniff", "Prompts Benthos to sniff for brokers to connect to when establishing a connection."),
 			docs.FieldAdvanced("healthcheck", "Whether to enable healthchecks."),
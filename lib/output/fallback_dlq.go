@@ -0,0 +1,477 @@
+package output
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/Jeffail/benthos/v3/internal/batch"
+	"github.com/Jeffail/benthos/v3/internal/component/output"
+	"github.com/Jeffail/benthos/v3/internal/docs"
+	"github.com/Jeffail/benthos/v3/internal/interop"
+	"github.com/Jeffail/benthos/v3/lib/log"
+	"github.com/Jeffail/benthos/v3/lib/message"
+	"github.com/Jeffail/benthos/v3/lib/metrics"
+	"github.com/Jeffail/benthos/v3/lib/types"
+	"github.com/Jeffail/benthos/v3/lib/util/retries"
+	"github.com/cenkalti/backoff/v4"
+)
+
+//------------------------------------------------------------------------------
+
+func init() {
+	Constructors[TypeFallbackDLQ] = TypeSpec{
+		constructor: fromSimpleConstructor(NewFallbackDLQ),
+		Status:      docs.StatusExperimental,
+		Version:     "3.47.0",
+		Summary: `
+Attempts to write messages to a child output with retries, and once the retries
+are exhausted sends the messages to a dead letter queue output annotated with
+the reason for the failure.`,
+		Description: `
+Messages that fail to be written to the primary ` + "`output`" + ` are
+reattempted according to the retry fields of this output. If a message still
+fails once the retries are exhausted then it is sent to the ` + "`dlq`" + ` output
+instead, with the following metadata fields added:
+
+` + "``` text" + `
+- dlq_error
+- dlq_label
+- dlq_attempts
+- dlq_first_attempt_at
+- dlq_last_attempt_at
+` + "```" + `
+
+Where ` + "`dlq_error`" + ` is the error returned by the final attempt,
+` + "`dlq_label`" + ` is the label of the primary output (or its type when no
+label is set), ` + "`dlq_attempts`" + ` is the total number of attempts made and
+the timestamps are formatted as RFC 3339 strings.
+
+When the primary output is able to report which messages of a batch failed only
+those messages are retried, and once the retries are exhausted only those
+messages are sent to the dead letter queue. If the message also fails to
+be written to the dead letter queue then the error is propagated back to the
+input, where it is handled in the same way as any other output error.`,
+		FieldSpecs: retries.FieldSpecs().Add(
+			docs.FieldCommon("output", "The primary output to write messages to.").HasType(docs.FieldOutput),
+			docs.FieldCommon("dlq", "An output to write messages to once attempts to write them to the primary output have been exhausted.").HasType(docs.FieldOutput),
+			docs.FieldCommon("max_in_flight", "The maximum number of messages to have in flight at a given time, including those waiting to be retried. If the primary output supports a higher number of messages in flight then that value is used instead."),
+		),
+		Categories: []Category{
+			CategoryUtility,
+		},
+		Examples: []docs.AnnotatedExample{
+			{
+				Title:   "HTTP with a Kafka DLQ",
+				Summary: "In this example messages are sent to an HTTP endpoint, and messages that fail after three retries are written to a Kafka topic along with the reason they failed.",
+				Config: `
+output:
+  fallback_dlq:
+    max_retries: 3
+    output:
+      label: events_api
+      http_client:
+        url: http://example.com/events
+        verb: POST
+    dlq:
+      kafka:
+        addresses: [ localhost:9092 ]
+        topic: events_dlq
+`,
+			},
+		},
+	}
+}
+
+//------------------------------------------------------------------------------
+
+// FallbackDLQConfig contains configuration values for the FallbackDLQ output
+// type.
+type FallbackDLQConfig struct {
+	Output         *Config `json:"output" yaml:"output"`
+	DLQ            *Config `json:"dlq" yaml:"dlq"`
+	MaxInFlight    int     `json:"max_in_flight" yaml:"max_in_flight"`
+	retries.Config `json:",inline" yaml:",inline"`
+}
+
+// NewFallbackDLQConfig creates a new FallbackDLQConfig with default values.
+func NewFallbackDLQConfig() FallbackDLQConfig {
+	rConf := retries.NewConfig()
+	rConf.MaxRetries = 3
+	return FallbackDLQConfig{
+		Output:      nil,
+		DLQ:         nil,
+		MaxInFlight: 1,
+		Config:      rConf,
+	}
+}
+
+//------------------------------------------------------------------------------
+
+type dummyFallbackDLQConfig struct {
+	Output         interface{} `json:"output" yaml:"output"`
+	DLQ            interface{} `json:"dlq" yaml:"dlq"`
+	MaxInFlight    int         `json:"max_in_flight" yaml:"max_in_flight"`
+	retries.Config `json:",inline" yaml:",inline"`
+}
+
+func (f FallbackDLQConfig) dummy() dummyFallbackDLQConfig {
+	dummy := dummyFallbackDLQConfig{
+		Output:      f.Output,
+		DLQ:         f.DLQ,
+		MaxInFlight: f.MaxInFlight,
+		Config:      f.Config,
+	}
+	if f.Output == nil {
+		dummy.Output = struct{}{}
+	}
+	if f.DLQ == nil {
+		dummy.DLQ = struct{}{}
+	}
+	return dummy
+}
+
+// MarshalJSON prints empty objects instead of nil.
+func (f FallbackDLQConfig) MarshalJSON() ([]byte, error) {
+	return json.Marshal(f.dummy())
+}
+
+// MarshalYAML prints empty objects instead of nil.
+func (f FallbackDLQConfig) MarshalYAML() (interface{}, error) {
+	return f.dummy(), nil
+}
+
+//------------------------------------------------------------------------------
+
+// FallbackDLQ is an output type that writes messages to a child output with
+// retries, and once the retries are exhausted writes the messages to a dead
+// letter queue output.
+type FallbackDLQ struct {
+	running int32
+
+	label       string
+	primary     Type
+	dlq         Type
+	backoffCtor func() backoff.BackOff
+	maxInFlight int
+
+	stats metrics.Type
+	log   log.Modular
+
+	transactionsIn <-chan types.Transaction
+	primaryOut     chan types.Transaction
+	dlqOut         chan types.Transaction
+
+	closeChan  chan struct{}
+	closedChan chan struct{}
+}
+
+// NewFallbackDLQ creates a new FallbackDLQ output type.
+func NewFallbackDLQ(
+	conf Config,
+	mgr types.Manager,
+	log log.Modular,
+	stats metrics.Type,
+) (Type, error) {
+	if conf.FallbackDLQ.Output == nil {
+		return nil, errors.New("cannot create fallback_dlq output without a child output")
+	}
+	if conf.FallbackDLQ.DLQ == nil {
+		return nil, errors.New("cannot create fallback_dlq output without a dlq output")
+	}
+	if conf.FallbackDLQ.MaxRetries == 0 {
+		if d, _ := time.ParseDuration(conf.FallbackDLQ.Backoff.MaxElapsedTime); d <= 0 {
+			return nil, errors.New("either max_retries or backoff.max_elapsed_time must be set, otherwise messages are retried indefinitely")
+		}
+	}
+
+	backoffCtor, err := conf.FallbackDLQ.GetCtor()
+	if err != nil {
+		return nil, err
+	}
+
+	pMgr, pLog, pStats := interop.LabelChild("fallback_dlq.output", mgr, log, stats)
+	primary, err := New(*conf.FallbackDLQ.Output, pMgr, pLog, metrics.Combine(stats, pStats))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create output '%v': %v", conf.FallbackDLQ.Output.Type, err)
+	}
+
+	dMgr, dLog, dStats := interop.LabelChild("fallback_dlq.dlq", mgr, log, stats)
+	dlq, err := New(*conf.FallbackDLQ.DLQ, dMgr, dLog, metrics.Combine(stats, dStats))
+	if err != nil {
+		primary.CloseAsync()
+		return nil, fmt.Errorf("failed to create dlq output '%v': %v", conf.FallbackDLQ.DLQ.Type, err)
+	}
+
+	maxInFlight := conf.FallbackDLQ.MaxInFlight
+	if mif, ok := output.GetMaxInFlight(primary); ok && mif > maxInFlight {
+		maxInFlight = mif
+	}
+	if maxInFlight < 1 {
+		maxInFlight = 1
+	}
+
+	label := conf.FallbackDLQ.Output.Label
+	if label == "" {
+		label = conf.FallbackDLQ.Output.Type
+	}
+
+	return &FallbackDLQ{
+		running: 1,
+
+		label:       label,
+		primary:     primary,
+		dlq:         dlq,
+		backoffCtor: backoffCtor,
+		maxInFlight: maxInFlight,
+
+		log:   log,
+		stats: stats,
+
+		primaryOut: make(chan types.Transaction),
+		dlqOut:     make(chan types.Transaction),
+
+		closeChan:  make(chan struct{}),
+		closedChan: make(chan struct{}),
+	}, nil
+}
+
+//------------------------------------------------------------------------------
+
+func (f *FallbackDLQ) send(tChan chan<- types.Transaction, msg types.Message) (types.Response, bool) {
+	resChan := make(chan types.Response)
+	select {
+	case tChan <- types.NewTransaction(msg, resChan):
+	case <-f.closeChan:
+		return nil, false
+	}
+	select {
+	case res := <-resChan:
+		return res, true
+	case <-f.closeChan:
+		return nil, false
+	}
+}
+
+// annotate creates a copy of the messages of a batch that failed to be written
+// to the primary output, with metadata describing the failure added to each.
+func (f *FallbackDLQ) annotate(msg types.Message, err error, attempts int, firstAttempt, lastAttempt time.Time) types.Message {
+	annotated := message.New(nil)
+	addPart := func(p types.Part, pErr error) {
+		part := p.Copy()
+		meta := part.Metadata()
+		meta.Set("dlq_error", pErr.Error())
+		meta.Set("dlq_label", f.label)
+		meta.Set("dlq_attempts", strconv.Itoa(attempts))
+		meta.Set("dlq_first_attempt_at", firstAttempt.Format(time.RFC3339Nano))
+		meta.Set("dlq_last_attempt_at", lastAttempt.Format(time.RFC3339Nano))
+		annotated.Append(part)
+	}
+
+	var bErr batch.WalkableError
+	if errors.As(err, &bErr) && bErr.IndexedErrors() > 0 {
+		bErr.WalkParts(func(_ int, p types.Part, pErr error) bool {
+			if pErr != nil {
+				addPart(p, pErr)
+			}
+			return true
+		})
+		return annotated
+	}
+
+	msg.Iter(func(_ int, p types.Part) error {
+		addPart(p, err)
+		return nil
+	})
+	return annotated
+}
+
+// failedParts returns the messages of a batch that failed according to an
+// error, which is the whole batch unless the error identifies a subset of it.
+func failedParts(msg types.Message, err error) types.Message {
+	var bErr batch.WalkableError
+	if !errors.As(err, &bErr) || bErr.IndexedErrors() == 0 || bErr.IndexedErrors() >= msg.Len() {
+		return msg
+	}
+	failed := message.New(nil)
+	bErr.WalkParts(func(i int, _ types.Part, pErr error) bool {
+		if pErr != nil && i < msg.Len() {
+			failed.Append(msg.Get(i))
+		}
+		return true
+	})
+	if failed.Len() == 0 {
+		return msg
+	}
+	return failed
+}
+
+func (f *FallbackDLQ) loop() {
+	// Metrics paths
+	var (
+		mRunning      = f.stats.GetGauge("fallback_dlq.running")
+		mCount        = f.stats.GetCounter("fallback_dlq.count")
+		mSuccess      = f.stats.GetCounter("fallback_dlq.send.success")
+		mError        = f.stats.GetCounter("fallback_dlq.send.error")
+		mEndOfRetries = f.stats.GetCounter("fallback_dlq.end_of_retries")
+		mDLQSuccess   = f.stats.GetCounter("fallback_dlq.dlq.send.success")
+		mDLQError     = f.stats.GetCounter("fallback_dlq.dlq.send.error")
+	)
+
+	wg := sync.WaitGroup{}
+	inFlight := make(chan struct{}, f.maxInFlight)
+
+	defer func() {
+		wg.Wait()
+		close(f.primaryOut)
+		close(f.dlqOut)
+		f.primary.CloseAsync()
+		f.dlq.CloseAsync()
+		for err := f.primary.WaitForClose(time.Second); err != nil; err = f.primary.WaitForClose(time.Second) {
+		}
+		for err := f.dlq.WaitForClose(time.Second); err != nil; err = f.dlq.WaitForClose(time.Second) {
+		}
+		mRunning.Decr(1)
+		close(f.closedChan)
+	}()
+	mRunning.Incr(1)
+
+	for atomic.LoadInt32(&f.running) == 1 {
+		// Do not consume another message until there is room for it to be in
+		// flight, as it may occupy a slot for the duration of its retries.
+		select {
+		case inFlight <- struct{}{}:
+		case <-f.closeChan:
+			return
+		}
+
+		var tran types.Transaction
+		var open bool
+		select {
+		case tran, open = <-f.transactionsIn:
+			if !open {
+				return
+			}
+			mCount.Incr(1)
+		case <-f.closeChan:
+			return
+		}
+
+		wg.Add(1)
+		go func(ts types.Transaction) {
+			defer func() {
+				<-inFlight
+				wg.Done()
+			}()
+
+			var backOff backoff.BackOff
+			var res types.Response
+			var lastAttempt time.Time
+
+			// Only the messages that failed are retried, or the whole batch
+			// when the primary output does not report which of them failed.
+			pending := ts.Payload
+			firstAttempt := time.Now()
+			attempts := 0
+			for {
+				lastAttempt = time.Now()
+				attempts++
+
+				var open bool
+				if res, open = f.send(f.primaryOut, pending); !open {
+					return
+				}
+				if res.Error() == nil {
+					mSuccess.Incr(1)
+					break
+				}
+
+				mError.Incr(1)
+				f.log.Errorf("Failed to send message to '%v': %v\n", f.label, res.Error())
+				if backOff == nil {
+					backOff = f.backoffCtor()
+				}
+
+				nextBackoff := backOff.NextBackOff()
+				if nextBackoff == backoff.Stop {
+					mEndOfRetries.Incr(1)
+					dlqMsg := f.annotate(pending, res.Error(), attempts, firstAttempt, lastAttempt)
+					if res, open = f.send(f.dlqOut, dlqMsg); !open {
+						return
+					}
+					if res.Error() != nil {
+						mDLQError.Incr(1)
+						f.log.Errorf("Failed to send message to dlq: %v\n", res.Error())
+					} else {
+						mDLQSuccess.Incr(1)
+					}
+					break
+				}
+
+				pending = failedParts(pending, res.Error())
+				select {
+				case <-time.After(nextBackoff):
+				case <-f.closeChan:
+					return
+				}
+			}
+
+			select {
+			case ts.ResponseChan <- res:
+			case <-f.closeChan:
+			}
+		}(tran)
+	}
+}
+
+// Consume assigns a messages channel for the output to read.
+func (f *FallbackDLQ) Consume(ts <-chan types.Transaction) error {
+	if f.transactionsIn != nil {
+		return types.ErrAlreadyStarted
+	}
+	if err := f.primary.Consume(f.primaryOut); err != nil {
+		return err
+	}
+	if err := f.dlq.Consume(f.dlqOut); err != nil {
+		return err
+	}
+	f.transactionsIn = ts
+	go f.loop()
+	return nil
+}
+
+// Connected returns a boolean indicating whether this output is currently
+// connected to its target.
+func (f *FallbackDLQ) Connected() bool {
+	return f.primary.Connected()
+}
+
+// MaxInFlight returns the maximum number of in flight messages permitted by the
+// output. This value can be used to determine a sensible value for parent
+// outputs, but should not be relied upon as part of dispatcher logic.
+func (f *FallbackDLQ) MaxInFlight() (int, bool) {
+	return f.maxInFlight, true
+}
+
+// CloseAsync shuts down the FallbackDLQ output and stops processing requests.
+func (f *FallbackDLQ) CloseAsync() {
+	if atomic.CompareAndSwapInt32(&f.running, 1, 0) {
+		close(f.closeChan)
+	}
+}
+
+// WaitForClose blocks until the FallbackDLQ output has closed down.
+func (f *FallbackDLQ) WaitForClose(timeout time.Duration) error {
+	select {
+	case <-f.closedChan:
+	case <-time.After(timeout):
+		return types.ErrTimeout
+	}
+	return nil
+}
+
+//------------------------------------------------------------------------------
@@ -0,0 +1,265 @@
+package output
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Jeffail/benthos/v3/internal/batch"
+	"github.com/Jeffail/benthos/v3/lib/log"
+	"github.com/Jeffail/benthos/v3/lib/message"
+	"github.com/Jeffail/benthos/v3/lib/metrics"
+	"github.com/Jeffail/benthos/v3/lib/response"
+	"github.com/Jeffail/benthos/v3/lib/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFallbackDLQConfigErrs(t *testing.T) {
+	childConf := NewConfig()
+	childConf.Type = TypeDrop
+
+	conf := NewConfig()
+	conf.Type = TypeFallbackDLQ
+	conf.FallbackDLQ.DLQ = &childConf
+	_, err := New(conf, nil, log.Noop(), metrics.Noop())
+	assert.EqualError(t, err, "failed to create output 'fallback_dlq': cannot create fallback_dlq output without a child output")
+
+	conf = NewConfig()
+	conf.Type = TypeFallbackDLQ
+	conf.FallbackDLQ.Output = &childConf
+	_, err = New(conf, nil, log.Noop(), metrics.Noop())
+	assert.EqualError(t, err, "failed to create output 'fallback_dlq': cannot create fallback_dlq output without a dlq output")
+
+	conf = NewConfig()
+	conf.Type = TypeFallbackDLQ
+	conf.FallbackDLQ.Output = &childConf
+	conf.FallbackDLQ.DLQ = &childConf
+	conf.FallbackDLQ.MaxRetries = 0
+	_, err = New(conf, nil, log.Noop(), metrics.Noop())
+	assert.Error(t, err)
+
+	conf.FallbackDLQ.Backoff.MaxElapsedTime = "1m"
+	_, err = New(conf, nil, log.Noop(), metrics.Noop())
+	assert.NoError(t, err)
+}
+
+func newTestFallbackDLQ(t *testing.T, maxRetries uint64) (tChan chan types.Transaction, primary, dlq *mockOutput) {
+	t.Helper()
+
+	childConf := NewConfig()
+	childConf.Type = TypeDrop
+	childConf.Label = "foo"
+
+	conf := NewConfig()
+	conf.FallbackDLQ.Output = &childConf
+	conf.FallbackDLQ.DLQ = &childConf
+	conf.FallbackDLQ.MaxRetries = maxRetries
+	conf.FallbackDLQ.Backoff.InitialInterval = "1ms"
+	conf.FallbackDLQ.Backoff.MaxInterval = "1ms"
+
+	o, err := NewFallbackDLQ(conf, nil, log.Noop(), metrics.Noop())
+	require.NoError(t, err)
+
+	f, ok := o.(*FallbackDLQ)
+	require.True(t, ok)
+
+	primary, dlq = &mockOutput{}, &mockOutput{}
+	f.primary, f.dlq = primary, dlq
+
+	tChan = make(chan types.Transaction)
+	require.NoError(t, f.Consume(tChan))
+
+	t.Cleanup(func() {
+		f.CloseAsync()
+		assert.NoError(t, f.WaitForClose(time.Second))
+	})
+	return
+}
+
+func readTran(t *testing.T, ts <-chan types.Transaction) types.Transaction {
+	t.Helper()
+	select {
+	case tran, open := <-ts:
+		require.True(t, open)
+		return tran
+	case <-time.After(time.Second):
+		t.Fatal("timed out")
+	}
+	return types.Transaction{}
+}
+
+func sendRes(t *testing.T, resChan chan<- types.Response, res types.Response) {
+	t.Helper()
+	select {
+	case resChan <- res:
+	case <-time.After(time.Second):
+		t.Fatal("timed out")
+	}
+}
+
+func TestFallbackDLQHappyPath(t *testing.T) {
+	tChan, primary, _ := newTestFallbackDLQ(t, 3)
+
+	resChan := make(chan types.Response)
+	go func() {
+		tChan <- types.NewTransaction(message.New([][]byte{[]byte("hello world")}), resChan)
+	}()
+
+	tran := readTran(t, primary.ts)
+	assert.Equal(t, [][]byte{[]byte("hello world")}, message.GetAllBytes(tran.Payload))
+	sendRes(t, tran.ResponseChan, response.NewAck())
+
+	select {
+	case res := <-resChan:
+		assert.NoError(t, res.Error())
+	case <-time.After(time.Second):
+		t.Fatal("timed out")
+	}
+}
+
+func TestFallbackDLQExhausted(t *testing.T) {
+	tChan, primary, dlq := newTestFallbackDLQ(t, 2)
+
+	resChan := make(chan types.Response)
+	go func() {
+		tChan <- types.NewTransaction(message.New([][]byte{
+			[]byte("first"), []byte("second"),
+		}), resChan)
+	}()
+
+	for i := 0; i < 3; i++ {
+		tran := readTran(t, primary.ts)
+		sendRes(t, tran.ResponseChan, response.NewError(errors.New("nope")))
+	}
+
+	tran := readTran(t, dlq.ts)
+	assert.Equal(t, [][]byte{[]byte("first"), []byte("second")}, message.GetAllBytes(tran.Payload))
+
+	tran.Payload.Iter(func(i int, p types.Part) error {
+		meta := p.Metadata()
+		assert.Equal(t, "nope", meta.Get("dlq_error"))
+		assert.Equal(t, "foo", meta.Get("dlq_label"))
+		assert.Equal(t, "3", meta.Get("dlq_attempts"))
+
+		first, err := time.Parse(time.RFC3339Nano, meta.Get("dlq_first_attempt_at"))
+		require.NoError(t, err)
+		last, err := time.Parse(time.RFC3339Nano, meta.Get("dlq_last_attempt_at"))
+		require.NoError(t, err)
+		assert.True(t, last.After(first))
+		return nil
+	})
+
+	sendRes(t, tran.ResponseChan, response.NewAck())
+
+	select {
+	case res := <-resChan:
+		assert.NoError(t, res.Error())
+	case <-time.After(time.Second):
+		t.Fatal("timed out")
+	}
+}
+
+func TestFallbackDLQBatchError(t *testing.T) {
+	tChan, primary, dlq := newTestFallbackDLQ(t, 1)
+
+	msg := message.New([][]byte{
+		[]byte("first"), []byte("second"), []byte("third"),
+	})
+
+	resChan := make(chan types.Response)
+	go func() {
+		tChan <- types.NewTransaction(msg, resChan)
+	}()
+
+	tran := readTran(t, primary.ts)
+	require.Equal(t, [][]byte{[]byte("first"), []byte("second"), []byte("third")}, message.GetAllBytes(tran.Payload))
+	bErr := batch.NewError(tran.Payload, errors.New("batch failed")).
+		Failed(1, errors.New("second failed"))
+	sendRes(t, tran.ResponseChan, response.NewError(bErr))
+
+	tran = readTran(t, primary.ts)
+	require.Equal(t, [][]byte{[]byte("second")}, message.GetAllBytes(tran.Payload))
+	bErr = batch.NewError(tran.Payload, errors.New("batch failed")).
+		Failed(0, errors.New("second failed"))
+	sendRes(t, tran.ResponseChan, response.NewError(bErr))
+
+	tran = readTran(t, dlq.ts)
+	require.Equal(t, [][]byte{[]byte("second")}, message.GetAllBytes(tran.Payload))
+	assert.Equal(t, "second failed", tran.Payload.Get(0).Metadata().Get("dlq_error"))
+	assert.Equal(t, "", msg.Get(1).Metadata().Get("dlq_error"))
+
+	sendRes(t, tran.ResponseChan, response.NewAck())
+
+	select {
+	case res := <-resChan:
+		assert.NoError(t, res.Error())
+	case <-time.After(time.Second):
+		t.Fatal("timed out")
+	}
+}
+
+func TestFallbackDLQMaxInFlight(t *testing.T) {
+	tChan, primary, _ := newTestFallbackDLQ(t, 1)
+
+	resChanOne, resChanTwo := make(chan types.Response), make(chan types.Response)
+	select {
+	case tChan <- types.NewTransaction(message.New([][]byte{[]byte("first")}), resChanOne):
+	case <-time.After(time.Second):
+		t.Fatal("timed out")
+	}
+
+	tran := readTran(t, primary.ts)
+	select {
+	case tChan <- types.NewTransaction(message.New([][]byte{[]byte("second")}), resChanTwo):
+		t.Fatal("consumed a transaction beyond max_in_flight")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	sendRes(t, tran.ResponseChan, response.NewAck())
+	select {
+	case res := <-resChanOne:
+		assert.NoError(t, res.Error())
+	case <-time.After(time.Second):
+		t.Fatal("timed out")
+	}
+
+	select {
+	case tChan <- types.NewTransaction(message.New([][]byte{[]byte("second")}), resChanTwo):
+	case <-time.After(time.Second):
+		t.Fatal("timed out")
+	}
+	tran = readTran(t, primary.ts)
+	assert.Equal(t, [][]byte{[]byte("second")}, message.GetAllBytes(tran.Payload))
+	sendRes(t, tran.ResponseChan, response.NewAck())
+	select {
+	case res := <-resChanTwo:
+		assert.NoError(t, res.Error())
+	case <-time.After(time.Second):
+		t.Fatal("timed out")
+	}
+}
+
+func TestFallbackDLQFailed(t *testing.T) {
+	tChan, primary, dlq := newTestFallbackDLQ(t, 1)
+
+	resChan := make(chan types.Response)
+	go func() {
+		tChan <- types.NewTransaction(message.New([][]byte{[]byte("hello world")}), resChan)
+	}()
+
+	for i := 0; i < 2; i++ {
+		tran := readTran(t, primary.ts)
+		sendRes(t, tran.ResponseChan, response.NewError(errors.New("nope")))
+	}
+
+	tran := readTran(t, dlq.ts)
+	sendRes(t, tran.ResponseChan, response.NewError(errors.New("dlq also nope")))
+
+	select {
+	case res := <-resChan:
+		assert.EqualError(t, res.Error(), "dlq also nope")
+	case <-time.After(time.Second):
+		t.Fatal("timed out")
+	}
+}
@@ -0,0 +1,74 @@
+package output
+
+import (
+	"github.com/Jeffail/benthos/v3/internal/docs"
+	"github.com/Jeffail/benthos/v3/lib/log"
+	"github.com/Jeffail/benthos/v3/lib/metrics"
+	"github.com/Jeffail/benthos/v3/lib/output/writer"
+	"github.com/Jeffail/benthos/v3/lib/types"
+	"github.com/Jeffail/benthos/v3/lib/util/tls"
+)
+
+//------------------------------------------------------------------------------
+
+func init() {
+	Constructors[TypeSyslog] = TypeSpec{
+		constructor: fromSimpleConstructor(NewSyslog),
+		Status:      docs.StatusExperimental,
+		Version:     "3.47.0",
+		Summary: `
+Formats messages as syslog and sends them to a server over UDP, TCP or TLS.`,
+		Description: `
+The contents of each message are used as the MSG part of a syslog message
+formatted as either [RFC5424](https://tools.ietf.org/html/rfc5424) or
+[RFC3164](https://tools.ietf.org/html/rfc3164). The header fields
+` + "`facility`, `severity`, `app_name`, `procid` and `msgid`" + ` support
+interpolation functions, as do the values of ` + "`structured_data`" + `, which
+is only included in RFC5424 messages. The facility and severity can be given
+either as a numerical code or as a keyword such as ` + "`local0`" + ` or
+` + "`warning`" + `, and messages that resolve to an invalid facility or
+severity are rejected.
+
+When sending over TCP messages are framed as described in
+[RFC6587](https://tools.ietf.org/html/rfc6587), and in order to send over TLS
+set the network to ` + "`tcp`" + ` and enable the ` + "`tls`" + ` field. When
+sending over UDP each message is sent as a single datagram. If the connection is
+lost it is reestablished before the failed messages are sent again.`,
+		FieldSpecs: docs.FieldSpecs{
+			docs.FieldCommon("network", "The network type to connect as.").HasOptions("udp", "tcp"),
+			docs.FieldCommon("address", "The address to connect to.", "localhost:514", "syslog.example.com:6514"),
+			docs.FieldCommon("format", "The syslog format of messages.").HasOptions("rfc5424", "rfc3164"),
+			docs.FieldAdvanced("framing", "The framing of messages sent over TCP.").HasOptions("octet_counting", "non_transparent"),
+			docs.FieldCommon("facility", "The facility of messages, either as a code or a keyword.", "local0", `${! meta("facility") }`).IsInterpolated(),
+			docs.FieldCommon("severity", "The severity of messages, either as a code or a keyword.", "warning", `${! json("level") }`).IsInterpolated(),
+			docs.FieldAdvanced("hostname", "The hostname of messages. When empty the hostname of the machine is used."),
+			docs.FieldCommon("app_name", "The application name of messages.").IsInterpolated(),
+			docs.FieldAdvanced("procid", "The process ID of messages.").IsInterpolated(),
+			docs.FieldAdvanced("msgid", "The message ID of messages, only included in RFC5424 messages.").IsInterpolated(),
+			docs.FieldAdvanced(
+				"structured_data", "A map of SD-IDs to maps of parameters to include as the structured data of RFC5424 messages.",
+				map[string]interface{}{
+					"origin@32473": map[string]interface{}{
+						"software": "benthos",
+						"topic":    `${! meta("kafka_topic") }`,
+					},
+				},
+			).IsInterpolated().HasType(docs.FieldObject).Map(),
+			tls.FieldSpec(),
+		},
+		Categories: []Category{
+			CategoryNetwork,
+		},
+	}
+}
+
+// NewSyslog creates a new Syslog output type.
+func NewSyslog(conf Config, mgr types.Manager, log log.Modular, stats metrics.Type) (Type, error) {
+	t, err := writer.NewSyslog(conf.Syslog, mgr, log, stats)
+	if err != nil {
+		return nil, err
+	}
+	return NewAsyncWriter(TypeSyslog, 1, t, log, stats)
+}
+
+//------------------------------------------------------------------------------
@@ -3,11 +3,13 @@ package writer
 import (
 	"context"
 	"crypto/tls"
+	"errors"
 	"fmt"
 	"net/http"
 	"strings"
 	"time"
 
+	batchInternal "github.com/Jeffail/benthos/v3/internal/batch"
 	"github.com/Jeffail/benthos/v3/internal/bloblang"
 	"github.com/Jeffail/benthos/v3/internal/bloblang/field"
 	"github.com/Jeffail/benthos/v3/lib/log"
@@ -41,6 +43,9 @@ type ElasticsearchConfig struct {
 	Sniff          bool                 `json:"sniff" yaml:"sniff"`
 	Healthcheck    bool                 `json:"healthcheck" yaml:"healthcheck"`
 	ID             string               `json:"id" yaml:"id"`
+	Action         string               `json:"action" yaml:"action"`
+	DocAsUpsert    bool                 `json:"doc_as_upsert" yaml:"doc_as_upsert"`
+	Script         string               `json:"script" yaml:"script"`
 	Index          string               `json:"index" yaml:"index"`
 	Pipeline       string               `json:"pipeline" yaml:"pipeline"`
 	Type           string               `json:"type" yaml:"type"`
@@ -65,6 +70,9 @@ func NewElasticsearchConfig() ElasticsearchConfig {
 		Sniff:       true,
 		Healthcheck: true,
 		ID:          `${!count("elastic_ids")}-${!timestamp_unix()}`,
+		Action:      "index",
+		DocAsUpsert: false,
+		Script:      "",
 		Index:       "benthos_index",
 		Pipeline:    "",
 		Type:        "doc",
@@ -98,6 +106,7 @@ type Elasticsearch struct {
 	tlsConf     *tls.Config
 
 	idStr       *field.Expression
+	actionStr   *field.Expression
 	indexStr    *field.Expression
 	pipelineStr *field.Expression
 
@@ -121,6 +130,9 @@ func NewElasticsearch(conf ElasticsearchConfig, log log.Modular, stats metrics.T
 	if e.idStr, err = bloblang.NewField(conf.ID); err != nil {
 		return nil, fmt.Errorf("failed to parse id expression: %v", err)
 	}
+	if e.actionStr, err = bloblang.NewField(conf.Action); err != nil {
+		return nil, fmt.Errorf("failed to parse action expression: %v", err)
+	}
 	if e.indexStr, err = bloblang.NewField(conf.Index); err != nil {
 		return nil, fmt.Errorf("failed to parse index expression: %v", err)
 	}
@@ -223,28 +235,22 @@ func shouldRetry(s int) bool {
 }
 
 type pendingBulkIndex struct {
+	Action   string
 	Index    string
 	Pipeline string
 	Type     string
+	ID       string
 	Doc      interface{}
 }
 
 // WriteWithContext will attempt to write a message to Elasticsearch, wait for
 // acknowledgement, and returns an error if applicable.
 func (e *Elasticsearch) WriteWithContext(ctx context.Context, msg types.Message) error {
-	return e.Write(msg)
-}
-
-// Write will attempt to write a message to Elasticsearch, wait for
-// acknowledgement, and returns an error if applicable.
-func (e *Elasticsearch) Write(msg types.Message) error {
 	if e.client == nil {
 		return types.ErrNotConnected
 	}
 
-	boff := e.backoffCtor()
-
-	if msg.Len() == 1 {
+	if msg.Len() == 1 && e.actionStr.String(0, msg) == "index" {
 		index := e.indexStr.String(0, msg)
 		// nolint:staticcheck // Ignore SA1019 Type is deprecated warning for .Index()
 		_, err := e.client.Index().
@@ -253,81 +259,184 @@ func (e *Elasticsearch) Write(msg types.Message) error {
 			Type(e.conf.Type).
 			Id(e.idStr.String(0, msg)).
 			BodyString(string(msg.Get(0).Get())).
-			Do(context.Background())
+			Do(ctx)
 		if err == nil {
 			// Flush to make sure the document got written.
-			_, err = e.client.Flush().Index(index).Do(context.Background())
+			_, err = e.client.Flush().Index(index).Do(ctx)
 		}
 		return err
 	}
 
-	requests := map[string]*pendingBulkIndex{}
-	msg.Iter(func(i int, part types.Part) error {
-		jObj, ierr := part.JSON()
-		if ierr != nil {
-			e.eJSONErr.Incr(1)
-			e.log.Errorf("Failed to marshal message into JSON document: %v\n", ierr)
-			return nil
+	boff := e.backoffCtor()
+
+	var batchErr *batchInternal.Error
+	failedPart := func(i int, err error) {
+		if batchErr == nil {
+			batchErr = batchInternal.NewError(msg, err)
 		}
-		requests[e.idStr.String(i, msg)] = &pendingBulkIndex{
+		batchErr.Failed(i, err)
+	}
+
+	requests := make([]*pendingBulkIndex, msg.Len())
+	msg.Iter(func(i int, part types.Part) error {
+		req := &pendingBulkIndex{
+			Action:   e.actionStr.String(i, msg),
 			Index:    e.indexStr.String(i, msg),
 			Pipeline: e.pipelineStr.String(i, msg),
 			Type:     e.conf.Type,
-			Doc:      jObj,
+			ID:       e.idStr.String(i, msg),
+		}
+		if req.Action != "delete" {
+			jObj, ierr := part.JSON()
+			if ierr != nil {
+				e.eJSONErr.Incr(1)
+				e.log.Errorf("Failed to marshal message into JSON document: %v\n", ierr)
+				failedPart(i, fmt.Errorf("failed to marshal message into JSON document: %w", ierr))
+				return nil
+			}
+			req.Doc = jObj
 		}
+		requests[i] = req
 		return nil
 	})
 
+	// The indexes of messages that are yet to be successfully sent, in the
+	// order of the actions of the bulk request.
+	var pending []int
 	b := e.client.Bulk()
-	for k, v := range requests {
-		b.Add(
-			elastic.NewBulkIndexRequest().
-				Index(v.Index).
-				Pipeline(v.Pipeline).
-				Type(v.Type).
-				Id(k).
-				Doc(v.Doc),
-		)
+	for i, req := range requests {
+		if req == nil {
+			continue
+		}
+		bulkReq, err := e.buildBulkRequest(req)
+		if err != nil {
+			e.log.Errorf("Failed to create Elasticsearch request: %v\n", err)
+			failedPart(i, err)
+			continue
+		}
+		pending = append(pending, i)
+		b.Add(bulkReq)
 	}
 
 	for b.NumberOfActions() != 0 {
-		result, err := b.Do(context.Background())
+		result, err := b.Do(ctx)
 		if err != nil {
 			return err
 		}
 
-		failed := result.Failed()
-		if len(failed) == 0 {
-			continue
+		var retries []int
+		retryErrs := map[int]error{}
+		for j, item := range result.Items {
+			if j >= len(pending) {
+				break
+			}
+			i := pending[j]
+			for _, resp := range item {
+				if resp.Status >= 200 && resp.Status <= 299 {
+					continue
+				}
+				// Deleting a document that does not exist achieves the same
+				// outcome as deleting one that does.
+				if resp.Status == http.StatusNotFound && requests[i].Action == "delete" {
+					continue
+				}
+				reason := http.StatusText(resp.Status)
+				if resp.Error != nil {
+					reason = resp.Error.Reason
+				}
+				itemErr := fmt.Errorf("failed to send message '%v' with code [%v]: %v", resp.Id, resp.Status, reason)
+				if !shouldRetry(resp.Status) {
+					e.log.Errorf("Elasticsearch message '%v' rejected with code [%v]: %v\n", resp.Id, resp.Status, reason)
+					failedPart(i, itemErr)
+					continue
+				}
+				e.log.Errorf("Elasticsearch message '%v' failed with code [%v]: %v\n", resp.Id, resp.Status, reason)
+				retries = append(retries, i)
+				retryErrs[i] = itemErr
+			}
+		}
+		if len(retries) == 0 {
+			break
 		}
 
 		wait := boff.NextBackOff()
-		for i := 0; i < len(failed); i++ {
-			if !shouldRetry(failed[i].Status) {
-				e.log.Errorf("Elasticsearch message '%v' rejected with code [%s]: %v\n", failed[i].Id, failed[i].Status, failed[i].Error.Reason)
-				return fmt.Errorf("failed to send %v parts from message: %v", len(failed), failed[0].Error.Reason)
+		if wait == backoff.Stop {
+			for _, i := range retries {
+				failedPart(i, retryErrs[i])
 			}
-			e.log.Errorf("Elasticsearch message '%v' failed with code [%s]: %v\n", failed[i].Id, failed[i].Status, failed[i].Error.Reason)
-			id := failed[i].Id
-			req := requests[id]
-			b.Add(
-				elastic.NewBulkIndexRequest().
-					Index(req.Index).
-					Pipeline(req.Pipeline).
-					Type(req.Type).
-					Id(id).
-					Doc(req.Doc),
-			)
+			break
 		}
-		if wait == backoff.Stop {
-			return fmt.Errorf("failed to send %v parts from message: %v", len(failed), failed[0].Error.Reason)
+		select {
+		case <-time.After(wait):
+		case <-ctx.Done():
+			for _, i := range retries {
+				failedPart(i, ctx.Err())
+			}
+			return batchErr
+		}
+
+		pending = retries
+		for _, i := range retries {
+			bulkReq, _ := e.buildBulkRequest(requests[i])
+			b.Add(bulkReq)
 		}
-		time.Sleep(wait)
 	}
 
+	if batchErr != nil {
+		return batchErr
+	}
 	return nil
 }
 
+// buildBulkRequest creates a bulk request item according to the action of a
+// pending request.
+func (e *Elasticsearch) buildBulkRequest(p *pendingBulkIndex) (elastic.BulkableRequest, error) {
+	switch p.Action {
+	case "index", "create":
+		r := elastic.NewBulkIndexRequest().
+			OpType(p.Action).
+			Index(p.Index).
+			Pipeline(p.Pipeline).
+			Type(p.Type).
+			Id(p.ID).
+			Doc(p.Doc)
+		return r, nil
+	case "update":
+		r := elastic.NewBulkUpdateRequest().
+			Index(p.Index).
+			Type(p.Type).
+			Id(p.ID).
+			Doc(p.Doc).
+			DocAsUpsert(e.conf.DocAsUpsert)
+		return r, nil
+	case "upsert":
+		if e.conf.Script == "" {
+			return nil, errors.New("a script must be specified in order to use the upsert action")
+		}
+		params, _ := p.Doc.(map[string]interface{})
+		r := elastic.NewBulkUpdateRequest().
+			Index(p.Index).
+			Type(p.Type).
+			Id(p.ID).
+			Script(elastic.NewScriptInline(e.conf.Script).Params(params)).
+			Upsert(p.Doc)
+		return r, nil
+	case "delete":
+		r := elastic.NewBulkDeleteRequest().
+			Index(p.Index).
+			Type(p.Type).
+			Id(p.ID)
+		return r, nil
+	}
+	return nil, fmt.Errorf("elasticsearch action '%v' is not supported", p.Action)
+}
+
+// Write will attempt to write a message to Elasticsearch, wait for
+// acknowledgement, and returns an error if applicable.
+func (e *Elasticsearch) Write(msg types.Message) error {
+	return e.WriteWithContext(context.Background(), msg)
+}
+
 // CloseAsync shuts down the Elasticsearch writer and stops processing messages.
 func (e *Elasticsearch) CloseAsync() {
 }
@@ -0,0 +1,280 @@
+package writer
+
+import (
+	"bufio"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/Jeffail/benthos/v3/internal/batch"
+	"github.com/Jeffail/benthos/v3/lib/log"
+	"github.com/Jeffail/benthos/v3/lib/message"
+	"github.com/Jeffail/benthos/v3/lib/metrics"
+	"github.com/Jeffail/benthos/v3/lib/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeBulkAction struct {
+	Action string
+	ID     string
+	Body   map[string]interface{}
+}
+
+// fakeBulkServer responds to bulk requests, where the status of each item is
+// determined by its ID.
+func fakeBulkServer(t *testing.T, statuses func(id string) int) (*httptest.Server, func() []fakeBulkAction) {
+	t.Helper()
+
+	var mut sync.Mutex
+	var actions []fakeBulkAction
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/_bulk" {
+			http.Error(w, "not found", http.StatusNotFound)
+			return
+		}
+
+		var items []map[string]interface{}
+		scanner := bufio.NewScanner(r.Body)
+		for scanner.Scan() {
+			var meta map[string]map[string]interface{}
+			require.NoError(t, json.Unmarshal(scanner.Bytes(), &meta))
+
+			for action, fields := range meta {
+				id, _ := fields["_id"].(string)
+				a := fakeBulkAction{Action: action, ID: id}
+				if action != "delete" {
+					require.True(t, scanner.Scan())
+					require.NoError(t, json.Unmarshal(scanner.Bytes(), &a.Body))
+				}
+
+				mut.Lock()
+				actions = append(actions, a)
+				mut.Unlock()
+
+				status := statuses(id)
+				item := map[string]interface{}{"_id": id, "status": status}
+				if status >= 300 {
+					item["error"] = map[string]interface{}{"type": "error", "reason": "nope"}
+				}
+				items = append(items, map[string]interface{}{action: item})
+			}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		require.NoError(t, json.NewEncoder(w).Encode(map[string]interface{}{
+			"took":   1,
+			"errors": true,
+			"items":  items,
+		}))
+	}))
+
+	return ts, func() []fakeBulkAction {
+		mut.Lock()
+		defer mut.Unlock()
+		return append([]fakeBulkAction(nil), actions...)
+	}
+}
+
+func newTestElasticsearch(t *testing.T, url string, fn func(c *ElasticsearchConfig)) *Elasticsearch {
+	t.Helper()
+
+	conf := NewElasticsearchConfig()
+	conf.URLs = []string{url}
+	conf.Sniff = false
+	conf.Healthcheck = false
+	conf.ID = `${! json("id") }`
+	conf.Backoff.InitialInterval = "1ms"
+	conf.Backoff.MaxInterval = "1ms"
+	conf.MaxRetries = 2
+	if fn != nil {
+		fn(&conf)
+	}
+
+	e, err := NewElasticsearch(conf, log.Noop(), metrics.Noop())
+	require.NoError(t, err)
+	require.NoError(t, e.Connect())
+	return e
+}
+
+func TestElasticsearchActions(t *testing.T) {
+	ts, actions := fakeBulkServer(t, func(string) int { return 200 })
+	defer ts.Close()
+
+	e := newTestElasticsearch(t, ts.URL, func(c *ElasticsearchConfig) {
+		c.Action = `${! meta("action") }`
+		c.DocAsUpsert = true
+		c.Script = "ctx._source.count += params.count"
+	})
+
+	msg := message.New(nil)
+	for _, a := range []string{"index", "create", "update", "upsert", "delete"} {
+		part := message.NewPart([]byte(`{"id":"` + a + `","count":1}`))
+		part.Metadata().Set("action", a)
+		msg.Append(part)
+	}
+	require.NoError(t, e.Write(msg))
+
+	res := actions()
+	require.Len(t, res, 5)
+
+	assert.Equal(t, "index", res[0].Action)
+	assert.Equal(t, map[string]interface{}{"id": "index", "count": float64(1)}, res[0].Body)
+
+	assert.Equal(t, "create", res[1].Action)
+	assert.Equal(t, map[string]interface{}{"id": "create", "count": float64(1)}, res[1].Body)
+
+	assert.Equal(t, "update", res[2].Action)
+	assert.Equal(t, map[string]interface{}{
+		"doc":           map[string]interface{}{"id": "update", "count": float64(1)},
+		"doc_as_upsert": true,
+	}, res[2].Body)
+
+	assert.Equal(t, "update", res[3].Action)
+	assert.Equal(t, map[string]interface{}{
+		"script": map[string]interface{}{
+			"source": "ctx._source.count += params.count",
+			"params": map[string]interface{}{"id": "upsert", "count": float64(1)},
+		},
+		"upsert": map[string]interface{}{"id": "upsert", "count": float64(1)},
+	}, res[3].Body)
+
+	assert.Equal(t, "delete", res[4].Action)
+	assert.Equal(t, "delete", res[4].ID)
+}
+
+func TestElasticsearchPartialErrors(t *testing.T) {
+	var mut sync.Mutex
+	retried := false
+	ts, actions := fakeBulkServer(t, func(id string) int {
+		switch id {
+		case "bad":
+			return 400
+		case "flaky":
+			mut.Lock()
+			defer mut.Unlock()
+			if !retried {
+				retried = true
+				return 503
+			}
+		case "down":
+			return 503
+		}
+		return 201
+	})
+	defer ts.Close()
+
+	e := newTestElasticsearch(t, ts.URL, nil)
+
+	msg := message.New([][]byte{
+		[]byte(`{"id":"good"}`),
+		[]byte(`{"id":"bad"}`),
+		[]byte(`{"id":"flaky"}`),
+		[]byte(`not json`),
+		[]byte(`{"id":"down"}`),
+	})
+
+	err := e.Write(msg)
+	require.Error(t, err)
+
+	bErr, ok := err.(*batch.Error)
+	require.True(t, ok, "expected batch error, got: %T", err)
+	assert.Equal(t, 3, bErr.IndexedErrors())
+
+	var failed []int
+	bErr.WalkParts(func(i int, _ types.Part, err error) bool {
+		if err != nil {
+			failed = append(failed, i)
+		}
+		return true
+	})
+	assert.Equal(t, []int{1, 3, 4}, failed)
+
+	var ids []string
+	for _, a := range actions() {
+		ids = append(ids, a.ID)
+	}
+	assert.Equal(t, []string{"good", "bad", "flaky", "down", "flaky", "down", "down"}, ids)
+}
+
+func TestElasticsearchDeleteNotFound(t *testing.T) {
+	ts, actions := fakeBulkServer(t, func(id string) int {
+		if id == "missing" {
+			return 404
+		}
+		return 200
+	})
+	defer ts.Close()
+
+	e := newTestElasticsearch(t, ts.URL, func(c *ElasticsearchConfig) {
+		c.Action = "delete"
+	})
+
+	require.NoError(t, e.Write(message.New([][]byte{
+		[]byte(`{"id":"foo"}`),
+		[]byte(`{"id":"missing"}`),
+	})))
+	assert.Len(t, actions(), 2)
+}
+
+func TestElasticsearchSingleIndex(t *testing.T) {
+	var mut sync.Mutex
+	var reqs []string
+	var body string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, err := ioutil.ReadAll(r.Body)
+		require.NoError(t, err)
+
+		mut.Lock()
+		reqs = append(reqs, r.Method+" "+r.URL.Path)
+		if len(b) > 0 {
+			body = string(b)
+		}
+		mut.Unlock()
+
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"_id":"foo","result":"created","_shards":{"total":1,"successful":1,"failed":0}}`))
+	}))
+	defer ts.Close()
+
+	e := newTestElasticsearch(t, ts.URL, nil)
+	require.NoError(t, e.Write(message.New([][]byte{[]byte(`{"id":"foo"}`)})))
+
+	mut.Lock()
+	defer mut.Unlock()
+	assert.Equal(t, []string{
+		"PUT /benthos_index/doc/foo",
+		"POST /benthos_index/_flush",
+	}, reqs)
+	assert.Equal(t, `{"id":"foo"}`, body)
+}
+
+func TestElasticsearchBadAction(t *testing.T) {
+	ts, actions := fakeBulkServer(t, func(string) int { return 201 })
+	defer ts.Close()
+
+	e := newTestElasticsearch(t, ts.URL, func(c *ElasticsearchConfig) {
+		c.Action = `${! json("action") }`
+	})
+
+	msg := message.New([][]byte{
+		[]byte(`{"id":"foo","action":"index"}`),
+		[]byte(`{"id":"bar","action":"nope"}`),
+		[]byte(`{"id":"baz","action":"upsert"}`),
+	})
+
+	err := e.Write(msg)
+	require.Error(t, err)
+
+	bErr, ok := err.(*batch.Error)
+	require.True(t, ok, "expected batch error, got: %T", err)
+	assert.Equal(t, 2, bErr.IndexedErrors())
+
+	res := actions()
+	require.Len(t, res, 1)
+	assert.Equal(t, "foo", res[0].ID)
+}
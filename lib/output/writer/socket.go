@@ -40,6 +40,7 @@ type Socket struct {
 	address   string
 	codec     codec.WriterConstructor
 	codecConf codec.WriterConfig
+	dial      func(ctx context.Context, network, address string) (net.Conn, error)
 
 	stats metrics.Type
 	log   log.Modular
@@ -69,6 +70,7 @@ func NewSocket(
 		address:   conf.Address,
 		codec:     codec,
 		codecConf: codecConf,
+		dial:      (&net.Dialer{}).DialContext,
 		stats:     stats,
 		log:       log,
 	}
@@ -90,7 +92,7 @@ func (s *Socket) ConnectWithContext(ctx context.Context) error {
 		return nil
 	}
 
-	conn, err := net.Dial(s.network, s.address)
+	conn, err := s.dial(ctx, s.network, s.address)
 	if err != nil {
 		return err
 	}
@@ -0,0 +1,266 @@
+package writer
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"time"
+
+	"github.com/Jeffail/benthos/v3/internal/bloblang"
+	"github.com/Jeffail/benthos/v3/internal/bloblang/field"
+	"github.com/Jeffail/benthos/v3/internal/syslog"
+	"github.com/Jeffail/benthos/v3/lib/log"
+	"github.com/Jeffail/benthos/v3/lib/message"
+	"github.com/Jeffail/benthos/v3/lib/metrics"
+	"github.com/Jeffail/benthos/v3/lib/types"
+	btls "github.com/Jeffail/benthos/v3/lib/util/tls"
+)
+
+//------------------------------------------------------------------------------
+
+// SyslogConfig contains configuration fields for the Syslog output type.
+type SyslogConfig struct {
+	Network        string                       `json:"network" yaml:"network"`
+	Address        string                       `json:"address" yaml:"address"`
+	Format         string                       `json:"format" yaml:"format"`
+	Framing        string                       `json:"framing" yaml:"framing"`
+	Facility       string                       `json:"facility" yaml:"facility"`
+	Severity       string                       `json:"severity" yaml:"severity"`
+	Hostname       string                       `json:"hostname" yaml:"hostname"`
+	AppName        string                       `json:"app_name" yaml:"app_name"`
+	ProcID         string                       `json:"procid" yaml:"procid"`
+	MsgID          string                       `json:"msgid" yaml:"msgid"`
+	StructuredData map[string]map[string]string `json:"structured_data" yaml:"structured_data"`
+	TLS            btls.Config                  `json:"tls" yaml:"tls"`
+}
+
+// NewSyslogConfig creates a new SyslogConfig with default values.
+func NewSyslogConfig() SyslogConfig {
+	return SyslogConfig{
+		Network:        "udp",
+		Address:        "localhost:514",
+		Format:         "rfc5424",
+		Framing:        "octet_counting",
+		Facility:       "user",
+		Severity:       "info",
+		Hostname:       "",
+		AppName:        "benthos",
+		ProcID:         "",
+		MsgID:          "",
+		StructuredData: map[string]map[string]string{},
+		TLS:            btls.NewConfig(),
+	}
+}
+
+//------------------------------------------------------------------------------
+
+// Syslog is an output type that formats messages as syslog and sends them over
+// a socket.
+type Syslog struct {
+	socket *Socket
+
+	format   syslog.Format
+	framing  syslog.Framing
+	hostname string
+
+	facility       *field.Expression
+	severity       *field.Expression
+	appName        *field.Expression
+	procID         *field.Expression
+	msgID          *field.Expression
+	structuredData map[string]map[string]*field.Expression
+
+	log log.Modular
+	now func() time.Time
+}
+
+// NewSyslog creates a new Syslog writer type.
+func NewSyslog(
+	conf SyslogConfig,
+	mgr types.Manager,
+	log log.Modular,
+	stats metrics.Type,
+) (*Syslog, error) {
+	s := &Syslog{
+		hostname:       conf.Hostname,
+		structuredData: map[string]map[string]*field.Expression{},
+		log:            log,
+		now:            time.Now,
+	}
+
+	var err error
+	if s.format, err = syslog.ParseFormat(conf.Format); err != nil {
+		return nil, err
+	}
+	if s.format == syslog.FormatAuto {
+		return nil, errors.New("format must be either rfc5424 or rfc3164")
+	}
+	if s.framing, err = syslog.ParseFraming(conf.Framing); err != nil {
+		return nil, err
+	}
+	if s.hostname == "" {
+		if s.hostname, err = os.Hostname(); err != nil {
+			return nil, fmt.Errorf("failed to obtain hostname: %v", err)
+		}
+	}
+
+	for _, f := range []struct {
+		name string
+		conf string
+		expr **field.Expression
+	}{
+		{"facility", conf.Facility, &s.facility},
+		{"severity", conf.Severity, &s.severity},
+		{"app_name", conf.AppName, &s.appName},
+		{"procid", conf.ProcID, &s.procID},
+		{"msgid", conf.MsgID, &s.msgID},
+	} {
+		if *f.expr, err = bloblang.NewField(f.conf); err != nil {
+			return nil, fmt.Errorf("failed to parse %v expression: %v", f.name, err)
+		}
+	}
+	for id, params := range conf.StructuredData {
+		exprs := make(map[string]*field.Expression, len(params))
+		for k, v := range params {
+			if exprs[k], err = bloblang.NewField(v); err != nil {
+				return nil, fmt.Errorf("failed to parse structured_data %v.%v expression: %v", id, k, err)
+			}
+		}
+		s.structuredData[id] = exprs
+	}
+
+	sockConf := NewSocketConfig()
+	sockConf.Address = conf.Address
+	sockConf.Codec = "append"
+
+	var tlsConf *tls.Config
+	switch conf.Network {
+	case "udp":
+		if conf.TLS.Enabled {
+			return nil, errors.New("tls is not supported over udp")
+		}
+		sockConf.Network = "udp"
+	case "tcp":
+		sockConf.Network = "tcp"
+		if conf.TLS.Enabled {
+			if tlsConf, err = conf.TLS.Get(); err != nil {
+				return nil, err
+			}
+		}
+	default:
+		return nil, fmt.Errorf("syslog network '%v' is not supported by this output", conf.Network)
+	}
+
+	if s.socket, err = NewSocket(sockConf, mgr, log, stats); err != nil {
+		return nil, err
+	}
+	if tlsConf != nil {
+		dialer := &net.Dialer{}
+		s.socket.dial = func(ctx context.Context, network, address string) (net.Conn, error) {
+			conn, err := dialer.DialContext(ctx, network, address)
+			if err != nil {
+				return nil, err
+			}
+			// Certificates are verified against the host of the address unless
+			// a server name is configured.
+			connConf := tlsConf.Clone()
+			if connConf.ServerName == "" {
+				if host, _, err := net.SplitHostPort(address); err == nil {
+					connConf.ServerName = host
+				}
+			}
+			tlsConn := tls.Client(conn, connConf)
+			if err := tlsConn.Handshake(); err != nil {
+				conn.Close()
+				return nil, err
+			}
+			return tlsConn, nil
+		}
+	}
+	return s, nil
+}
+
+//------------------------------------------------------------------------------
+
+// Connect establises a connection to the target syslog server.
+func (s *Syslog) Connect() error {
+	return s.ConnectWithContext(context.Background())
+}
+
+// ConnectWithContext establises a connection to the target syslog server.
+func (s *Syslog) ConnectWithContext(ctx context.Context) error {
+	return s.socket.ConnectWithContext(ctx)
+}
+
+func (s *Syslog) formatPart(i int, msg types.Message) ([]byte, error) {
+	facility, err := syslog.ParseFacility(s.facility.String(i, msg))
+	if err != nil {
+		return nil, err
+	}
+	severity, err := syslog.ParseSeverity(s.severity.String(i, msg))
+	if err != nil {
+		return nil, err
+	}
+
+	var sd map[string]map[string]string
+	if len(s.structuredData) > 0 {
+		sd = make(map[string]map[string]string, len(s.structuredData))
+		for id, params := range s.structuredData {
+			p := make(map[string]string, len(params))
+			for k, v := range params {
+				p[k] = v.String(i, msg)
+			}
+			sd[id] = p
+		}
+	}
+
+	formatted := syslog.Marshal(s.format, syslog.Fields{
+		Facility:       facility,
+		Severity:       severity,
+		Timestamp:      s.now(),
+		Hostname:       s.hostname,
+		AppName:        s.appName.String(i, msg),
+		ProcID:         s.procID.String(i, msg),
+		MsgID:          s.msgID.String(i, msg),
+		StructuredData: sd,
+		Message:        msg.Get(i).Get(),
+	})
+
+	// Datagrams contain a single message and are therefore not framed.
+	if s.socket.network == "udp" {
+		return formatted, nil
+	}
+	return syslog.AppendFrame(nil, formatted, s.framing), nil
+}
+
+// Write attempts to write a message.
+func (s *Syslog) Write(msg types.Message) error {
+	return s.WriteWithContext(context.Background(), msg)
+}
+
+// WriteWithContext attempts to write a message.
+func (s *Syslog) WriteWithContext(ctx context.Context, msg types.Message) error {
+	return IterateBatchedSend(msg, func(i int, p types.Part) error {
+		formatted, err := s.formatPart(i, msg)
+		if err != nil {
+			s.log.Errorf("Failed to format syslog message: %v\n", err)
+			return err
+		}
+		return s.socket.WriteWithContext(ctx, message.New([][]byte{formatted}))
+	})
+}
+
+// CloseAsync shuts down the syslog output and stops processing messages.
+func (s *Syslog) CloseAsync() {
+	s.socket.CloseAsync()
+}
+
+// WaitForClose blocks until the syslog output has closed down.
+func (s *Syslog) WaitForClose(timeout time.Duration) error {
+	return s.socket.WaitForClose(timeout)
+}
+
+//------------------------------------------------------------------------------
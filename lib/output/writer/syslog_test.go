@@ -0,0 +1,299 @@
+package writer
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Jeffail/benthos/v3/internal/batch"
+	"github.com/Jeffail/benthos/v3/internal/syslog"
+	"github.com/Jeffail/benthos/v3/lib/log"
+	"github.com/Jeffail/benthos/v3/lib/message"
+	"github.com/Jeffail/benthos/v3/lib/metrics"
+	"github.com/Jeffail/benthos/v3/lib/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var syslogTestTime = time.Date(2021, 3, 4, 5, 6, 7, 0, time.UTC)
+
+func newTestSyslogWriter(t *testing.T, conf SyslogConfig) *Syslog {
+	t.Helper()
+
+	conf.Hostname = "benthos-host"
+	w, err := NewSyslog(conf, nil, log.Noop(), metrics.Noop())
+	require.NoError(t, err)
+	w.now = func() time.Time {
+		return syslogTestTime
+	}
+
+	t.Cleanup(func() {
+		w.CloseAsync()
+		assert.NoError(t, w.WaitForClose(time.Second))
+	})
+	return w
+}
+
+func readSyslogFrames(t *testing.T, conn net.Conn, n int) []string {
+	t.Helper()
+
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(time.Second*5)))
+	frames := syslog.NewFrameReader(conn, syslog.FramingAuto, 0)
+
+	var res []string
+	for len(res) < n {
+		frame, err := frames.Next()
+		require.NoError(t, err)
+		res = append(res, string(frame))
+	}
+	return res
+}
+
+func TestSyslogConfigErrors(t *testing.T) {
+	for _, fn := range []func(c *SyslogConfig){
+		func(c *SyslogConfig) { c.Network = "unix" },
+		func(c *SyslogConfig) { c.Format = "nope" },
+		func(c *SyslogConfig) { c.Format = "auto" },
+		func(c *SyslogConfig) { c.Framing = "nope" },
+		func(c *SyslogConfig) { c.Severity = "${! meta( }" },
+		func(c *SyslogConfig) { c.StructuredData = map[string]map[string]string{"a": {"b": "${! meta( }"}} },
+		func(c *SyslogConfig) { c.TLS.Enabled = true },
+	} {
+		conf := NewSyslogConfig()
+		fn(&conf)
+		_, err := NewSyslog(conf, nil, log.Noop(), metrics.Noop())
+		assert.Error(t, err)
+	}
+}
+
+func TestSyslogUDP(t *testing.T) {
+	pConn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer pConn.Close()
+
+	conf := NewSyslogConfig()
+	conf.Address = pConn.LocalAddr().String()
+	conf.Facility = "local0"
+	conf.Severity = `${! meta("level") }`
+	conf.MsgID = "ID${! meta(\"topic\").uppercase() }"
+	conf.StructuredData = map[string]map[string]string{
+		"meta@1": {"topic": `${! meta("topic") }`},
+	}
+
+	w := newTestSyslogWriter(t, conf)
+	require.NoError(t, w.Connect())
+
+	msg := message.New([][]byte{[]byte("foo"), []byte("bar")})
+	msg.Get(0).Metadata().Set("level", "warning").Set("topic", "a")
+	msg.Get(1).Metadata().Set("level", "3").Set("topic", "b")
+	require.NoError(t, w.Write(msg))
+
+	require.NoError(t, pConn.SetReadDeadline(time.Now().Add(time.Second*5)))
+	buf := make([]byte, 1024)
+
+	n, _, err := pConn.ReadFrom(buf)
+	require.NoError(t, err)
+	assert.Equal(t, `<132>1 2021-03-04T05:06:07Z benthos-host benthos - IDA [meta@1 topic="a"] foo`, string(buf[:n]))
+
+	n, _, err = pConn.ReadFrom(buf)
+	require.NoError(t, err)
+	assert.Equal(t, `<131>1 2021-03-04T05:06:07Z benthos-host benthos - IDB [meta@1 topic="b"] bar`, string(buf[:n]))
+}
+
+func TestSyslogBadSeverity(t *testing.T) {
+	pConn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer pConn.Close()
+
+	conf := NewSyslogConfig()
+	conf.Address = pConn.LocalAddr().String()
+	conf.Severity = `${! meta("level") }`
+
+	w := newTestSyslogWriter(t, conf)
+	require.NoError(t, w.Connect())
+
+	msg := message.New([][]byte{[]byte("foo"), []byte("bar")})
+	msg.Get(0).Metadata().Set("level", "nope")
+	msg.Get(1).Metadata().Set("level", "debug")
+
+	err = w.Write(msg)
+	require.Error(t, err)
+
+	bErr, ok := err.(*batch.Error)
+	require.True(t, ok)
+
+	var failed []int
+	bErr.WalkParts(func(i int, _ types.Part, err error) bool {
+		if err != nil {
+			failed = append(failed, i)
+		}
+		return true
+	})
+	assert.Equal(t, []int{0}, failed)
+
+	require.NoError(t, pConn.SetReadDeadline(time.Now().Add(time.Second*5)))
+	buf := make([]byte, 1024)
+	n, _, err := pConn.ReadFrom(buf)
+	require.NoError(t, err)
+	assert.Equal(t, `<15>1 2021-03-04T05:06:07Z benthos-host benthos - - - bar`, string(buf[:n]))
+}
+
+func TestSyslogTCPReconnect(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer ln.Close()
+
+	conf := NewSyslogConfig()
+	conf.Network = "tcp"
+	conf.Address = ln.Addr().String()
+	conf.Format = "rfc3164"
+	conf.ProcID = "10"
+
+	w := newTestSyslogWriter(t, conf)
+	require.NoError(t, w.Connect())
+
+	conn, err := ln.Accept()
+	require.NoError(t, err)
+
+	require.NoError(t, w.Write(message.New([][]byte{[]byte("foo\nbar")})))
+	assert.Equal(t, []string{
+		"<14>Mar  4 05:06:07 benthos-host benthos[10]: foo\nbar",
+	}, readSyslogFrames(t, conn, 1))
+
+	// Close the server side of the connection and wait for writes to fail.
+	conn.Close()
+	var writeErr error
+	for i := 0; i < 100 && writeErr == nil; i++ {
+		writeErr = w.Write(message.New([][]byte{[]byte("lost")}))
+		<-time.After(time.Millisecond * 10)
+	}
+	require.Error(t, writeErr)
+	assert.Equal(t, types.ErrNotConnected, w.Write(message.New([][]byte{[]byte("lost")})))
+
+	require.NoError(t, w.Connect())
+	conn, err = ln.Accept()
+	require.NoError(t, err)
+	defer conn.Close()
+
+	require.NoError(t, w.Write(message.New([][]byte{[]byte("baz")})))
+	assert.Equal(t, []string{
+		"<14>Mar  4 05:06:07 benthos-host benthos[10]: baz",
+	}, readSyslogFrames(t, conn, 1))
+}
+
+func syslogTestTLSListener(t *testing.T) (net.Listener, []byte) {
+	t.Helper()
+
+	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	template := x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{Organization: []string{"Benthos"}},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	certBytes, err := x509.CreateCertificate(rand.Reader, &template, &template, &priv.PublicKey, priv)
+	require.NoError(t, err)
+
+	ln, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{
+		Certificates: []tls.Certificate{{
+			Certificate: [][]byte{certBytes},
+			PrivateKey:  priv,
+		}},
+	})
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		ln.Close()
+	})
+	return ln, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certBytes})
+}
+
+func TestSyslogTLSVerified(t *testing.T) {
+	ln, certPEM := syslogTestTLSListener(t)
+
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	require.NoError(t, ioutil.WriteFile(caFile, certPEM, 0644))
+
+	conf := NewSyslogConfig()
+	conf.Network = "tcp"
+	conf.Address = ln.Addr().String()
+	conf.TLS.Enabled = true
+	conf.TLS.RootCAsFile = caFile
+
+	w := newTestSyslogWriter(t, conf)
+
+	connChan := make(chan net.Conn, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err == nil {
+			_ = conn.(*tls.Conn).Handshake()
+		}
+		connChan <- conn
+	}()
+
+	require.NoError(t, w.Connect())
+	conn := <-connChan
+	require.NotNil(t, conn)
+	defer conn.Close()
+
+	require.NoError(t, w.Write(message.New([][]byte{[]byte("foo")})))
+	assert.Equal(t, []string{
+		"<14>1 2021-03-04T05:06:07Z benthos-host benthos - - - foo",
+	}, readSyslogFrames(t, conn, 1))
+}
+
+func TestSyslogTLSNonTransparent(t *testing.T) {
+	ln, _ := syslogTestTLSListener(t)
+
+	conf := NewSyslogConfig()
+	conf.Network = "tcp"
+	conf.Address = ln.Addr().String()
+	conf.Framing = "non_transparent"
+	conf.TLS.Enabled = true
+	conf.TLS.InsecureSkipVerify = true
+
+	w := newTestSyslogWriter(t, conf)
+
+	connChan := make(chan net.Conn, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err == nil {
+			// Force the handshake so that the client connect completes.
+			_ = conn.(*tls.Conn).Handshake()
+		}
+		connChan <- conn
+	}()
+
+	require.NoError(t, w.Connect())
+	conn := <-connChan
+	require.NotNil(t, conn)
+	defer conn.Close()
+
+	require.NoError(t, w.Write(message.New([][]byte{[]byte("foo"), []byte("bar")})))
+	assert.Equal(t, []string{
+		"<14>1 2021-03-04T05:06:07Z benthos-host benthos - - - foo",
+		"<14>1 2021-03-04T05:06:07Z benthos-host benthos - - - bar",
+	}, readSyslogFrames(t, conn, 2))
+
+	w.CloseAsync()
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(time.Second)))
+	_, err := conn.Read(make([]byte, 1))
+	assert.Equal(t, io.EOF, err)
+}
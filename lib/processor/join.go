@@ -0,0 +1,412 @@
+package processor
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/Jeffail/benthos/v3/internal/bloblang"
+	"github.com/Jeffail/benthos/v3/internal/bloblang/field"
+	"github.com/Jeffail/benthos/v3/internal/bloblang/mapping"
+	"github.com/Jeffail/benthos/v3/internal/bloblang/query"
+	"github.com/Jeffail/benthos/v3/internal/docs"
+	"github.com/Jeffail/benthos/v3/lib/log"
+	"github.com/Jeffail/benthos/v3/lib/message"
+	"github.com/Jeffail/benthos/v3/lib/metrics"
+	"github.com/Jeffail/benthos/v3/lib/response"
+	"github.com/Jeffail/benthos/v3/lib/types"
+)
+
+//------------------------------------------------------------------------------
+
+func init() {
+	Constructors[TypeJoin] = TypeSpec{
+		constructor: NewJoin,
+		Categories: []Category{
+			CategoryIntegration,
+		},
+		Summary: `
+Joins messages from two streams that share a key by buffering each side within
+a [cache resource](/docs/components/caches/about).`,
+		Description: `
+A join is performed by placing a ` + "`join`" + ` processor within each of the
+two streams, both targeting the same cache resource, where one is configured
+with the ` + "`side`" + ` ` + "`left`" + ` and the other ` + "`right`" + `.
+
+Each message consumed is stored within the cache under its key and then the
+most recent message of the opposite side with the same key is looked up. If a
+match is found, and the timestamps of both messages are within the duration
+` + "`within`" + ` of each other, a joined message is emitted in place of the
+original. The contents of a joined message is a JSON object of the form:
+
+` + "```json" + `
+{"left":{"id":"foo","name":"left doc"},"right":{"id":"foo","name":"right doc"}}
+` + "```" + `
+
+Where documents that are not valid JSON are represented as strings, and the
+side of an unmatched message is ` + "`null`" + `. The metadata of the joined
+message is taken from the message being processed, and the metadata field
+` + "`join_matched`" + ` is set to either ` + "`true` or `false`" + `.
+
+Entries within the cache are expired according to the ` + "`ttl`" + ` field,
+and therefore messages that are never matched are eventually removed.
+
+Only the most recent message of each side is kept for a given key, as storing a
+message replaces any previous message of the same side and key. Therefore a key
+shared by many messages of one side, such as a one-to-many join, only ever
+matches the latest of those messages, and the others are no longer available to
+be joined once they have been replaced.
+
+### Modes
+
+- ` + "`inner`" + `: Only joined messages are emitted, messages without a match are
+  removed from the stream once they have been stored.
+- ` + "`left`" + `: Messages of the ` + "`left`" + ` side are always emitted,
+  with a ` + "`null`" + ` right side when there is no match.
+- ` + "`outer`" + `: Messages of both sides are always emitted, with a
+  ` + "`null`" + ` opposite side when there is no match.
+
+Since a stream processor cannot wait for a match indefinitely the ` + "`left`" + `
+and ` + "`outer`" + ` modes emit unmatched messages immediately, and a later
+match of the same message results in a second, joined, message.`,
+		FieldSpecs: docs.FieldSpecs{
+			docs.FieldCommon("resource", "The [`cache` resource](/docs/components/caches/about) in which to buffer messages."),
+			docs.FieldCommon("side", "The side of the join that this processor consumes.").HasOptions("left", "right"),
+			docs.FieldCommon("key", "A key that messages of both sides are joined by.", `${! json("id") }`).IsInterpolated(),
+			docs.FieldCommon("mode", "Determines which messages are emitted.").HasOptions("inner", "left", "outer"),
+			docs.FieldCommon("within", "The maximum duration between the timestamps of two messages for them to be joined. Leave empty to join messages regardless of their timestamps.", "1m", "1h"),
+			docs.FieldAdvanced(
+				"timestamp_mapping", "A [Bloblang mapping](/docs/guides/bloblang/about) that extracts the timestamp of a message, used by the `within` check.",
+				`root = this.created_at`,
+				`root = meta("kafka_timestamp_unix").number()`,
+			).Linter(docs.LintBloblangMapping),
+			docs.FieldCommon("ttl", "The TTL of cached entries as a duration string, after which unmatched messages are expired. Leave empty to rely on the TTL configured for the cache resource.", "10m", "1h"),
+			docs.FieldAdvanced("key_prefix", "An optional prefix added to all cache keys, allowing multiple joins to share a cache resource."),
+		},
+		Examples: []docs.AnnotatedExample{
+			{
+				Title: "Enriching Orders",
+				Summary: `
+Here we join a topic of orders with a topic of shipments by their order ID, where
+both are consumed by the same pipeline and are distinguished by the Kafka topic
+they came from:`,
+				Config: `
+pipeline:
+  processors:
+    - switch:
+        - check: meta("kafka_topic") == "orders"
+          processors:
+            - join:
+                resource: joincache
+                side: left
+                key: ${! json("order_id") }
+                mode: inner
+                within: 1h
+        - processors:
+            - join:
+                resource: joincache
+                side: right
+                key: ${! json("order_id") }
+                mode: inner
+                within: 1h
+
+cache_resources:
+  - label: joincache
+    memory:
+      ttl: 7200
+`,
+			},
+		},
+	}
+}
+
+//------------------------------------------------------------------------------
+
+// JoinConfig contains configuration fields for the Join processor.
+type JoinConfig struct {
+	Resource         string `json:"resource" yaml:"resource"`
+	Side             string `json:"side" yaml:"side"`
+	Key              string `json:"key" yaml:"key"`
+	Mode             string `json:"mode" yaml:"mode"`
+	Within           string `json:"within" yaml:"within"`
+	TimestampMapping string `json:"timestamp_mapping" yaml:"timestamp_mapping"`
+	TTL              string `json:"ttl" yaml:"ttl"`
+	KeyPrefix        string `json:"key_prefix" yaml:"key_prefix"`
+}
+
+// NewJoinConfig returns a JoinConfig with default values.
+func NewJoinConfig() JoinConfig {
+	return JoinConfig{
+		Resource:         "",
+		Side:             "left",
+		Key:              "",
+		Mode:             "inner",
+		Within:           "",
+		TimestampMapping: "root = now()",
+		TTL:              "",
+		KeyPrefix:        "",
+	}
+}
+
+//------------------------------------------------------------------------------
+
+// joinEntry is the representation of a message stored within the cache.
+type joinEntry struct {
+	Timestamp int64             `json:"ts"`
+	Content   []byte            `json:"content"`
+	Metadata  map[string]string `json:"metadata,omitempty"`
+}
+
+// Join is a processor that joins messages of two streams by buffering them in a
+// cache.
+type Join struct {
+	log log.Modular
+
+	side      string
+	otherSide string
+	mode      string
+	prefix    string
+
+	key       *field.Expression
+	timestamp *mapping.Executor
+	within    time.Duration
+	ttl       *time.Duration
+
+	cache types.Cache
+
+	mCount     metrics.StatCounter
+	mErr       metrics.StatCounter
+	mMatched   metrics.StatCounter
+	mUnmatched metrics.StatCounter
+	mSent      metrics.StatCounter
+	mBatchSent metrics.StatCounter
+}
+
+// NewJoin returns a Join processor.
+func NewJoin(
+	conf Config, mgr types.Manager, log log.Modular, stats metrics.Type,
+) (Type, error) {
+	c, err := mgr.GetCache(conf.Join.Resource)
+	if err != nil {
+		return nil, err
+	}
+
+	j := &Join{
+		log:    log,
+		mode:   conf.Join.Mode,
+		side:   conf.Join.Side,
+		prefix: conf.Join.KeyPrefix,
+		cache:  c,
+
+		mCount:     stats.GetCounter("count"),
+		mErr:       stats.GetCounter("error"),
+		mMatched:   stats.GetCounter("matched"),
+		mUnmatched: stats.GetCounter("unmatched"),
+		mSent:      stats.GetCounter("sent"),
+		mBatchSent: stats.GetCounter("batch.sent"),
+	}
+
+	switch j.side {
+	case "left":
+		j.otherSide = "right"
+	case "right":
+		j.otherSide = "left"
+	default:
+		return nil, fmt.Errorf("join side not recognised: %v", j.side)
+	}
+
+	switch j.mode {
+	case "inner", "left", "outer":
+	default:
+		return nil, fmt.Errorf("join mode not recognised: %v", j.mode)
+	}
+
+	if j.key, err = bloblang.NewField(conf.Join.Key); err != nil {
+		return nil, fmt.Errorf("failed to parse key expression: %v", err)
+	}
+	if j.timestamp, err = bloblang.NewMapping("", conf.Join.TimestampMapping); err != nil {
+		return nil, fmt.Errorf("failed to parse timestamp mapping: %w", err)
+	}
+
+	if conf.Join.Within != "" {
+		if j.within, err = time.ParseDuration(conf.Join.Within); err != nil {
+			return nil, fmt.Errorf("failed to parse within duration: %v", err)
+		}
+	}
+
+	if conf.Join.TTL != "" {
+		if _, ok := c.(types.CacheWithTTL); !ok {
+			return nil, fmt.Errorf("this cache type does not support per-key ttl")
+		}
+		ttl, err := time.ParseDuration(conf.Join.TTL)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse ttl duration: %v", err)
+		}
+		j.ttl = &ttl
+	}
+	return j, nil
+}
+
+//------------------------------------------------------------------------------
+
+func (j *Join) cacheKey(side, key string) string {
+	return j.prefix + side + ":" + key
+}
+
+func (j *Join) getTimestamp(index int, msg types.Message) (time.Time, error) {
+	v, err := j.timestamp.Exec(query.FunctionContext{
+		Maps:     j.timestamp.Maps(),
+		Vars:     map[string]interface{}{},
+		Index:    index,
+		MsgBatch: msg,
+	}.WithValueFunc(func() *interface{} {
+		jObj, err := msg.Get(index).JSON()
+		if err != nil {
+			return nil
+		}
+		return &jObj
+	}))
+	if err != nil {
+		return time.Time{}, err
+	}
+	return query.IGetTimestamp(v)
+}
+
+func (j *Join) store(key string, entry joinEntry) error {
+	entryBytes, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	if cttl, ok := j.cache.(types.CacheWithTTL); ok {
+		return cttl.SetWithTTL(key, entryBytes, j.ttl)
+	}
+	return j.cache.Set(key, entryBytes)
+}
+
+func (j *Join) lookup(key string) (*joinEntry, error) {
+	entryBytes, err := j.cache.Get(key)
+	if err != nil {
+		if err == types.ErrKeyNotFound {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var entry joinEntry
+	if err = json.Unmarshal(entryBytes, &entry); err != nil {
+		return nil, fmt.Errorf("failed to parse cached entry: %v", err)
+	}
+	return &entry, nil
+}
+
+func joinDocument(content []byte) interface{} {
+	var doc interface{}
+	if err := json.Unmarshal(content, &doc); err != nil {
+		return string(content)
+	}
+	return doc
+}
+
+func (j *Join) joinPart(index int, msg types.Message) (types.Part, error) {
+	part := msg.Get(index)
+
+	ts, err := j.getTimestamp(index, msg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to extract timestamp: %w", err)
+	}
+
+	key := j.key.String(index, msg)
+	entry := joinEntry{
+		Timestamp: ts.UnixNano(),
+		Content:   part.Get(),
+		Metadata:  map[string]string{},
+	}
+	part.Metadata().Iter(func(k, v string) error {
+		entry.Metadata[k] = v
+		return nil
+	})
+	// Each side holds a single slot per key, and therefore this replaces any
+	// previous message of the same side and key.
+	if err = j.store(j.cacheKey(j.side, key), entry); err != nil {
+		return nil, fmt.Errorf("failed to store message: %w", err)
+	}
+
+	other, err := j.lookup(j.cacheKey(j.otherSide, key))
+	if err != nil {
+		return nil, fmt.Errorf("failed to lookup key '%v': %w", key, err)
+	}
+	if other != nil && j.within > 0 {
+		diff := ts.Sub(time.Unix(0, other.Timestamp))
+		if diff < 0 {
+			diff = -diff
+		}
+		if diff > j.within {
+			other = nil
+		}
+	}
+
+	if other == nil {
+		j.mUnmatched.Incr(1)
+		if j.mode == "inner" || (j.mode == "left" && j.side != "left") {
+			return nil, nil
+		}
+	} else {
+		j.mMatched.Incr(1)
+	}
+
+	joined := map[string]interface{}{
+		j.side:      joinDocument(part.Get()),
+		j.otherSide: nil,
+	}
+	if other != nil {
+		joined[j.otherSide] = joinDocument(other.Content)
+	}
+
+	newPart := part.Copy()
+	if err = newPart.SetJSON(joined); err != nil {
+		return nil, err
+	}
+	newPart.Metadata().Set("join_matched", fmt.Sprintf("%v", other != nil))
+	return newPart, nil
+}
+
+// ProcessMessage applies the processor to a message, either creating >0
+// resulting messages or a response to be sent back to the message source.
+func (j *Join) ProcessMessage(msg types.Message) ([]types.Message, types.Response) {
+	j.mCount.Incr(1)
+
+	newMsg := message.New(nil)
+	msg.Iter(func(i int, p types.Part) error {
+		joined, err := j.joinPart(i, msg)
+		if err != nil {
+			j.mErr.Incr(1)
+			j.log.Debugf("Failed to join message: %v\n", err)
+			errPart := p.Copy()
+			FlagErr(errPart, err)
+			newMsg.Append(errPart)
+			return nil
+		}
+		if joined != nil {
+			newMsg.Append(joined)
+		}
+		return nil
+	})
+
+	if newMsg.Len() == 0 {
+		return nil, response.NewAck()
+	}
+
+	j.mBatchSent.Incr(1)
+	j.mSent.Incr(int64(newMsg.Len()))
+	return []types.Message{newMsg}, nil
+}
+
+// CloseAsync shuts down the processor and stops processing requests.
+func (j *Join) CloseAsync() {
+}
+
+// WaitForClose blocks until the processor has closed down.
+func (j *Join) WaitForClose(_ time.Duration) error {
+	return nil
+}
+
+//------------------------------------------------------------------------------
@@ -0,0 +1,144 @@
+package processor
+
+import (
+	"testing"
+
+	"github.com/Jeffail/benthos/v3/lib/cache"
+	"github.com/Jeffail/benthos/v3/lib/log"
+	"github.com/Jeffail/benthos/v3/lib/message"
+	"github.com/Jeffail/benthos/v3/lib/metrics"
+	"github.com/Jeffail/benthos/v3/lib/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newJoinPair(t *testing.T, mode, within string) (left, right Type) {
+	t.Helper()
+
+	memCache, err := cache.NewMemory(cache.NewConfig(), nil, log.Noop(), metrics.Noop())
+	require.NoError(t, err)
+
+	mgr := &fakeMgr{
+		caches: map[string]types.Cache{
+			"foocache": memCache,
+		},
+	}
+
+	conf := NewConfig()
+	conf.Type = TypeJoin
+	conf.Join.Resource = "foocache"
+	conf.Join.Key = `${! json("id") }`
+	conf.Join.Mode = mode
+	conf.Join.Within = within
+	conf.Join.TimestampMapping = "root = this.ts"
+
+	conf.Join.Side = "left"
+	left, err = New(conf, mgr, log.Noop(), metrics.Noop())
+	require.NoError(t, err)
+
+	conf.Join.Side = "right"
+	right, err = New(conf, mgr, log.Noop(), metrics.Noop())
+	require.NoError(t, err)
+	return
+}
+
+func joinResults(t *testing.T, proc Type, input ...string) []string {
+	t.Helper()
+
+	var inputBytes [][]byte
+	for _, in := range input {
+		inputBytes = append(inputBytes, []byte(in))
+	}
+
+	msgs, res := proc.ProcessMessage(message.New(inputBytes))
+	if len(msgs) == 0 {
+		require.NotNil(t, res)
+		require.NoError(t, res.Error())
+		return nil
+	}
+	require.Len(t, msgs, 1)
+
+	var results []string
+	msgs[0].Iter(func(i int, p types.Part) error {
+		require.False(t, HasFailed(p), GetFail(p))
+		results = append(results, string(p.Get()))
+		return nil
+	})
+	return results
+}
+
+func TestJoinInner(t *testing.T) {
+	left, right := newJoinPair(t, "inner", "")
+
+	assert.Empty(t, joinResults(t, left, `{"id":"a","ts":1,"v":"l1"}`, `{"id":"b","ts":1,"v":"l2"}`))
+	assert.Equal(t, []string{
+		`{"left":{"id":"a","ts":1,"v":"l1"},"right":{"id":"a","ts":2,"v":"r1"}}`,
+	}, joinResults(t, right, `{"id":"a","ts":2,"v":"r1"}`, `{"id":"c","ts":2,"v":"r2"}`))
+	assert.Equal(t, []string{
+		`{"left":{"id":"c","ts":3,"v":"l3"},"right":{"id":"c","ts":2,"v":"r2"}}`,
+	}, joinResults(t, left, `{"id":"c","ts":3,"v":"l3"}`))
+}
+
+func TestJoinSingleSlotPerKey(t *testing.T) {
+	left, right := newJoinPair(t, "inner", "")
+
+	assert.Empty(t, joinResults(t, left, `{"id":"a","ts":1,"v":"l1"}`, `{"id":"a","ts":1,"v":"l2"}`))
+	assert.Equal(t, []string{
+		`{"left":{"id":"a","ts":1,"v":"l2"},"right":{"id":"a","ts":2,"v":"r1"}}`,
+		`{"left":{"id":"a","ts":1,"v":"l2"},"right":{"id":"a","ts":2,"v":"r2"}}`,
+	}, joinResults(t, right, `{"id":"a","ts":2,"v":"r1"}`, `{"id":"a","ts":2,"v":"r2"}`))
+	assert.Equal(t, []string{
+		`{"left":{"id":"a","ts":3,"v":"l3"},"right":{"id":"a","ts":2,"v":"r2"}}`,
+	}, joinResults(t, left, `{"id":"a","ts":3,"v":"l3"}`))
+}
+
+func TestJoinWithin(t *testing.T) {
+	left, right := newJoinPair(t, "inner", "10s")
+
+	assert.Empty(t, joinResults(t, left, `{"id":"a","ts":1}`, `{"id":"b","ts":1}`))
+	assert.Equal(t, []string{
+		`{"left":{"id":"a","ts":1},"right":{"id":"a","ts":5}}`,
+	}, joinResults(t, right, `{"id":"a","ts":5}`, `{"id":"b","ts":20}`))
+}
+
+func TestJoinLeft(t *testing.T) {
+	left, right := newJoinPair(t, "left", "")
+
+	assert.Equal(t, []string{
+		`{"left":{"id":"a","ts":1},"right":null}`,
+	}, joinResults(t, left, `{"id":"a","ts":1}`))
+	assert.Empty(t, joinResults(t, right, `{"id":"b","ts":2}`))
+	assert.Equal(t, []string{
+		`{"left":{"id":"a","ts":1},"right":{"id":"a","ts":3}}`,
+	}, joinResults(t, right, `{"id":"a","ts":3}`))
+}
+
+func TestJoinOuter(t *testing.T) {
+	left, right := newJoinPair(t, "outer", "")
+
+	assert.Equal(t, []string{
+		`{"left":{"id":"a","ts":1},"right":null}`,
+	}, joinResults(t, left, `{"id":"a","ts":1}`))
+	assert.Equal(t, []string{
+		`{"left":null,"right":{"id":"b","ts":2}}`,
+	}, joinResults(t, right, `{"id":"b","ts":2}`))
+}
+
+func TestJoinBadConfig(t *testing.T) {
+	memCache, err := cache.NewMemory(cache.NewConfig(), nil, log.Noop(), metrics.Noop())
+	require.NoError(t, err)
+
+	mgr := &fakeMgr{
+		caches: map[string]types.Cache{
+			"foocache": memCache,
+		},
+	}
+
+	conf := NewConfig()
+	conf.Type = TypeJoin
+	conf.Join.Resource = "foocache"
+	conf.Join.Side = "middle"
+
+	_, err = New(conf, mgr, log.Noop(), metrics.Noop())
+	assert.EqualError(t, err, "join side not recognised: middle")
+}
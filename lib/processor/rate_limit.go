@@ -1,11 +1,15 @@
 package processor
 
 import (
+	"errors"
 	"fmt"
 	"sync"
 	"time"
 
+	"github.com/Jeffail/benthos/v3/internal/bloblang"
+	"github.com/Jeffail/benthos/v3/internal/bloblang/field"
 	"github.com/Jeffail/benthos/v3/internal/docs"
+	"github.com/Jeffail/benthos/v3/internal/ratelimit"
 	"github.com/Jeffail/benthos/v3/lib/log"
 	"github.com/Jeffail/benthos/v3/lib/metrics"
 	"github.com/Jeffail/benthos/v3/lib/types"
@@ -24,9 +28,40 @@ Throttles the throughput of a pipeline according to a specified
 ` + "[`rate_limit`](/docs/components/rate_limits/about)" + ` resource. Rate limits are
 shared across components and therefore apply globally to all processing
 pipelines.`,
+		Description: `
+Messages can also be throttled by an interpolated key with the
+` + "`keyed`" + ` field, where each distinct key is given its own limit. This is
+useful for preventing a single noisy key, such as a tenant, from consuming the
+throughput of all others. When both a ` + "`resource`" + ` and a
+` + "`keyed.key`" + ` are set then messages must satisfy both limits.
+
+Messages are processed in order, and therefore a message that is waiting on the
+limit of its key also holds back any messages behind it, including those of keys
+that are not being throttled. In order to let other keys continue while one is
+throttled increase the number of
+` + "[pipeline threads](/docs/configuration/processing_pipelines)" + `, where
+each thread is blocked independently.`,
 		FieldSpecs: docs.FieldSpecs{
-			docs.FieldCommon("resource", "The target [`rate_limit` resource](/docs/components/rate_limits/about)."),
+			docs.FieldCommon("resource", "The target [`rate_limit` resource](/docs/components/rate_limits/about). This field is optional when a `keyed.key` is set."),
+			ratelimit.KeyedFieldSpec("keyed"),
 		},
+		Footnotes: `
+## Examples
+
+### Per Tenant Limits
+
+Limit each tenant, identified by the metadata field ` + "`tenant`" + `, to 100
+messages per second:
+
+` + "```yaml" + `
+pipeline:
+  processors:
+    - rate_limit:
+        keyed:
+          key: ${! meta("tenant") }
+          count: 100
+          interval: 1s
+` + "```" + ``,
 	}
 }
 
@@ -34,13 +69,15 @@ pipelines.`,
 
 // RateLimitConfig contains configuration fields for the RateLimit processor.
 type RateLimitConfig struct {
-	Resource string `json:"resource" yaml:"resource"`
+	Resource string                `json:"resource" yaml:"resource"`
+	Keyed    ratelimit.KeyedConfig `json:"keyed" yaml:"keyed"`
 }
 
 // NewRateLimitConfig returns a RateLimitConfig with default values.
 func NewRateLimitConfig() RateLimitConfig {
 	return RateLimitConfig{
 		Resource: "",
+		Keyed:    ratelimit.NewKeyedConfig(),
 	}
 }
 
@@ -51,6 +88,9 @@ func NewRateLimitConfig() RateLimitConfig {
 type RateLimit struct {
 	rl types.RateLimit
 
+	keyed    *ratelimit.Keyed
+	keyedKey *field.Expression
+
 	log log.Modular
 
 	mCount       metrics.StatCounter
@@ -67,12 +107,10 @@ type RateLimit struct {
 func NewRateLimit(
 	conf Config, mgr types.Manager, log log.Modular, stats metrics.Type,
 ) (Type, error) {
-	rl, err := mgr.GetRateLimit(conf.RateLimit.Resource)
-	if err != nil {
-		return nil, fmt.Errorf("failed to obtain rate limit resource '%v': %v", conf.RateLimit.Resource, err)
+	if conf.RateLimit.Resource == "" && conf.RateLimit.Keyed.Key == "" {
+		return nil, errors.New("either a resource or a keyed.key must be specified")
 	}
 	r := &RateLimit{
-		rl:           rl,
 		log:          log,
 		mCount:       stats.GetCounter("count"),
 		mRateLimited: stats.GetCounter("rate.limited"),
@@ -81,6 +119,21 @@ func NewRateLimit(
 		mBatchSent:   stats.GetCounter("batch.sent"),
 		closeChan:    make(chan struct{}),
 	}
+	if conf.RateLimit.Resource != "" {
+		var err error
+		if r.rl, err = mgr.GetRateLimit(conf.RateLimit.Resource); err != nil {
+			return nil, fmt.Errorf("failed to obtain rate limit resource '%v': %v", conf.RateLimit.Resource, err)
+		}
+	}
+	if conf.RateLimit.Keyed.Key != "" {
+		var err error
+		if r.keyedKey, err = bloblang.NewField(conf.RateLimit.Keyed.Key); err != nil {
+			return nil, fmt.Errorf("failed to parse keyed key expression: %v", err)
+		}
+		if r.keyed, err = ratelimit.NewKeyed(conf.RateLimit.Keyed); err != nil {
+			return nil, fmt.Errorf("failed to create keyed rate limit: %v", err)
+		}
+	}
 	return r, nil
 }
 
@@ -92,6 +145,24 @@ func (r *RateLimit) ProcessMessage(msg types.Message) ([]types.Message, types.Re
 	r.mCount.Incr(1)
 
 	msg.Iter(func(i int, p types.Part) error {
+		if r.keyed != nil {
+			key := r.keyedKey.String(i, msg)
+			// Waiting here blocks the remaining parts and batches of this
+			// pipeline thread regardless of their keys, as ordering must be
+			// preserved.
+			for waitFor := r.keyed.Access(key); waitFor > 0; waitFor = r.keyed.Access(key) {
+				r.mRateLimited.Incr(1)
+				select {
+				case <-time.After(waitFor):
+				case <-r.closeChan:
+					return types.ErrTypeClosed
+				}
+			}
+		}
+		if r.rl == nil {
+			return nil
+		}
+
 		waitFor, err := r.rl.Access()
 		for err != nil || waitFor > 0 {
 			if err == types.ErrTypeClosed {
@@ -11,6 +11,8 @@ import (
 	"github.com/Jeffail/benthos/v3/lib/message"
 	"github.com/Jeffail/benthos/v3/lib/metrics"
 	"github.com/Jeffail/benthos/v3/lib/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 type fakeRateLimit struct {
@@ -210,3 +212,84 @@ func TestRateLimitBlocked(t *testing.T) {
 		t.Error("Timed out")
 	}
 }
+
+func TestRateLimitNoResourceOrKey(t *testing.T) {
+	conf := NewConfig()
+	_, err := NewRateLimit(conf, &fakeMgr{}, log.Noop(), metrics.Noop())
+	assert.Error(t, err)
+
+	conf = NewConfig()
+	conf.RateLimit.Keyed.Key = `${! meta( }`
+	_, err = NewRateLimit(conf, &fakeMgr{}, log.Noop(), metrics.Noop())
+	assert.Error(t, err)
+}
+
+func TestRateLimitKeyed(t *testing.T) {
+	conf := NewConfig()
+	conf.RateLimit.Keyed.Key = `${! meta("tenant") }`
+	conf.RateLimit.Keyed.Count = 2
+	conf.RateLimit.Keyed.Interval = "1h"
+
+	proc, err := NewRateLimit(conf, &fakeMgr{}, log.Noop(), metrics.Noop())
+	require.NoError(t, err)
+
+	newMsg := func(tenant string) types.Message {
+		msg := message.New([][]byte{[]byte("hello world")})
+		msg.Get(0).Metadata().Set("tenant", tenant)
+		return msg
+	}
+
+	for i := 0; i < 2; i++ {
+		_, res := proc.ProcessMessage(newMsg("foo"))
+		require.Nil(t, res)
+	}
+
+	blocked := make(chan struct{})
+	go func() {
+		proc.ProcessMessage(newMsg("foo"))
+		close(blocked)
+	}()
+
+	for i := 0; i < 2; i++ {
+		_, res := proc.ProcessMessage(newMsg("bar"))
+		require.Nil(t, res)
+	}
+
+	select {
+	case <-blocked:
+		t.Fatal("expected key foo to be throttled")
+	case <-time.After(time.Millisecond * 50):
+	}
+
+	proc.CloseAsync()
+	select {
+	case <-blocked:
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for throttled processor to close")
+	}
+}
+
+func TestRateLimitKeyedAndResource(t *testing.T) {
+	var hits int32
+	mgr := &fakeMgr{
+		ratelimits: map[string]types.RateLimit{
+			"foo": fakeRateLimit{resFn: func() (time.Duration, error) {
+				atomic.AddInt32(&hits, 1)
+				return 0, nil
+			}},
+		},
+	}
+
+	conf := NewConfig()
+	conf.RateLimit.Resource = "foo"
+	conf.RateLimit.Keyed.Key = `${! content() }`
+
+	proc, err := NewRateLimit(conf, mgr, log.Noop(), metrics.Noop())
+	require.NoError(t, err)
+
+	_, res := proc.ProcessMessage(message.New([][]byte{
+		[]byte("a"), []byte("b"), []byte("a"),
+	}))
+	require.Nil(t, res)
+	assert.Equal(t, int32(3), atomic.LoadInt32(&hits))
+}
@@ -0,0 +1,449 @@
+package processor
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Jeffail/benthos/v3/internal/docs"
+	"github.com/Jeffail/benthos/v3/lib/util/http/auth"
+	btls "github.com/Jeffail/benthos/v3/lib/util/tls"
+	"github.com/jhump/protoreflect/desc"
+	"github.com/jhump/protoreflect/desc/protoparse"
+	"github.com/jhump/protoreflect/dynamic"
+	"github.com/linkedin/goavro/v2"
+	jsonschema "github.com/xeipuuv/gojsonschema"
+)
+
+//------------------------------------------------------------------------------
+
+// The schema types supported by a schema registry, where an empty type implies
+// Avro.
+const (
+	schemaTypeAvro     = "AVRO"
+	schemaTypeProtobuf = "PROTOBUF"
+	schemaTypeJSON     = "JSON"
+)
+
+// schemaRegistryMagicByte is the first byte of all messages serialised with
+// the Confluent wire format, followed by a 4 byte big endian schema ID.
+const schemaRegistryMagicByte = 0
+
+func schemaRegistryFieldSpecs() docs.FieldSpecs {
+	return docs.FieldSpecs{
+		docs.FieldCommon("url", "The base URL of the schema registry service."),
+		btls.FieldSpec(),
+		auth.BasicAuthFieldSpec(),
+	}
+}
+
+const schemaRegistryDescription = `
+Messages are serialised with the
+[Confluent wire format](https://docs.confluent.io/platform/current/schema-registry/serdes-develop/index.html#wire-format),
+where the contents are prefixed with a magic byte and the ID of the schema used
+to serialise them. Schemas of the type Avro, Protobuf and JSON Schema are
+supported, and schemas are cached after they're first obtained from the
+registry.
+
+When a Protobuf schema contains multiple message types the message indexes of
+the wire format determine the type being serialised. Schema references are
+supported for Protobuf schemas only.`
+
+//------------------------------------------------------------------------------
+
+type schemaRegistryReference struct {
+	Name    string `json:"name"`
+	Subject string `json:"subject"`
+	Version int    `json:"version"`
+}
+
+type schemaRegistryResponse struct {
+	ID         int                       `json:"id"`
+	Schema     string                    `json:"schema"`
+	SchemaType string                    `json:"schemaType"`
+	References []schemaRegistryReference `json:"references"`
+}
+
+// schemaRegistryClient obtains schemas from a schema registry service and
+// caches those obtained by ID, which are immutable.
+type schemaRegistryClient struct {
+	baseURL *url.URL
+	auth    auth.BasicAuthConfig
+	client  *http.Client
+
+	mut  sync.Mutex
+	byID map[int]*schemaRegistryCodec
+}
+
+func newSchemaRegistryClient(urlStr string, tlsConf btls.Config, authConf auth.BasicAuthConfig) (*schemaRegistryClient, error) {
+	if urlStr == "" {
+		return nil, errors.New("a schema registry url must be specified")
+	}
+	u, err := url.Parse(urlStr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse url: %w", err)
+	}
+
+	client := &http.Client{Timeout: 5 * time.Second}
+	if tlsConf.Enabled {
+		tlsCfg, err := tlsConf.Get()
+		if err != nil {
+			return nil, err
+		}
+		client.Transport = &http.Transport{TLSClientConfig: tlsCfg}
+	}
+
+	return &schemaRegistryClient{
+		baseURL: u,
+		auth:    authConf,
+		client:  client,
+		byID:    map[int]*schemaRegistryCodec{},
+	}, nil
+}
+
+func (c *schemaRegistryClient) get(ctx context.Context, path string) (*schemaRegistryResponse, error) {
+	reqURL := *c.baseURL
+	reqURL.Path = reqURL.Path + path
+
+	req, err := http.NewRequestWithContext(ctx, "GET", reqURL.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/vnd.schemaregistry.v1+json")
+	if err := c.auth.Sign(req); err != nil {
+		return nil, err
+	}
+
+	res, err := c.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	body, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return nil, err
+	}
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("request to schema registry returned status %v: %s", res.StatusCode, bytes.TrimSpace(body))
+	}
+
+	var sRes schemaRegistryResponse
+	if err := json.Unmarshal(body, &sRes); err != nil {
+		return nil, fmt.Errorf("failed to parse schema registry response: %w", err)
+	}
+	return &sRes, nil
+}
+
+// resolveReferences obtains the schemas of references recursively, adding
+// them to a map of file names to schemas.
+func (c *schemaRegistryClient) resolveReferences(ctx context.Context, refs []schemaRegistryReference, files map[string]string) error {
+	for _, ref := range refs {
+		if _, exists := files[ref.Name]; exists {
+			continue
+		}
+		res, err := c.get(ctx, "/subjects/"+url.PathEscape(ref.Subject)+"/versions/"+strconv.Itoa(ref.Version))
+		if err != nil {
+			return fmt.Errorf("failed to obtain reference '%v': %w", ref.Name, err)
+		}
+		files[ref.Name] = res.Schema
+		if err := c.resolveReferences(ctx, res.References, files); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *schemaRegistryClient) compile(ctx context.Context, res *schemaRegistryResponse) (*schemaRegistryCodec, error) {
+	codec := &schemaRegistryCodec{id: res.ID, schemaType: res.SchemaType}
+	if codec.schemaType == "" {
+		codec.schemaType = schemaTypeAvro
+	}
+	if len(res.References) > 0 && codec.schemaType != schemaTypeProtobuf {
+		return nil, fmt.Errorf("schema references are not supported for %v schemas", codec.schemaType)
+	}
+
+	var err error
+	switch codec.schemaType {
+	case schemaTypeAvro:
+		if codec.avro, err = goavro.NewCodec(res.Schema); err != nil {
+			return nil, fmt.Errorf("failed to parse Avro schema: %w", err)
+		}
+	case schemaTypeJSON:
+		if codec.json, err = jsonschema.NewSchema(jsonschema.NewStringLoader(res.Schema)); err != nil {
+			return nil, fmt.Errorf("failed to parse JSON schema: %w", err)
+		}
+	case schemaTypeProtobuf:
+		const rootName = "schema.proto"
+		files := map[string]string{rootName: res.Schema}
+		if err = c.resolveReferences(ctx, res.References, files); err != nil {
+			return nil, err
+		}
+		parser := protoparse.Parser{
+			Accessor: protoparse.FileContentsFromMap(files),
+		}
+		fds, err := parser.ParseFiles(rootName)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse Protobuf schema: %w", err)
+		}
+		if codec.proto = fds[0].GetMessageTypes(); len(codec.proto) == 0 {
+			return nil, errors.New("protobuf schema does not contain any message types")
+		}
+	default:
+		return nil, fmt.Errorf("schema type '%v' is not supported", codec.schemaType)
+	}
+	return codec, nil
+}
+
+// GetByID returns a codec for the schema of a given ID.
+func (c *schemaRegistryClient) GetByID(ctx context.Context, id int) (*schemaRegistryCodec, error) {
+	c.mut.Lock()
+	codec, exists := c.byID[id]
+	c.mut.Unlock()
+	if exists {
+		return codec, nil
+	}
+
+	res, err := c.get(ctx, "/schemas/ids/"+strconv.Itoa(id))
+	if err != nil {
+		return nil, fmt.Errorf("failed to obtain schema %v: %w", id, err)
+	}
+	res.ID = id
+	if codec, err = c.compile(ctx, res); err != nil {
+		return nil, err
+	}
+
+	c.mut.Lock()
+	c.byID[id] = codec
+	c.mut.Unlock()
+	return codec, nil
+}
+
+// GetLatest returns a codec for the latest schema of a subject.
+func (c *schemaRegistryClient) GetLatest(ctx context.Context, subject string) (*schemaRegistryCodec, error) {
+	res, err := c.get(ctx, "/subjects/"+url.PathEscape(subject)+"/versions/latest")
+	if err != nil {
+		return nil, fmt.Errorf("failed to obtain latest schema of subject '%v': %w", subject, err)
+	}
+
+	c.mut.Lock()
+	codec, exists := c.byID[res.ID]
+	c.mut.Unlock()
+	if exists {
+		return codec, nil
+	}
+
+	if codec, err = c.compile(ctx, res); err != nil {
+		return nil, err
+	}
+
+	c.mut.Lock()
+	c.byID[res.ID] = codec
+	c.mut.Unlock()
+	return codec, nil
+}
+
+//------------------------------------------------------------------------------
+
+// schemaRegistryCodec converts messages between JSON documents and the
+// Confluent wire format for a particular schema.
+type schemaRegistryCodec struct {
+	id         int
+	schemaType string
+
+	avro  *goavro.Codec
+	json  *jsonschema.Schema
+	proto []*desc.MessageDescriptor
+}
+
+func schemaRegistryHeader(id int) []byte {
+	header := make([]byte, 5)
+	header[0] = schemaRegistryMagicByte
+	binary.BigEndian.PutUint32(header[1:], uint32(id))
+	return header
+}
+
+// extractSchemaID returns the schema ID and remaining payload of a message in
+// the Confluent wire format.
+func extractSchemaID(b []byte) (int, []byte, error) {
+	if len(b) < 5 {
+		return 0, nil, errors.New("message is too short to contain a schema ID")
+	}
+	if b[0] != schemaRegistryMagicByte {
+		return 0, nil, fmt.Errorf("unexpected magic byte: %v", b[0])
+	}
+	return int(binary.BigEndian.Uint32(b[1:5])), b[5:], nil
+}
+
+func validateJSONSchema(schema *jsonschema.Schema, b []byte) error {
+	result, err := schema.Validate(jsonschema.NewBytesLoader(b))
+	if err != nil {
+		return fmt.Errorf("failed to validate document: %w", err)
+	}
+	if !result.Valid() {
+		var errStr string
+		for i, desc := range result.Errors() {
+			if i > 0 {
+				errStr += "\n"
+			}
+			errStr += desc.String()
+		}
+		return errors.New(errStr)
+	}
+	return nil
+}
+
+// protobufMessageType resolves the message type referenced by a list of
+// message indexes.
+func (s *schemaRegistryCodec) protobufMessageType(indexes []int64) (*desc.MessageDescriptor, error) {
+	if len(indexes) == 0 {
+		indexes = []int64{0}
+	}
+	candidates := s.proto
+	var msg *desc.MessageDescriptor
+	for _, i := range indexes {
+		if i < 0 || int(i) >= len(candidates) {
+			return nil, fmt.Errorf("message index %v is out of bounds", i)
+		}
+		msg = candidates[i]
+		candidates = msg.GetNestedMessageTypes()
+	}
+	return msg, nil
+}
+
+// protobufMessageIndexes resolves the message indexes of a message type by
+// its name, which may be either fully qualified or relative to the package of
+// the schema. An empty name refers to the first message type of the schema.
+func (s *schemaRegistryCodec) protobufMessageIndexes(name string) ([]int64, *desc.MessageDescriptor, error) {
+	if name == "" {
+		return []int64{0}, s.proto[0], nil
+	}
+	var search func(candidates []*desc.MessageDescriptor, indexes []int64) ([]int64, *desc.MessageDescriptor)
+	search = func(candidates []*desc.MessageDescriptor, indexes []int64) ([]int64, *desc.MessageDescriptor) {
+		for i, msg := range candidates {
+			msgIndexes := append(append([]int64{}, indexes...), int64(i))
+			fullName := msg.GetFullyQualifiedName()
+			if pkg := msg.GetFile().GetPackage(); pkg != "" && strings.TrimPrefix(fullName, pkg+".") == name {
+				return msgIndexes, msg
+			}
+			if fullName == name {
+				return msgIndexes, msg
+			}
+			if nestedIndexes, nested := search(msg.GetNestedMessageTypes(), msgIndexes); nested != nil {
+				return nestedIndexes, nested
+			}
+		}
+		return nil, nil
+	}
+	indexes, msg := search(s.proto, nil)
+	if msg == nil {
+		return nil, nil, fmt.Errorf("message type '%v' was not found within the schema", name)
+	}
+	return indexes, msg, nil
+}
+
+// Decode converts the payload of a message in the wire format, without the
+// magic byte and schema ID, into a JSON document.
+func (s *schemaRegistryCodec) Decode(payload []byte) ([]byte, error) {
+	switch s.schemaType {
+	case schemaTypeAvro:
+		native, _, err := s.avro.NativeFromBinary(payload)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode Avro document: %w", err)
+		}
+		return s.avro.TextualFromNative(nil, native)
+	case schemaTypeJSON:
+		if err := validateJSONSchema(s.json, payload); err != nil {
+			return nil, err
+		}
+		return payload, nil
+	case schemaTypeProtobuf:
+		r := bytes.NewReader(payload)
+		count, err := binary.ReadVarint(r)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read message indexes: %w", err)
+		}
+		indexes := make([]int64, 0, count)
+		for i := int64(0); i < count; i++ {
+			index, err := binary.ReadVarint(r)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read message indexes: %w", err)
+			}
+			indexes = append(indexes, index)
+		}
+		msgType, err := s.protobufMessageType(indexes)
+		if err != nil {
+			return nil, err
+		}
+		msg := dynamic.NewMessage(msgType)
+		if err := msg.Unmarshal(payload[len(payload)-r.Len():]); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal Protobuf message: %w", err)
+		}
+		return msg.MarshalJSON()
+	}
+	return nil, fmt.Errorf("schema type '%v' is not supported", s.schemaType)
+}
+
+// Encode converts a JSON document into a message in the wire format, including
+// the magic byte and schema ID. The message type is only used by Protobuf
+// schemas, where it selects the message type being serialised.
+func (s *schemaRegistryCodec) Encode(doc []byte, messageType string) ([]byte, error) {
+	buf := bytes.NewBuffer(schemaRegistryHeader(s.id))
+	switch s.schemaType {
+	case schemaTypeAvro:
+		native, _, err := s.avro.NativeFromTextual(doc)
+		if err != nil {
+			return nil, fmt.Errorf("failed to convert JSON to Avro schema: %w", err)
+		}
+		binaryBytes, err := s.avro.BinaryFromNative(nil, native)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode Avro document: %w", err)
+		}
+		buf.Write(binaryBytes)
+	case schemaTypeJSON:
+		if err := validateJSONSchema(s.json, doc); err != nil {
+			return nil, err
+		}
+		buf.Write(doc)
+	case schemaTypeProtobuf:
+		indexes, msgType, err := s.protobufMessageIndexes(messageType)
+		if err != nil {
+			return nil, err
+		}
+		msg := dynamic.NewMessage(msgType)
+		if err := msg.UnmarshalJSON(doc); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal JSON message: %w", err)
+		}
+		protoBytes, err := msg.Marshal()
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal Protobuf message: %w", err)
+		}
+		if len(indexes) == 1 && indexes[0] == 0 {
+			// Message indexes of only the first message type are encoded as a
+			// single zero.
+			buf.WriteByte(0)
+		} else {
+			varint := make([]byte, binary.MaxVarintLen64)
+			buf.Write(varint[:binary.PutVarint(varint, int64(len(indexes)))])
+			for _, index := range indexes {
+				buf.Write(varint[:binary.PutVarint(varint, index)])
+			}
+		}
+		buf.Write(protoBytes)
+	default:
+		return nil, fmt.Errorf("schema type '%v' is not supported", s.schemaType)
+	}
+	return buf.Bytes(), nil
+}
+
+//------------------------------------------------------------------------------
@@ -0,0 +1,167 @@
+package processor
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/Jeffail/benthos/v3/internal/docs"
+	"github.com/Jeffail/benthos/v3/lib/log"
+	"github.com/Jeffail/benthos/v3/lib/metrics"
+	"github.com/Jeffail/benthos/v3/lib/types"
+	"github.com/Jeffail/benthos/v3/lib/util/http/auth"
+	btls "github.com/Jeffail/benthos/v3/lib/util/tls"
+	"github.com/opentracing/opentracing-go"
+)
+
+//------------------------------------------------------------------------------
+
+func init() {
+	Constructors[TypeSchemaRegistryDecode] = TypeSpec{
+		constructor: NewSchemaRegistryDecode,
+		Categories: []Category{
+			CategoryParsing, CategoryIntegration,
+		},
+		Summary: `
+Decodes messages serialised with a schema obtained from a
+[Confluent schema registry service](https://docs.confluent.io/platform/current/schema-registry/index.html)
+into JSON documents.`,
+		Description: schemaRegistryDescription + `
+
+The ID of the schema used to decode a message is added to it as the metadata
+field ` + "`schema_id`" + `.`,
+		Status:     docs.StatusExperimental,
+		Version:    "3.47.0",
+		FieldSpecs: schemaRegistryFieldSpecs(),
+		Examples: []docs.AnnotatedExample{
+			{
+				Title:   "Kafka Topic",
+				Summary: "Here we decode messages of a Kafka topic into JSON documents using a local schema registry:",
+				Config: `
+input:
+  kafka:
+    addresses: [ localhost:9092 ]
+    topics: [ foo ]
+    consumer_group: benthos_foo
+
+pipeline:
+  processors:
+    - schema_registry_decode:
+        url: http://localhost:8081
+`,
+			},
+		},
+	}
+}
+
+//------------------------------------------------------------------------------
+
+// SchemaRegistryDecodeConfig contains configuration fields for the
+// SchemaRegistryDecode processor.
+type SchemaRegistryDecodeConfig struct {
+	URL       string               `json:"url" yaml:"url"`
+	TLS       btls.Config          `json:"tls" yaml:"tls"`
+	BasicAuth auth.BasicAuthConfig `json:"basic_auth" yaml:"basic_auth"`
+}
+
+// NewSchemaRegistryDecodeConfig returns a SchemaRegistryDecodeConfig with
+// default values.
+func NewSchemaRegistryDecodeConfig() SchemaRegistryDecodeConfig {
+	return SchemaRegistryDecodeConfig{
+		URL:       "",
+		TLS:       btls.NewConfig(),
+		BasicAuth: auth.NewBasicAuthConfig(),
+	}
+}
+
+//------------------------------------------------------------------------------
+
+// SchemaRegistryDecode is a processor that decodes messages serialised with a
+// schema from a schema registry.
+type SchemaRegistryDecode struct {
+	client *schemaRegistryClient
+
+	log   log.Modular
+	stats metrics.Type
+
+	mCount     metrics.StatCounter
+	mErr       metrics.StatCounter
+	mSent      metrics.StatCounter
+	mBatchSent metrics.StatCounter
+}
+
+// NewSchemaRegistryDecode returns a SchemaRegistryDecode processor.
+func NewSchemaRegistryDecode(
+	conf Config, mgr types.Manager, log log.Modular, stats metrics.Type,
+) (Type, error) {
+	client, err := newSchemaRegistryClient(conf.SchemaRegistryDecode.URL, conf.SchemaRegistryDecode.TLS, conf.SchemaRegistryDecode.BasicAuth)
+	if err != nil {
+		return nil, err
+	}
+	return &SchemaRegistryDecode{
+		client: client,
+		log:    log,
+		stats:  stats,
+
+		mCount:     stats.GetCounter("count"),
+		mErr:       stats.GetCounter("error"),
+		mSent:      stats.GetCounter("sent"),
+		mBatchSent: stats.GetCounter("batch.sent"),
+	}, nil
+}
+
+//------------------------------------------------------------------------------
+
+// ProcessMessage applies the processor to a message, either creating >0
+// resulting messages or a response to be sent back to the message source.
+func (s *SchemaRegistryDecode) ProcessMessage(msg types.Message) ([]types.Message, types.Response) {
+	s.mCount.Incr(1)
+	newMsg := msg.Copy()
+
+	proc := func(index int, span opentracing.Span, part types.Part) error {
+		id, payload, err := extractSchemaID(part.Get())
+		if err != nil {
+			s.mErr.Incr(1)
+			s.log.Debugf("Failed to extract schema ID: %v\n", err)
+			return err
+		}
+
+		ctx, done := context.WithTimeout(context.Background(), 5*time.Second)
+		defer done()
+
+		codec, err := s.client.GetByID(ctx, id)
+		if err != nil {
+			s.mErr.Incr(1)
+			s.log.Errorf("Failed to obtain schema: %v\n", err)
+			return err
+		}
+
+		doc, err := codec.Decode(payload)
+		if err != nil {
+			s.mErr.Incr(1)
+			s.log.Debugf("Failed to decode message: %v\n", err)
+			return err
+		}
+
+		part.Set(doc)
+		part.Metadata().Set("schema_id", strconv.Itoa(id))
+		return nil
+	}
+
+	IteratePartsWithSpan(TypeSchemaRegistryDecode, nil, newMsg, proc)
+
+	s.mBatchSent.Incr(1)
+	s.mSent.Incr(int64(newMsg.Len()))
+	return []types.Message{newMsg}, nil
+}
+
+// CloseAsync shuts down the processor and stops processing requests.
+func (s *SchemaRegistryDecode) CloseAsync() {
+}
+
+// WaitForClose blocks until the processor has closed down.
+func (s *SchemaRegistryDecode) WaitForClose(timeout time.Duration) error {
+	return nil
+}
+
+//------------------------------------------------------------------------------
@@ -0,0 +1,225 @@
+package processor
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/Jeffail/benthos/v3/internal/bloblang"
+	"github.com/Jeffail/benthos/v3/internal/bloblang/field"
+	"github.com/Jeffail/benthos/v3/internal/docs"
+	"github.com/Jeffail/benthos/v3/lib/log"
+	"github.com/Jeffail/benthos/v3/lib/metrics"
+	"github.com/Jeffail/benthos/v3/lib/types"
+	"github.com/Jeffail/benthos/v3/lib/util/http/auth"
+	btls "github.com/Jeffail/benthos/v3/lib/util/tls"
+	"github.com/opentracing/opentracing-go"
+)
+
+//------------------------------------------------------------------------------
+
+func init() {
+	Constructors[TypeSchemaRegistryEncode] = TypeSpec{
+		constructor: NewSchemaRegistryEncode,
+		Categories: []Category{
+			CategoryParsing, CategoryIntegration,
+		},
+		Summary: `
+Encodes JSON documents using the latest schema of a subject obtained from a
+[Confluent schema registry service](https://docs.confluent.io/platform/current/schema-registry/index.html).`,
+		Description: schemaRegistryDescription + `
+
+The latest schema of a subject is refreshed periodically according to the field
+` + "`refresh_period`" + `. When encoding a message with a Protobuf schema the
+message type is selected with the field ` + "`message_type`" + `, and defaults
+to the first message type of the schema. The ID of the schema used to encode a
+message is added to it as the metadata field ` + "`schema_id`" + `.`,
+		Status:  docs.StatusExperimental,
+		Version: "3.47.0",
+		FieldSpecs: append(schemaRegistryFieldSpecs(),
+			docs.FieldCommon("subject", "The subject of the schema to encode messages with.", "foo", `${! meta("kafka_topic") }-value`).IsInterpolated(),
+			docs.FieldAdvanced("message_type", "The name of the message type to encode messages as when the schema is Protobuf, which can be either fully qualified or relative to the package of the schema. When empty the first message type of the schema is used.", "Person", "testing.Person.Pet"),
+			docs.FieldAdvanced("refresh_period", "The period after which the latest schema of a subject is obtained again from the registry.", "60s", "1h"),
+		),
+		Examples: []docs.AnnotatedExample{
+			{
+				Title:   "Kafka Topic",
+				Summary: "Here we encode JSON documents using the latest schema of a subject before writing them to a Kafka topic:",
+				Config: `
+pipeline:
+  processors:
+    - schema_registry_encode:
+        url: http://localhost:8081
+        subject: foo-value
+
+output:
+  kafka:
+    addresses: [ localhost:9092 ]
+    topic: foo
+`,
+			},
+		},
+	}
+}
+
+//------------------------------------------------------------------------------
+
+// SchemaRegistryEncodeConfig contains configuration fields for the
+// SchemaRegistryEncode processor.
+type SchemaRegistryEncodeConfig struct {
+	URL           string               `json:"url" yaml:"url"`
+	TLS           btls.Config          `json:"tls" yaml:"tls"`
+	BasicAuth     auth.BasicAuthConfig `json:"basic_auth" yaml:"basic_auth"`
+	Subject       string               `json:"subject" yaml:"subject"`
+	MessageType   string               `json:"message_type" yaml:"message_type"`
+	RefreshPeriod string               `json:"refresh_period" yaml:"refresh_period"`
+}
+
+// NewSchemaRegistryEncodeConfig returns a SchemaRegistryEncodeConfig with
+// default values.
+func NewSchemaRegistryEncodeConfig() SchemaRegistryEncodeConfig {
+	return SchemaRegistryEncodeConfig{
+		URL:           "",
+		TLS:           btls.NewConfig(),
+		BasicAuth:     auth.NewBasicAuthConfig(),
+		Subject:       "",
+		MessageType:   "",
+		RefreshPeriod: "10m",
+	}
+}
+
+//------------------------------------------------------------------------------
+
+type schemaRegistrySubject struct {
+	codec     *schemaRegistryCodec
+	refreshed time.Time
+}
+
+// SchemaRegistryEncode is a processor that encodes messages with a schema from
+// a schema registry.
+type SchemaRegistryEncode struct {
+	client        *schemaRegistryClient
+	subject       *field.Expression
+	messageType   string
+	refreshPeriod time.Duration
+
+	subjectsMut sync.Mutex
+	subjects    map[string]*schemaRegistrySubject
+
+	log   log.Modular
+	stats metrics.Type
+
+	mCount     metrics.StatCounter
+	mErr       metrics.StatCounter
+	mSent      metrics.StatCounter
+	mBatchSent metrics.StatCounter
+}
+
+// NewSchemaRegistryEncode returns a SchemaRegistryEncode processor.
+func NewSchemaRegistryEncode(
+	conf Config, mgr types.Manager, log log.Modular, stats metrics.Type,
+) (Type, error) {
+	client, err := newSchemaRegistryClient(conf.SchemaRegistryEncode.URL, conf.SchemaRegistryEncode.TLS, conf.SchemaRegistryEncode.BasicAuth)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &SchemaRegistryEncode{
+		client:      client,
+		messageType: conf.SchemaRegistryEncode.MessageType,
+		subjects:    map[string]*schemaRegistrySubject{},
+		log:         log,
+		stats:       stats,
+
+		mCount:     stats.GetCounter("count"),
+		mErr:       stats.GetCounter("error"),
+		mSent:      stats.GetCounter("sent"),
+		mBatchSent: stats.GetCounter("batch.sent"),
+	}
+
+	if conf.SchemaRegistryEncode.Subject == "" {
+		return nil, fmt.Errorf("a subject must be specified")
+	}
+	if s.subject, err = bloblang.NewField(conf.SchemaRegistryEncode.Subject); err != nil {
+		return nil, fmt.Errorf("failed to parse subject expression: %v", err)
+	}
+	if s.refreshPeriod, err = time.ParseDuration(conf.SchemaRegistryEncode.RefreshPeriod); err != nil {
+		return nil, fmt.Errorf("failed to parse refresh period: %v", err)
+	}
+	return s, nil
+}
+
+//------------------------------------------------------------------------------
+
+func (s *SchemaRegistryEncode) getCodec(subject string) (*schemaRegistryCodec, error) {
+	s.subjectsMut.Lock()
+	defer s.subjectsMut.Unlock()
+
+	cached, exists := s.subjects[subject]
+	if exists && time.Since(cached.refreshed) < s.refreshPeriod {
+		return cached.codec, nil
+	}
+
+	ctx, done := context.WithTimeout(context.Background(), 5*time.Second)
+	defer done()
+
+	codec, err := s.client.GetLatest(ctx, subject)
+	if err != nil {
+		if exists {
+			s.log.Warnf("Failed to refresh schema of subject '%v', using the previous schema: %v\n", subject, err)
+			return cached.codec, nil
+		}
+		return nil, err
+	}
+	s.subjects[subject] = &schemaRegistrySubject{
+		codec:     codec,
+		refreshed: time.Now(),
+	}
+	return codec, nil
+}
+
+// ProcessMessage applies the processor to a message, either creating >0
+// resulting messages or a response to be sent back to the message source.
+func (s *SchemaRegistryEncode) ProcessMessage(msg types.Message) ([]types.Message, types.Response) {
+	s.mCount.Incr(1)
+	newMsg := msg.Copy()
+
+	proc := func(index int, span opentracing.Span, part types.Part) error {
+		codec, err := s.getCodec(s.subject.String(index, msg))
+		if err != nil {
+			s.mErr.Incr(1)
+			s.log.Errorf("Failed to obtain schema: %v\n", err)
+			return err
+		}
+
+		encoded, err := codec.Encode(part.Get(), s.messageType)
+		if err != nil {
+			s.mErr.Incr(1)
+			s.log.Debugf("Failed to encode message: %v\n", err)
+			return err
+		}
+
+		part.Set(encoded)
+		part.Metadata().Set("schema_id", strconv.Itoa(codec.id))
+		return nil
+	}
+
+	IteratePartsWithSpan(TypeSchemaRegistryEncode, nil, newMsg, proc)
+
+	s.mBatchSent.Incr(1)
+	s.mSent.Incr(int64(newMsg.Len()))
+	return []types.Message{newMsg}, nil
+}
+
+// CloseAsync shuts down the processor and stops processing requests.
+func (s *SchemaRegistryEncode) CloseAsync() {
+}
+
+// WaitForClose blocks until the processor has closed down.
+func (s *SchemaRegistryEncode) WaitForClose(timeout time.Duration) error {
+	return nil
+}
+
+//------------------------------------------------------------------------------
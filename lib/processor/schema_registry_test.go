@@ -0,0 +1,379 @@
+package processor
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/Jeffail/benthos/v3/lib/log"
+	"github.com/Jeffail/benthos/v3/lib/message"
+	"github.com/Jeffail/benthos/v3/lib/metrics"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testAvroSchema = `{
+	"type": "record",
+	"name": "foo",
+	"fields": [
+		{"name": "name", "type": "string"},
+		{"name": "age", "type": "int"}
+	]
+}`
+
+const testAvroBytesSchema = `{
+	"type": "record",
+	"name": "payload",
+	"fields": [
+		{"name": "data", "type": "bytes"},
+		{"name": "price", "type": {"type": "bytes", "logicalType": "decimal", "precision": 4, "scale": 2}}
+	]
+}`
+
+const testJSONSchema = `{
+	"type": "object",
+	"properties": {
+		"name": {"type": "string"}
+	},
+	"required": ["name"]
+}`
+
+const testProtoSchema = `
+syntax = "proto3";
+package testing;
+
+import "common.proto";
+
+message Person {
+  string name = 1;
+  int32 age = 2;
+
+  message Pet {
+    string name = 1;
+  }
+}
+
+message Wrapper {
+  testing.common.Meta meta = 1;
+}
+`
+
+const testProtoCommonSchema = `
+syntax = "proto3";
+package testing.common;
+
+message Meta {
+  string source = 1;
+}
+`
+
+func schemaRegistryTestServer(t *testing.T) (*httptest.Server, *int32) {
+	t.Helper()
+
+	var requests int32
+	schemas := map[string]interface{}{
+		"/schemas/ids/1": map[string]interface{}{
+			"schema": testAvroSchema,
+		},
+		"/schemas/ids/2": map[string]interface{}{
+			"schema":     testJSONSchema,
+			"schemaType": "JSON",
+		},
+		"/schemas/ids/3": map[string]interface{}{
+			"schema":     testProtoSchema,
+			"schemaType": "PROTOBUF",
+			"references": []interface{}{
+				map[string]interface{}{"name": "common.proto", "subject": "common", "version": 1},
+			},
+		},
+		"/subjects/common/versions/1": map[string]interface{}{
+			"id":         4,
+			"schema":     testProtoCommonSchema,
+			"schemaType": "PROTOBUF",
+		},
+		"/subjects/foo/versions/latest": map[string]interface{}{
+			"id":     1,
+			"schema": testAvroSchema,
+		},
+		"/subjects/qux/versions/latest": map[string]interface{}{
+			"id":     5,
+			"schema": testAvroBytesSchema,
+		},
+		"/schemas/ids/5": map[string]interface{}{
+			"schema": testAvroBytesSchema,
+		},
+		"/subjects/bar/versions/latest": map[string]interface{}{
+			"id":         2,
+			"schema":     testJSONSchema,
+			"schemaType": "JSON",
+		},
+		"/subjects/baz/versions/latest": map[string]interface{}{
+			"id":         3,
+			"schema":     testProtoSchema,
+			"schemaType": "PROTOBUF",
+			"references": []interface{}{
+				map[string]interface{}{"name": "common.proto", "subject": "common", "version": 1},
+			},
+		},
+	}
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		res, exists := schemas[r.URL.Path]
+		if !exists {
+			http.Error(w, `{"error_code":40403,"message":"Schema not found"}`, http.StatusNotFound)
+			return
+		}
+		require.NoError(t, json.NewEncoder(w).Encode(res))
+	}))
+	return ts, &requests
+}
+
+func TestSchemaRegistryRoundTrip(t *testing.T) {
+	ts, _ := schemaRegistryTestServer(t)
+	defer ts.Close()
+
+	tests := []struct {
+		subject  string
+		input    string
+		schemaID string
+		output   string
+	}{
+		{
+			subject:  "foo",
+			input:    `{"name":"foo","age":10}`,
+			schemaID: "1",
+			output:   `{"age":10,"name":"foo"}`,
+		},
+		{
+			subject:  "qux",
+			input:    `{"data":"\u00ff\u0001","price":"\u0004\u00d2"}`,
+			schemaID: "5",
+			output:   `{"data":"\u00ff\u0001","price":"\u0004\u00d2"}`,
+		},
+		{
+			subject:  "bar",
+			input:    `{"name":"bar"}`,
+			schemaID: "2",
+			output:   `{"name":"bar"}`,
+		},
+		{
+			subject:  "baz",
+			input:    `{"name":"baz","age":20}`,
+			schemaID: "3",
+			output:   `{"name":"baz","age":20}`,
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.subject, func(t *testing.T) {
+			encConf := NewConfig()
+			encConf.Type = TypeSchemaRegistryEncode
+			encConf.SchemaRegistryEncode.URL = ts.URL
+			encConf.SchemaRegistryEncode.Subject = test.subject
+
+			enc, err := New(encConf, nil, log.Noop(), metrics.Noop())
+			require.NoError(t, err)
+
+			decConf := NewConfig()
+			decConf.Type = TypeSchemaRegistryDecode
+			decConf.SchemaRegistryDecode.URL = ts.URL
+
+			dec, err := New(decConf, nil, log.Noop(), metrics.Noop())
+			require.NoError(t, err)
+
+			msgs, res := enc.ProcessMessage(message.New([][]byte{[]byte(test.input)}))
+			require.Nil(t, res)
+			require.Len(t, msgs, 1)
+
+			encoded := msgs[0].Get(0)
+			require.Empty(t, encoded.Metadata().Get(FailFlagKey))
+			assert.Equal(t, test.schemaID, encoded.Metadata().Get("schema_id"))
+			assert.Equal(t, []byte{0, 0, 0, 0}, encoded.Get()[:4])
+			assert.Equal(t, test.schemaID, fmt.Sprintf("%v", encoded.Get()[4]))
+
+			encoded.Metadata().Delete("schema_id")
+
+			msgs, res = dec.ProcessMessage(msgs[0])
+			require.Nil(t, res)
+			require.Len(t, msgs, 1)
+
+			decoded := msgs[0].Get(0)
+			require.Empty(t, decoded.Metadata().Get(FailFlagKey))
+			assert.Equal(t, test.schemaID, decoded.Metadata().Get("schema_id"))
+			assert.JSONEq(t, test.output, string(decoded.Get()))
+		})
+	}
+}
+
+func TestSchemaRegistryDecodeProtobufIndexes(t *testing.T) {
+	ts, _ := schemaRegistryTestServer(t)
+	defer ts.Close()
+
+	conf := NewConfig()
+	conf.Type = TypeSchemaRegistryDecode
+	conf.SchemaRegistryDecode.URL = ts.URL
+
+	dec, err := New(conf, nil, log.Noop(), metrics.Noop())
+	require.NoError(t, err)
+
+	// Message indexes [0, 0] refer to testing.Person.Pet, which are encoded
+	// as zig-zag varints 4 (count of 2), 0, 0, followed by a name field.
+	input := []byte{0, 0, 0, 0, 3, 4, 0, 0, 0x0a, 3, 'c', 'a', 't'}
+
+	msgs, res := dec.ProcessMessage(message.New([][]byte{input}))
+	require.Nil(t, res)
+	require.Len(t, msgs, 1)
+	require.Empty(t, msgs[0].Get(0).Metadata().Get(FailFlagKey))
+	assert.JSONEq(t, `{"name":"cat"}`, string(msgs[0].Get(0).Get()))
+
+	// Message indexes [1] refer to testing.Wrapper, which contains a type
+	// from a referenced schema.
+	input = []byte{0, 0, 0, 0, 3, 2, 2, 0x0a, 5, 0x0a, 3, 'f', 'o', 'o'}
+
+	msgs, res = dec.ProcessMessage(message.New([][]byte{input}))
+	require.Nil(t, res)
+	require.Len(t, msgs, 1)
+	require.Empty(t, msgs[0].Get(0).Metadata().Get(FailFlagKey))
+	assert.JSONEq(t, `{"meta":{"source":"foo"}}`, string(msgs[0].Get(0).Get()))
+}
+
+func TestSchemaRegistryEncodeProtobufMessageType(t *testing.T) {
+	ts, _ := schemaRegistryTestServer(t)
+	defer ts.Close()
+
+	decConf := NewConfig()
+	decConf.Type = TypeSchemaRegistryDecode
+	decConf.SchemaRegistryDecode.URL = ts.URL
+
+	dec, err := New(decConf, nil, log.Noop(), metrics.Noop())
+	require.NoError(t, err)
+
+	tests := []struct {
+		messageType string
+		input       string
+		indexes     []byte
+	}{
+		{
+			messageType: "Person.Pet",
+			input:       `{"name":"cat"}`,
+			indexes:     []byte{4, 0, 0},
+		},
+		{
+			messageType: "testing.Wrapper",
+			input:       `{"meta":{"source":"foo"}}`,
+			indexes:     []byte{2, 2},
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.messageType, func(t *testing.T) {
+			encConf := NewConfig()
+			encConf.Type = TypeSchemaRegistryEncode
+			encConf.SchemaRegistryEncode.URL = ts.URL
+			encConf.SchemaRegistryEncode.Subject = "baz"
+			encConf.SchemaRegistryEncode.MessageType = test.messageType
+
+			enc, err := New(encConf, nil, log.Noop(), metrics.Noop())
+			require.NoError(t, err)
+
+			msgs, res := enc.ProcessMessage(message.New([][]byte{[]byte(test.input)}))
+			require.Nil(t, res)
+			require.Len(t, msgs, 1)
+			require.Empty(t, GetFail(msgs[0].Get(0)))
+
+			encoded := msgs[0].Get(0).Get()
+			assert.Equal(t, test.indexes, encoded[5:5+len(test.indexes)])
+
+			msgs, res = dec.ProcessMessage(msgs[0])
+			require.Nil(t, res)
+			require.Len(t, msgs, 1)
+			require.Empty(t, GetFail(msgs[0].Get(0)))
+			assert.JSONEq(t, test.input, string(msgs[0].Get(0).Get()))
+		})
+	}
+
+	encConf := NewConfig()
+	encConf.Type = TypeSchemaRegistryEncode
+	encConf.SchemaRegistryEncode.URL = ts.URL
+	encConf.SchemaRegistryEncode.Subject = "baz"
+	encConf.SchemaRegistryEncode.MessageType = "Nope"
+
+	enc, err := New(encConf, nil, log.Noop(), metrics.Noop())
+	require.NoError(t, err)
+
+	msgs, res := enc.ProcessMessage(message.New([][]byte{[]byte(`{}`)}))
+	require.Nil(t, res)
+	require.Len(t, msgs, 1)
+	assert.Contains(t, GetFail(msgs[0].Get(0)), "message type 'Nope' was not found")
+}
+
+func TestSchemaRegistryDecodeErrors(t *testing.T) {
+	ts, requests := schemaRegistryTestServer(t)
+	defer ts.Close()
+
+	conf := NewConfig()
+	conf.Type = TypeSchemaRegistryDecode
+	conf.SchemaRegistryDecode.URL = ts.URL
+
+	dec, err := New(conf, nil, log.Noop(), metrics.Noop())
+	require.NoError(t, err)
+
+	msgs, res := dec.ProcessMessage(message.New([][]byte{
+		[]byte(`{"name":"foo"}`),
+		{0, 0, 0, 0, 9, 0},
+		{0, 0, 0, 0, 2, '{', '}'},
+		{0, 0, 0, 0, 2, '{', '"', 'n', 'a', 'm', 'e', '"', ':', '"', 'a', '"', '}'},
+	}))
+	require.Nil(t, res)
+	require.Len(t, msgs, 1)
+
+	assert.Contains(t, GetFail(msgs[0].Get(0)), "unexpected magic byte")
+	assert.Contains(t, GetFail(msgs[0].Get(1)), "failed to obtain schema 9")
+	assert.Contains(t, GetFail(msgs[0].Get(2)), "name is required")
+	assert.Empty(t, GetFail(msgs[0].Get(3)))
+
+	// Schemas obtained by ID are cached.
+	before := atomic.LoadInt32(requests)
+	msgs, _ = dec.ProcessMessage(message.New([][]byte{
+		{0, 0, 0, 0, 2, '{', '"', 'n', 'a', 'm', 'e', '"', ':', '"', 'a', '"', '}'},
+	}))
+	assert.Empty(t, GetFail(msgs[0].Get(0)))
+	assert.Equal(t, before, atomic.LoadInt32(requests))
+}
+
+func TestSchemaRegistryEncodeErrors(t *testing.T) {
+	ts, _ := schemaRegistryTestServer(t)
+	defer ts.Close()
+
+	conf := NewConfig()
+	conf.Type = TypeSchemaRegistryEncode
+	conf.SchemaRegistryEncode.URL = ts.URL
+	conf.SchemaRegistryEncode.Subject = `${! meta("subject") }`
+
+	enc, err := New(conf, nil, log.Noop(), metrics.Noop())
+	require.NoError(t, err)
+
+	inputMsg := message.New([][]byte{
+		[]byte(`{"name":"foo"}`),
+		[]byte(`{"nope":"foo"}`),
+		[]byte(`{"name":"foo"}`),
+	})
+	inputMsg.Get(0).Metadata().Set("subject", "foo")
+	inputMsg.Get(1).Metadata().Set("subject", "bar")
+	inputMsg.Get(2).Metadata().Set("subject", "nope")
+
+	msgs, res := enc.ProcessMessage(inputMsg)
+	require.Nil(t, res)
+	require.Len(t, msgs, 1)
+
+	assert.True(t, strings.Contains(GetFail(msgs[0].Get(0)), "Avro"), GetFail(msgs[0].Get(0)))
+	assert.Contains(t, GetFail(msgs[0].Get(1)), "name is required")
+	assert.Contains(t, GetFail(msgs[0].Get(2)), "status 404")
+}
@@ -66,26 +66,32 @@ var Constructors = map[string]TypeSpec{}
 
 // String constants representing each ratelimit type.
 const (
-	TypeLocal = "local"
+	TypeLocal       = "local"
+	TypeRedis       = "redis"
+	TypeTokenBucket = "token_bucket"
 )
 
 //------------------------------------------------------------------------------
 
 // Config is the all encompassing configuration struct for all cache types.
 type Config struct {
-	Label  string      `json:"label" yaml:"label"`
-	Type   string      `json:"type" yaml:"type"`
-	Local  LocalConfig `json:"local" yaml:"local"`
-	Plugin interface{} `json:"plugin,omitempty" yaml:"plugin,omitempty"`
+	Label       string            `json:"label" yaml:"label"`
+	Type        string            `json:"type" yaml:"type"`
+	Local       LocalConfig       `json:"local" yaml:"local"`
+	Redis       RedisConfig       `json:"redis" yaml:"redis"`
+	TokenBucket TokenBucketConfig `json:"token_bucket" yaml:"token_bucket"`
+	Plugin      interface{}       `json:"plugin,omitempty" yaml:"plugin,omitempty"`
 }
 
 // NewConfig returns a configuration struct fully populated with default values.
 func NewConfig() Config {
 	return Config{
-		Label:  "",
-		Type:   "local",
-		Local:  NewLocalConfig(),
-		Plugin: nil,
+		Label:       "",
+		Type:        "local",
+		Local:       NewLocalConfig(),
+		Redis:       NewRedisConfig(),
+		TokenBucket: NewTokenBucketConfig(),
+		Plugin:      nil,
 	}
 }
 
@@ -0,0 +1,188 @@
+package ratelimit
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Jeffail/benthos/v3/internal/docs"
+	bredis "github.com/Jeffail/benthos/v3/internal/service/redis"
+	"github.com/Jeffail/benthos/v3/lib/log"
+	"github.com/Jeffail/benthos/v3/lib/metrics"
+	"github.com/Jeffail/benthos/v3/lib/types"
+	"github.com/go-redis/redis/v7"
+)
+
+//------------------------------------------------------------------------------
+
+func init() {
+	Constructors[TypeRedis] = TypeSpec{
+		constructor: NewRedis,
+		Status:      docs.StatusExperimental,
+		Version:     "3.47.0",
+		Summary: `
+A rate limit backed by Redis, which can be shared across multiple running
+instances of Benthos in order to enforce a distributed rate limit.`,
+		Description: `
+Requests are limited using the
+[generic cell rate algorithm](https://en.wikipedia.org/wiki/Generic_cell_rate_algorithm),
+which is executed atomically by a Lua script on the Redis server. This allows
+bursts of up to ` + "`count`" + ` requests, after which requests are spread
+evenly across the ` + "`interval`" + `.
+
+The state of the rate limit is stored under a single key, and therefore any
+number of Benthos instances configured with the same ` + "`key`" + ` share the
+same limit. The current time is provided by each Benthos instance, and
+therefore the clocks of instances sharing a limit should be synchronised.`,
+		FieldSpecs: bredis.ConfigDocs().Add(
+			docs.FieldCommon("count", "The maximum number of requests to allow for a given period of time."),
+			docs.FieldCommon("interval", "The time window to limit requests by."),
+			docs.FieldCommon("key", "The key used to store the state of the rate limit, instances that share a key share the same limit."),
+		),
+	}
+}
+
+//------------------------------------------------------------------------------
+
+// RedisConfig is a config struct containing rate limit fields for a redis rate
+// limit.
+type RedisConfig struct {
+	bredis.Config `json:",inline" yaml:",inline"`
+	Count         int    `json:"count" yaml:"count"`
+	Interval      string `json:"interval" yaml:"interval"`
+	Key           string `json:"key" yaml:"key"`
+}
+
+// NewRedisConfig returns a redis rate limit configuration struct with default
+// values.
+func NewRedisConfig() RedisConfig {
+	return RedisConfig{
+		Config:   bredis.NewConfig(),
+		Count:    1000,
+		Interval: "1s",
+		Key:      "benthos_rate_limit",
+	}
+}
+
+//------------------------------------------------------------------------------
+
+// redisGCRAScript implements the generic cell rate algorithm, where the key
+// holds the theoretical arrival time (TAT) of the next request in
+// milliseconds. The script returns zero when a request is permitted, otherwise
+// the number of milliseconds to wait before the next request would be.
+var redisGCRAScript = redis.NewScript(`
+local key = KEYS[1]
+local emission = tonumber(ARGV[1])
+local burst = tonumber(ARGV[2])
+local now = tonumber(ARGV[3])
+
+local tat = tonumber(redis.call("GET", key)) or now
+if tat < now then
+  tat = now
+end
+
+local new_tat = tat + emission
+local allow_at = new_tat - burst
+if now < allow_at then
+  return math.ceil(allow_at - now)
+end
+
+redis.call("SET", key, string.format("%.3f", new_tat), "PX", math.ceil(new_tat - now))
+return 0
+`)
+
+// Redis is a rate limit that stores its state in a Redis server, allowing it
+// to be shared across any number of Benthos instances.
+type Redis struct {
+	client redis.UniversalClient
+	key    string
+
+	emission float64
+	burst    float64
+
+	log log.Modular
+
+	mChecked metrics.StatCounter
+	mLimited metrics.StatCounter
+	mErr     metrics.StatCounter
+}
+
+// NewRedis creates a redis rate limit from a configuration struct. This type is
+// safe to share and call from parallel goroutines.
+func NewRedis(
+	conf Config,
+	mgr types.Manager,
+	logger log.Modular,
+	stats metrics.Type,
+) (types.RateLimit, error) {
+	if conf.Redis.Count <= 0 {
+		return nil, errors.New("count must be larger than zero")
+	}
+	if conf.Redis.Key == "" {
+		return nil, errors.New("a key must be specified")
+	}
+	period, err := time.ParseDuration(conf.Redis.Interval)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse interval: %v", err)
+	}
+	if period <= 0 {
+		return nil, errors.New("interval must be larger than zero")
+	}
+
+	client, err := conf.Redis.Config.Client()
+	if err != nil {
+		return nil, err
+	}
+
+	periodMS := float64(period) / float64(time.Millisecond)
+	return &Redis{
+		client:   client,
+		key:      conf.Redis.Key,
+		emission: periodMS / float64(conf.Redis.Count),
+		burst:    periodMS,
+		log:      logger,
+
+		mChecked: stats.GetCounter("checked"),
+		mLimited: stats.GetCounter("limited"),
+		mErr:     stats.GetCounter("error"),
+	}, nil
+}
+
+//------------------------------------------------------------------------------
+
+// Access the rate limited resource. Returns a duration or an error if the rate
+// limit check fails. The returned duration is either zero (meaning the resource
+// can be accessed) or a reasonable length of time to wait before requesting
+// again.
+func (r *Redis) Access() (time.Duration, error) {
+	r.mChecked.Incr(1)
+
+	nowMS := float64(time.Now().UnixNano()) / float64(time.Millisecond)
+	waitMS, err := redisGCRAScript.Run(
+		r.client, []string{r.key},
+		r.emission, r.burst, fmt.Sprintf("%.3f", nowMS),
+	).Int64()
+	if err != nil {
+		r.mErr.Incr(1)
+		r.log.Errorf("Failed to access rate limit: %v\n", err)
+		return 0, err
+	}
+
+	if waitMS > 0 {
+		r.mLimited.Incr(1)
+		return time.Duration(waitMS) * time.Millisecond, nil
+	}
+	return 0, nil
+}
+
+// CloseAsync shuts down the rate limit.
+func (r *Redis) CloseAsync() {
+	r.client.Close()
+}
+
+// WaitForClose blocks until the rate limit has closed down.
+func (r *Redis) WaitForClose(timeout time.Duration) error {
+	return nil
+}
+
+//------------------------------------------------------------------------------
@@ -0,0 +1,118 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Jeffail/benthos/v3/lib/log"
+	"github.com/Jeffail/benthos/v3/lib/metrics"
+	"github.com/Jeffail/benthos/v3/lib/types"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestRedisRateLimit(t *testing.T, url string, count int, interval string) types.RateLimit {
+	t.Helper()
+
+	conf := NewConfig()
+	conf.Type = TypeRedis
+	conf.Redis.URL = url
+	conf.Redis.Count = count
+	conf.Redis.Interval = interval
+
+	rl, err := New(conf, nil, log.Noop(), metrics.Noop())
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		rl.CloseAsync()
+	})
+	return rl
+}
+
+func TestRedisRateLimitConfErrors(t *testing.T) {
+	conf := NewConfig()
+	conf.Type = TypeRedis
+	conf.Redis.Count = 0
+	_, err := New(conf, nil, log.Noop(), metrics.Noop())
+	assert.Error(t, err)
+
+	conf = NewConfig()
+	conf.Type = TypeRedis
+	conf.Redis.Interval = "nope"
+	_, err = New(conf, nil, log.Noop(), metrics.Noop())
+	assert.Error(t, err)
+
+	conf = NewConfig()
+	conf.Type = TypeRedis
+	conf.Redis.Key = ""
+	_, err = New(conf, nil, log.Noop(), metrics.Noop())
+	assert.Error(t, err)
+}
+
+func TestRedisRateLimitBasic(t *testing.T) {
+	s, err := miniredis.Run()
+	require.NoError(t, err)
+	defer s.Close()
+
+	rl := newTestRedisRateLimit(t, "tcp://"+s.Addr(), 10, "1s")
+
+	for i := 0; i < 10; i++ {
+		period, err := rl.Access()
+		require.NoError(t, err)
+		assert.Equal(t, time.Duration(0), period, i)
+	}
+
+	period, err := rl.Access()
+	require.NoError(t, err)
+	assert.True(t, period > 0 && period <= time.Millisecond*100, period)
+
+	<-time.After(period + time.Millisecond*5)
+
+	period, err = rl.Access()
+	require.NoError(t, err)
+	assert.Equal(t, time.Duration(0), period)
+
+	assert.True(t, s.Exists("benthos_rate_limit"))
+}
+
+func TestRedisRateLimitShared(t *testing.T) {
+	s, err := miniredis.Run()
+	require.NoError(t, err)
+	defer s.Close()
+
+	rlOne := newTestRedisRateLimit(t, "tcp://"+s.Addr(), 10, "10s")
+	rlTwo := newTestRedisRateLimit(t, "tcp://"+s.Addr(), 10, "10s")
+
+	for i := 0; i < 5; i++ {
+		period, err := rlOne.Access()
+		require.NoError(t, err)
+		assert.Equal(t, time.Duration(0), period)
+
+		period, err = rlTwo.Access()
+		require.NoError(t, err)
+		assert.Equal(t, time.Duration(0), period)
+	}
+
+	period, err := rlOne.Access()
+	require.NoError(t, err)
+	assert.True(t, period > time.Millisecond*900, period)
+
+	period, err = rlTwo.Access()
+	require.NoError(t, err)
+	assert.True(t, period > time.Millisecond*900, period)
+}
+
+func TestRedisRateLimitError(t *testing.T) {
+	s, err := miniredis.Run()
+	require.NoError(t, err)
+
+	rl := newTestRedisRateLimit(t, "tcp://"+s.Addr(), 10, "1s")
+
+	_, err = rl.Access()
+	require.NoError(t, err)
+
+	s.Close()
+
+	_, err = rl.Access()
+	assert.Error(t, err)
+}
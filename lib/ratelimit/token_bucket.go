@@ -0,0 +1,154 @@
+package ratelimit
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/Jeffail/benthos/v3/internal/docs"
+	iratelimit "github.com/Jeffail/benthos/v3/internal/ratelimit"
+	"github.com/Jeffail/benthos/v3/lib/log"
+	"github.com/Jeffail/benthos/v3/lib/metrics"
+	"github.com/Jeffail/benthos/v3/lib/types"
+)
+
+//------------------------------------------------------------------------------
+
+func init() {
+	Constructors[TypeTokenBucket] = TypeSpec{
+		constructor: NewTokenBucket,
+		Status:      docs.StatusExperimental,
+		Version:     "3.47.0",
+		Summary: `
+A token bucket rate limit that permits a sustained rate of requests with an
+allowance for bursts, and can be shared across any number of components within
+the pipeline.`,
+		Description: `
+The bucket holds up to ` + "`burst`" + ` tokens and is refilled continuously at
+` + "`rate`" + ` tokens per second, where each request consumes a single token.
+Unlike the ` + "[`local`](/docs/components/rate_limits/local)" + ` rate limit
+there are no fixed windows, and therefore requests are not released in spikes at
+window boundaries.
+
+### Smoothing
+
+When many components are waiting on an empty bucket they would all be told to
+retry once the next token becomes available, at which point only one of them
+succeeds. When ` + "`smoothing`" + ` is enabled requests that are limited are
+instead given staggered wait periods spaced evenly at the configured rate, so
+that waiting components resume one at a time.`,
+		FieldSpecs: docs.FieldSpecs{
+			docs.FieldCommon("rate", "The number of tokens added to the bucket per second, which is the sustained rate of requests allowed."),
+			docs.FieldCommon("burst", "The maximum number of tokens the bucket can hold, which is the largest burst of requests allowed after a period of inactivity."),
+			docs.FieldAdvanced("smoothing", "Whether to stagger the wait periods of limited requests so that they resume evenly spaced rather than all at once."),
+		},
+	}
+}
+
+//------------------------------------------------------------------------------
+
+// TokenBucketConfig is a config struct containing rate limit fields for a
+// token bucket rate limit.
+type TokenBucketConfig struct {
+	Rate      float64 `json:"rate" yaml:"rate"`
+	Burst     int     `json:"burst" yaml:"burst"`
+	Smoothing bool    `json:"smoothing" yaml:"smoothing"`
+}
+
+// NewTokenBucketConfig returns a token bucket rate limit configuration struct
+// with default values.
+func NewTokenBucketConfig() TokenBucketConfig {
+	return TokenBucketConfig{
+		Rate:      1000,
+		Burst:     1000,
+		Smoothing: false,
+	}
+}
+
+//------------------------------------------------------------------------------
+
+// TokenBucket is a rate limit where tokens are refilled at a constant rate up
+// to a burst capacity, it can be shared across parallel processes in order to
+// maintain a maximum rate of a protected resource.
+type TokenBucket struct {
+	mut      sync.Mutex
+	bucket   *iratelimit.TokenBucket
+	perToken time.Duration
+
+	smoothing bool
+	nextSlot  time.Time
+
+	mChecked metrics.StatCounter
+	mLimited metrics.StatCounter
+	mErr     metrics.StatCounter
+}
+
+// NewTokenBucket creates a token bucket rate limit from a configuration
+// struct. This type is safe to share and call from parallel goroutines.
+func NewTokenBucket(
+	conf Config,
+	mgr types.Manager,
+	logger log.Modular,
+	stats metrics.Type,
+) (types.RateLimit, error) {
+	if conf.TokenBucket.Rate <= 0 {
+		return nil, errors.New("rate must be larger than zero")
+	}
+	if conf.TokenBucket.Burst <= 0 {
+		return nil, errors.New("burst must be larger than zero")
+	}
+
+	perToken := time.Duration(float64(time.Second) / conf.TokenBucket.Rate)
+	if perToken <= 0 {
+		perToken = 1
+	}
+
+	return &TokenBucket{
+		bucket:    iratelimit.NewTokenBucket(perToken, conf.TokenBucket.Burst, time.Now()),
+		perToken:  perToken,
+		smoothing: conf.TokenBucket.Smoothing,
+
+		mChecked: stats.GetCounter("checked"),
+		mLimited: stats.GetCounter("limited"),
+		mErr:     stats.GetCounter("error"),
+	}, nil
+}
+
+//------------------------------------------------------------------------------
+
+// Access the rate limited resource. Returns a duration or an error if the rate
+// limit check fails. The returned duration is either zero (meaning the resource
+// can be accessed) or a reasonable length of time to wait before requesting
+// again.
+func (r *TokenBucket) Access() (time.Duration, error) {
+	r.mChecked.Incr(1)
+	now := time.Now()
+
+	r.mut.Lock()
+	wait := r.bucket.Take(now)
+	if wait > 0 && r.smoothing {
+		slot := now.Add(wait)
+		if r.nextSlot.After(slot) {
+			slot = r.nextSlot
+		}
+		r.nextSlot = slot.Add(r.perToken)
+		wait = slot.Sub(now)
+	}
+	r.mut.Unlock()
+
+	if wait > 0 {
+		r.mLimited.Incr(1)
+	}
+	return wait, nil
+}
+
+// CloseAsync shuts down the rate limit.
+func (r *TokenBucket) CloseAsync() {
+}
+
+// WaitForClose blocks until the rate limit has closed down.
+func (r *TokenBucket) WaitForClose(timeout time.Duration) error {
+	return nil
+}
+
+//------------------------------------------------------------------------------
@@ -0,0 +1,143 @@
+package ratelimit
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Jeffail/benthos/v3/lib/log"
+	"github.com/Jeffail/benthos/v3/lib/metrics"
+	"github.com/Jeffail/benthos/v3/lib/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestTokenBucket(t *testing.T, rate float64, burst int, smoothing bool) types.RateLimit {
+	t.Helper()
+
+	conf := NewConfig()
+	conf.Type = TypeTokenBucket
+	conf.TokenBucket.Rate = rate
+	conf.TokenBucket.Burst = burst
+	conf.TokenBucket.Smoothing = smoothing
+
+	rl, err := New(conf, nil, log.Noop(), metrics.Noop())
+	require.NoError(t, err)
+	return rl
+}
+
+func TestTokenBucketConfErrors(t *testing.T) {
+	conf := NewConfig()
+	conf.Type = TypeTokenBucket
+	conf.TokenBucket.Rate = 0
+	_, err := New(conf, nil, log.Noop(), metrics.Noop())
+	assert.Error(t, err)
+
+	conf = NewConfig()
+	conf.Type = TypeTokenBucket
+	conf.TokenBucket.Burst = 0
+	_, err = New(conf, nil, log.Noop(), metrics.Noop())
+	assert.Error(t, err)
+}
+
+func TestTokenBucketBurst(t *testing.T) {
+	rl := newTestTokenBucket(t, 10, 5, false)
+
+	for i := 0; i < 5; i++ {
+		period, err := rl.Access()
+		require.NoError(t, err)
+		assert.Equal(t, time.Duration(0), period, i)
+	}
+
+	period, err := rl.Access()
+	require.NoError(t, err)
+	assert.True(t, period > 0 && period <= time.Millisecond*100, period)
+
+	<-time.After(period)
+
+	period, err = rl.Access()
+	require.NoError(t, err)
+	assert.Equal(t, time.Duration(0), period)
+}
+
+func TestTokenBucketSustainedRate(t *testing.T) {
+	rl := newTestTokenBucket(t, 100, 1, false)
+
+	start := time.Now()
+	for i := 0; i < 11; i++ {
+		for {
+			period, err := rl.Access()
+			require.NoError(t, err)
+			if period == 0 {
+				break
+			}
+			<-time.After(period)
+		}
+	}
+
+	dur := time.Since(start)
+	assert.True(t, dur >= time.Millisecond*95, dur)
+	assert.True(t, dur < time.Millisecond*500, dur)
+}
+
+func TestTokenBucketSmoothing(t *testing.T) {
+	rl := newTestTokenBucket(t, 10, 1, true)
+
+	period, err := rl.Access()
+	require.NoError(t, err)
+	assert.Equal(t, time.Duration(0), period)
+
+	var last time.Duration
+	for i := 0; i < 3; i++ {
+		period, err = rl.Access()
+		require.NoError(t, err)
+		assert.True(t, period > last+time.Millisecond*90, period)
+		last = period
+	}
+
+	unsmoothed := newTestTokenBucket(t, 10, 1, false)
+
+	period, err = unsmoothed.Access()
+	require.NoError(t, err)
+	assert.Equal(t, time.Duration(0), period)
+
+	for i := 0; i < 3; i++ {
+		period, err = unsmoothed.Access()
+		require.NoError(t, err)
+		assert.True(t, period > 0 && period <= time.Millisecond*100, period)
+	}
+}
+
+func TestTokenBucketParallel(t *testing.T) {
+	rl := newTestTokenBucket(t, 1000, 100, true)
+
+	wg := sync.WaitGroup{}
+	start := make(chan struct{})
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-start
+			for j := 0; j < 20; j++ {
+				for {
+					period, err := rl.Access()
+					if err != nil {
+						t.Error(err)
+						return
+					}
+					if period == 0 {
+						break
+					}
+					<-time.After(period)
+				}
+			}
+		}()
+	}
+
+	begin := time.Now()
+	close(start)
+	wg.Wait()
+
+	dur := time.Since(begin)
+	assert.True(t, dur >= time.Millisecond*90, dur)
+}
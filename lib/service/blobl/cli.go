@@ -64,6 +64,72 @@ func CliCommand() *cli.Command {
 		},
 		Action: run,
 		Subcommands: []*cli.Command{
+			{
+				Name:  "test",
+				Usage: "Execute the unit tests declared within Bloblang mapping files",
+				Description: `
+   Execute the tests declared as comments within any number of Bloblang mapping
+   files. If one or more tests fail the process will report the errors and exit
+   with a status code 1.
+
+   benthos blobl test ./path/to/mappings/...
+   benthos blobl test ./foo.blobl
+
+   Each test is declared with comment directives:
+
+   # test: uppercases names
+   # in: {"name":"bob"}
+   # in_meta: {"topic":"foo"}
+   # out: {"name":"BOB"}
+   # out_meta: {"topic":"foo"}
+   root.name = this.name.uppercase()
+
+   An error directive asserts that the mapping fails with an error containing a
+   string, and can be used instead of output directives.`[4:],
+				Action: runTestsCommand,
+			},
+			{
+				Name:  "fmt",
+				Usage: "Format Bloblang mapping files",
+				Description: `
+   Format any number of Bloblang mapping files with consistent indentation and
+   spacing. Only the layout of mappings is changed, statements and expressions
+   are never rewritten. By default the formatted mappings are printed to stdout.
+
+   benthos blobl fmt ./foo.blobl
+   benthos blobl fmt -w ./path/to/mappings/...`[4:],
+				Flags: []cli.Flag{
+					&cli.BoolFlag{
+						Name:    "write",
+						Aliases: []string{"w"},
+						Usage:   "write the formatted mappings back to their files.",
+					},
+					&cli.BoolFlag{
+						Name:    "list",
+						Aliases: []string{"l"},
+						Usage:   "list the files that are not formatted.",
+					},
+				},
+				Action: runFormatCommand,
+			},
+			{
+				Name:  "lint",
+				Usage: "Report problems found within Bloblang mapping files",
+				Description: `
+   Parse any number of Bloblang mapping files and report problems that do not
+   prevent the mappings from running, such as variables that are declared and
+   never used, maps that are never applied, deprecated functions and methods,
+   type mismatches of literal values and arguments, and assignments that are
+   later deleted.
+   Mappings that are not formatted are also reported. If any problems are found
+   the process exits with a status code 1.
+
+   benthos blobl lint ./path/to/mappings/...
+   benthos blobl lint ./foo.blobl
+
+   Mappings embedded within configs can be analysed with 'benthos lint --blobl'.`[4:],
+				Action: runLintCommand,
+			},
 			{
 				Name:        "server",
 				Usage:       "EXPERIMENTAL: Run a web server that hosts a Bloblang app",
@@ -0,0 +1,154 @@
+package blobl
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+
+	"github.com/Jeffail/benthos/v3/internal/bloblang"
+	"github.com/Jeffail/benthos/v3/internal/bloblang/parser"
+	"github.com/urfave/cli/v2"
+)
+
+func mappingParseErr(mapping string, err error) error {
+	if perr, ok := err.(*parser.Error); ok {
+		return fmt.Errorf("failed to parse mapping: %v", perr.ErrorAtPosition([]rune(mapping)))
+	}
+	return err
+}
+
+// formatMappingFile returns the formatted contents of a mapping file, and
+// whether the formatted contents differ from the file.
+func formatMappingFile(path string) (string, bool, error) {
+	mappingBytes, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", false, err
+	}
+	m := string(mappingBytes)
+
+	formatted, err := bloblang.FormatMapping(path, m)
+	if err != nil {
+		return "", false, mappingParseErr(m, err)
+	}
+	return formatted, formatted != m, nil
+}
+
+// lintMappingFile returns the lints found within a mapping file, including a
+// lint for when the mapping is not formatted.
+func lintMappingFile(path string) ([]string, error) {
+	mappingBytes, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	m := string(mappingBytes)
+
+	lints, err := bloblang.LintMapping(path, m)
+	if err != nil {
+		return nil, mappingParseErr(m, err)
+	}
+
+	var lintStrs []string
+	for _, l := range lints {
+		lintStrs = append(lintStrs, l.String())
+	}
+
+	formatted, err := bloblang.FormatMapping(path, m)
+	if err != nil {
+		return nil, mappingParseErr(m, err)
+	}
+	if formatted != m {
+		lintStrs = append(lintStrs, "mapping is not formatted, run 'benthos blobl fmt -w' to fix")
+	}
+	return lintStrs, nil
+}
+
+func getMappingTargets(paths []string) ([]string, error) {
+	if len(paths) == 0 {
+		paths = []string{"."}
+	}
+	targets := map[string]bool{}
+	for _, path := range paths {
+		lTargets, err := getTestTargets(path)
+		if err != nil {
+			return nil, err
+		}
+		for _, t := range lTargets {
+			targets[t] = true
+		}
+	}
+
+	targetPaths := make([]string, 0, len(targets))
+	for k := range targets {
+		targetPaths = append(targetPaths, k)
+	}
+	sort.Strings(targetPaths)
+	return targetPaths, nil
+}
+
+//------------------------------------------------------------------------------
+
+func runFormatCommand(c *cli.Context) error {
+	targets, err := getMappingTargets(c.Args().Slice())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to obtain mapping targets: %v\n", err)
+		os.Exit(1)
+	}
+
+	write, list := c.Bool("write"), c.Bool("list")
+
+	failed := false
+	for _, target := range targets {
+		formatted, changed, err := formatMappingFile(target)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%v: %v\n", target, red(err))
+			failed = true
+			continue
+		}
+		if list && changed {
+			fmt.Println(target)
+		}
+		if write {
+			if changed {
+				if err := ioutil.WriteFile(target, []byte(formatted), 0644); err != nil {
+					fmt.Fprintf(os.Stderr, "%v: %v\n", target, red(err))
+					failed = true
+				}
+			}
+		} else if !list {
+			fmt.Print(formatted)
+		}
+	}
+	if failed {
+		os.Exit(1)
+	}
+	os.Exit(0)
+	return nil
+}
+
+func runLintCommand(c *cli.Context) error {
+	targets, err := getMappingTargets(c.Args().Slice())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to obtain mapping targets: %v\n", err)
+		os.Exit(1)
+	}
+
+	failed := false
+	for _, target := range targets {
+		lints, err := lintMappingFile(target)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%v: %v\n", target, red(err))
+			failed = true
+			continue
+		}
+		for _, l := range lints {
+			fmt.Fprintf(os.Stderr, "%v: %v\n", target, yellow(l))
+			failed = true
+		}
+	}
+	if failed {
+		os.Exit(1)
+	}
+	os.Exit(0)
+	return nil
+}
@@ -0,0 +1,106 @@
+package blobl
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFormatMappingFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "benthos_blobl_fmt")
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		os.RemoveAll(dir)
+	})
+
+	formattedPath := filepath.Join(dir, "formatted.blobl")
+	require.NoError(t, ioutil.WriteFile(formattedPath, []byte(`root.foo = this.foo
+`), 0644))
+
+	unformattedPath := filepath.Join(dir, "unformatted.blobl")
+	require.NoError(t, ioutil.WriteFile(unformattedPath, []byte(`map foo {
+root.foo =   this.foo
+}
+root = this.apply( "foo" )`), 0644))
+
+	badPath := filepath.Join(dir, "bad.blobl")
+	require.NoError(t, ioutil.WriteFile(badPath, []byte(`root = this.foo(`), 0644))
+
+	formatted, changed, err := formatMappingFile(formattedPath)
+	require.NoError(t, err)
+	assert.False(t, changed)
+	assert.Equal(t, "root.foo = this.foo\n", formatted)
+
+	formatted, changed, err = formatMappingFile(unformattedPath)
+	require.NoError(t, err)
+	assert.True(t, changed)
+	assert.Equal(t, `map foo {
+  root.foo = this.foo
+}
+root = this.apply("foo")
+`, formatted)
+
+	_, _, err = formatMappingFile(badPath)
+	require.Error(t, err)
+	assert.Equal(t, "failed to parse mapping: line 1 char 17: required: expected function argument", err.Error())
+}
+
+func TestLintMappingFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "benthos_blobl_lint")
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		os.RemoveAll(dir)
+	})
+
+	tests := map[string]struct {
+		mapping string
+		lints   []string
+		err     string
+	}{
+		"no lints": {
+			mapping: `let foo = this.foo
+root.foo = $foo
+`,
+		},
+		"lints": {
+			mapping: `let foo = this.foo
+root.bar = this.bar.parse_timestamp_unix()
+`,
+			lints: []string{
+				"line 1 char 1: variable foo is declared but never used",
+				"line 2 char 21: method parse_timestamp_unix is deprecated",
+			},
+		},
+		"not formatted": {
+			mapping: `root.foo   = this.foo`,
+			lints: []string{
+				"mapping is not formatted, run 'benthos blobl fmt -w' to fix",
+			},
+		},
+		"bad mapping": {
+			mapping: `root = this.foo(`,
+			err:     "failed to parse mapping: line 1 char 17: required: expected function argument",
+		},
+	}
+
+	for name, test := range tests {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(dir, "test.blobl")
+			require.NoError(t, ioutil.WriteFile(path, []byte(test.mapping), 0644))
+
+			lints, err := lintMappingFile(path)
+			if test.err != "" {
+				require.Error(t, err)
+				assert.Equal(t, test.err, err.Error())
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, test.lints, lints)
+		})
+	}
+}
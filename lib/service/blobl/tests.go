@@ -0,0 +1,337 @@
+package blobl
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/Jeffail/benthos/v3/internal/bloblang"
+	"github.com/Jeffail/benthos/v3/internal/bloblang/mapping"
+	"github.com/Jeffail/benthos/v3/internal/bloblang/parser"
+	"github.com/Jeffail/benthos/v3/lib/message"
+	"github.com/Jeffail/benthos/v3/lib/message/metadata"
+	"github.com/fatih/color"
+	"github.com/urfave/cli/v2"
+)
+
+var green = color.New(color.FgGreen).SprintFunc()
+var yellow = color.New(color.FgYellow).SprintFunc()
+var blue = color.New(color.FgBlue).SprintFunc()
+
+//------------------------------------------------------------------------------
+
+// testCase is a test declared within the comments of a mapping file.
+type testCase struct {
+	name string
+	line int
+
+	input     *string
+	inputMeta map[string]string
+
+	output     *string
+	outputMeta map[string]string
+	err        *string
+}
+
+// testFailure describes a failed test case.
+type testFailure struct {
+	name   string
+	line   int
+	reason string
+}
+
+func (f testFailure) String() string {
+	return fmt.Sprintf("%v [line %v]: %v", f.name, f.line, f.reason)
+}
+
+func parseMetaDirective(value string) (map[string]string, error) {
+	meta := map[string]string{}
+	if err := json.Unmarshal([]byte(value), &meta); err != nil {
+		return nil, fmt.Errorf("expected a JSON object of string values: %w", err)
+	}
+	return meta, nil
+}
+
+// parseTestCases extracts test cases from comment directives within a mapping.
+// A test is started either with a `# test: <name>` directive, or with an
+// `# in: <content>` directive when the current test already has an input.
+func parseTestCases(mapping string) ([]*testCase, error) {
+	var cases []*testCase
+	var current *testCase
+
+	for i, line := range strings.Split(mapping, "\n") {
+		lineNum := i + 1
+
+		line = strings.TrimSpace(line)
+		if !strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = strings.TrimSpace(strings.TrimPrefix(line, "#"))
+
+		colon := strings.Index(line, ":")
+		if colon <= 0 {
+			continue
+		}
+		directive := strings.ToLower(line[:colon])
+		value := strings.TrimSpace(line[colon+1:])
+
+		switch directive {
+		case "test":
+			current = &testCase{name: value, line: lineNum}
+			cases = append(cases, current)
+			continue
+		case "in":
+			if current == nil || current.input != nil {
+				current = &testCase{line: lineNum}
+				cases = append(cases, current)
+			}
+		case "in_meta", "out", "out_meta", "error":
+			if current == nil {
+				return nil, fmt.Errorf("line %v: %v directive found outside of a test", lineNum, directive)
+			}
+		default:
+			continue
+		}
+
+		var err error
+		switch directive {
+		case "in":
+			current.input = &value
+		case "in_meta":
+			current.inputMeta, err = parseMetaDirective(value)
+		case "out":
+			current.output = &value
+		case "out_meta":
+			current.outputMeta, err = parseMetaDirective(value)
+		case "error":
+			current.err = &value
+		}
+		if err != nil {
+			return nil, fmt.Errorf("line %v: %v", lineNum, err)
+		}
+	}
+
+	for i, c := range cases {
+		if c.name == "" {
+			c.name = fmt.Sprintf("test %v", i+1)
+		}
+		if c.input == nil {
+			return nil, fmt.Errorf("line %v: test '%v' is missing an input", c.line, c.name)
+		}
+		if c.output == nil && c.outputMeta == nil && c.err == nil {
+			return nil, fmt.Errorf("line %v: test '%v' has no expected output or error", c.line, c.name)
+		}
+		if c.err != nil && (c.output != nil || c.outputMeta != nil) {
+			return nil, fmt.Errorf("line %v: test '%v' cannot expect both an output and an error", c.line, c.name)
+		}
+	}
+	return cases, nil
+}
+
+// contentMatches compares the contents of a mapping result with an expected
+// value, where values that are both valid JSON are compared structurally.
+func contentMatches(expected string, actual []byte) bool {
+	var expJSON, actJSON interface{}
+	if json.Unmarshal([]byte(expected), &expJSON) == nil && json.Unmarshal(actual, &actJSON) == nil {
+		return reflect.DeepEqual(expJSON, actJSON)
+	}
+	return expected == string(actual)
+}
+
+func (c *testCase) execute(exec *mapping.Executor) (failures []testFailure) {
+	reportFailure := func(reason string) {
+		failures = append(failures, testFailure{
+			name:   c.name,
+			line:   c.line,
+			reason: reason,
+		})
+	}
+
+	part := message.NewPart([]byte(*c.input))
+	part.SetMetadata(metadata.New(c.inputMeta))
+	msg := message.New(nil)
+	msg.Append(part)
+
+	resPart, err := exec.MapPart(0, msg)
+	if c.err != nil {
+		if err == nil {
+			reportFailure(fmt.Sprintf("expected error containing: %v\n  but the mapping succeeded", blue(*c.err)))
+		} else if !strings.Contains(err.Error(), *c.err) {
+			reportFailure(fmt.Sprintf("error mismatch\n  expected: %v\n  received: %v", blue(*c.err), red(err.Error())))
+		}
+		return
+	}
+	if err != nil {
+		reportFailure(fmt.Sprintf("mapping failed: %v", red(err.Error())))
+		return
+	}
+	if resPart == nil {
+		reportFailure("mapping deleted the message")
+		return
+	}
+
+	if c.output != nil && !contentMatches(*c.output, resPart.Get()) {
+		reportFailure(fmt.Sprintf("content mismatch\n  expected: %v\n  received: %v", blue(*c.output), red(string(resPart.Get()))))
+	}
+
+	keys := make([]string, 0, len(c.outputMeta))
+	for k := range c.outputMeta {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		if exp, act := c.outputMeta[k], resPart.Metadata().Get(k); exp != act {
+			reportFailure(fmt.Sprintf("metadata key '%v' mismatch\n  expected: %v\n  received: %v", k, blue(exp), red(act)))
+		}
+	}
+	return
+}
+
+var errNoTests = errors.New("no tests found")
+
+// runMappingTests parses a mapping file and executes the test cases it
+// contains, returning any test failures.
+func runMappingTests(path string) ([]testFailure, error) {
+	mappingBytes, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	m := string(mappingBytes)
+
+	cases, err := parseTestCases(m)
+	if err != nil {
+		return nil, err
+	}
+	if len(cases) == 0 {
+		return nil, errNoTests
+	}
+
+	exec, err := bloblang.NewMapping(path, m)
+	if err != nil {
+		if perr, ok := err.(*parser.Error); ok {
+			return nil, fmt.Errorf("failed to parse mapping: %v", perr.ErrorAtPosition([]rune(m)))
+		}
+		return nil, err
+	}
+
+	var failures []testFailure
+	for _, c := range cases {
+		failures = append(failures, c.execute(exec)...)
+	}
+	return failures, nil
+}
+
+//------------------------------------------------------------------------------
+
+// getTestTargets returns the paths of mapping files found within a path, which
+// can either be a file, a directory, or a directory followed by the wildcard
+// pattern '/...' in order to also search subdirectories.
+func getTestTargets(path string) ([]string, error) {
+	recurse := false
+	if path == "./..." || path == "..." {
+		recurse = true
+		path = "."
+	}
+	if strings.HasSuffix(path, "/...") {
+		recurse = true
+		path = strings.TrimSuffix(path, "/...")
+	}
+
+	path = filepath.Clean(path)
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return []string{path}, nil
+	}
+
+	var targets []string
+	err = filepath.Walk(path, func(p string, info os.FileInfo, werr error) error {
+		if werr != nil {
+			return werr
+		}
+		if info.IsDir() {
+			if recurse || p == path {
+				return nil
+			}
+			return filepath.SkipDir
+		}
+		if filepath.Ext(p) == ".blobl" {
+			targets = append(targets, p)
+		}
+		return nil
+	})
+	return targets, err
+}
+
+func runTests(paths []string) bool {
+	targetPaths, err := getMappingTargets(paths)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to obtain test targets: %v\n", err)
+		return false
+	}
+
+	type failedTarget struct {
+		target string
+		cases  []testFailure
+	}
+	var fails []failedTarget
+
+	tested := 0
+	for _, target := range targetPaths {
+		failures, err := runMappingTests(target)
+		if err != nil {
+			if errors.Is(err, errNoTests) {
+				continue
+			}
+			fmt.Fprintf(os.Stderr, "Failed to execute test target '%v': %v\n", target, err)
+			return false
+		}
+		tested++
+		if len(failures) > 0 {
+			fails = append(fails, failedTarget{target: target, cases: failures})
+			fmt.Printf("Test '%v' %v\n", target, red("failed"))
+		} else {
+			fmt.Printf("Test '%v' %v\n", target, green("succeeded"))
+		}
+	}
+
+	if tested == 0 {
+		fmt.Printf("%v\n", yellow("No tests were found"))
+		return false
+	}
+
+	if len(fails) > 0 {
+		fmt.Printf("\nFailures:\n\n")
+		for i, fail := range fails {
+			if i > 0 {
+				fmt.Println("")
+			}
+			fmt.Printf("--- %v ---\n\n", fail.target)
+			for _, c := range fail.cases {
+				fmt.Println(c.String())
+			}
+		}
+		return false
+	}
+	return true
+}
+
+func runTestsCommand(c *cli.Context) error {
+	paths := c.Args().Slice()
+	if len(paths) == 0 {
+		paths = []string{"."}
+	}
+	if runTests(paths) {
+		os.Exit(0)
+	}
+	os.Exit(1)
+	return nil
+}
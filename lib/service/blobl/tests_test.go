@@ -0,0 +1,246 @@
+package blobl
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/fatih/color"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseTestCases(t *testing.T) {
+	cases, err := parseTestCases(`# A normal comment
+# test: first test
+# in: {"name":"bob"}
+# in_meta: {"topic":"foo"}
+# out: {"name":"BOB"}
+# out_meta: {"topic":"foo"}
+root.name = this.name.uppercase()
+
+# In:  {"name":"first"}
+# Out: {"name":"FIRST"}
+
+# In:  {"name":"second"}
+# Out: {"name":"SECOND"}
+
+# test: fails
+# in: {}
+# error: nope
+`)
+	require.NoError(t, err)
+	require.Len(t, cases, 4)
+
+	assert.Equal(t, "first test", cases[0].name)
+	assert.Equal(t, 2, cases[0].line)
+	assert.Equal(t, `{"name":"bob"}`, *cases[0].input)
+	assert.Equal(t, map[string]string{"topic": "foo"}, cases[0].inputMeta)
+	assert.Equal(t, `{"name":"BOB"}`, *cases[0].output)
+	assert.Equal(t, map[string]string{"topic": "foo"}, cases[0].outputMeta)
+	assert.Nil(t, cases[0].err)
+
+	assert.Equal(t, "test 2", cases[1].name)
+	assert.Equal(t, 9, cases[1].line)
+	assert.Equal(t, `{"name":"first"}`, *cases[1].input)
+	assert.Equal(t, `{"name":"FIRST"}`, *cases[1].output)
+
+	assert.Equal(t, "test 3", cases[2].name)
+	assert.Equal(t, 12, cases[2].line)
+	assert.Equal(t, `{"name":"second"}`, *cases[2].input)
+	assert.Equal(t, `{"name":"SECOND"}`, *cases[2].output)
+
+	assert.Equal(t, "fails", cases[3].name)
+	assert.Equal(t, 15, cases[3].line)
+	assert.Equal(t, "nope", *cases[3].err)
+	assert.Nil(t, cases[3].output)
+}
+
+func TestParseTestCasesErrors(t *testing.T) {
+	tests := map[string]struct {
+		mapping string
+		err     string
+	}{
+		"output outside of test": {
+			mapping: `# out: {}`,
+			err:     "line 1: out directive found outside of a test",
+		},
+		"missing input": {
+			mapping: `root = this
+# test: foo
+# out: {}`,
+			err: "line 2: test 'foo' is missing an input",
+		},
+		"missing output": {
+			mapping: `# in: {}
+root = this`,
+			err: "line 1: test 'test 1' has no expected output or error",
+		},
+		"output and error": {
+			mapping: `# in: {}
+# out: {}
+# error: nope`,
+			err: "line 1: test 'test 1' cannot expect both an output and an error",
+		},
+		"bad metadata": {
+			mapping: `# in: {}
+# in_meta: nope`,
+			err: "line 2: expected a JSON object of string values: invalid character 'o' in literal null (expecting 'u')",
+		},
+	}
+
+	for name, test := range tests {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			_, err := parseTestCases(test.mapping)
+			require.Error(t, err)
+			assert.Equal(t, test.err, err.Error())
+		})
+	}
+}
+
+func TestRunMappingTests(t *testing.T) {
+	color.NoColor = true
+
+	dir, err := ioutil.TempDir("", "benthos_blobl_tests")
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		os.RemoveAll(dir)
+	})
+
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "funcs.blobl"), []byte(`func greet(name) {
+  root = "hello " + name
+}`), 0644))
+
+	tests := map[string]struct {
+		mapping  string
+		failures []string
+		err      string
+	}{
+		"passing tests": {
+			mapping: `import "./funcs.blobl"
+
+# test: greets
+# in: {"name":"bob"}
+# in_meta: {"topic":"foo"}
+# out: {"greeting": "hello bob", "topic": "foo"}
+# out_meta: {"topic":"foo","processed":"true"}
+root.greeting = greet(this.name)
+root.topic = meta("topic")
+meta processed = "true"
+
+# test: fails
+# in: {"name":5}
+# error: failed assignment (line 8)
+`,
+		},
+		"raw output": {
+			mapping: `root = this.name.uppercase()
+
+# in: {"name":"bob"}
+# out: BOB
+`,
+		},
+		"failing tests": {
+			mapping: `root.name = this.name.uppercase()
+meta topic = "bar"
+
+# test: wrong content
+# in: {"name":"bob"}
+# out: {"name":"bob"}
+
+# test: wrong meta
+# in: {"name":"bob"}
+# out_meta: {"topic":"baz"}
+
+# test: unexpected error
+# in: {"name":5}
+# out: {"name":"5"}
+
+# test: wrong error
+# in: {"name":5}
+# error: nope
+
+# test: no error
+# in: {"name":"bob"}
+# error: nope
+`,
+			failures: []string{
+				`wrong content [line 4]: content mismatch
+  expected: {"name":"bob"}
+  received: {"name":"BOB"}`,
+				`wrong meta [line 8]: metadata key 'topic' mismatch
+  expected: baz
+  received: bar`,
+				`unexpected error [line 12]: mapping failed: failed assignment (line 1): expected string value, got number from field ` + "`this.name` (5)",
+				`wrong error [line 16]: error mismatch
+  expected: nope
+  received: failed assignment (line 1): expected string value, got number from field ` + "`this.name` (5)",
+				`no error [line 20]: expected error containing: nope
+  but the mapping succeeded`,
+			},
+		},
+		"no tests": {
+			mapping: `root = this`,
+			err:     "no tests found",
+		},
+		"bad mapping": {
+			mapping: `# in: {}
+# out: {}
+
+root = this.foo(`,
+			err: "failed to parse mapping: line 4 char 17: required: expected function argument",
+		},
+	}
+
+	for name, test := range tests {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(dir, "test.blobl")
+			require.NoError(t, ioutil.WriteFile(path, []byte(test.mapping), 0644))
+
+			failures, err := runMappingTests(path)
+			if test.err != "" {
+				require.Error(t, err)
+				assert.Equal(t, test.err, err.Error())
+				return
+			}
+			require.NoError(t, err)
+
+			var failureStrs []string
+			for _, f := range failures {
+				failureStrs = append(failureStrs, f.String())
+			}
+			assert.Equal(t, test.failures, failureStrs)
+		})
+	}
+}
+
+func TestGetTestTargets(t *testing.T) {
+	dir, err := ioutil.TempDir("", "benthos_blobl_targets")
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		os.RemoveAll(dir)
+	})
+
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "nested"), 0755))
+	for _, p := range []string{"a.blobl", "b.yaml", "nested/c.blobl"} {
+		require.NoError(t, ioutil.WriteFile(filepath.Join(dir, p), []byte(`root = this`), 0644))
+	}
+
+	targets, err := getTestTargets(dir)
+	require.NoError(t, err)
+	assert.Equal(t, []string{filepath.Join(dir, "a.blobl")}, targets)
+
+	targets, err = getTestTargets(dir + "/...")
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		filepath.Join(dir, "a.blobl"),
+		filepath.Join(dir, "nested/c.blobl"),
+	}, targets)
+
+	targets, err = getTestTargets(filepath.Join(dir, "b.yaml"))
+	require.NoError(t, err)
+	assert.Equal(t, []string{filepath.Join(dir, "b.yaml")}, targets)
+}
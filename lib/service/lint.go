@@ -11,6 +11,7 @@ import (
 	"strings"
 	"sync"
 
+	"github.com/Jeffail/benthos/v3/internal/docs"
 	"github.com/Jeffail/benthos/v3/lib/config"
 	"github.com/fatih/color"
 	"github.com/urfave/cli/v2"
@@ -40,9 +41,15 @@ type pathLint struct {
 	err    string
 }
 
-func lintFile(path string) (pathLints []pathLint) {
+func newLintContext(lintBlobl bool) docs.LintContext {
+	ctx := docs.NewLintContext()
+	ctx.LintBloblang = lintBlobl
+	return ctx
+}
+
+func lintFile(path string, lintBlobl bool) (pathLints []pathLint) {
 	conf := config.New()
-	lints, err := config.Read(path, true, &conf)
+	lints, err := config.ReadWithLintContext(newLintContext(lintBlobl), path, true, &conf)
 	if err != nil {
 		pathLints = append(pathLints, pathLint{
 			source: path,
@@ -59,7 +66,7 @@ func lintFile(path string) (pathLints []pathLint) {
 	return
 }
 
-func lintMDSnippets(path string) (pathLints []pathLint) {
+func lintMDSnippets(path string, lintBlobl bool) (pathLints []pathLint) {
 	rawBytes, err := ioutil.ReadFile(path)
 	if err != nil {
 		pathLints = append(pathLints, pathLint{
@@ -98,7 +105,7 @@ func lintMDSnippets(path string) (pathLints []pathLint) {
 				err:    err.Error(),
 			})
 		} else {
-			lints, err := config.Lint(configBytes, conf)
+			lints, err := config.LintWithContext(newLintContext(lintBlobl), configBytes, conf)
 			if err != nil {
 				pathLints = append(pathLints, pathLint{
 					source: path,
@@ -135,8 +142,20 @@ func lintCliCommand() *cli.Command {
    benthos lint ./configs/...
    
    If a path ends with '...' then Benthos will walk the target and lint any
-   files with the .yaml or .yml extension.`[4:],
+   files with the .yaml or .yml extension.
+
+   The --blobl flag enables static analysis of Bloblang mappings within the
+   configs, reporting problems such as unused variables and deprecated
+   functions. Unformatted mappings are only reported by 'benthos blobl lint'.`[4:],
+		Flags: []cli.Flag{
+			&cli.BoolFlag{
+				Name:  "blobl",
+				Usage: "perform static analysis of Bloblang mappings within configs.",
+			},
+		},
 		Action: func(c *cli.Context) error {
+			lintBlobl := c.Bool("blobl")
+
 			var targets []string
 			for _, p := range c.Args().Slice() {
 				var recurse bool
@@ -182,9 +201,9 @@ func lintCliCommand() *cli.Command {
 						}
 						var lints []pathLint
 						if path.Ext(target) == ".md" {
-							lints = lintMDSnippets(target)
+							lints = lintMDSnippets(target, lintBlobl)
 						} else {
-							lints = lintFile(target)
+							lints = lintFile(target, lintBlobl)
 						}
 						if len(lints) > 0 {
 							pathLintMut.Lock()
@@ -65,8 +65,9 @@ var Constructors = map[string]TypeSpec{}
 
 // String constants representing each tracer type.
 const (
-	TypeJaeger = "jaeger"
-	TypeNone   = "none"
+	TypeJaeger                 = "jaeger"
+	TypeNone                   = "none"
+	TypeOpenTelemetryCollector = "open_telemetry_collector"
 )
 
 //------------------------------------------------------------------------------
@@ -81,17 +82,19 @@ type Type interface {
 
 // Config is the all encompassing configuration struct for all tracer types.
 type Config struct {
-	Type   string       `json:"type" yaml:"type"`
-	Jaeger JaegerConfig `json:"jaeger" yaml:"jaeger"`
-	None   struct{}     `json:"none" yaml:"none"`
+	Type                   string                       `json:"type" yaml:"type"`
+	Jaeger                 JaegerConfig                 `json:"jaeger" yaml:"jaeger"`
+	None                   struct{}                     `json:"none" yaml:"none"`
+	OpenTelemetryCollector OpenTelemetryCollectorConfig `json:"open_telemetry_collector" yaml:"open_telemetry_collector"`
 }
 
 // NewConfig returns a configuration struct fully populated with default values.
 func NewConfig() Config {
 	return Config{
-		Type:   TypeNone,
-		Jaeger: NewJaegerConfig(),
-		None:   struct{}{},
+		Type:                   TypeNone,
+		Jaeger:                 NewJaegerConfig(),
+		None:                   struct{}{},
+		OpenTelemetryCollector: NewOpenTelemetryCollectorConfig(),
 	}
 }
 
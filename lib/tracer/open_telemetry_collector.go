@@ -0,0 +1,286 @@
+package tracer
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/Jeffail/benthos/v3/internal/docs"
+	btls "github.com/Jeffail/benthos/v3/lib/util/tls"
+	"github.com/opentracing/opentracing-go"
+	"go.opentelemetry.io/otel/attribute"
+	otbridge "go.opentelemetry.io/otel/bridge/opentracing"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"google.golang.org/grpc/credentials"
+)
+
+//------------------------------------------------------------------------------
+
+func init() {
+	Constructors[TypeOpenTelemetryCollector] = TypeSpec{
+		constructor: NewOpenTelemetryCollector,
+		Status:      docs.StatusExperimental,
+		Version:     "3.47.0",
+		Summary: `
+Send spans to an [OpenTelemetry collector](https://opentelemetry.io/docs/collector/) using the OTLP protocol.`,
+		Description: `
+Spans are exported over either gRPC or HTTP according to the field ` + "`protocol`" + `.
+
+Tracing information is propagated in the [W3C Trace Context](https://www.w3.org/TR/trace-context/) format, which means mappings of fields such as ` + "`inject_tracing_map` and `extract_tracing_map`" + ` produce and consume objects containing the keys ` + "`traceparent`" + ` and optionally ` + "`tracestate` and `baggage`" + `.`,
+		FieldSpecs: docs.FieldSpecs{
+			docs.FieldCommon("endpoint", "The address of a collector to send spans to, as a host and port.", "localhost:4317", "otel-collector:4318"),
+			docs.FieldCommon("protocol", "The protocol to export spans with.").HasOptions("grpc", "http"),
+			docs.FieldAdvanced("url_path", "The URL path spans are sent to when the protocol is `http`."),
+			docs.FieldAdvanced("headers", "A map of headers to add to each export request, which can be used for authentication.").Map(),
+			btls.FieldSpec(),
+			docs.FieldCommon("service_name", "A name to provide for this service, which is added as the resource attribute `service.name`."),
+			docs.FieldAdvanced("resource_attributes", "A map of attributes to add to the resource of all spans.", map[string]string{
+				"deployment.environment": "production",
+			}).Map(),
+			docs.FieldCommon("sampler_type", "The sampler type to use.").HasAnnotatedOptions(
+				"always_on", "Every trace is sampled.",
+				"always_off", "No traces are sampled.",
+				"ratio", "A ratio of traces equal to the field `sampler_ratio` is sampled.",
+			),
+			docs.FieldAdvanced("sampler_ratio", "The ratio of traces to sample when the sampler type is `ratio`, between 0 and 1."),
+			docs.FieldAdvanced("sampler_parent_based", "Whether the sampling decision of a parent span, such as one extracted from a message, should be respected over the configured sampler."),
+			docs.FieldCommon("flush_interval", "The maximum period of time between each flush of tracing spans."),
+		},
+	}
+}
+
+//------------------------------------------------------------------------------
+
+// OpenTelemetryCollectorConfig is config for the OpenTelemetryCollector tracer
+// type.
+type OpenTelemetryCollectorConfig struct {
+	Endpoint           string            `json:"endpoint" yaml:"endpoint"`
+	Protocol           string            `json:"protocol" yaml:"protocol"`
+	URLPath            string            `json:"url_path" yaml:"url_path"`
+	Headers            map[string]string `json:"headers" yaml:"headers"`
+	TLS                btls.Config       `json:"tls" yaml:"tls"`
+	ServiceName        string            `json:"service_name" yaml:"service_name"`
+	ResourceAttributes map[string]string `json:"resource_attributes" yaml:"resource_attributes"`
+	SamplerType        string            `json:"sampler_type" yaml:"sampler_type"`
+	SamplerRatio       float64           `json:"sampler_ratio" yaml:"sampler_ratio"`
+	SamplerParentBased bool              `json:"sampler_parent_based" yaml:"sampler_parent_based"`
+	FlushInterval      string            `json:"flush_interval" yaml:"flush_interval"`
+}
+
+// NewOpenTelemetryCollectorConfig creates an OpenTelemetryCollectorConfig
+// struct with default values.
+func NewOpenTelemetryCollectorConfig() OpenTelemetryCollectorConfig {
+	return OpenTelemetryCollectorConfig{
+		Endpoint:           "localhost:4317",
+		Protocol:           "grpc",
+		URLPath:            "/v1/traces",
+		Headers:            map[string]string{},
+		TLS:                btls.NewConfig(),
+		ServiceName:        "benthos",
+		ResourceAttributes: map[string]string{},
+		SamplerType:        "always_on",
+		SamplerRatio:       1.0,
+		SamplerParentBased: true,
+		FlushInterval:      "5s",
+	}
+}
+
+//------------------------------------------------------------------------------
+
+// OpenTelemetryCollector is a tracer with the capability to push spans to an
+// OpenTelemetry collector.
+type OpenTelemetryCollector struct {
+	provider *sdktrace.TracerProvider
+}
+
+// NewOpenTelemetryCollector creates and returns a new OpenTelemetryCollector
+// object.
+func NewOpenTelemetryCollector(config Config, opts ...func(Type)) (Type, error) {
+	o := &OpenTelemetryCollector{}
+
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	conf := config.OpenTelemetryCollector
+
+	sampler, err := otelSampler(conf)
+	if err != nil {
+		return nil, err
+	}
+
+	flushInterval, err := time.ParseDuration(conf.FlushInterval)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse flush interval '%s': %v", conf.FlushInterval, err)
+	}
+
+	client, err := otelClient(conf)
+	if err != nil {
+		return nil, err
+	}
+
+	// The exporter does not block on the connection being established, and
+	// therefore this context only applies to initialisation.
+	exporter, err := otlptrace.New(context.Background(), client)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create exporter: %w", err)
+	}
+
+	attrs := []attribute.KeyValue{
+		attribute.String("service.name", conf.ServiceName),
+	}
+	for k, v := range conf.ResourceAttributes {
+		attrs = append(attrs, attribute.String(k, v))
+	}
+	res, err := resource.Merge(resource.Default(), resource.NewSchemaless(attrs...))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create resource: %w", err)
+	}
+
+	o.provider = sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter, sdktrace.WithBatchTimeout(flushInterval)),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sampler),
+	)
+
+	bridge := otbridge.NewBridgeTracer()
+	bridge.SetWarningHandler(func(string) {})
+	bridge.SetOpenTelemetryTracer(o.provider.Tracer("benthos"))
+	bridge.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{}, propagation.Baggage{},
+	))
+
+	opentracing.SetGlobalTracer(&textMapBridge{BridgeTracer: bridge})
+	return o, nil
+}
+
+func otelSampler(conf OpenTelemetryCollectorConfig) (sdktrace.Sampler, error) {
+	var sampler sdktrace.Sampler
+	switch strings.ToLower(conf.SamplerType) {
+	case "always_on":
+		sampler = sdktrace.AlwaysSample()
+	case "always_off":
+		sampler = sdktrace.NeverSample()
+	case "ratio":
+		if conf.SamplerRatio < 0 || conf.SamplerRatio > 1 {
+			return nil, fmt.Errorf("sampler ratio must be between 0 and 1, got: %v", conf.SamplerRatio)
+		}
+		sampler = sdktrace.TraceIDRatioBased(conf.SamplerRatio)
+	default:
+		return nil, fmt.Errorf("unrecognised sampler type: %v", conf.SamplerType)
+	}
+	if conf.SamplerParentBased {
+		sampler = sdktrace.ParentBased(sampler)
+	}
+	return sampler, nil
+}
+
+func otelClient(conf OpenTelemetryCollectorConfig) (otlptrace.Client, error) {
+	switch strings.ToLower(conf.Protocol) {
+	case "grpc":
+		opts := []otlptracegrpc.Option{
+			otlptracegrpc.WithEndpoint(conf.Endpoint),
+			otlptracegrpc.WithHeaders(conf.Headers),
+		}
+		if conf.TLS.Enabled {
+			tlsConf, err := conf.TLS.Get()
+			if err != nil {
+				return nil, err
+			}
+			opts = append(opts, otlptracegrpc.WithTLSCredentials(credentials.NewTLS(tlsConf)))
+		} else {
+			opts = append(opts, otlptracegrpc.WithInsecure())
+		}
+		return otlptracegrpc.NewClient(opts...), nil
+	case "http":
+		opts := []otlptracehttp.Option{
+			otlptracehttp.WithEndpoint(conf.Endpoint),
+			otlptracehttp.WithURLPath(conf.URLPath),
+			otlptracehttp.WithHeaders(conf.Headers),
+		}
+		if conf.TLS.Enabled {
+			tlsConf, err := conf.TLS.Get()
+			if err != nil {
+				return nil, err
+			}
+			opts = append(opts, otlptracehttp.WithTLSClientConfig(tlsConf))
+		} else {
+			opts = append(opts, otlptracehttp.WithInsecure())
+		}
+		return otlptracehttp.NewClient(opts...), nil
+	}
+	return nil, fmt.Errorf("unrecognised protocol: %v", conf.Protocol)
+}
+
+//------------------------------------------------------------------------------
+
+// textMapBridge extends the OpenTracing bridge, which only supports the HTTP
+// headers format, with support for the text map format used by components
+// such as inputs with an extract_tracing_map field.
+type textMapBridge struct {
+	*otbridge.BridgeTracer
+}
+
+func (t *textMapBridge) Inject(sm opentracing.SpanContext, format interface{}, carrier interface{}) error {
+	if format != opentracing.TextMap {
+		return t.BridgeTracer.Inject(sm, format, carrier)
+	}
+
+	writer, ok := carrier.(opentracing.TextMapWriter)
+	if !ok {
+		return opentracing.ErrInvalidCarrier
+	}
+
+	headers := http.Header{}
+	if err := t.BridgeTracer.Inject(sm, opentracing.HTTPHeaders, opentracing.HTTPHeadersCarrier(headers)); err != nil {
+		return err
+	}
+	for k := range headers {
+		writer.Set(strings.ToLower(k), headers.Get(k))
+	}
+	return nil
+}
+
+func (t *textMapBridge) Extract(format interface{}, carrier interface{}) (opentracing.SpanContext, error) {
+	if format != opentracing.TextMap {
+		return t.BridgeTracer.Extract(format, carrier)
+	}
+
+	reader, ok := carrier.(opentracing.TextMapReader)
+	if !ok {
+		return nil, opentracing.ErrInvalidCarrier
+	}
+
+	headers := http.Header{}
+	if err := reader.ForeachKey(func(k, v string) error {
+		headers.Set(k, v)
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	return t.BridgeTracer.Extract(opentracing.HTTPHeaders, opentracing.HTTPHeadersCarrier(headers))
+}
+
+//------------------------------------------------------------------------------
+
+// Close stops the tracer, flushing any pending spans.
+func (o *OpenTelemetryCollector) Close() error {
+	if o.provider != nil {
+		ctx, done := context.WithTimeout(context.Background(), time.Second*5)
+		defer done()
+		if err := o.provider.Shutdown(ctx); err != nil {
+			return err
+		}
+		o.provider = nil
+	}
+	return nil
+}
+
+//------------------------------------------------------------------------------
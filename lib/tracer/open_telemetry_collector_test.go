@@ -0,0 +1,221 @@
+package tracer
+
+import (
+	"context"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/opentracing/opentracing-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	collectortrace "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/proto"
+)
+
+type otlpReceiver struct {
+	collectortrace.UnimplementedTraceServiceServer
+
+	mut      sync.Mutex
+	requests []*collectortrace.ExportTraceServiceRequest
+}
+
+func (r *otlpReceiver) Export(ctx context.Context, req *collectortrace.ExportTraceServiceRequest) (*collectortrace.ExportTraceServiceResponse, error) {
+	r.mut.Lock()
+	r.requests = append(r.requests, req)
+	r.mut.Unlock()
+	return &collectortrace.ExportTraceServiceResponse{}, nil
+}
+
+func (r *otlpReceiver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.URL.Path != "/v1/traces" {
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+
+	body, err := ioutil.ReadAll(req.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var exportReq collectortrace.ExportTraceServiceRequest
+	if err := proto.Unmarshal(body, &exportReq); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	res, _ := r.Export(req.Context(), &exportReq)
+	resBytes, _ := proto.Marshal(res)
+	w.Header().Set("Content-Type", "application/x-protobuf")
+	_, _ = w.Write(resBytes)
+}
+
+// spans returns the names of received spans along with the resource
+// attributes of the last received resource.
+func (r *otlpReceiver) spans() ([]string, map[string]string) {
+	r.mut.Lock()
+	defer r.mut.Unlock()
+
+	var names []string
+	attrs := map[string]string{}
+	for _, req := range r.requests {
+		for _, rs := range req.ResourceSpans {
+			for _, kv := range rs.Resource.Attributes {
+				attrs[kv.Key] = kv.Value.GetStringValue()
+			}
+			for _, ils := range rs.InstrumentationLibrarySpans {
+				for _, s := range ils.Spans {
+					names = append(names, s.Name)
+				}
+			}
+		}
+	}
+	return names, attrs
+}
+
+func testOTELTracer(t *testing.T, fn func(c *OpenTelemetryCollectorConfig)) Type {
+	t.Helper()
+
+	t.Cleanup(func() {
+		opentracing.SetGlobalTracer(opentracing.NoopTracer{})
+	})
+
+	conf := NewConfig()
+	conf.Type = TypeOpenTelemetryCollector
+	conf.OpenTelemetryCollector.ServiceName = "foo"
+	conf.OpenTelemetryCollector.ResourceAttributes = map[string]string{
+		"deployment.environment": "testing",
+	}
+	fn(&conf.OpenTelemetryCollector)
+
+	tr, err := New(conf)
+	require.NoError(t, err)
+	return tr
+}
+
+func TestOpenTelemetryCollectorGRPC(t *testing.T) {
+	receiver := &otlpReceiver{}
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	server := grpc.NewServer()
+	collectortrace.RegisterTraceServiceServer(server, receiver)
+	go func() {
+		_ = server.Serve(lis)
+	}()
+	defer server.Stop()
+
+	tr := testOTELTracer(t, func(c *OpenTelemetryCollectorConfig) {
+		c.Endpoint = lis.Addr().String()
+	})
+
+	span := opentracing.StartSpan("foo")
+	opentracing.StartSpan("bar", opentracing.ChildOf(span.Context())).Finish()
+	span.Finish()
+
+	require.NoError(t, tr.Close())
+
+	names, attrs := receiver.spans()
+	assert.ElementsMatch(t, []string{"foo", "bar"}, names)
+	assert.Equal(t, "foo", attrs["service.name"])
+	assert.Equal(t, "testing", attrs["deployment.environment"])
+}
+
+func TestOpenTelemetryCollectorHTTP(t *testing.T) {
+	receiver := &otlpReceiver{}
+
+	ts := httptest.NewServer(receiver)
+	defer ts.Close()
+
+	tr := testOTELTracer(t, func(c *OpenTelemetryCollectorConfig) {
+		c.Protocol = "http"
+		c.Endpoint = strings.TrimPrefix(ts.URL, "http://")
+	})
+
+	opentracing.StartSpan("foo").Finish()
+
+	require.NoError(t, tr.Close())
+
+	names, attrs := receiver.spans()
+	assert.Equal(t, []string{"foo"}, names)
+	assert.Equal(t, "foo", attrs["service.name"])
+}
+
+func TestOpenTelemetryCollectorSampler(t *testing.T) {
+	receiver := &otlpReceiver{}
+
+	ts := httptest.NewServer(receiver)
+	defer ts.Close()
+
+	tr := testOTELTracer(t, func(c *OpenTelemetryCollectorConfig) {
+		c.Protocol = "http"
+		c.Endpoint = strings.TrimPrefix(ts.URL, "http://")
+		c.SamplerType = "always_off"
+		c.FlushInterval = "10ms"
+	})
+
+	opentracing.StartSpan("foo").Finish()
+
+	require.NoError(t, tr.Close())
+
+	names, _ := receiver.spans()
+	assert.Empty(t, names)
+}
+
+func TestOpenTelemetryCollectorPropagation(t *testing.T) {
+	tr := testOTELTracer(t, func(c *OpenTelemetryCollectorConfig) {
+		c.Protocol = "http"
+		c.Endpoint = "localhost:1"
+	})
+	defer func() {
+		ctx, done := context.WithTimeout(context.Background(), time.Millisecond*100)
+		defer done()
+		_ = tr.(*OpenTelemetryCollector).provider.Shutdown(ctx)
+	}()
+
+	traceParent := "00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01"
+
+	parent, err := opentracing.GlobalTracer().Extract(opentracing.TextMap, opentracing.TextMapCarrier{
+		"traceparent": traceParent,
+	})
+	require.NoError(t, err)
+
+	span := opentracing.StartSpan("foo", opentracing.ChildOf(parent))
+	defer span.Finish()
+
+	carrier := opentracing.TextMapCarrier{}
+	require.NoError(t, opentracing.GlobalTracer().Inject(span.Context(), opentracing.TextMap, carrier))
+
+	injected := carrier["traceparent"]
+	require.NotEmpty(t, injected)
+	assert.True(t, strings.HasPrefix(injected, "00-0af7651916cd43dd8448eb211c80319c-"), injected)
+	assert.NotEqual(t, traceParent, injected)
+	assert.True(t, strings.HasSuffix(injected, "-01"), injected)
+
+	_, err = opentracing.GlobalTracer().Extract(opentracing.TextMap, opentracing.TextMapCarrier{})
+	assert.Error(t, err)
+}
+
+func TestOpenTelemetryCollectorBadConfig(t *testing.T) {
+	for _, fn := range []func(c *OpenTelemetryCollectorConfig){
+		func(c *OpenTelemetryCollectorConfig) { c.Protocol = "nope" },
+		func(c *OpenTelemetryCollectorConfig) { c.SamplerType = "nope" },
+		func(c *OpenTelemetryCollectorConfig) { c.SamplerType = "ratio"; c.SamplerRatio = 2 },
+		func(c *OpenTelemetryCollectorConfig) { c.FlushInterval = "nope" },
+	} {
+		conf := NewConfig()
+		conf.Type = TypeOpenTelemetryCollector
+		fn(&conf.OpenTelemetryCollector)
+
+		_, err := New(conf)
+		assert.Error(t, err)
+	}
+}
@@ -1,7 +1,9 @@
 package client
 
 import (
+	"github.com/Jeffail/benthos/v3/internal/circuitbreaker"
 	"github.com/Jeffail/benthos/v3/internal/docs"
+	"github.com/Jeffail/benthos/v3/internal/ratelimit"
 	"github.com/Jeffail/benthos/v3/lib/util/http/auth"
 	"github.com/Jeffail/benthos/v3/lib/util/tls"
 )
@@ -19,6 +21,7 @@ func FieldSpecs() docs.FieldSpecs {
 	httpSpecs = append(httpSpecs, tls.FieldSpec(),
 		docs.FieldAdvanced("copy_response_headers", "Sets whether to copy the headers from the response to the resulting payload.").HasType("bool"),
 		docs.FieldCommon("rate_limit", "An optional [rate limit](/docs/components/rate_limits/about) to throttle requests by.").HasType("string"),
+		ratelimit.KeyedFieldSpec("keyed_rate_limit"),
 		docs.FieldCommon("timeout", "A static timeout to apply to requests.").HasType("string"),
 		docs.FieldAdvanced("retry_period", "The base period to wait between failed requests.").HasType("string"),
 		docs.FieldAdvanced("max_retry_backoff", "The maximum period to wait between failed requests.").HasType("string"),
@@ -27,6 +30,14 @@ func FieldSpecs() docs.FieldSpecs {
 		docs.FieldAdvanced("drop_on", "A list of status codes whereby the request should be considered to have failed but retries should not be attempted. This is useful for preventing wasted retries for requests that will never succeed. Note that with these status codes the _request_ is dropped, but _message_ that caused the request will not be dropped.").HasType("array").Array(),
 		docs.FieldAdvanced("successful_on", "A list of status codes whereby the attempt should be considered successful, this is useful for dropping requests that return non-2XX codes indicating that the message has been dealt with, such as a 303 See Other or a 409 Conflict. All 2XX codes are considered successful unless they are present within `backoff_on` or `drop_on`, regardless of this field.").HasType("array").Array(),
 		docs.FieldAdvanced("proxy_url", "An optional HTTP proxy URL.").HasType("string"),
+		docs.FieldAdvanced(
+			"circuit_breaker",
+			"An optional circuit breaker that trips open when the target service appears to be unhealthy, during which requests fail immediately rather than being attempted. Connection errors, 5XX status codes and codes listed in `backoff_on` are considered failures.",
+		).HasType("object").WithChildren(
+			append(docs.FieldSpecs{
+				docs.FieldAdvanced("enabled", "Whether to enable the circuit breaker.").HasType("bool"),
+			}, circuitbreaker.FieldSpecs()...)...,
+		).AtVersion("3.47.0"),
 	)
 
 	return httpSpecs
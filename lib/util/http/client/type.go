@@ -18,6 +18,8 @@ import (
 
 	"github.com/Jeffail/benthos/v3/internal/bloblang"
 	"github.com/Jeffail/benthos/v3/internal/bloblang/field"
+	"github.com/Jeffail/benthos/v3/internal/circuitbreaker"
+	"github.com/Jeffail/benthos/v3/internal/ratelimit"
 	"github.com/Jeffail/benthos/v3/lib/log"
 	"github.com/Jeffail/benthos/v3/lib/message"
 	"github.com/Jeffail/benthos/v3/lib/metrics"
@@ -33,20 +35,22 @@ import (
 
 // Config is a configuration struct for an HTTP client.
 type Config struct {
-	URL                 string            `json:"url" yaml:"url"`
-	Verb                string            `json:"verb" yaml:"verb"`
-	Headers             map[string]string `json:"headers" yaml:"headers"`
-	CopyResponseHeaders bool              `json:"copy_response_headers" yaml:"copy_response_headers"`
-	RateLimit           string            `json:"rate_limit" yaml:"rate_limit"`
-	Timeout             string            `json:"timeout" yaml:"timeout"`
-	Retry               string            `json:"retry_period" yaml:"retry_period"`
-	MaxBackoff          string            `json:"max_retry_backoff" yaml:"max_retry_backoff"`
-	NumRetries          int               `json:"retries" yaml:"retries"`
-	BackoffOn           []int             `json:"backoff_on" yaml:"backoff_on"`
-	DropOn              []int             `json:"drop_on" yaml:"drop_on"`
-	SuccessfulOn        []int             `json:"successful_on" yaml:"successful_on"`
-	TLS                 tls.Config        `json:"tls" yaml:"tls"`
-	ProxyURL            string            `json:"proxy_url" yaml:"proxy_url"`
+	URL                 string                `json:"url" yaml:"url"`
+	Verb                string                `json:"verb" yaml:"verb"`
+	Headers             map[string]string     `json:"headers" yaml:"headers"`
+	CopyResponseHeaders bool                  `json:"copy_response_headers" yaml:"copy_response_headers"`
+	RateLimit           string                `json:"rate_limit" yaml:"rate_limit"`
+	KeyedRateLimit      ratelimit.KeyedConfig `json:"keyed_rate_limit" yaml:"keyed_rate_limit"`
+	CircuitBreaker      CircuitBreakerConfig  `json:"circuit_breaker" yaml:"circuit_breaker"`
+	Timeout             string                `json:"timeout" yaml:"timeout"`
+	Retry               string                `json:"retry_period" yaml:"retry_period"`
+	MaxBackoff          string                `json:"max_retry_backoff" yaml:"max_retry_backoff"`
+	NumRetries          int                   `json:"retries" yaml:"retries"`
+	BackoffOn           []int                 `json:"backoff_on" yaml:"backoff_on"`
+	DropOn              []int                 `json:"drop_on" yaml:"drop_on"`
+	SuccessfulOn        []int                 `json:"successful_on" yaml:"successful_on"`
+	TLS                 tls.Config            `json:"tls" yaml:"tls"`
+	ProxyURL            string                `json:"proxy_url" yaml:"proxy_url"`
 	auth.Config         `json:",inline" yaml:",inline"`
 	OAuth2              auth.OAuth2Config `json:"oauth2" yaml:"oauth2"`
 	JWT                 auth.JWTConfig    `json:"jwt" yaml:"jwt"`
@@ -62,6 +66,8 @@ func NewConfig() Config {
 		},
 		CopyResponseHeaders: false,
 		RateLimit:           "",
+		KeyedRateLimit:      ratelimit.NewKeyedConfig(),
+		CircuitBreaker:      NewCircuitBreakerConfig(),
 		Timeout:             "5s",
 		Retry:               "1s",
 		MaxBackoff:          "300s",
@@ -78,6 +84,24 @@ func NewConfig() Config {
 
 //------------------------------------------------------------------------------
 
+// CircuitBreakerConfig contains configuration fields for an optional circuit
+// breaker applied to requests.
+type CircuitBreakerConfig struct {
+	Enabled               bool `json:"enabled" yaml:"enabled"`
+	circuitbreaker.Config `json:",inline" yaml:",inline"`
+}
+
+// NewCircuitBreakerConfig creates a new CircuitBreakerConfig with default
+// values.
+func NewCircuitBreakerConfig() CircuitBreakerConfig {
+	return CircuitBreakerConfig{
+		Enabled: false,
+		Config:  circuitbreaker.NewConfig(),
+	}
+}
+
+//------------------------------------------------------------------------------
+
 // Type is an output type that pushes messages to Type.
 type Type struct {
 	client *http.Client
@@ -93,6 +117,9 @@ type Type struct {
 	conf          Config
 	retryThrottle *throttle.Type
 	rateLimit     types.RateLimit
+	keyedLimit    *ratelimit.Keyed
+	limitKey      *field.Expression
+	breaker       *circuitbreaker.Breaker
 
 	log   log.Modular
 	stats metrics.Type
@@ -219,6 +246,23 @@ func New(conf Config, opts ...func(*Type)) (*Type, error) {
 		}
 	}
 
+	if len(h.conf.KeyedRateLimit.Key) > 0 {
+		var err error
+		if h.limitKey, err = bloblang.NewField(h.conf.KeyedRateLimit.Key); err != nil {
+			return nil, fmt.Errorf("failed to parse keyed rate limit key expression: %v", err)
+		}
+		if h.keyedLimit, err = ratelimit.NewKeyed(h.conf.KeyedRateLimit); err != nil {
+			return nil, fmt.Errorf("failed to create keyed rate limit: %v", err)
+		}
+	}
+
+	if h.conf.CircuitBreaker.Enabled {
+		var err error
+		if h.breaker, err = circuitbreaker.New(h.conf.CircuitBreaker.Config, h.stats); err != nil {
+			return nil, fmt.Errorf("failed to create circuit breaker: %v", err)
+		}
+	}
+
 	var retry, maxBackoff time.Duration
 	if tout := conf.Retry; len(tout) > 0 {
 		var err error
@@ -313,7 +357,23 @@ func (h *Type) incrCode(code int) {
 	h.codesMut.Unlock()
 }
 
-func (h *Type) waitForAccess() bool {
+func (h *Type) waitForAccess(msg types.Message) bool {
+	if h.keyedLimit != nil {
+		key := h.limitKey.String(0, msg)
+		for {
+			period := h.keyedLimit.Access(key)
+			if period <= 0 {
+				break
+			}
+			h.mLimited.Incr(1)
+			h.mLimitFor.Incr(period.Nanoseconds() / 1000000)
+			select {
+			case <-time.After(period):
+			case <-h.closeChan:
+				return false
+			}
+		}
+	}
 	if h.rateLimit == nil {
 		return true
 	}
@@ -518,6 +578,30 @@ func (h *Type) checkStatus(code int) (succeeded bool, retStrat retryStrategy) {
 	return true, noRetry
 }
 
+// do performs a single request attempt. When a circuit breaker is configured
+// the attempt is rejected whilst it is open, and the outcome of the attempt is
+// recorded, where connection errors, 5XX codes and codes within backoff_on are
+// considered failures.
+func (h *Type) do(ctx context.Context, req *http.Request) (*http.Response, error) {
+	if h.breaker == nil {
+		return h.client.Do(req.WithContext(ctx))
+	}
+	if err := h.breaker.Allow(); err != nil {
+		return nil, err
+	}
+	res, err := h.client.Do(req.WithContext(ctx))
+	if err != nil {
+		h.breaker.Failure()
+		return res, err
+	}
+	if _, isBackoff := h.backoffOn[res.StatusCode]; isBackoff || res.StatusCode >= 500 {
+		h.breaker.Failure()
+	} else {
+		h.breaker.Success()
+	}
+	return res, err
+}
+
 // Do attempts to create and perform an HTTP request from a message payload.
 // This attempt may include retries, and if all retries fail an error is
 // returned.
@@ -561,13 +645,13 @@ func (h *Type) DoWithContext(ctx context.Context, msg types.Message) (res *http.
 
 	startedAt := time.Now()
 
-	if !h.waitForAccess() {
+	if !h.waitForAccess(msg) {
 		return nil, types.ErrTypeClosed
 	}
 
 	rateLimited := false
 	numRetries := h.conf.NumRetries
-	if res, err = h.client.Do(req.WithContext(ctx)); err == nil {
+	if res, err = h.do(ctx, req); err == nil {
 		h.incrCode(res.StatusCode)
 		if resolved, retryStrat := h.checkStatus(res.StatusCode); !resolved {
 			rateLimited = retryStrat == retryBackoff
@@ -579,6 +663,8 @@ func (h *Type) DoWithContext(ctx context.Context, msg types.Message) (res *http.
 				res.Body.Close()
 			}
 		}
+	} else if err == circuitbreaker.ErrOpen {
+		numRetries = 0
 	} else if err, ok := err.(net.Error); ok && err.Timeout() {
 		h.mErrReqTimeout.Incr(1)
 	}
@@ -605,11 +691,11 @@ func (h *Type) DoWithContext(ctx context.Context, msg types.Message) (res *http.
 				return nil, types.ErrTypeClosed
 			}
 		}
-		if !h.waitForAccess() {
+		if !h.waitForAccess(msg) {
 			return nil, types.ErrTypeClosed
 		}
 		rateLimited = false
-		if res, err = h.client.Do(req.WithContext(ctx)); err == nil {
+		if res, err = h.do(ctx, req); err == nil {
 			h.incrCode(res.StatusCode)
 			if resolved, retryStrat := h.checkStatus(res.StatusCode); !resolved {
 				rateLimited = retryStrat == retryBackoff
@@ -621,6 +707,8 @@ func (h *Type) DoWithContext(ctx context.Context, msg types.Message) (res *http.
 					res.Body.Close()
 				}
 			}
+		} else if err == circuitbreaker.ErrOpen {
+			j = 0
 		} else if err, ok := err.(net.Error); ok && err.Timeout() {
 			h.mErrReqTimeout.Incr(1)
 		}
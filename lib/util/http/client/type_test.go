@@ -16,6 +16,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/Jeffail/benthos/v3/internal/circuitbreaker"
 	"github.com/Jeffail/benthos/v3/lib/log"
 	"github.com/Jeffail/benthos/v3/lib/message"
 	"github.com/Jeffail/benthos/v3/lib/metrics"
@@ -177,6 +178,83 @@ func TestHTTPClientSuccessfulOn(t *testing.T) {
 	}
 }
 
+func TestHTTPClientKeyedRateLimit(t *testing.T) {
+	var reqs int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&reqs, 1)
+		w.Write([]byte("ok"))
+	}))
+	defer ts.Close()
+
+	conf := NewConfig()
+	conf.URL = ts.URL + "/testpost"
+	conf.KeyedRateLimit.Key = "${! content() }"
+	conf.KeyedRateLimit.Count = 1
+	conf.KeyedRateLimit.Interval = "200ms"
+
+	h, err := New(conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Now()
+	for _, k := range []string{"foo", "bar", "baz"} {
+		if _, err = h.Send(message.New([][]byte{[]byte(k)})); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if dur := time.Since(start); dur >= time.Millisecond*150 {
+		t.Errorf("Distinct keys were throttled: %v", dur)
+	}
+
+	if _, err = h.Send(message.New([][]byte{[]byte("foo")})); err != nil {
+		t.Fatal(err)
+	}
+	if dur := time.Since(start); dur < time.Millisecond*150 {
+		t.Errorf("Repeated key was not throttled: %v", dur)
+	}
+
+	if exp, act := int32(4), atomic.LoadInt32(&reqs); exp != act {
+		t.Errorf("Wrong total of requests: %v != %v", act, exp)
+	}
+}
+
+func TestHTTPClientCircuitBreaker(t *testing.T) {
+	var reqs int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&reqs, 1)
+		http.Error(w, "test error", http.StatusInternalServerError)
+	}))
+	defer ts.Close()
+
+	conf := NewConfig()
+	conf.URL = ts.URL + "/testpost"
+	conf.Retry = "1ms"
+	conf.NumRetries = 3
+	conf.CircuitBreaker.Enabled = true
+	conf.CircuitBreaker.ConsecutiveFailures = 2
+	conf.CircuitBreaker.OpenTimeout = "1h"
+
+	h, err := New(conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = h.Send(message.New([][]byte{[]byte("test")})); err != circuitbreaker.ErrOpen {
+		t.Errorf("Expected circuit breaker error, received: %v", err)
+	}
+	if exp, act := int32(2), atomic.LoadInt32(&reqs); exp != act {
+		t.Errorf("Wrong total of requests: %v != %v", act, exp)
+	}
+
+	if _, err = h.Send(message.New([][]byte{[]byte("test")})); err != circuitbreaker.ErrOpen {
+		t.Errorf("Expected circuit breaker error, received: %v", err)
+	}
+	if exp, act := int32(2), atomic.LoadInt32(&reqs); exp != act {
+		t.Errorf("Wrong total of requests: %v != %v", act, exp)
+	}
+}
+
 func TestHTTPClientSendInterpolate(t *testing.T) {
 	nTestLoops := 1000
 
@@ -1,9 +1,15 @@
 package service
 
 import (
+	"crypto/tls"
+	"errors"
 	"fmt"
+	"strings"
+	"time"
 
 	"github.com/Jeffail/benthos/v3/internal/docs"
+	btls "github.com/Jeffail/benthos/v3/lib/util/tls"
+	"github.com/Jeffail/benthos/v3/public/bloblang"
 	"github.com/Jeffail/gabs/v2"
 	"gopkg.in/yaml.v3"
 )
@@ -22,6 +28,103 @@ func NewConfigField(name string) *ConfigField {
 	}
 }
 
+// NewStringField describes a new string type config field.
+func NewStringField(name string) *ConfigField {
+	return &ConfigField{
+		field: docs.FieldCommon(name, "").HasType(docs.FieldString),
+	}
+}
+
+// NewIntField describes a new int type config field.
+func NewIntField(name string) *ConfigField {
+	return &ConfigField{
+		field: docs.FieldCommon(name, "").HasType(docs.FieldNumber),
+	}
+}
+
+// NewFloatField describes a new float type config field.
+func NewFloatField(name string) *ConfigField {
+	return &ConfigField{
+		field: docs.FieldCommon(name, "").HasType(docs.FieldNumber),
+	}
+}
+
+// NewBoolField describes a new bool type config field.
+func NewBoolField(name string) *ConfigField {
+	return &ConfigField{
+		field: docs.FieldCommon(name, "").HasType(docs.FieldBool),
+	}
+}
+
+// NewStringListField describes a new config field consisting of a list of
+// strings.
+func NewStringListField(name string) *ConfigField {
+	return &ConfigField{
+		field: docs.FieldCommon(name, "").HasType(docs.FieldString).Array(),
+	}
+}
+
+// NewStringMapField describes a new config field consisting of an object of
+// arbitrary keys with string values.
+func NewStringMapField(name string) *ConfigField {
+	return &ConfigField{
+		field: docs.FieldCommon(name, "").HasType(docs.FieldString).Map(),
+	}
+}
+
+// NewDurationField describes a new duration string type config field, allowing
+// users to define a time interval with strings of the form 60s, 3m, etc.
+func NewDurationField(name string) *ConfigField {
+	return &ConfigField{
+		field: docs.FieldCommon(name, "").HasType(docs.FieldString),
+	}
+}
+
+// NewInterpolatedStringField describes a new config field consisting of a
+// string that supports interpolation functions, which can be resolved for each
+// message with an InterpolatedField.
+func NewInterpolatedStringField(name string) *ConfigField {
+	return &ConfigField{
+		field: docs.FieldCommon(name, "").HasType(docs.FieldString).IsInterpolated(),
+	}
+}
+
+// NewBloblangField describes a new config field consisting of a Bloblang
+// mapping.
+func NewBloblangField(name string) *ConfigField {
+	return &ConfigField{
+		field: docs.FieldCommon(name, "").HasType(docs.FieldString).Linter(docs.LintBloblangMapping),
+	}
+}
+
+// NewTLSField describes a new config field consisting of the common TLS
+// settings used by Benthos components, which can be parsed into a TLS config.
+func NewTLSField(name string) *ConfigField {
+	tField := btls.FieldSpec()
+	tField.Name = name
+	tField.Type = docs.FieldObject
+
+	// Defaults are required by all children in order for the field to be
+	// omitted from configs.
+	defaults := map[string]interface{}{
+		"enabled":              false,
+		"skip_cert_verify":     false,
+		"enable_renegotiation": false,
+		"root_cas_file":        "",
+		"client_certs":         []interface{}{},
+	}
+	children := make(docs.FieldSpecs, len(tField.Children))
+	for i, c := range tField.Children {
+		if v, exists := defaults[c.Name]; exists {
+			c = c.HasDefault(v)
+		}
+		children[i] = c
+	}
+	tField.Children = children
+
+	return &ConfigField{field: tField}
+}
+
 // Description adds a description to the field which will be shown when printing
 // documentation for the component config spec.
 func (c *ConfigField) Description(d string) *ConfigField {
@@ -88,7 +191,7 @@ func (c *ConfigSpec) genericFromFields(node *yaml.Node, fields docs.FieldSpecs)
 		if f, exists := pendingFieldsMap[fieldName]; exists {
 			delete(pendingFieldsMap, f.Name)
 
-			if len(f.Children) > 0 {
+			if len(f.Children) > 0 && !f.IsArray && !f.IsMap {
 				var err error
 				if resultMap[fieldName], err = c.genericFromFields(node.Content[i+1], f.Children); err != nil {
 					return nil, fmt.Errorf("field '%v': %w", fieldName, err)
@@ -251,3 +354,218 @@ func (p *ParsedConfig) Field(path ...string) (interface{}, bool) {
 	}
 	return gObj.S(path...).Data(), true
 }
+
+func (p *ParsedConfig) fullField(path ...string) (interface{}, error) {
+	if p.asStruct != nil {
+		return nil, errors.New("typed field accessors are not valid for configs built with a struct constructor")
+	}
+	v, exists := p.Field(path...)
+	if !exists {
+		return nil, fmt.Errorf("field '%v' was not found in the config", strings.Join(path, "."))
+	}
+	return v, nil
+}
+
+func fieldTypeErr(path []string, expected string, v interface{}) error {
+	return fieldTypeErrStr(strings.Join(path, "."), expected, v)
+}
+
+func fieldTypeErrStr(path, expected string, v interface{}) error {
+	return fmt.Errorf("expected field '%v' to be %v, got %T", path, expected, v)
+}
+
+// FieldString accesses a string field from the parsed config by its name. If
+// the field is not found or is not a string an error is returned.
+func (p *ParsedConfig) FieldString(path ...string) (string, error) {
+	v, err := p.fullField(path...)
+	if err != nil {
+		return "", err
+	}
+	str, ok := v.(string)
+	if !ok {
+		return "", fieldTypeErr(path, "a string", v)
+	}
+	return str, nil
+}
+
+// FieldInt accesses an int field from the parsed config by its name. If the
+// field is not found or is not an int an error is returned.
+func (p *ParsedConfig) FieldInt(path ...string) (int, error) {
+	v, err := p.fullField(path...)
+	if err != nil {
+		return 0, err
+	}
+	switch t := v.(type) {
+	case int:
+		return t, nil
+	case int64:
+		return int(t), nil
+	case uint64:
+		return int(t), nil
+	case float64:
+		if i := int(t); float64(i) == t {
+			return i, nil
+		}
+	}
+	return 0, fieldTypeErr(path, "an int", v)
+}
+
+// FieldFloat accesses a float field from the parsed config by its name. If the
+// field is not found or is not a number an error is returned.
+func (p *ParsedConfig) FieldFloat(path ...string) (float64, error) {
+	v, err := p.fullField(path...)
+	if err != nil {
+		return 0, err
+	}
+	switch t := v.(type) {
+	case float64:
+		return t, nil
+	case float32:
+		return float64(t), nil
+	case int:
+		return float64(t), nil
+	case int64:
+		return float64(t), nil
+	case uint64:
+		return float64(t), nil
+	}
+	return 0, fieldTypeErr(path, "a float", v)
+}
+
+// FieldBool accesses a bool field from the parsed config by its name. If the
+// field is not found or is not a bool an error is returned.
+func (p *ParsedConfig) FieldBool(path ...string) (bool, error) {
+	v, err := p.fullField(path...)
+	if err != nil {
+		return false, err
+	}
+	b, ok := v.(bool)
+	if !ok {
+		return false, fieldTypeErr(path, "a bool", v)
+	}
+	return b, nil
+}
+
+// FieldStringList accesses a field that is a list of strings from the parsed
+// config by its name. If the field is not found or is not a list of strings an
+// error is returned.
+func (p *ParsedConfig) FieldStringList(path ...string) ([]string, error) {
+	v, err := p.fullField(path...)
+	if err != nil {
+		return nil, err
+	}
+	switch t := v.(type) {
+	case []string:
+		return t, nil
+	case []interface{}:
+		strs := make([]string, len(t))
+		for i, e := range t {
+			str, ok := e.(string)
+			if !ok {
+				return nil, fieldTypeErrStr(fmt.Sprintf("%v.%v", strings.Join(path, "."), i), "a string", e)
+			}
+			strs[i] = str
+		}
+		return strs, nil
+	}
+	return nil, fieldTypeErr(path, "a list of strings", v)
+}
+
+// FieldStringMap accesses a field that is an object of arbitrary keys and
+// string values from the parsed config by its name. If the field is not found
+// or is not an object of strings an error is returned.
+func (p *ParsedConfig) FieldStringMap(path ...string) (map[string]string, error) {
+	v, err := p.fullField(path...)
+	if err != nil {
+		return nil, err
+	}
+	switch t := v.(type) {
+	case map[string]string:
+		return t, nil
+	case map[string]interface{}:
+		strs := make(map[string]string, len(t))
+		for k, e := range t {
+			str, ok := e.(string)
+			if !ok {
+				return nil, fieldTypeErrStr(strings.Join(path, ".")+"."+k, "a string", e)
+			}
+			strs[k] = str
+		}
+		return strs, nil
+	}
+	return nil, fieldTypeErr(path, "an object of strings", v)
+}
+
+// FieldDuration accesses a duration string field from the parsed config by its
+// name. If the field is not found or is not a valid duration string an error is
+// returned.
+func (p *ParsedConfig) FieldDuration(path ...string) (time.Duration, error) {
+	str, err := p.FieldString(path...)
+	if err != nil {
+		return 0, err
+	}
+	d, err := time.ParseDuration(str)
+	if err != nil {
+		return 0, fmt.Errorf("failed to parse field '%v' as a duration: %w", strings.Join(path, "."), err)
+	}
+	return d, nil
+}
+
+// FieldInterpolatedString accesses a field containing an interpolated string
+// from the parsed config by its name. If the field is not found or fails to
+// parse an error is returned.
+func (p *ParsedConfig) FieldInterpolatedString(path ...string) (*InterpolatedField, error) {
+	str, err := p.FieldString(path...)
+	if err != nil {
+		return nil, err
+	}
+	i, err := NewInterpolatedField(str)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse field '%v' as an interpolated string: %w", strings.Join(path, "."), err)
+	}
+	return i, nil
+}
+
+// FieldBloblang accesses a field containing a Bloblang mapping from the parsed
+// config by its name. If the field is not found or fails to parse an error is
+// returned.
+func (p *ParsedConfig) FieldBloblang(path ...string) (*bloblang.Executor, error) {
+	str, err := p.FieldString(path...)
+	if err != nil {
+		return nil, err
+	}
+	exec, err := bloblang.Parse(str)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse field '%v' as a Bloblang mapping: %w", strings.Join(path, "."), err)
+	}
+	return exec, nil
+}
+
+// FieldTLS accesses a field containing TLS settings from the parsed config by
+// its name, as described by NewTLSField. If TLS settings are not enabled then
+// a nil config is returned. If the field is not found or the settings are
+// invalid an error is returned.
+func (p *ParsedConfig) FieldTLS(path ...string) (*tls.Config, error) {
+	v, err := p.fullField(path...)
+	if err != nil {
+		return nil, err
+	}
+
+	var node yaml.Node
+	if err := node.Encode(v); err != nil {
+		return nil, fmt.Errorf("failed to parse field '%v' as TLS settings: %w", strings.Join(path, "."), err)
+	}
+	conf := btls.NewConfig()
+	if err := node.Decode(&conf); err != nil {
+		return nil, fmt.Errorf("failed to parse field '%v' as TLS settings: %w", strings.Join(path, "."), err)
+	}
+	if !conf.Enabled {
+		return nil, nil
+	}
+
+	tlsConf, err := conf.Get()
+	if err != nil {
+		return nil, fmt.Errorf("field '%v': %w", strings.Join(path, "."), err)
+	}
+	return tlsConf, nil
+}
@@ -2,6 +2,7 @@ package service
 
 import (
 	"testing"
+	"time"
 
 	"github.com/Jeffail/benthos/v3/internal/docs"
 	"github.com/stretchr/testify/assert"
@@ -281,3 +282,134 @@ c:
 	assert.True(t, ok)
 	assert.Equal(t, 23.1, v)
 }
+
+func TestConfigTypedFields(t *testing.T) {
+	spec := NewConfigSpec().
+		Field(NewStringField("a")).
+		Field(NewIntField("b").Default(11)).
+		Field(NewFloatField("c").Default(1.5)).
+		Field(NewBoolField("d").Default(true)).
+		Field(NewStringListField("e").Default([]string{"foo"})).
+		Field(NewStringMapField("f").Default(map[string]string{})).
+		Field(NewDurationField("g").Default("5s")).
+		Field(NewInterpolatedStringField("h").Default(`${! meta("foo") }`)).
+		Field(NewBloblangField("i").Default(`root = this.foo`)).
+		Field(NewTLSField("j")).
+		Field(NewConfigField("k").Children(
+			NewIntField("l").Default(12),
+		))
+
+	node, err := getYAMLNode([]byte(`
+a: setavalue
+c: 10
+e: [ bar, baz ]
+f:
+  foo: bar
+k:
+  l: 22
+`))
+	require.NoError(t, err)
+
+	parsedConfig, err := spec.configFromNode(node)
+	require.NoError(t, err)
+
+	s, err := parsedConfig.FieldString("a")
+	require.NoError(t, err)
+	assert.Equal(t, "setavalue", s)
+
+	i, err := parsedConfig.FieldInt("b")
+	require.NoError(t, err)
+	assert.Equal(t, 11, i)
+
+	f, err := parsedConfig.FieldFloat("c")
+	require.NoError(t, err)
+	assert.Equal(t, 10.0, f)
+
+	b, err := parsedConfig.FieldBool("d")
+	require.NoError(t, err)
+	assert.True(t, b)
+
+	l, err := parsedConfig.FieldStringList("e")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"bar", "baz"}, l)
+
+	m, err := parsedConfig.FieldStringMap("f")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"foo": "bar"}, m)
+
+	d, err := parsedConfig.FieldDuration("g")
+	require.NoError(t, err)
+	assert.Equal(t, 5*time.Second, d)
+
+	interp, err := parsedConfig.FieldInterpolatedString("h")
+	require.NoError(t, err)
+	msg := NewMessage([]byte("hello"))
+	msg.MetaSet("foo", "bar")
+	assert.Equal(t, "bar", interp.String(msg))
+
+	exec, err := parsedConfig.FieldBloblang("i")
+	require.NoError(t, err)
+	res, err := exec.Query(map[string]interface{}{"foo": "bar"})
+	require.NoError(t, err)
+	assert.Equal(t, "bar", res)
+
+	tlsConf, err := parsedConfig.FieldTLS("j")
+	require.NoError(t, err)
+	assert.Nil(t, tlsConf)
+
+	i, err = parsedConfig.FieldInt("k", "l")
+	require.NoError(t, err)
+	assert.Equal(t, 22, i)
+}
+
+func TestConfigTypedFieldErrors(t *testing.T) {
+	spec := NewConfigSpec().
+		Field(NewStringField("a")).
+		Field(NewConfigField("b").Children(
+			NewIntField("c"),
+			NewStringListField("d"),
+			NewDurationField("e"),
+			NewBloblangField("f"),
+		)).
+		Field(NewTLSField("g"))
+
+	node, err := getYAMLNode([]byte(`
+a: 10
+b:
+  c: nope
+  d: [ foo, 10 ]
+  e: nope
+  f: 'root = '
+g:
+  enabled: true
+  client_certs:
+    - cert: foo
+`))
+	require.NoError(t, err)
+
+	parsedConfig, err := spec.configFromNode(node)
+	require.NoError(t, err)
+
+	_, err = parsedConfig.FieldString("a")
+	assert.EqualError(t, err, "expected field 'a' to be a string, got int")
+
+	_, err = parsedConfig.FieldString("z")
+	assert.EqualError(t, err, "field 'z' was not found in the config")
+
+	_, err = parsedConfig.FieldInt("b", "c")
+	assert.EqualError(t, err, "expected field 'b.c' to be an int, got string")
+
+	_, err = parsedConfig.FieldStringList("b", "d")
+	assert.EqualError(t, err, "expected field 'b.d.1' to be a string, got int")
+
+	_, err = parsedConfig.FieldDuration("b", "e")
+	assert.EqualError(t, err, `failed to parse field 'b.e' as a duration: time: invalid duration "nope"`)
+
+	_, err = parsedConfig.FieldBloblang("b", "f")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "failed to parse field 'b.f' as a Bloblang mapping")
+
+	_, err = parsedConfig.FieldTLS("g")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "field 'g'")
+}
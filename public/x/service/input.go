@@ -47,6 +47,40 @@ type Input interface {
 
 //------------------------------------------------------------------------------
 
+// BatchInput is an interface implemented by Benthos inputs that produce
+// messages in batches, where there is a desire to process and send them as a
+// batch in order to benefit from performance gains or to preserve the
+// acknowledgement semantics of the source. Calls to ReadBatch should block
+// until either a batch has been received, the connection is lost, or the
+// provided context is cancelled.
+type BatchInput interface {
+	// Establish a connection to the upstream service. Connect will always be
+	// called first when a reader is instantiated, and will be continuously
+	// called with back off until a nil error is returned.
+	//
+	// Once Connect returns a nil error the ReadBatch method will be called
+	// until either ErrNotConnected is returned, or the reader is closed.
+	Connect(context.Context) error
+
+	// Read a message batch from a source, along with a function to be called
+	// once the entire batch can be either acked (successfully sent or
+	// intentionally filtered) or nacked (failed to be processed or dispatched
+	// to the output).
+	//
+	// The AckFunc will be called for every batch at least once, but there are
+	// no guarantees as to when this will occur.
+	//
+	// If this method returns ErrNotConnected then ReadBatch will not be called
+	// again until Connect has returned a nil error. If ErrEndOfInput is
+	// returned then ReadBatch will no longer be called and the pipeline will
+	// gracefully terminate.
+	ReadBatch(context.Context) ([]*Message, AckFunc, error)
+
+	Closer
+}
+
+//------------------------------------------------------------------------------
+
 // Implements input.AsyncReader
 type airGapReader struct {
 	r Input
@@ -99,3 +133,60 @@ func (a *airGapReader) WaitForClose(tout time.Duration) error {
 	}
 	return nil
 }
+
+//------------------------------------------------------------------------------
+
+// Implements input.AsyncReader
+type airGapBatchReader struct {
+	r BatchInput
+
+	sig *shutdown.Signaller
+}
+
+func newAirGapBatchReader(r BatchInput) reader.Async {
+	return &airGapBatchReader{r, shutdown.NewSignaller()}
+}
+
+func (a *airGapBatchReader) ConnectWithContext(ctx context.Context) error {
+	err := a.r.Connect(ctx)
+	if err != nil && errors.Is(err, ErrEndOfInput) {
+		err = types.ErrTypeClosed
+	}
+	return err
+}
+
+func (a *airGapBatchReader) ReadWithContext(ctx context.Context) (types.Message, reader.AsyncAckFn, error) {
+	batch, ackFn, err := a.r.ReadBatch(ctx)
+	if err != nil {
+		if errors.Is(err, ErrNotConnected) {
+			err = types.ErrNotConnected
+		} else if errors.Is(err, ErrEndOfInput) {
+			err = types.ErrTypeClosed
+		}
+		return nil, nil, err
+	}
+	tMsg := message.New(nil)
+	for _, msg := range batch {
+		tMsg.Append(msg.part)
+	}
+	return tMsg, func(c context.Context, r types.Response) error {
+		return ackFn(c, r.Error())
+	}, nil
+}
+
+func (a *airGapBatchReader) CloseAsync() {
+	go func() {
+		if err := a.r.Close(context.Background()); err == nil {
+			a.sig.ShutdownComplete()
+		}
+	}()
+}
+
+func (a *airGapBatchReader) WaitForClose(tout time.Duration) error {
+	select {
+	case <-a.sig.HasClosedChan():
+	case <-time.After(tout):
+		return types.ErrTimeout
+	}
+	return nil
+}
@@ -107,3 +107,103 @@ func TestInputAirGapHappy(t *testing.T) {
 	assert.NoError(t, outAckFn(context.Background(), response.NewError(errors.New("foobar"))))
 	assert.EqualError(t, ackErr, "foobar")
 }
+
+type fnBatchInput struct {
+	connect func() error
+	read    func() ([]*Message, AckFunc, error)
+	closed  bool
+}
+
+func (f *fnBatchInput) Connect(ctx context.Context) error {
+	return f.connect()
+}
+
+func (f *fnBatchInput) ReadBatch(ctx context.Context) ([]*Message, AckFunc, error) {
+	return f.read()
+}
+
+func (f *fnBatchInput) Close(ctx context.Context) error {
+	f.closed = true
+	return nil
+}
+
+func TestBatchInputAirGapShutdown(t *testing.T) {
+	i := &fnBatchInput{}
+	agi := newAirGapBatchReader(i)
+
+	err := agi.WaitForClose(time.Millisecond * 5)
+	assert.EqualError(t, err, "action timed out")
+	assert.False(t, i.closed)
+
+	agi.CloseAsync()
+	err = agi.WaitForClose(time.Millisecond * 5)
+	assert.NoError(t, err)
+	assert.True(t, i.closed)
+}
+
+func TestBatchInputAirGapSad(t *testing.T) {
+	i := &fnBatchInput{
+		connect: func() error {
+			return errors.New("bad connect")
+		},
+		read: func() ([]*Message, AckFunc, error) {
+			return nil, nil, errors.New("bad read")
+		},
+	}
+	agi := newAirGapBatchReader(i)
+
+	err := agi.ConnectWithContext(context.Background())
+	assert.EqualError(t, err, "bad connect")
+
+	_, _, err = agi.ReadWithContext(context.Background())
+	assert.EqualError(t, err, "bad read")
+
+	i.read = func() ([]*Message, AckFunc, error) {
+		return nil, nil, ErrNotConnected
+	}
+
+	_, _, err = agi.ReadWithContext(context.Background())
+	assert.Equal(t, types.ErrNotConnected, err)
+
+	i.read = func() ([]*Message, AckFunc, error) {
+		return nil, nil, ErrEndOfInput
+	}
+
+	_, _, err = agi.ReadWithContext(context.Background())
+	assert.Equal(t, types.ErrTypeClosed, err)
+}
+
+func TestBatchInputAirGapHappy(t *testing.T) {
+	var ackErr error
+	ackCalls := 0
+	ackFn := func(ctx context.Context, err error) error {
+		ackCalls++
+		ackErr = err
+		return nil
+	}
+	i := &fnBatchInput{
+		connect: func() error {
+			return nil
+		},
+		read: func() ([]*Message, AckFunc, error) {
+			return []*Message{
+				NewMessage([]byte("hello")),
+				NewMessage([]byte("world")),
+			}, ackFn, nil
+		},
+	}
+	agi := newAirGapBatchReader(i)
+
+	err := agi.ConnectWithContext(context.Background())
+	assert.NoError(t, err)
+
+	outMsg, outAckFn, err := agi.ReadWithContext(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 2, outMsg.Len())
+	assert.Equal(t, "hello", string(outMsg.Get(0).Get()))
+	assert.Equal(t, "world", string(outMsg.Get(1).Get()))
+
+	assert.NoError(t, outAckFn(context.Background(), response.NewError(errors.New("foobar"))))
+	assert.EqualError(t, ackErr, "foobar")
+	assert.Equal(t, 1, ackCalls)
+}
@@ -66,6 +66,36 @@ func RegisterInput(name string, spec *ConfigSpec, ctor InputConstructor) error {
 	}), componentSpec)
 }
 
+// BatchInputConstructor is a func that's provided a configuration type and
+// access to a service manager, and must return an instantiation of a batched
+// reader based on the config, or an error.
+type BatchInputConstructor func(conf *ParsedConfig, mgr *Resources) (BatchInput, error)
+
+// RegisterBatchInput attempts to register a new batched input plugin by
+// providing a description of the configuration for the plugin as well as a
+// constructor for the input itself. The constructor will be called for each
+// instantiation of the component within a config.
+//
+// Batches read by the input are acknowledged as a whole, and are processed and
+// delivered to outputs together unless broken up by the pipeline.
+func RegisterBatchInput(name string, spec *ConfigSpec, ctor BatchInputConstructor) error {
+	componentSpec := spec.component
+	componentSpec.Name = name
+	componentSpec.Type = docs.TypeInput
+	return bundle.AllInputs.Add(bundle.InputConstructorFromSimple(func(conf input.Config, nm bundle.NewManagement) (input.Type, error) {
+		pluginConf, err := spec.configFromNode(conf.Plugin.(*yaml.Node))
+		if err != nil {
+			return nil, err
+		}
+		i, err := ctor(pluginConf, newResourcesFromManager(nm))
+		if err != nil {
+			return nil, err
+		}
+		rdr := newAirGapBatchReader(i)
+		return input.NewAsyncReader(conf.Type, false, rdr, nm.Logger(), nm.Metrics())
+	}), componentSpec)
+}
+
 // OutputConstructor is a func that's provided a configuration type and access
 // to a service manager, and must return an instantiation of a writer based on
 // the config and a maximum number of in-flight messages to allow, or an error.
@@ -192,6 +192,55 @@ type: test_input_plugin_without_config
 	assert.Equal(t, "foo", initLabel)
 }
 
+func TestBatchInputPluginWithConfig(t *testing.T) {
+	type testConfig struct {
+		A int `yaml:"a"`
+	}
+
+	configSpec, err := service.NewStructConfigSpec(func() interface{} {
+		return &testConfig{A: 100}
+	})
+	require.NoError(t, err)
+
+	var initConf *testConfig
+	var initLabel string
+	require.NoError(t, service.RegisterBatchInput("test_batch_input_plugin_with_config", configSpec,
+		func(conf *service.ParsedConfig, mgr *service.Resources) (service.BatchInput, error) {
+			initConf = conf.Root().(*testConfig)
+			initLabel = mgr.Label()
+			return nil, errors.New("this is a test error")
+		}))
+
+	inConfStr := `label: foo
+test_batch_input_plugin_with_config:
+    a: 20
+`
+
+	inConf := input.NewConfig()
+	require.NoError(t, yaml.Unmarshal([]byte(inConfStr), &inConf))
+
+	var outNode yaml.Node
+	require.NoError(t, outNode.Encode(inConf))
+
+	require.NoError(t, docs.SanitiseNode(docs.TypeInput, &outNode, docs.SanitiseConfig{
+		RemoveTypeField:  true,
+		RemoveDeprecated: true,
+	}))
+
+	outConfOutBytes, err := yaml.Marshal(outNode)
+	require.NoError(t, err)
+	assert.Equal(t, inConfStr, string(outConfOutBytes))
+
+	mgr, err := manager.New(manager.NewConfig(), types.NoopMgr(), log.Noop(), metrics.Noop())
+	require.NoError(t, err)
+
+	_, err = mgr.NewInput(inConf, false)
+	assert.EqualError(t, err, "failed to create input 'test_batch_input_plugin_with_config': this is a test error")
+	require.NotNil(t, initConf)
+	assert.Equal(t, 20, initConf.A)
+	assert.Equal(t, "foo", initLabel)
+}
+
 func TestOutputPluginWithConfig(t *testing.T) {
 	type testConfig struct {
 		A int `yaml:"a"`
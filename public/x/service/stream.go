@@ -3,13 +3,16 @@ package service
 import (
 	"context"
 	"errors"
+	"sync"
 	"time"
 
 	"github.com/Jeffail/benthos/v3/internal/shutdown"
 	"github.com/Jeffail/benthos/v3/lib/log"
 	"github.com/Jeffail/benthos/v3/lib/manager"
 	"github.com/Jeffail/benthos/v3/lib/metrics"
+	"github.com/Jeffail/benthos/v3/lib/response"
 	"github.com/Jeffail/benthos/v3/lib/stream"
+	"github.com/Jeffail/benthos/v3/lib/types"
 )
 
 // Stream executes a full Benthos stream and provides methods for performing
@@ -22,6 +25,11 @@ type Stream struct {
 	mgr    *manager.Type
 	stats  metrics.Type
 	logger log.Modular
+
+	consumerID          string
+	consumerFunc        func(context.Context, types.Message) error
+	consumerMaxInFlight int
+	consumerWG          sync.WaitGroup
 }
 
 func newStream(conf stream.Config, mgr *manager.Type, stats metrics.Type, logger log.Modular) *Stream {
@@ -49,6 +57,10 @@ func (s *Stream) Run(ctx context.Context) (err error) {
 		stream.OptSetStats(s.stats)); err != nil {
 		return
 	}
+	if s.consumerFunc != nil {
+		s.consumerWG.Add(1)
+		go s.runConsumer()
+	}
 	select {
 	case <-s.shutSig.HasClosedChan():
 		for {
@@ -64,6 +76,66 @@ func (s *Stream) Run(ctx context.Context) (err error) {
 	return ctx.Err()
 }
 
+func (s *Stream) runConsumer() {
+	defer s.consumerWG.Done()
+
+	var tChan <-chan types.Transaction
+	for {
+		var err error
+		if tChan, err = s.mgr.GetPipe(s.consumerID); err == nil {
+			break
+		}
+		select {
+		case <-time.After(time.Millisecond * 10):
+		case <-s.shutSig.HasClosedChan():
+			return
+		}
+	}
+
+	ctx, done := s.shutSig.HasClosedCtx(context.Background())
+	defer done()
+
+	maxInFlight := s.consumerMaxInFlight
+	if maxInFlight < 1 {
+		maxInFlight = 1
+	}
+
+	var workersWG sync.WaitGroup
+	workersWG.Add(maxInFlight)
+	for i := 0; i < maxInFlight; i++ {
+		go func() {
+			defer workersWG.Done()
+			for t := range tChan {
+				var res types.Response = response.NewAck()
+				if err := s.consumerFunc(ctx, t.Payload); err != nil {
+					res = response.NewError(err)
+				}
+				select {
+				case t.ResponseChan <- res:
+				case <-s.shutSig.HasClosedChan():
+				}
+			}
+		}()
+	}
+	workersWG.Wait()
+}
+
+// waitForConsumer blocks until the consumer func and all of its workers have
+// finished, or the timeout is reached.
+func (s *Stream) waitForConsumer(timeout time.Duration) error {
+	doneChan := make(chan struct{})
+	go func() {
+		s.consumerWG.Wait()
+		close(doneChan)
+	}()
+	select {
+	case <-doneChan:
+	case <-time.After(timeout):
+		return types.ErrTimeout
+	}
+	return nil
+}
+
 // StopWithin attempts to close the stream within the specified timeout period.
 // Initially the attempt is graceful, but as the timeout draws close the attempt
 // becomes progressively less graceful.
@@ -86,6 +158,15 @@ func (s *Stream) StopWithin(timeout time.Duration) error {
 		return err
 	}
 
+	if err := s.waitForConsumer(time.Until(stopAt)); err != nil {
+		// Still attempt to shut down other resources but do not block.
+		defer func() {
+			s.mgr.CloseAsync()
+			s.stats.Close()
+		}()
+		return err
+	}
+
 	s.mgr.CloseAsync()
 	if err := s.mgr.WaitForClose(time.Until(stopAt)); err != nil {
 		// Same as above, attempt to shut down other resources but do not block.
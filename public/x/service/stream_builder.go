@@ -2,11 +2,13 @@ package service
 
 import (
 	"bytes"
+	"context"
 	"errors"
 	"fmt"
 	"net/http"
 	"os"
 
+	batchInternal "github.com/Jeffail/benthos/v3/internal/batch"
 	"github.com/Jeffail/benthos/v3/internal/docs"
 	"github.com/Jeffail/benthos/v3/lib/api"
 	"github.com/Jeffail/benthos/v3/lib/buffer"
@@ -15,11 +17,14 @@ import (
 	"github.com/Jeffail/benthos/v3/lib/input"
 	"github.com/Jeffail/benthos/v3/lib/log"
 	"github.com/Jeffail/benthos/v3/lib/manager"
+	"github.com/Jeffail/benthos/v3/lib/message"
 	"github.com/Jeffail/benthos/v3/lib/metrics"
 	"github.com/Jeffail/benthos/v3/lib/output"
 	"github.com/Jeffail/benthos/v3/lib/processor"
 	"github.com/Jeffail/benthos/v3/lib/ratelimit"
 	"github.com/Jeffail/benthos/v3/lib/stream"
+	"github.com/Jeffail/benthos/v3/lib/types"
+	"github.com/gofrs/uuid"
 	"gopkg.in/yaml.v3"
 )
 
@@ -39,6 +44,13 @@ type StreamBuilder struct {
 	metrics    metrics.Config
 	logger     log.Config
 
+	producerChan        chan types.Transaction
+	producerID          string
+	producerBuilt       bool
+	consumerFunc        func(context.Context, types.Message) error
+	consumerID          string
+	consumerMaxInFlight int
+
 	apiMut       manager.APIReg
 	customLogger log.Modular
 }
@@ -51,6 +63,8 @@ func NewStreamBuilder() *StreamBuilder {
 		resources: manager.NewResourceConfig(),
 		metrics:   metrics.NewConfig(),
 		logger:    log.NewConfig(),
+
+		consumerMaxInFlight: 64,
 	}
 }
 
@@ -63,6 +77,13 @@ func (s *StreamBuilder) SetThreads(n int) {
 	s.threads = n
 }
 
+// SetConsumerMaxInFlight configures the maximum number of concurrent calls
+// made to a consumer func added with AddConsumerFunc or AddBatchConsumerFunc.
+// By default the maximum is 64.
+func (s *StreamBuilder) SetConsumerMaxInFlight(n int) {
+	s.consumerMaxInFlight = n
+}
+
 // PrintLogger is a simple Print based interface implemented by custom loggers.
 type PrintLogger interface {
 	Printf(format string, v ...interface{})
@@ -98,6 +119,190 @@ func (s *StreamBuilder) SetHTTPMux(m HTTPMultiplexer) {
 
 //------------------------------------------------------------------------------
 
+// MessageHandlerFunc is a function signature defining a component that
+// consumes Benthos messages. An error must be returned if the context is
+// cancelled, or if the message could not be delivered or processed.
+type MessageHandlerFunc func(context.Context, *Message) error
+
+// MessageBatchHandlerFunc is a function signature defining a component that
+// consumes Benthos message batches. An error must be returned if the context
+// is cancelled, or if the messages could not be delivered or processed.
+type MessageBatchHandlerFunc func(context.Context, []*Message) error
+
+func inprocID() (string, error) {
+	u4, err := uuid.NewV4()
+	if err != nil {
+		return "", err
+	}
+	return "stream_builder_" + u4.String(), nil
+}
+
+func (s *StreamBuilder) addProducer() error {
+	if s.producerChan != nil {
+		return errors.New("unable to add multiple producer funcs to a stream builder")
+	}
+
+	id, err := inprocID()
+	if err != nil {
+		return err
+	}
+
+	iconf := input.NewConfig()
+	iconf.Type = input.TypeInproc
+	iconf.Inproc = input.InprocConfig(id)
+
+	s.producerChan = make(chan types.Transaction)
+	s.producerID = id
+	s.inputs = append(s.inputs, iconf)
+	return nil
+}
+
+func (s *StreamBuilder) produce(ctx context.Context, msg types.Message) error {
+	resChan := make(chan types.Response)
+	select {
+	case s.producerChan <- types.NewTransaction(msg, resChan):
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	select {
+	case res := <-resChan:
+		return res.Error()
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// AddProducerFunc adds an input to the builder that allows you to write
+// messages directly into the stream with a closure function. If any other
+// input has or will be added to the stream builder they will be automatically
+// composed within a broker when the pipeline is built.
+//
+// The returned MessageHandlerFunc can be called concurrently from any number of
+// goroutines, and each call blocks until the message is either acknowledged by
+// the stream, in which case nil is returned, or an error occurs, or the
+// provided context is cancelled. Calls block until the built stream is run.
+//
+// Only one producer func can be added to a stream builder, and subsequent calls
+// will return an error. The returned func writes to the stream created by the
+// next call to Build, and a stream builder with a producer func can therefore
+// only be built once.
+func (s *StreamBuilder) AddProducerFunc() (MessageHandlerFunc, error) {
+	if err := s.addProducer(); err != nil {
+		return nil, err
+	}
+	return func(ctx context.Context, m *Message) error {
+		msg := message.New(nil)
+		msg.Append(m.part.Copy())
+		return s.produce(ctx, msg)
+	}, nil
+}
+
+// AddBatchProducerFunc adds an input to the builder that allows you to write
+// message batches directly into the stream with a closure function. If any
+// other input has or will be added to the stream builder they will be
+// automatically composed within a broker when the pipeline is built.
+//
+// The returned MessageBatchHandlerFunc can be called concurrently from any
+// number of goroutines, and each call blocks until the batch is either
+// acknowledged by the stream, in which case nil is returned, or an error
+// occurs, or the provided context is cancelled. Calls block until the built
+// stream is run.
+//
+// Only one producer func can be added to a stream builder, and subsequent calls
+// will return an error. The returned func writes to the stream created by the
+// next call to Build, and a stream builder with a producer func can therefore
+// only be built once.
+func (s *StreamBuilder) AddBatchProducerFunc() (MessageBatchHandlerFunc, error) {
+	if err := s.addProducer(); err != nil {
+		return nil, err
+	}
+	return func(ctx context.Context, b []*Message) error {
+		msg := message.New(nil)
+		for _, m := range b {
+			msg.Append(m.part.Copy())
+		}
+		return s.produce(ctx, msg)
+	}, nil
+}
+
+func (s *StreamBuilder) addConsumer(fn func(context.Context, types.Message) error) error {
+	if s.consumerFunc != nil {
+		return errors.New("unable to add multiple consumer funcs to a stream builder")
+	}
+
+	id, err := inprocID()
+	if err != nil {
+		return err
+	}
+
+	oconf := output.NewConfig()
+	oconf.Type = output.TypeInproc
+	oconf.Inproc = output.InprocConfig(id)
+
+	s.consumerFunc = fn
+	s.consumerID = id
+	s.outputs = append(s.outputs, oconf)
+	return nil
+}
+
+// AddConsumerFunc adds an output to the builder that executes a closure
+// function argument for each message. If more than one output configuration
+// is added they will automatically be composed within a fan out broker when
+// the pipeline is built.
+//
+// The provided MessageHandlerFunc may be called from multiple goroutines
+// concurrently, up to the limit set with SetConsumerMaxInFlight, and returning
+// an error results in the message being negatively acknowledged, which in most
+// cases results in it being retried. When the stream receives a batch each
+// message of the batch is passed to the function individually, and only the
+// messages that result in an error are retried.
+//
+// Only one consumer func can be added to a stream builder, and subsequent
+// calls will return an error.
+func (s *StreamBuilder) AddConsumerFunc(fn MessageHandlerFunc) error {
+	return s.addConsumer(func(ctx context.Context, msg types.Message) error {
+		var bErr *batchInternal.Error
+		_ = msg.Iter(func(i int, part types.Part) error {
+			if err := fn(ctx, newMessageFromPart(part)); err != nil {
+				if bErr == nil {
+					bErr = batchInternal.NewError(msg, err)
+				}
+				bErr.Failed(i, err)
+			}
+			return nil
+		})
+		if bErr != nil {
+			return bErr
+		}
+		return nil
+	})
+}
+
+// AddBatchConsumerFunc adds an output to the builder that executes a closure
+// function argument for each message batch. If more than one output
+// configuration is added they will automatically be composed within a fan out
+// broker when the pipeline is built.
+//
+// The provided MessageBatchHandlerFunc may be called from multiple goroutines
+// concurrently, up to the limit set with SetConsumerMaxInFlight, and returning
+// an error results in the entire batch being negatively acknowledged, which in
+// most cases results in it being retried.
+//
+// Only one consumer func can be added to a stream builder, and subsequent
+// calls will return an error.
+func (s *StreamBuilder) AddBatchConsumerFunc(fn MessageBatchHandlerFunc) error {
+	return s.addConsumer(func(ctx context.Context, msg types.Message) error {
+		batch := make([]*Message, 0, msg.Len())
+		_ = msg.Iter(func(i int, part types.Part) error {
+			batch = append(batch, newMessageFromPart(part))
+			return nil
+		})
+		return fn(ctx, batch)
+	})
+}
+
+//------------------------------------------------------------------------------
+
 // AddInputYAML parses an input YAML configuration and adds it to the builder.
 // If more than one input configuration is added they will automatically be
 // composed within a broker when the pipeline is built.
@@ -327,7 +532,15 @@ func (s *StreamBuilder) AsYAML() (string, error) {
 
 // Build a Benthos stream pipeline according to the components specified by this
 // stream builder.
+//
+// A stream builder can be built any number of times, unless a producer func has
+// been added, in which case subsequent calls return an error as the producer
+// func can only write to a single stream.
 func (s *StreamBuilder) Build() (*Stream, error) {
+	if s.producerBuilt {
+		return nil, errors.New("unable to build a stream builder with a producer func more than once")
+	}
+
 	conf := s.buildConfig()
 
 	logger := s.customLogger
@@ -373,7 +586,18 @@ func (s *StreamBuilder) Build() (*Stream, error) {
 		return nil, err
 	}
 
-	return newStream(conf.Config, mgr, stats, logger), nil
+	if s.producerChan != nil {
+		mgr.SetPipe(s.producerID, s.producerChan)
+		s.producerBuilt = true
+	}
+
+	strm := newStream(conf.Config, mgr, stats, logger)
+	if s.consumerFunc != nil {
+		strm.consumerID = s.consumerID
+		strm.consumerFunc = s.consumerFunc
+		strm.consumerMaxInFlight = s.consumerMaxInFlight
+	}
+	return strm, nil
 }
 
 type builderConfig struct {
@@ -1,7 +1,13 @@
 package service_test
 
 import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/Jeffail/benthos/v3/public/x/service"
 	"github.com/stretchr/testify/assert"
@@ -280,3 +286,157 @@ output:
 		assert.Contains(t, act, str)
 	}
 }
+
+func TestStreamBuilderProducerConsumerFuncs(t *testing.T) {
+	b := service.NewStreamBuilder()
+	require.NoError(t, b.SetLoggerYAML(`level: NONE`))
+	b.SetHTTPMux(http.NewServeMux())
+	require.NoError(t, b.AddProcessorYAML(`bloblang: 'root = content().uppercase()'`))
+
+	produce, err := b.AddProducerFunc()
+	require.NoError(t, err)
+
+	_, err = b.AddBatchProducerFunc()
+	require.Error(t, err)
+
+	var received []string
+	var mut sync.Mutex
+	require.NoError(t, b.AddConsumerFunc(func(ctx context.Context, m *service.Message) error {
+		msgBytes, err := m.AsBytes()
+		require.NoError(t, err)
+		if string(msgBytes) == "BAD" {
+			return errors.New("bad message")
+		}
+
+		mut.Lock()
+		received = append(received, string(msgBytes))
+		mut.Unlock()
+		return nil
+	}))
+
+	require.Error(t, b.AddBatchConsumerFunc(func(context.Context, []*service.Message) error {
+		return nil
+	}))
+
+	strm, err := b.Build()
+	require.NoError(t, err)
+
+	_, err = b.Build()
+	require.Error(t, err)
+
+	go func() {
+		assert.NoError(t, strm.Run(context.Background()))
+	}()
+
+	ctx, done := context.WithTimeout(context.Background(), time.Second*10)
+	defer done()
+
+	require.NoError(t, produce(ctx, service.NewMessage([]byte("hello"))))
+	require.NoError(t, produce(ctx, service.NewMessage([]byte("world"))))
+	require.EqualError(t, produce(ctx, service.NewMessage([]byte("bad"))), "bad message")
+
+	mut.Lock()
+	assert.Equal(t, []string{"HELLO", "WORLD"}, received)
+	mut.Unlock()
+
+	require.NoError(t, strm.StopWithin(time.Second*10))
+}
+
+func TestStreamBuilderBatchProducerConsumerFuncs(t *testing.T) {
+	b := service.NewStreamBuilder()
+	require.NoError(t, b.SetLoggerYAML(`level: NONE`))
+	b.SetHTTPMux(http.NewServeMux())
+
+	produce, err := b.AddBatchProducerFunc()
+	require.NoError(t, err)
+
+	batchChan := make(chan []string, 1)
+	require.NoError(t, b.AddBatchConsumerFunc(func(ctx context.Context, batch []*service.Message) error {
+		var strs []string
+		for _, m := range batch {
+			msgBytes, err := m.AsBytes()
+			require.NoError(t, err)
+			strs = append(strs, string(msgBytes))
+		}
+		select {
+		case batchChan <- strs:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		return nil
+	}))
+
+	strm, err := b.Build()
+	require.NoError(t, err)
+
+	go func() {
+		assert.NoError(t, strm.Run(context.Background()))
+	}()
+
+	ctx, done := context.WithTimeout(context.Background(), time.Second*10)
+	defer done()
+
+	require.NoError(t, produce(ctx, []*service.Message{
+		service.NewMessage([]byte("foo")),
+		service.NewMessage([]byte("bar")),
+	}))
+
+	select {
+	case strs := <-batchChan:
+		assert.Equal(t, []string{"foo", "bar"}, strs)
+	case <-ctx.Done():
+		t.Fatal("timed out")
+	}
+
+	require.NoError(t, strm.StopWithin(time.Second*10))
+}
+
+func TestStreamBuilderConsumerFuncMaxInFlight(t *testing.T) {
+	b := service.NewStreamBuilder()
+	require.NoError(t, b.SetLoggerYAML(`level: NONE`))
+	b.SetHTTPMux(http.NewServeMux())
+	b.SetConsumerMaxInFlight(2)
+
+	produce, err := b.AddProducerFunc()
+	require.NoError(t, err)
+
+	var inFlight, maxInFlight, consumed int32
+	require.NoError(t, b.AddConsumerFunc(func(ctx context.Context, m *service.Message) error {
+		n := atomic.AddInt32(&inFlight, 1)
+		defer atomic.AddInt32(&inFlight, -1)
+		for {
+			current := atomic.LoadInt32(&maxInFlight)
+			if n <= current || atomic.CompareAndSwapInt32(&maxInFlight, current, n) {
+				break
+			}
+		}
+		<-time.After(time.Millisecond * 50)
+		atomic.AddInt32(&consumed, 1)
+		return nil
+	}))
+
+	strm, err := b.Build()
+	require.NoError(t, err)
+
+	go func() {
+		assert.NoError(t, strm.Run(context.Background()))
+	}()
+
+	ctx, done := context.WithTimeout(context.Background(), time.Second*10)
+	defer done()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			assert.NoError(t, produce(ctx, service.NewMessage([]byte("hello"))))
+		}()
+	}
+	wg.Wait()
+
+	require.NoError(t, strm.StopWithin(time.Second*10))
+	assert.Equal(t, int32(0), atomic.LoadInt32(&inFlight))
+	assert.Equal(t, int32(10), atomic.LoadInt32(&consumed))
+	assert.LessOrEqual(t, atomic.LoadInt32(&maxInFlight), int32(2))
+}